@@ -0,0 +1,175 @@
+package authgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// PERSONAL_ACCESS_TOKEN_PREFIX distinguishes personal access tokens from the access tokens issued to API clients.
+	PERSONAL_ACCESS_TOKEN_PREFIX              = "pat_"
+	MAXIMUM_PERSONAL_ACCESS_TOKEN_NAME_LENGTH = 64
+	// PERSONAL_ACCESS_TOKEN_USED_INTERVAL limits how often the time a token was last used is stored, so that frequent requests do not each cause a write.
+	PERSONAL_ACCESS_TOKEN_USED_INTERVAL = time.Minute
+)
+
+var (
+	ErrPersonalAccessTokenInvalid       = errors.New("Invalid Or Expired Token")
+	ErrPersonalAccessTokenNotFound      = errors.New("Token Not Found")
+	ErrPersonalAccessTokenNameMissing   = errors.New("Token Name Missing")
+	ErrPersonalAccessTokenNameTooLong   = errors.New("Token Name Too Long")
+	ErrPersonalAccessTokenScopeInvalid  = errors.New("Invalid Token Scope")
+	ErrPersonalAccessTokenExpiryInvalid = errors.New("Invalid Token Expiry")
+)
+
+// PersonalAccessToken is a long-lived token a user created for their scripts, stored as a hash of the token.
+type PersonalAccessToken struct {
+	ID     string
+	Name   string
+	Hash   string
+	Scopes []string
+	// Expires is when the token stops working, or zero if it never expires.
+	Expires time.Time
+	Created time.Time
+	Used    time.Time
+}
+
+// HasScope returns true if the token was created with the scope.
+func (t *PersonalAccessToken) HasScope(scope string) bool {
+	return containsScope(t.Scopes, scope)
+}
+
+// IsPersonalAccessToken returns true if the token looks like a personal access token.
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PERSONAL_ACCESS_TOKEN_PREFIX)
+}
+
+func ValidatePersonalAccessTokenName(name string) error {
+	if name == "" {
+		return ErrPersonalAccessTokenNameMissing
+	}
+	if len(name) > MAXIMUM_PERSONAL_ACCESS_TOKEN_NAME_LENGTH {
+		return ErrPersonalAccessTokenNameTooLong
+	}
+	return nil
+}
+
+type personalAccessTokenKey struct{}
+
+// WithPersonalAccessToken returns a copy of the context carrying the personal access token which authenticated the request.
+func WithPersonalAccessToken(ctx context.Context, token *PersonalAccessToken) context.Context {
+	return context.WithValue(ctx, personalAccessTokenKey{}, token)
+}
+
+// BearerPersonalAccessToken returns the personal access token carried by the context, or nil if there is none.
+func BearerPersonalAccessToken(ctx context.Context) *PersonalAccessToken {
+	token, _ := ctx.Value(personalAccessTokenKey{}).(*PersonalAccessToken)
+	return token
+}
+
+// HasBearerScope returns true if the request carried by the context is allowed the scope.
+// Access tokens issued to API clients allow every scope, whereas personal access tokens only allow the scopes they were created with.
+func HasBearerScope(ctx context.Context, scope string) bool {
+	if BearerAccount(ctx) == nil {
+		return false
+	}
+	if token := BearerPersonalAccessToken(ctx); token != nil {
+		return token.HasScope(scope)
+	}
+	return true
+}
+
+func (a *authenticator) PersonalAccessTokenScopes() []string {
+	return a.tokenScopes
+}
+
+// SetPersonalAccessTokenScopes sets the scopes users can choose from when creating a personal access token, such as "products:read".
+func (a *authenticator) SetPersonalAccessTokenScopes(scopes []string) {
+	a.tokenScopes = scopes
+}
+
+// NewPersonalAccessToken creates a token for the account with the given name, scopes, and expiry, returning the token so it can be shown to the user once.
+func (a *authenticator) NewPersonalAccessToken(ctx context.Context, username, name string, scopes []string, expires time.Time) (string, error) {
+	if err := ValidatePersonalAccessTokenName(name); err != nil {
+		return "", err
+	}
+	var unique []string
+	for _, s := range scopes {
+		if !containsScope(a.tokenScopes, s) {
+			return "", fmt.Errorf("%w: %s", ErrPersonalAccessTokenScopeInvalid, s)
+		}
+		if !containsScope(unique, s) {
+			unique = append(unique, s)
+		}
+	}
+	now := time.Now()
+	if !expires.IsZero() && !expires.After(now) {
+		return "", ErrPersonalAccessTokenExpiryInvalid
+	}
+	id, err := NewBearerToken()
+	if err != nil {
+		return "", err
+	}
+	secret, err := NewBearerToken()
+	if err != nil {
+		return "", err
+	}
+	token := PERSONAL_ACCESS_TOKEN_PREFIX + secret
+	if _, err := a.accounts.CreatePersonalAccessToken(ctx, username, &PersonalAccessToken{
+		ID:      id,
+		Name:    name,
+		Hash:    HashBearerToken(token),
+		Scopes:  unique,
+		Expires: expires,
+		Created: now,
+	}); err != nil {
+		return "", err
+	}
+	log.Println("Created Personal Access Token", id, "for", username)
+	a.notify(ctx, username, fmt.Sprintf("A personal access token named %q was created for your account.", name))
+	return token, nil
+}
+
+// LookupPersonalAccessTokens returns the account's tokens, oldest first.
+func (a *authenticator) LookupPersonalAccessTokens(ctx context.Context, username string) ([]*PersonalAccessToken, error) {
+	return a.accounts.SelectPersonalAccessTokens(ctx, username)
+}
+
+func (a *authenticator) DeletePersonalAccessToken(ctx context.Context, username, id string) error {
+	if _, err := a.accounts.DeletePersonalAccessToken(ctx, username, id); err != nil {
+		return err
+	}
+	log.Println("Deleted Personal Access Token", id, "for", username)
+	return nil
+}
+
+// AuthenticatePersonalAccessToken returns the account the token belongs to, and the token's details, and records that the token was used.
+func (a *authenticator) AuthenticatePersonalAccessToken(ctx context.Context, token string) (*Account, *PersonalAccessToken, error) {
+	if !IsPersonalAccessToken(token) {
+		return nil, nil, ErrPersonalAccessTokenInvalid
+	}
+	username, t, err := a.accounts.SelectPersonalAccessToken(ctx, HashBearerToken(token))
+	if err != nil {
+		return nil, nil, ErrPersonalAccessTokenInvalid
+	}
+	now := time.Now()
+	if !t.Expires.IsZero() && t.Expires.Before(now) {
+		return nil, nil, ErrPersonalAccessTokenInvalid
+	}
+	account, err := a.LookupAccount(ctx, username)
+	if err != nil {
+		return nil, nil, ErrPersonalAccessTokenInvalid
+	}
+	if t.Used.Add(PERSONAL_ACCESS_TOKEN_USED_INTERVAL).Before(now) {
+		if _, err := a.accounts.UpdatePersonalAccessTokenUsed(ctx, t.ID, now); err != nil {
+			log.Println(err)
+		} else {
+			t.Used = now
+		}
+	}
+	return account, t, nil
+}
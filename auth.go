@@ -1,6 +1,7 @@
 package authgo
 
 import (
+	"context"
 	"log"
 	"net/http"
 	"time"
@@ -8,94 +9,239 @@ import (
 
 type Authenticator interface {
 	CurrentAccount(w http.ResponseWriter, r *http.Request) *Account
-	NewAccount(string, string, []byte) (*Account, error)
-	LookupAccount(string) (*Account, error)
-	AuthenticateAccount(string, []byte) (*Account, error)
-	LookupUsernameForEmail(string) (string, error)
-	ChangePassword(string, []byte) error
-	DeactivateAccount(*Account) error
-
-	IsEmailVerified(string) bool
-	SetEmailVerified(string, bool) error
+	NewAccount(context.Context, string, string, []byte) (*Account, error)
+	LookupAccount(context.Context, string) (*Account, error)
+	AuthenticateAccount(context.Context, string, []byte) (*Account, error)
+	LookupUsernameForEmail(context.Context, string) (string, error)
+	ChangePassword(context.Context, string, []byte) error
+	DeactivateAccount(context.Context, *Account) error
+	PasswordHasher() PasswordHasher
+	SetPasswordHasher(PasswordHasher)
+	PasswordPolicy() PasswordPolicy
+	SetPasswordPolicy(PasswordPolicy)
+	PasswordHistoryLimit() int
+	SetPasswordHistoryLimit(int)
+	LockoutPolicy() *LockoutPolicy
+	SetLockoutPolicy(*LockoutPolicy)
+	UnlockAccount(context.Context, string) error
+	UnlockClient(context.Context, string) error
+
+	TOTPIssuer() string
+	SetTOTPIssuer(string)
+	IsTOTPEnabled(context.Context, string) bool
+	NewTOTP(context.Context, string) (*TOTPEnrollment, error)
+	LookupTOTPEnrollment(context.Context, string) *TOTPEnrollment
+	ConfirmTOTP(context.Context, string, string) error
+	VerifyTOTP(context.Context, string, string) error
+	DisableTOTP(context.Context, string) error
+
+	NewRecoveryCodes(context.Context, string) ([]string, error)
+	LookupRecoveryCodes(context.Context, string) *RecoveryCodes
+	VerifyRecoveryCode(context.Context, string, string) error
+
+	PasswordlessSignIn() bool
+	SetPasswordlessSignIn(bool)
+	SignInLinkURL() string
+	SetSignInLinkURL(string)
+	SendsSignInLinks() bool
+	SignInChallengeTimeout() time.Duration
+	SetSignInChallengeTimeout(time.Duration)
+	NewSignInChallenge(context.Context, string, string) error
+	VerifySignInCode(context.Context, string, string) (*Account, error)
+	VerifySignInLink(context.Context, string) (*Account, error)
+
+	RelyingParty() *RelyingParty
+	SetRelyingParty(*RelyingParty)
+	NewPasskeyCreationOptions(context.Context, string, string, string) (*PasskeyCreationOptions, error)
+	VerifyPasskeyCreation(string, []byte) (*Passkey, error)
+	AddPasskey(context.Context, string, *Passkey) error
+	NewPasskeyAccount(context.Context, string, string, *Passkey) (*Account, error)
+	NewPasskeyRequestOptions(string) (*PasskeyRequestOptions, error)
+	AuthenticatePasskey(context.Context, string, []byte) (*Account, error)
+	LookupPasskeys(context.Context, string) ([]*Passkey, error)
+	DeletePasskey(context.Context, string, string) error
+
+	OIDCProviders() []*OIDCProvider
+	SetOIDCProviders([]*OIDCProvider)
+	NewOIDCAuthorization(context.Context, string, string, string) (string, error)
+	AuthenticateOIDC(context.Context, string, string, string) (*Account, string, error)
+
+	OIDCIssuer() string
+	SetOIDCIssuer(string)
+	OIDCKeyRotation() time.Duration
+	SetOIDCKeyRotation(time.Duration)
+	OIDCConfiguration() (*OIDCConfiguration, error)
+	RotateOIDCSigningKey(context.Context) error
+	OIDCJSONWebKeySet(context.Context) (*JSONWebKeySet, error)
+	NewOIDCClient(context.Context, string, []string, bool) (*OIDCClient, string, error)
+	LookupOIDCClient(context.Context, string) (*OIDCClient, error)
+	DeleteOIDCClient(context.Context, string) error
+	AuthenticateOIDCClient(context.Context, string, string) (*OIDCClient, error)
+	VerifyOIDCAuthorizationRequest(context.Context, *OIDCAuthorizationRequest) (*OIDCClient, error)
+	NewOIDCCode(context.Context, string, *OIDCAuthorizationRequest) (string, error)
+	RedeemOIDCCode(context.Context, *OIDCClient, string, string, string) (*OIDCTokens, error)
+	RefreshOIDCTokens(context.Context, *OIDCClient, string, string) (*OIDCTokens, error)
+	VerifyOIDCAccessToken(context.Context, string) (*Account, []string, error)
+	LookupOIDCUserInfo(context.Context, string) (map[string]interface{}, error)
+
+	BearerAccessTokenTimeout() time.Duration
+	SetBearerAccessTokenTimeout(time.Duration)
+	BearerRefreshTokenTimeout() time.Duration
+	SetBearerRefreshTokenTimeout(time.Duration)
+	CurrentBearerAccount(*http.Request) (*Account, *PersonalAccessToken)
+	NewBearerTokens(context.Context, string) (*BearerTokens, error)
+	RefreshBearerTokens(context.Context, string) (*BearerTokens, error)
+	LookupBearerAccount(context.Context, string) (*Account, error)
+	RevokeBearerToken(context.Context, string) error
+	RevokeBearerTokens(context.Context, string) error
+
+	PersonalAccessTokenScopes() []string
+	SetPersonalAccessTokenScopes([]string)
+	NewPersonalAccessToken(context.Context, string, string, []string, time.Time) (string, error)
+	LookupPersonalAccessTokens(context.Context, string) ([]*PersonalAccessToken, error)
+	DeletePersonalAccessToken(context.Context, string, string) error
+	AuthenticatePersonalAccessToken(context.Context, string) (*Account, *PersonalAccessToken, error)
+
+	TrustedOrigins() []string
+	SetTrustedOrigins([]string)
+	CSRFToken(http.ResponseWriter, *http.Request) string
+	VerifyCSRF(*http.Request) error
+
+	CookiePolicy() *CookiePolicy
+	SetCookiePolicy(*CookiePolicy)
+	SessionSealer() *SessionSealer
+	SetSessionSealer(*SessionSealer)
+	SealedSessionHandler(http.Handler) http.Handler
+
+	IsEmailVerified(context.Context, string) bool
+	SetEmailVerified(context.Context, string, bool) error
 	EmailVerifier() EmailVerifier
+	SetEmailVerifier(EmailVerifier)
+	EmailNotifier() EmailNotifier
+	SetEmailNotifier(EmailNotifier)
 
 	SignUpSessionTimeout() time.Duration
 	SetSignUpSessionTimeout(time.Duration)
 	NewSignUpSessionCookie(string) *http.Cookie
-	CurrentSignUpSession(*http.Request) (string, string, string, string, string, string)
-	NewSignUpSession() (string, error)
-	LookupSignUpSession(string) (string, string, string, string, string, bool)
-	SetSignUpSessionIdentity(string, string, string) error
-	SetSignUpSessionChallenge(string, string) error
-	SetSignUpSessionReferrer(string, string) error
-	SetSignUpSessionError(string, string)
+	CurrentSignUpSession(*http.Request) *SignUpSession
+	NewSignUpSession(context.Context) (string, error)
+	LookupSignUpSession(context.Context, string) *SignUpSession
+	SetSignUpSessionIdentity(context.Context, string, string, string) error
+	SetSignUpSessionChallenge(context.Context, string, string) error
+	SetSignUpSessionReferrer(context.Context, string, string) error
+	SetSignUpSessionPasskeyChallenge(context.Context, string, string) error
+	SetSignUpSessionError(context.Context, string, string)
 
 	SignInSessionTimeout() time.Duration
 	SetSignInSessionTimeout(time.Duration)
 	NewSignInSessionCookie(string) *http.Cookie
-	CurrentSignInSession(*http.Request) (string, string, bool, time.Time, string)
-	NewSignInSession(string, bool) (string, error)
-	LookupSignInSession(string) (string, bool, time.Time, string, bool)
-	SetSignInSessionUsername(string, string) error
-	SetSignInSessionAuthenticated(string, bool) error
-	SetSignInSessionError(string, string)
+	CurrentSignInSession(*http.Request) *SignInSession
+	NewSignInSession(context.Context, string, bool) (string, error)
+	LookupSignInSession(context.Context, string) *SignInSession
+	SetSignInSessionUsername(context.Context, string, string) error
+	SetSignInSessionAuthenticated(context.Context, string, bool) error
+	SetSignInSessionPendingSecondFactor(context.Context, string, bool) error
+	SetSignInSessionPasskeyChallenge(context.Context, string, string) error
+	SetSignInSessionError(context.Context, string, string)
+	LookupSignInSessions(context.Context, string) ([]*SignInSession, error)
+	RevokeSignInSession(context.Context, string, string) error
+	RevokeOtherSignInSessions(context.Context, string, string) error
 
 	AccountPasswordSessionTimeout() time.Duration
 	SetAccountPasswordSessionTimeout(time.Duration)
 	NewAccountPasswordSessionCookie(string) *http.Cookie
-	CurrentAccountPasswordSession(*http.Request) (string, string, string)
-	NewAccountPasswordSession(string) (string, error)
-	LookupAccountPasswordSession(string) (string, string, bool)
-	SetAccountPasswordSessionError(string, string)
+	CurrentAccountPasswordSession(*http.Request) *AccountPasswordSession
+	NewAccountPasswordSession(context.Context, string) (string, error)
+	LookupAccountPasswordSession(context.Context, string) *AccountPasswordSession
+	SetAccountPasswordSessionError(context.Context, string, string)
 
 	AccountRecoverySessionTimeout() time.Duration
 	SetAccountRecoverySessionTimeout(time.Duration)
 	NewAccountRecoverySessionCookie(string) *http.Cookie
-	CurrentAccountRecoverySession(*http.Request) (string, string, string, string, string)
-	NewAccountRecoverySession() (string, error)
-	LookupAccountRecoverySession(string) (string, string, string, string, bool)
-	SetAccountRecoverySessionEmail(string, string) error
-	SetAccountRecoverySessionUsername(string, string) error
-	SetAccountRecoverySessionChallenge(string, string) error
-	SetAccountRecoverySessionError(string, string)
+	CurrentAccountRecoverySession(*http.Request) *AccountRecoverySession
+	NewAccountRecoverySession(context.Context) (string, error)
+	LookupAccountRecoverySession(context.Context, string) *AccountRecoverySession
+	SetAccountRecoverySessionEmail(context.Context, string, string) error
+	SetAccountRecoverySessionUsername(context.Context, string, string) error
+	SetAccountRecoverySessionChallenge(context.Context, string, string) error
+	SetAccountRecoverySessionError(context.Context, string, string)
+
+	DeleteExpiredSessions(context.Context) (int64, error)
+	StartSessionJanitor(time.Duration)
+	StopSessionJanitor()
 }
 
-func NewAuthenticator(db Database, ev EmailVerifier) Authenticator {
+func NewAuthenticator(accounts AccountStore, sessions SessionStore, ev EmailVerifier) Authenticator {
 	return &authenticator{
-		database:                      db,
+		accounts:                      accounts,
+		sessions:                      sessions,
 		verifier:                      ev,
+		hasher:                        NewBcryptHasher(DEFAULT_BCRYPT_COST),
+		policy:                        NewPasswordPolicy(MINIMUM_PASSWORD_ENTROPY, nil),
+		historyLimit:                  DEFAULT_PASSWORD_HISTORY_LIMIT,
+		lockout:                       NewLockoutPolicy(),
+		cookies:                       NewCookiePolicy(),
 		signInSessionTimeout:          36 * time.Hour,
 		signUpSessionTimeout:          30 * time.Minute,
 		accountPasswordSessionTimeout: 15 * time.Minute,
 		accountRecoverySessionTimeout: 15 * time.Minute,
+		signInChallengeTimeout:        15 * time.Minute,
+		bearerAccessTimeout:           DEFAULT_BEARER_ACCESS_TOKEN_TIMEOUT,
+		bearerRefreshTimeout:          DEFAULT_BEARER_REFRESH_TOKEN_TIMEOUT,
+		keyRotation:                   DEFAULT_OIDC_KEY_ROTATION,
+		janitor:                       &janitor{},
 	}
 }
 
 type authenticator struct {
-	database Database
-	verifier EmailVerifier
+	accounts     AccountStore
+	sessions     SessionStore
+	verifier     EmailVerifier
+	notifier     EmailNotifier
+	hasher       PasswordHasher
+	policy       PasswordPolicy
+	historyLimit int
+	lockout      *LockoutPolicy
+	cookies      *CookiePolicy
+	sealer       *SessionSealer
+	totpIssuer   string
+	relyingParty *RelyingParty
+	providers    []*OIDCProvider
+	issuer       string
+	keyRotation  time.Duration
+	tokenScopes  []string
+	origins      []string
+	passwordless bool
+	signInLink   string
 	signUpSessionTimeout,
 	signInSessionTimeout,
 	accountPasswordSessionTimeout,
-	accountRecoverySessionTimeout time.Duration
+	accountRecoverySessionTimeout,
+	signInChallengeTimeout,
+	bearerAccessTimeout,
+	bearerRefreshTimeout time.Duration
+	janitor *janitor
 }
 
 func (a *authenticator) CurrentAccount(w http.ResponseWriter, r *http.Request) *Account {
-	token, username, authenticated, created, _ := a.CurrentSignInSession(r)
-	if token == "" || username == "" || !authenticated {
+	ctx := r.Context()
+	session := a.CurrentSignInSession(r)
+	if session == nil || session.Username == "" || !session.Authenticated {
 		return nil
 	}
-	if created.Add(a.signInSessionTimeout * 2 / 3).Before(time.Now()) {
+	if session.Created.Add(a.signInSessionTimeout * 2 / 3).Before(time.Now()) {
 		// Refresh sign in session if it is close to expiring
-		a.SetSignInSessionAuthenticated(token, false)
-		token, err := a.NewSignInSession(username, true)
+		a.SetSignInSessionAuthenticated(ctx, session.Token, false)
+		token, err := a.NewSignInSession(WithClient(r), session.Username, true)
 		if err != nil {
 			log.Println(err)
 			return nil
 		}
 		http.SetCookie(w, a.NewSignInSessionCookie(token))
+	} else {
+		a.updateSignInSessionActivity(WithClient(r), session)
 	}
-	account, err := a.LookupAccount(username)
+	account, err := a.LookupAccount(ctx, session.Username)
 	if err != nil {
 		log.Println(err)
 		return nil
@@ -103,13 +249,13 @@ func (a *authenticator) CurrentAccount(w http.ResponseWriter, r *http.Request) *
 	return account
 }
 
-func (a *authenticator) NewAccount(email, username string, password []byte) (*Account, error) {
-	hash, err := GeneratePasswordHash(password)
+func (a *authenticator) NewAccount(ctx context.Context, email, username string, password []byte) (*Account, error) {
+	hash, err := a.hasher.Hash(password)
 	if err != nil {
 		return nil, err
 	}
 	created := time.Now()
-	id, err := a.database.CreateUser(email, username, hash, created)
+	id, err := a.accounts.CreateUser(ctx, email, username, hash, created)
 	if err != nil {
 		return nil, err
 	}
@@ -123,8 +269,8 @@ func (a *authenticator) NewAccount(email, username string, password []byte) (*Ac
 	return acc, nil
 }
 
-func (a *authenticator) LookupAccount(username string) (*Account, error) {
-	id, email, _, created, err := a.database.SelectUser(username)
+func (a *authenticator) LookupAccount(ctx context.Context, username string) (*Account, error) {
+	id, email, _, created, err := a.accounts.SelectUser(ctx, username)
 	if err != nil {
 		return nil, err
 	}
@@ -136,15 +282,35 @@ func (a *authenticator) LookupAccount(username string) (*Account, error) {
 	}, nil
 }
 
-func (a *authenticator) AuthenticateAccount(username string, password []byte) (*Account, error) {
-	id, email, hash, created, err := a.database.SelectUser(username)
+func (a *authenticator) AuthenticateAccount(ctx context.Context, username string, password []byte) (*Account, error) {
+	now := time.Now()
+	subjects := a.lockoutSubjects(ctx, username)
+	if err := a.checkLockout(ctx, subjects, now); err != nil {
+		return nil, err
+	}
+	id, email, hash, created, err := a.accounts.SelectUser(ctx, username)
 	if err != nil {
 		log.Println(err)
+		if ctx.Err() == nil {
+			a.recordFailure(ctx, subjects, now)
+		}
 		return nil, ErrCredentialsIncorrect
 	}
-	if !CheckPasswordHash(hash, password) {
+	if !a.hasher.Check(hash, password) {
+		a.recordFailure(ctx, subjects, now)
 		return nil, ErrCredentialsIncorrect
 	}
+	if _, err := a.accounts.DeleteAuthenticationFailures(ctx, accountSubject(username)); err != nil {
+		log.Println(err)
+	}
+	if a.hasher.NeedsRehash(hash) {
+		// Upgrade to the current algorithm and parameters now that the password is known
+		if rehash, err := a.hasher.Hash(password); err != nil {
+			log.Println(err)
+		} else if _, err := a.accounts.ChangePassword(ctx, username, rehash); err != nil {
+			log.Println(err)
+		}
+	}
 	return &Account{
 		ID:       id,
 		Email:    email,
@@ -153,26 +319,88 @@ func (a *authenticator) AuthenticateAccount(username string, password []byte) (*
 	}, nil
 }
 
-func (a *authenticator) LookupUsernameForEmail(email string) (string, error) {
-	return a.database.SelectUsernameByEmail(email)
+func (a *authenticator) LookupUsernameForEmail(ctx context.Context, email string) (string, error) {
+	return a.accounts.SelectUsernameByEmail(ctx, email)
 }
 
-func (a *authenticator) ChangePassword(username string, password []byte) error {
-	hash, err := GeneratePasswordHash(password)
+func (a *authenticator) ChangePassword(ctx context.Context, username string, password []byte) error {
+	_, _, current, _, err := a.accounts.SelectUser(ctx, username)
 	if err != nil {
 		return err
 	}
-	_, err = a.database.ChangePassword(username, hash)
+	if a.historyLimit > 0 {
+		if a.hasher.Check(current, password) {
+			return ErrPasswordPreviouslyUsed
+		}
+		history, err := a.accounts.SelectPasswordHistory(ctx, username)
+		if err != nil {
+			return err
+		}
+		for i, previous := range history {
+			if i >= a.historyLimit {
+				break
+			}
+			if a.hasher.Check(previous, password) {
+				return ErrPasswordPreviouslyUsed
+			}
+		}
+	}
+	hash, err := a.hasher.Hash(password)
+	if err != nil {
+		return err
+	}
+	if _, err := a.accounts.ChangePassword(ctx, username, hash); err != nil {
+		return err
+	}
+	if a.historyLimit > 0 {
+		if _, err := a.accounts.CreatePasswordHistory(ctx, username, current, time.Now()); err != nil {
+			return err
+		}
+	}
+	_, err = a.accounts.PrunePasswordHistory(ctx, username, a.historyLimit)
 	return err
 }
 
-func (a *authenticator) DeactivateAccount(acc *Account) error {
-	_, err := a.database.DeactivateUser(acc.Username, time.Now())
+func (a *authenticator) DeactivateAccount(ctx context.Context, acc *Account) error {
+	if _, err := a.accounts.DeactivateUser(ctx, acc.Username, time.Now()); err != nil {
+		return err
+	}
+	if _, err := a.sessions.DeauthenticateSignInSessions(ctx, acc.Username); err != nil {
+		return err
+	}
+	if err := a.RevokeBearerTokens(ctx, acc.Username); err != nil {
+		return err
+	}
+	_, err := a.accounts.DeletePersonalAccessTokens(ctx, acc.Username)
 	return err
 }
 
-func (a *authenticator) IsEmailVerified(email string) bool {
-	verified, err := a.database.IsEmailVerified(email)
+func (a *authenticator) PasswordHasher() PasswordHasher {
+	return a.hasher
+}
+
+func (a *authenticator) SetPasswordHasher(hasher PasswordHasher) {
+	a.hasher = hasher
+}
+
+func (a *authenticator) PasswordPolicy() PasswordPolicy {
+	return a.policy
+}
+
+func (a *authenticator) SetPasswordPolicy(policy PasswordPolicy) {
+	a.policy = policy
+}
+
+func (a *authenticator) PasswordHistoryLimit() int {
+	return a.historyLimit
+}
+
+func (a *authenticator) SetPasswordHistoryLimit(limit int) {
+	a.historyLimit = limit
+}
+
+func (a *authenticator) IsEmailVerified(ctx context.Context, email string) bool {
+	verified, err := a.accounts.IsEmailVerified(ctx, email)
 	if err != nil {
 		log.Println(err)
 		return false
@@ -180,8 +408,8 @@ func (a *authenticator) IsEmailVerified(email string) bool {
 	return verified
 }
 
-func (a *authenticator) SetEmailVerified(email string, verified bool) error {
-	_, err := a.database.SetEmailVerified(email, verified)
+func (a *authenticator) SetEmailVerified(ctx context.Context, email string, verified bool) error {
+	_, err := a.accounts.SetEmailVerified(ctx, email, verified)
 	return err
 }
 
@@ -189,6 +417,35 @@ func (a *authenticator) EmailVerifier() EmailVerifier {
 	return a.verifier
 }
 
+func (a *authenticator) SetEmailVerifier(verifier EmailVerifier) {
+	a.verifier = verifier
+}
+
+func (a *authenticator) EmailNotifier() EmailNotifier {
+	return a.notifier
+}
+
+func (a *authenticator) SetEmailNotifier(notifier EmailNotifier) {
+	a.notifier = notifier
+}
+
+// notify sends the message to the account's email address, if a notifier is set.
+// Failures are logged rather than returned so they cannot block the event being notified.
+func (a *authenticator) notify(ctx context.Context, username, message string) {
+	log.Println("Notifying", username+":", message)
+	if a.notifier == nil {
+		return
+	}
+	_, email, _, _, err := a.accounts.SelectUser(ctx, username)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := a.notifier.Notify(email, username, message); err != nil {
+		log.Println(err)
+	}
+}
+
 func (a *authenticator) SignUpSessionTimeout() time.Duration {
 	return a.signUpSessionTimeout
 }
@@ -198,29 +455,27 @@ func (a *authenticator) SetSignUpSessionTimeout(timeout time.Duration) {
 }
 
 func (a *authenticator) NewSignUpSessionCookie(token string) *http.Cookie {
-	return NewCookie(COOKIE_SIGN_UP, token, a.signUpSessionTimeout)
+	return a.cookies.NewCookie(COOKIE_SIGN_UP, token, a.signUpSessionTimeout)
 }
 
-func (a authenticator) CurrentSignUpSession(r *http.Request) (string, string, string, string, string, string) {
-	c, err := r.Cookie(COOKIE_SIGN_UP)
-	if err != nil {
-		return "", "", "", "", "", ""
-	}
-	token := c.Value
-	email, username, referrer, challenge, errmsg, ok := a.LookupSignUpSession(token)
-	if !ok {
-		return "", "", "", "", "", ""
+func (a authenticator) CurrentSignUpSession(r *http.Request) *SignUpSession {
+	c := a.cookies.Cookie(r, COOKIE_SIGN_UP)
+	if c == nil {
+		return nil
 	}
-	return token, email, username, referrer, challenge, errmsg
+	return a.LookupSignUpSession(r.Context(), c.Value)
 }
 
-func (a *authenticator) NewSignUpSession() (string, error) {
+func (a *authenticator) NewSignUpSession(ctx context.Context) (string, error) {
 	token, err := NewSessionToken()
 	if err != nil {
 		return "", err
 	}
 
-	id, err := a.database.CreateSignUpSession(token, time.Now())
+	id, err := a.sessionStore().CreateSignUpSession(ctx, &SignUpSession{
+		Token:   token,
+		Created: time.Now(),
+	})
 	if err != nil {
 		return "", err
 	}
@@ -229,35 +484,40 @@ func (a *authenticator) NewSignUpSession() (string, error) {
 	return token, nil
 }
 
-func (a *authenticator) LookupSignUpSession(token string) (string, string, string, string, string, bool) {
-	errmsg, email, username, referrer, challenge, created, err := a.database.SelectSignUpSession(token)
+func (a *authenticator) LookupSignUpSession(ctx context.Context, token string) *SignUpSession {
+	session, err := a.sessionStore().SelectSignUpSession(ctx, token)
 	if err != nil {
 		log.Println(err)
-		return "", "", "", "", "", false
+		return nil
 	}
-	if created.Add(a.signUpSessionTimeout).Before(time.Now()) {
-		return "", "", "", "", "", false
+	if session.Created.Add(a.signUpSessionTimeout).Before(time.Now()) {
+		return nil
 	}
-	return email, username, referrer, challenge, errmsg, true
+	return session
 }
 
-func (a *authenticator) SetSignUpSessionIdentity(token, email, username string) error {
-	_, err := a.database.UpdateSignUpSessionIdentity(token, email, username)
+func (a *authenticator) SetSignUpSessionIdentity(ctx context.Context, token, email, username string) error {
+	_, err := a.sessionStore().UpdateSignUpSessionIdentity(ctx, token, email, username)
 	return err
 }
 
-func (a *authenticator) SetSignUpSessionReferrer(token, referrer string) error {
-	_, err := a.database.UpdateSignUpSessionReferrer(token, referrer)
+func (a *authenticator) SetSignUpSessionReferrer(ctx context.Context, token, referrer string) error {
+	_, err := a.sessionStore().UpdateSignUpSessionReferrer(ctx, token, referrer)
 	return err
 }
 
-func (a *authenticator) SetSignUpSessionChallenge(token, challenge string) error {
-	_, err := a.database.UpdateSignUpSessionChallenge(token, challenge)
+func (a *authenticator) SetSignUpSessionChallenge(ctx context.Context, token, challenge string) error {
+	_, err := a.sessionStore().UpdateSignUpSessionChallenge(ctx, token, challenge)
 	return err
 }
 
-func (a *authenticator) SetSignUpSessionError(token string, errmsg string) {
-	_, err := a.database.UpdateSignUpSessionError(token, errmsg)
+func (a *authenticator) SetSignUpSessionPasskeyChallenge(ctx context.Context, token, challenge string) error {
+	_, err := a.sessionStore().UpdateSignUpSessionPasskeyChallenge(ctx, token, challenge)
+	return err
+}
+
+func (a *authenticator) SetSignUpSessionError(ctx context.Context, token string, errmsg string) {
+	_, err := a.sessionStore().UpdateSignUpSessionError(ctx, token, errmsg)
 	if err != nil {
 		log.Println(err)
 	}
@@ -272,29 +532,34 @@ func (a *authenticator) SetSignInSessionTimeout(timeout time.Duration) {
 }
 
 func (a *authenticator) NewSignInSessionCookie(token string) *http.Cookie {
-	return NewCookie(COOKIE_SIGN_IN, token, a.signInSessionTimeout)
+	return a.cookies.NewCookie(COOKIE_SIGN_IN, token, a.signInSessionTimeout)
 }
 
-func (a authenticator) CurrentSignInSession(r *http.Request) (string, string, bool, time.Time, string) {
-	c, err := r.Cookie(COOKIE_SIGN_IN)
-	if err != nil {
-		return "", "", false, time.Time{}, ""
-	}
-	token := c.Value
-	username, authenticated, created, errmsg, ok := a.LookupSignInSession(token)
-	if !ok {
-		return "", "", false, time.Time{}, ""
+func (a authenticator) CurrentSignInSession(r *http.Request) *SignInSession {
+	c := a.cookies.Cookie(r, COOKIE_SIGN_IN)
+	if c == nil {
+		return nil
 	}
-	return token, username, authenticated, created, errmsg
+	return a.LookupSignInSession(r.Context(), c.Value)
 }
 
-func (a *authenticator) NewSignInSession(username string, authenticated bool) (string, error) {
+func (a *authenticator) NewSignInSession(ctx context.Context, username string, authenticated bool) (string, error) {
 	token, err := NewSessionToken()
 	if err != nil {
 		return "", err
 	}
 
-	id, err := a.database.CreateSignInSession(token, username, authenticated, time.Now())
+	now := time.Now()
+	address, agent := signInSessionClient(ctx)
+	id, err := a.sessions.CreateSignInSession(ctx, &SignInSession{
+		Token:         token,
+		Username:      username,
+		Authenticated: authenticated,
+		Address:       address,
+		UserAgent:     agent,
+		Created:       now,
+		Active:        now,
+	})
 	if err != nil {
 		return "", err
 	}
@@ -303,30 +568,40 @@ func (a *authenticator) NewSignInSession(username string, authenticated bool) (s
 	return token, nil
 }
 
-func (a *authenticator) LookupSignInSession(token string) (string, bool, time.Time, string, bool) {
-	errmsg, username, created, authenticated, err := a.database.SelectSignInSession(token)
+func (a *authenticator) LookupSignInSession(ctx context.Context, token string) *SignInSession {
+	session, err := a.sessions.SelectSignInSession(ctx, token)
 	if err != nil {
 		log.Println(err)
-		return "", false, time.Time{}, "", false
+		return nil
 	}
-	if created.Add(a.signInSessionTimeout).Before(time.Now()) {
-		return "", false, time.Time{}, "", false
+	if session.Created.Add(a.signInSessionTimeout).Before(time.Now()) {
+		return nil
 	}
-	return username, authenticated, created, errmsg, true
+	return session
 }
 
-func (a *authenticator) SetSignInSessionUsername(token string, username string) error {
-	_, err := a.database.UpdateSignInSessionUsername(token, username)
+func (a *authenticator) SetSignInSessionUsername(ctx context.Context, token string, username string) error {
+	_, err := a.sessions.UpdateSignInSessionUsername(ctx, token, username)
 	return err
 }
 
-func (a *authenticator) SetSignInSessionAuthenticated(token string, authenticated bool) error {
-	_, err := a.database.UpdateSignInSessionAuthenticated(token, authenticated)
+func (a *authenticator) SetSignInSessionAuthenticated(ctx context.Context, token string, authenticated bool) error {
+	_, err := a.sessions.UpdateSignInSessionAuthenticated(ctx, token, authenticated)
 	return err
 }
 
-func (a *authenticator) SetSignInSessionError(token string, errmsg string) {
-	_, err := a.database.UpdateSignInSessionError(token, errmsg)
+func (a *authenticator) SetSignInSessionPendingSecondFactor(ctx context.Context, token string, pending bool) error {
+	_, err := a.sessions.UpdateSignInSessionPendingSecondFactor(ctx, token, pending)
+	return err
+}
+
+func (a *authenticator) SetSignInSessionPasskeyChallenge(ctx context.Context, token, challenge string) error {
+	_, err := a.sessions.UpdateSignInSessionPasskeyChallenge(ctx, token, challenge)
+	return err
+}
+
+func (a *authenticator) SetSignInSessionError(ctx context.Context, token string, errmsg string) {
+	_, err := a.sessions.UpdateSignInSessionError(ctx, token, errmsg)
 	if err != nil {
 		log.Println(err)
 	}
@@ -341,29 +616,28 @@ func (a *authenticator) SetAccountPasswordSessionTimeout(timeout time.Duration)
 }
 
 func (a *authenticator) NewAccountPasswordSessionCookie(token string) *http.Cookie {
-	return NewCookie(COOKIE_ACCOUNT_PASSWORD, token, a.accountPasswordSessionTimeout)
+	return a.cookies.NewCookie(COOKIE_ACCOUNT_PASSWORD, token, a.accountPasswordSessionTimeout)
 }
 
-func (a authenticator) CurrentAccountPasswordSession(r *http.Request) (string, string, string) {
-	c, err := r.Cookie(COOKIE_ACCOUNT_PASSWORD)
-	if err != nil {
-		return "", "", ""
-	}
-	token := c.Value
-	username, errmsg, ok := a.LookupAccountPasswordSession(token)
-	if !ok {
-		return "", "", ""
+func (a authenticator) CurrentAccountPasswordSession(r *http.Request) *AccountPasswordSession {
+	c := a.cookies.Cookie(r, COOKIE_ACCOUNT_PASSWORD)
+	if c == nil {
+		return nil
 	}
-	return token, username, errmsg
+	return a.LookupAccountPasswordSession(r.Context(), c.Value)
 }
 
-func (a *authenticator) NewAccountPasswordSession(username string) (string, error) {
+func (a *authenticator) NewAccountPasswordSession(ctx context.Context, username string) (string, error) {
 	token, err := NewSessionToken()
 	if err != nil {
 		return "", err
 	}
 
-	id, err := a.database.CreateAccountPasswordSession(token, username, time.Now())
+	id, err := a.sessionStore().CreateAccountPasswordSession(ctx, &AccountPasswordSession{
+		Token:    token,
+		Username: username,
+		Created:  time.Now(),
+	})
 	if err != nil {
 		return "", err
 	}
@@ -372,16 +646,16 @@ func (a *authenticator) NewAccountPasswordSession(username string) (string, erro
 	return token, nil
 }
 
-func (a *authenticator) LookupAccountPasswordSession(token string) (string, string, bool) {
-	errmsg, username, created, err := a.database.SelectAccountPasswordSession(token)
+func (a *authenticator) LookupAccountPasswordSession(ctx context.Context, token string) *AccountPasswordSession {
+	session, err := a.sessionStore().SelectAccountPasswordSession(ctx, token)
 	if err != nil {
 		log.Println(err)
-		return "", "", false
+		return nil
 	}
-	if created.Add(a.accountPasswordSessionTimeout).Before(time.Now()) {
-		return "", "", false
+	if session.Created.Add(a.accountPasswordSessionTimeout).Before(time.Now()) {
+		return nil
 	}
-	return username, errmsg, true
+	return session
 }
 
 func (a *authenticator) AccountRecoverySessionTimeout() time.Duration {
@@ -392,37 +666,35 @@ func (a *authenticator) SetAccountRecoverySessionTimeout(timeout time.Duration)
 	a.accountRecoverySessionTimeout = timeout
 }
 
-func (a *authenticator) SetAccountPasswordSessionError(token string, errmsg string) {
-	_, err := a.database.UpdateAccountPasswordSessionError(token, errmsg)
+func (a *authenticator) SetAccountPasswordSessionError(ctx context.Context, token string, errmsg string) {
+	_, err := a.sessionStore().UpdateAccountPasswordSessionError(ctx, token, errmsg)
 	if err != nil {
 		log.Println(err)
 	}
 }
 
 func (a *authenticator) NewAccountRecoverySessionCookie(token string) *http.Cookie {
-	return NewCookie(COOKIE_ACCOUNT_RECOVERY, token, a.accountRecoverySessionTimeout)
+	return a.cookies.NewCookie(COOKIE_ACCOUNT_RECOVERY, token, a.accountRecoverySessionTimeout)
 }
 
-func (a authenticator) CurrentAccountRecoverySession(r *http.Request) (string, string, string, string, string) {
-	c, err := r.Cookie(COOKIE_ACCOUNT_RECOVERY)
-	if err != nil {
-		return "", "", "", "", ""
-	}
-	token := c.Value
-	email, username, challenge, errmsg, ok := a.LookupAccountRecoverySession(token)
-	if !ok {
-		return "", "", "", "", ""
+func (a authenticator) CurrentAccountRecoverySession(r *http.Request) *AccountRecoverySession {
+	c := a.cookies.Cookie(r, COOKIE_ACCOUNT_RECOVERY)
+	if c == nil {
+		return nil
 	}
-	return token, email, username, challenge, errmsg
+	return a.LookupAccountRecoverySession(r.Context(), c.Value)
 }
 
-func (a *authenticator) NewAccountRecoverySession() (string, error) {
+func (a *authenticator) NewAccountRecoverySession(ctx context.Context) (string, error) {
 	token, err := NewSessionToken()
 	if err != nil {
 		return "", err
 	}
 
-	id, err := a.database.CreateAccountRecoverySession(token, time.Now())
+	id, err := a.sessionStore().CreateAccountRecoverySession(ctx, &AccountRecoverySession{
+		Token:   token,
+		Created: time.Now(),
+	})
 	if err != nil {
 		return "", err
 	}
@@ -431,35 +703,35 @@ func (a *authenticator) NewAccountRecoverySession() (string, error) {
 	return token, nil
 }
 
-func (a *authenticator) LookupAccountRecoverySession(token string) (string, string, string, string, bool) {
-	errmsg, email, username, challenge, created, err := a.database.SelectAccountRecoverySession(token)
+func (a *authenticator) LookupAccountRecoverySession(ctx context.Context, token string) *AccountRecoverySession {
+	session, err := a.sessionStore().SelectAccountRecoverySession(ctx, token)
 	if err != nil {
 		log.Println(err)
-		return "", "", "", "", false
+		return nil
 	}
-	if created.Add(a.accountRecoverySessionTimeout).Before(time.Now()) {
-		return "", "", "", "", false
+	if session.Created.Add(a.accountRecoverySessionTimeout).Before(time.Now()) {
+		return nil
 	}
-	return email, username, challenge, errmsg, true
+	return session
 }
 
-func (a *authenticator) SetAccountRecoverySessionEmail(token string, email string) error {
-	_, err := a.database.UpdateAccountRecoverySessionEmail(token, email)
+func (a *authenticator) SetAccountRecoverySessionEmail(ctx context.Context, token string, email string) error {
+	_, err := a.sessionStore().UpdateAccountRecoverySessionEmail(ctx, token, email)
 	return err
 }
 
-func (a *authenticator) SetAccountRecoverySessionUsername(token string, username string) error {
-	_, err := a.database.UpdateAccountRecoverySessionUsername(token, username)
+func (a *authenticator) SetAccountRecoverySessionUsername(ctx context.Context, token string, username string) error {
+	_, err := a.sessionStore().UpdateAccountRecoverySessionUsername(ctx, token, username)
 	return err
 }
 
-func (a *authenticator) SetAccountRecoverySessionChallenge(token, challenge string) error {
-	_, err := a.database.UpdateAccountRecoverySessionChallenge(token, challenge)
+func (a *authenticator) SetAccountRecoverySessionChallenge(ctx context.Context, token, challenge string) error {
+	_, err := a.sessionStore().UpdateAccountRecoverySessionChallenge(ctx, token, challenge)
 	return err
 }
 
-func (a *authenticator) SetAccountRecoverySessionError(token string, errmsg string) {
-	_, err := a.database.UpdateAccountRecoverySessionError(token, errmsg)
+func (a *authenticator) SetAccountRecoverySessionError(ctx context.Context, token string, errmsg string) {
+	_, err := a.sessionStore().UpdateAccountRecoverySessionError(ctx, token, errmsg)
 	if err != nil {
 		log.Println(err)
 	}
@@ -0,0 +1,174 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func PersonalAccessToken(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	ctx := context.Background()
+	setup := func(t *testing.T) authgo.Authenticator {
+		t.Helper()
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPersonalAccessTokenScopes([]string{"products:read", "products:write"})
+		return auth
+	}
+	t.Run("New", func(t *testing.T) {
+		auth := setup(t)
+		notifier := authtest.NewEmailNotifier()
+		auth.SetEmailNotifier(notifier)
+		token, err := auth.NewPersonalAccessToken(ctx, authtest.TEST_USERNAME, "Deploy", []string{"products:read", "products:read"}, time.Time{})
+		assert.Nil(t, err)
+		assert.True(t, authgo.IsPersonalAccessToken(token), token)
+
+		tokens, err := auth.LookupPersonalAccessTokens(ctx, authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		require.Equal(t, 1, len(tokens))
+		assert.Equal(t, "Deploy", tokens[0].Name)
+		assert.Equal(t, []string{"products:read"}, tokens[0].Scopes)
+		assert.True(t, tokens[0].Expires.IsZero())
+		assert.True(t, tokens[0].Used.IsZero())
+		assert.False(t, tokens[0].Created.IsZero())
+		// Only the hash of the token is stored
+		assert.Equal(t, authgo.HashBearerToken(token), tokens[0].Hash)
+
+		require.Equal(t, 1, len(notifier.Notifications))
+		assert.Contains(t, notifier.Notifications[0].Message, "Deploy")
+	})
+	t.Run("New Validates", func(t *testing.T) {
+		auth := setup(t)
+		for name, tt := range map[string]struct {
+			name    string
+			scopes  []string
+			expires time.Time
+			err     error
+		}{
+			"Name Missing":  {"", nil, time.Time{}, authgo.ErrPersonalAccessTokenNameMissing},
+			"Name Too Long": {strings.Repeat("a", authgo.MAXIMUM_PERSONAL_ACCESS_TOKEN_NAME_LENGTH+1), nil, time.Time{}, authgo.ErrPersonalAccessTokenNameTooLong},
+			"Scope Invalid": {"Deploy", []string{"admin"}, time.Time{}, authgo.ErrPersonalAccessTokenScopeInvalid},
+			"Expired":       {"Deploy", nil, time.Now().Add(-time.Hour), authgo.ErrPersonalAccessTokenExpiryInvalid},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, err := auth.NewPersonalAccessToken(ctx, authtest.TEST_USERNAME, tt.name, tt.scopes, tt.expires)
+				assert.True(t, errors.Is(err, tt.err), err)
+			})
+		}
+		tokens, err := auth.LookupPersonalAccessTokens(ctx, authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		assert.Empty(t, tokens)
+	})
+	t.Run("Authenticate", func(t *testing.T) {
+		auth := setup(t)
+		token, err := auth.NewPersonalAccessToken(ctx, authtest.TEST_USERNAME, "Deploy", []string{"products:read"}, time.Now().Add(time.Hour))
+		require.Nil(t, err)
+		account, pat, err := auth.AuthenticatePersonalAccessToken(ctx, token)
+		assert.Nil(t, err)
+		require.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+		require.NotNil(t, pat)
+		assert.True(t, pat.HasScope("products:read"))
+		assert.False(t, pat.HasScope("products:write"))
+
+		tokens, err := auth.LookupPersonalAccessTokens(ctx, authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		require.Equal(t, 1, len(tokens))
+		assert.False(t, tokens[0].Used.IsZero())
+
+		_, _, err = auth.AuthenticatePersonalAccessToken(ctx, authgo.PERSONAL_ACCESS_TOKEN_PREFIX+"invalid")
+		assert.Equal(t, authgo.ErrPersonalAccessTokenInvalid, err)
+		_, _, err = auth.AuthenticatePersonalAccessToken(ctx, strings.TrimPrefix(token, authgo.PERSONAL_ACCESS_TOKEN_PREFIX))
+		assert.Equal(t, authgo.ErrPersonalAccessTokenInvalid, err)
+	})
+	t.Run("Current Bearer Account", func(t *testing.T) {
+		auth := setup(t)
+		token, err := auth.NewPersonalAccessToken(ctx, authtest.TEST_USERNAME, "Deploy", []string{"products:read"}, time.Time{})
+		require.Nil(t, err)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		account, pat := auth.CurrentBearerAccount(request)
+		require.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+		// Scopes are returned so they cannot be bypassed
+		require.NotNil(t, pat)
+		assert.Equal(t, "Deploy", pat.Name)
+		assert.True(t, pat.HasScope("products:read"))
+		assert.False(t, pat.HasScope("products:write"))
+	})
+	t.Run("Expires", func(t *testing.T) {
+		auth := setup(t)
+		token, err := auth.NewPersonalAccessToken(ctx, authtest.TEST_USERNAME, "Deploy", nil, time.Now().Add(10*time.Millisecond))
+		require.Nil(t, err)
+		time.Sleep(20 * time.Millisecond)
+		_, _, err = auth.AuthenticatePersonalAccessToken(ctx, token)
+		assert.Equal(t, authgo.ErrPersonalAccessTokenInvalid, err)
+
+		count, err := auth.DeleteExpiredSessions(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count)
+		tokens, err := auth.LookupPersonalAccessTokens(ctx, authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		assert.Empty(t, tokens)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		auth := setup(t)
+		token, err := auth.NewPersonalAccessToken(ctx, authtest.TEST_USERNAME, "Deploy", nil, time.Time{})
+		require.Nil(t, err)
+		tokens, err := auth.LookupPersonalAccessTokens(ctx, authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		require.Equal(t, 1, len(tokens))
+
+		// Tokens can only be deleted by their owner
+		assert.Equal(t, authgo.ErrPersonalAccessTokenNotFound, auth.DeletePersonalAccessToken(ctx, "bob", tokens[0].ID))
+
+		assert.Nil(t, auth.DeletePersonalAccessToken(ctx, authtest.TEST_USERNAME, tokens[0].ID))
+		_, _, err = auth.AuthenticatePersonalAccessToken(ctx, token)
+		assert.Equal(t, authgo.ErrPersonalAccessTokenInvalid, err)
+		assert.Equal(t, authgo.ErrPersonalAccessTokenNotFound, auth.DeletePersonalAccessToken(ctx, authtest.TEST_USERNAME, tokens[0].ID))
+	})
+	t.Run("Revoke", func(t *testing.T) {
+		auth := setup(t)
+		token, err := auth.NewPersonalAccessToken(ctx, authtest.TEST_USERNAME, "Deploy", nil, time.Time{})
+		require.Nil(t, err)
+		assert.Nil(t, auth.RevokeBearerToken(ctx, token))
+		_, _, err = auth.AuthenticatePersonalAccessToken(ctx, token)
+		assert.Equal(t, authgo.ErrPersonalAccessTokenInvalid, err)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, auth.RevokeBearerToken(ctx, token))
+	})
+	t.Run("Deactivated Account", func(t *testing.T) {
+		auth := setup(t)
+		token, err := auth.NewPersonalAccessToken(ctx, authtest.TEST_USERNAME, "Deploy", nil, time.Time{})
+		require.Nil(t, err)
+		account, err := auth.LookupAccount(ctx, authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		assert.Nil(t, auth.DeactivateAccount(ctx, account))
+		_, _, err = auth.AuthenticatePersonalAccessToken(ctx, token)
+		assert.Equal(t, authgo.ErrPersonalAccessTokenInvalid, err)
+	})
+	t.Run("Scope", func(t *testing.T) {
+		auth := setup(t)
+		assert.False(t, authgo.HasBearerScope(ctx, "products:read"))
+
+		account, err := auth.LookupAccount(ctx, authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		// Access tokens allow every scope
+		c := authgo.WithBearerAccount(ctx, account)
+		assert.True(t, authgo.HasBearerScope(c, "products:read"))
+		assert.True(t, authgo.HasBearerScope(c, "products:write"))
+
+		c = authgo.WithPersonalAccessToken(c, &authgo.PersonalAccessToken{
+			Scopes: []string{"products:read"},
+		})
+		assert.True(t, authgo.HasBearerScope(c, "products:read"))
+		assert.False(t, authgo.HasBearerScope(c, "products:write"))
+	})
+}
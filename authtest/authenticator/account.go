@@ -0,0 +1,318 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func CurrentAccount(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("Valid", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewSignInSessionCookie(token))
+		response := httptest.NewRecorder()
+		account := auth.CurrentAccount(response, request)
+		assert.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+	})
+	t.Run("Valid_Refresh", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSignInSessionTimeout(time.Second * 6)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewSignInSessionCookie(token))
+		response := httptest.NewRecorder()
+		time.Sleep(time.Second * 4) // Sleep to ensure expiry is imminent
+		account := auth.CurrentAccount(response, request)
+		assert.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+		// Expect new session
+		cookies := response.Result().Cookies()
+		assert.Equal(t, 1, len(cookies))
+		assert.Equal(t, authgo.COOKIE_SIGN_IN, cookies[0].Name)
+		assert.NotEqual(t, token, cookies[0].Value)
+	})
+	t.Run("NoCookie", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		response := httptest.NewRecorder()
+		account := auth.CurrentAccount(response, request)
+		assert.Nil(t, account)
+	})
+	t.Run("NoSession", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewSignInSessionCookie("token"))
+		response := httptest.NewRecorder()
+		account := auth.CurrentAccount(response, request)
+		assert.Nil(t, account)
+	})
+	t.Run("ExpiredSession", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSignInSessionTimeout(time.Nanosecond)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewSignInSessionCookie(token))
+		response := httptest.NewRecorder()
+		time.Sleep(time.Millisecond) // Sleep to ensure expiry
+		account := auth.CurrentAccount(response, request)
+		assert.Nil(t, account)
+	})
+}
+
+func NewAccount(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	account := authtest.NewTestAccount(t, auth)
+	assert.NotNil(t, account)
+	assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+	assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+}
+
+func LookupAccount(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("DoesNotExist", func(t *testing.T) {
+		auth := a(t)
+		account, err := auth.LookupAccount(context.Background(), authtest.TEST_USERNAME)
+		assert.Error(t, authgo.ErrUsernameNotRegistered, err)
+		assert.Nil(t, account)
+	})
+	t.Run("Exists", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		account, err := auth.LookupAccount(context.Background(), authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		assert.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+	})
+}
+
+func AuthenticateAccount(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("DoesNotExist", func(t *testing.T) {
+		auth := a(t)
+		account, err := auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+		assert.Error(t, authgo.ErrCredentialsIncorrect, err)
+		assert.Nil(t, account)
+	})
+	t.Run("Exists_CredentialsIncorrect", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		account, err := auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte("1234password"))
+		assert.Error(t, authgo.ErrCredentialsIncorrect, err)
+		assert.Nil(t, account)
+	})
+	t.Run("Exists_CredentialsCorrect", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		account, err := auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+		assert.NoError(t, err)
+		assert.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+	})
+}
+
+func LookupUsernameForEmail(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("DoesNotExist", func(t *testing.T) {
+		auth := a(t)
+		username, err := auth.LookupUsernameForEmail(context.Background(), authtest.TEST_EMAIL)
+		assert.Error(t, authgo.ErrUsernameNotRegistered, err)
+		assert.Empty(t, username)
+	})
+	t.Run("Exists", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		username, err := auth.LookupUsernameForEmail(context.Background(), authtest.TEST_EMAIL)
+		assert.NoError(t, err)
+		assert.Equal(t, authtest.TEST_USERNAME, username)
+	})
+}
+
+func ChangePassword(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	authtest.NewTestAccount(t, auth)
+	newPassword := []byte("1234password")
+	err := auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, newPassword)
+	assert.NoError(t, err)
+
+	// Old password should not work
+	account, err := auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+	assert.Error(t, authgo.ErrCredentialsIncorrect, err)
+	assert.Nil(t, account)
+
+	// New password should work
+	account, err = auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, newPassword)
+	assert.NotNil(t, account)
+	assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+	assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+}
+
+func PasswordHistory(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	passwords := [][]byte{
+		[]byte("violet-marble-stapler-97"),
+		[]byte("amber-walnut-lantern-63"),
+		[]byte("crimson-pebble-teapot-18"),
+	}
+	t.Run("Current", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		err := auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+		assert.Equal(t, authgo.ErrPasswordPreviouslyUsed, err)
+	})
+	t.Run("Limit", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordHistoryLimit(2)
+		assert.Equal(t, 2, auth.PasswordHistoryLimit())
+		for _, p := range passwords {
+			assert.NoError(t, auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, p))
+		}
+		// Current and remembered passwords cannot be reused
+		for _, p := range passwords {
+			err := auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, p)
+			assert.Equal(t, authgo.ErrPasswordPreviouslyUsed, err, string(p))
+		}
+		// Oldest password has been pruned
+		assert.NoError(t, auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD)))
+	})
+	t.Run("Disabled", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordHistoryLimit(0)
+		assert.NoError(t, auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, passwords[0]))
+		assert.NoError(t, auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD)))
+		assert.NoError(t, auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD)))
+	})
+	t.Run("Rehash", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordHistoryLimit(1)
+		assert.NoError(t, auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, passwords[0]))
+
+		// Upgrading the hash on sign in does not change the history
+		auth.SetPasswordHasher(authgo.NewScryptHasher(10, 8, 1))
+		_, err := auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, passwords[0])
+		assert.Nil(t, err)
+		err = auth.ChangePassword(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+		assert.Equal(t, authgo.ErrPasswordPreviouslyUsed, err)
+	})
+}
+
+func DeactivateAccount(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	account := authtest.NewTestAccount(t, auth)
+	token, _ := authtest.SignIn(t, auth)
+	err := auth.DeactivateAccount(context.Background(), account)
+	assert.NoError(t, err)
+
+	// Should be signed out
+	session := auth.LookupSignInSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.False(t, session.Authenticated)
+
+	// Should not longer authenticate
+	account, err = auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+	assert.Error(t, authgo.ErrCredentialsIncorrect, err)
+	assert.Nil(t, account)
+}
+
+func IsEmailVerified(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+
+	// Unregistered email is never verified
+	assert.False(t, auth.IsEmailVerified(context.Background(), authtest.TEST_EMAIL))
+
+	// New account is not verified
+	authtest.NewTestAccount(t, auth)
+	assert.False(t, auth.IsEmailVerified(context.Background(), authtest.TEST_EMAIL))
+}
+
+func SetEmailVerified(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+
+	// Cannot verify an unregistered email
+	err := auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true)
+	assert.Error(t, authgo.ErrEmailNotRegistered)
+	err = auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, false)
+	assert.Error(t, authgo.ErrEmailNotRegistered)
+
+	// Registered account can be verified
+	authtest.NewTestAccount(t, auth)
+	err = auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true)
+	assert.NoError(t, err)
+	assert.True(t, auth.IsEmailVerified(context.Background(), authtest.TEST_EMAIL))
+
+	// Registered account can be unverified
+	err = auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, false)
+	assert.NoError(t, err)
+	assert.False(t, auth.IsEmailVerified(context.Background(), authtest.TEST_EMAIL))
+}
+
+// countingHasher counts the number of hashes generated by the wrapped hasher.
+type countingHasher struct {
+	authgo.PasswordHasher
+	count int
+}
+
+func (h *countingHasher) Hash(password []byte) ([]byte, error) {
+	h.count++
+	return h.PasswordHasher.Hash(password)
+}
+
+func SetPasswordHasher(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	assert.NotNil(t, auth.PasswordHasher())
+	hasher := authgo.NewArgon2idHasher(1, 1024, 1)
+	auth.SetPasswordHasher(hasher)
+	assert.Equal(t, hasher, auth.PasswordHasher())
+	authtest.NewTestAccount(t, auth)
+	account, err := auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+	assert.Nil(t, err)
+	assert.NotNil(t, account)
+}
+
+func RehashPassword(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	for name, hasher := range map[string]authgo.PasswordHasher{
+		"Bcrypt":   authgo.NewBcryptHasher(5),
+		"Scrypt":   authgo.NewScryptHasher(10, 8, 1),
+		"Argon2id": authgo.NewArgon2idHasher(1, 1024, 1),
+	} {
+		t.Run(name, func(t *testing.T) {
+			auth := a(t)
+			authtest.NewTestAccount(t, auth)
+			counter := &countingHasher{
+				PasswordHasher: hasher,
+			}
+			auth.SetPasswordHasher(counter)
+
+			// Incorrect password does not upgrade the hash
+			_, err := auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte("1234password"))
+			assert.Equal(t, authgo.ErrCredentialsIncorrect, err)
+			assert.Equal(t, 0, counter.count)
+
+			// Correct password upgrades the hash
+			_, err = auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+			assert.Nil(t, err)
+			assert.Equal(t, 1, counter.count)
+
+			// Upgraded hash is current
+			_, err = auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+			assert.Nil(t, err)
+			assert.Equal(t, 1, counter.count)
+		})
+	}
+}
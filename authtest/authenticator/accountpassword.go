@@ -0,0 +1,100 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func AccountPasswordSessionTimeout(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	assert.True(t, auth.AccountPasswordSessionTimeout().Seconds() > 0)
+}
+
+func SetAccountPasswordSessionTimeout(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	auth.SetAccountPasswordSessionTimeout(time.Second * 5)
+	assert.True(t, auth.AccountPasswordSessionTimeout().Seconds() == 5)
+}
+
+func NewAccountPasswordSessionCookie(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := authgo.NewSessionToken()
+	assert.NoError(t, err)
+	cookie := auth.NewAccountPasswordSessionCookie(token)
+	assert.NotNil(t, cookie)
+	assert.Equal(t, authgo.COOKIE_ACCOUNT_PASSWORD, cookie.Name)
+	assert.Equal(t, token, cookie.Value)
+}
+
+func CurrentAccountPasswordSession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("NoCookie", func(t *testing.T) {
+		auth := a(t)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.Nil(t, auth.CurrentAccountPasswordSession(request))
+	})
+	t.Run("NoSession", func(t *testing.T) {
+		auth := a(t)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		token, err := authgo.NewSessionToken()
+		assert.NoError(t, err)
+		cookie := auth.NewAccountPasswordSessionCookie(token)
+		assert.NotNil(t, cookie)
+		request.AddCookie(cookie)
+		assert.Nil(t, auth.CurrentAccountPasswordSession(request))
+	})
+	t.Run("ExpiredSession", func(t *testing.T) {
+		auth := a(t)
+		auth.SetAccountPasswordSessionTimeout(time.Nanosecond)
+		token, err := auth.NewAccountPasswordSession(context.Background(), authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewAccountPasswordSessionCookie(token))
+		time.Sleep(time.Millisecond) // Sleep to ensure expiry
+		assert.Nil(t, auth.CurrentAccountPasswordSession(request))
+	})
+	t.Run("Exists", func(t *testing.T) {
+		auth := a(t)
+		token, err := auth.NewAccountPasswordSession(context.Background(), authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewAccountPasswordSessionCookie(token))
+		session := auth.CurrentAccountPasswordSession(request)
+		require.NotNil(t, session)
+		assert.Equal(t, token, session.Token)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	})
+}
+
+func NewAccountPasswordSession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewAccountPasswordSession(context.Background(), "")
+	assert.NoError(t, err)
+	session := auth.LookupAccountPasswordSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Username)
+	assert.Empty(t, session.Error)
+}
+
+func LookupAccountPasswordSession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	assert.Nil(t, auth.LookupAccountPasswordSession(context.Background(), ""))
+}
+
+func SetAccountPasswordSessionError(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewAccountPasswordSession(context.Background(), "")
+	assert.NoError(t, err)
+	error := "ERR"
+	auth.SetAccountPasswordSessionError(context.Background(), token, error)
+	session := auth.LookupAccountPasswordSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Username)
+	assert.Equal(t, error, session.Error)
+}
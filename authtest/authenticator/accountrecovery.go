@@ -0,0 +1,143 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func AccountRecoverySessionTimeout(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	assert.True(t, auth.AccountRecoverySessionTimeout().Seconds() > 0)
+}
+
+func SetAccountRecoverySessionTimeout(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	auth.SetAccountRecoverySessionTimeout(time.Second * 5)
+	assert.True(t, auth.AccountRecoverySessionTimeout().Seconds() == 5)
+}
+
+func NewAccountRecoverySessionCookie(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := authgo.NewSessionToken()
+	assert.NoError(t, err)
+	cookie := auth.NewAccountRecoverySessionCookie(token)
+	assert.NotNil(t, cookie)
+	assert.Equal(t, authgo.COOKIE_ACCOUNT_RECOVERY, cookie.Name)
+	assert.Equal(t, token, cookie.Value)
+}
+
+func CurrentAccountRecoverySession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("NoCookie", func(t *testing.T) {
+		auth := a(t)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.Nil(t, auth.CurrentAccountRecoverySession(request))
+	})
+	t.Run("NoSession", func(t *testing.T) {
+		auth := a(t)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		token, err := authgo.NewSessionToken()
+		assert.NoError(t, err)
+		cookie := auth.NewAccountRecoverySessionCookie(token)
+		assert.NotNil(t, cookie)
+		request.AddCookie(cookie)
+		assert.Nil(t, auth.CurrentAccountRecoverySession(request))
+	})
+	t.Run("ExpiredSession", func(t *testing.T) {
+		auth := a(t)
+		auth.SetAccountRecoverySessionTimeout(time.Nanosecond)
+		token, err := auth.NewAccountRecoverySession(context.Background())
+		assert.NoError(t, err)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewAccountRecoverySessionCookie(token))
+		time.Sleep(time.Millisecond) // Sleep to ensure expiry
+		assert.Nil(t, auth.CurrentAccountRecoverySession(request))
+	})
+	t.Run("Exists", func(t *testing.T) {
+		auth := a(t)
+		token, err := auth.NewAccountRecoverySession(context.Background())
+		assert.NoError(t, err)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewAccountRecoverySessionCookie(token))
+		session := auth.CurrentAccountRecoverySession(request)
+		require.NotNil(t, session)
+		assert.Equal(t, token, session.Token)
+	})
+}
+
+func NewAccountRecoverySession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewAccountRecoverySession(context.Background())
+	assert.NoError(t, err)
+	session := auth.LookupAccountRecoverySession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Email)
+	assert.Empty(t, session.Username)
+	assert.Empty(t, session.Challenge)
+	assert.Empty(t, session.Error)
+}
+
+func LookupAccountRecoverySession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	assert.Nil(t, auth.LookupAccountRecoverySession(context.Background(), ""))
+}
+
+func SetAccountRecoverySessionError(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewAccountRecoverySession(context.Background())
+	assert.NoError(t, err)
+	error := "ERR"
+	auth.SetAccountRecoverySessionError(context.Background(), token, error)
+	session := auth.LookupAccountRecoverySession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Email)
+	assert.Empty(t, session.Username)
+	assert.Empty(t, session.Challenge)
+	assert.Equal(t, error, session.Error)
+}
+
+func SetAccountRecoverySessionEmail(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewAccountRecoverySession(context.Background())
+	assert.NoError(t, err)
+	auth.SetAccountRecoverySessionEmail(context.Background(), token, authtest.TEST_EMAIL)
+	session := auth.LookupAccountRecoverySession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Equal(t, authtest.TEST_EMAIL, session.Email)
+	assert.Empty(t, session.Username)
+	assert.Empty(t, session.Challenge)
+	assert.Empty(t, session.Error)
+}
+
+func SetAccountRecoverySessionUsername(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewAccountRecoverySession(context.Background())
+	assert.NoError(t, err)
+	auth.SetAccountRecoverySessionUsername(context.Background(), token, authtest.TEST_USERNAME)
+	session := auth.LookupAccountRecoverySession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Email)
+	assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	assert.Empty(t, session.Challenge)
+	assert.Empty(t, session.Error)
+}
+
+func SetAccountRecoverySessionChallenge(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewAccountRecoverySession(context.Background())
+	assert.NoError(t, err)
+	challenge := "ERR"
+	auth.SetAccountRecoverySessionChallenge(context.Background(), token, challenge)
+	session := auth.LookupAccountRecoverySession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Email)
+	assert.Empty(t, session.Username)
+	assert.Equal(t, challenge, session.Challenge)
+	assert.Empty(t, session.Error)
+}
@@ -0,0 +1,159 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func BearerToken(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (authgo.Authenticator, *authgo.BearerTokens) {
+		t.Helper()
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		tokens, err := auth.NewBearerTokens(ctx, authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		return auth, tokens
+	}
+	t.Run("New", func(t *testing.T) {
+		auth, tokens := setup(t)
+		assert.Equal(t, "Bearer", tokens.TokenType)
+		assert.Equal(t, int64(authgo.DEFAULT_BEARER_ACCESS_TOKEN_TIMEOUT/time.Second), tokens.ExpiresIn)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.NotEmpty(t, tokens.RefreshToken)
+		assert.NotEqual(t, tokens.AccessToken, tokens.RefreshToken)
+
+		account, err := auth.LookupBearerAccount(ctx, tokens.AccessToken)
+		assert.Nil(t, err)
+		require.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+		assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+	})
+	t.Run("Refresh Token Is Not An Access Token", func(t *testing.T) {
+		auth, tokens := setup(t)
+		_, err := auth.LookupBearerAccount(ctx, tokens.RefreshToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+	})
+	t.Run("Current Bearer Account", func(t *testing.T) {
+		auth, tokens := setup(t)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		account, pat := auth.CurrentBearerAccount(request)
+		assert.Nil(t, account)
+		assert.Nil(t, pat)
+		request.Header.Set("Authorization", "Bearer invalid")
+		account, _ = auth.CurrentBearerAccount(request)
+		assert.Nil(t, account)
+		request.Header.Set("Authorization", "Basic "+tokens.AccessToken)
+		account, _ = auth.CurrentBearerAccount(request)
+		assert.Nil(t, account)
+		request.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+		account, pat = auth.CurrentBearerAccount(request)
+		require.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+		assert.Nil(t, pat)
+		request.Header.Set("Authorization", "bearer "+tokens.AccessToken)
+		account, _ = auth.CurrentBearerAccount(request)
+		assert.NotNil(t, account)
+	})
+	t.Run("Access Token Expires", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetBearerAccessTokenTimeout(10 * time.Millisecond)
+		assert.Equal(t, 10*time.Millisecond, auth.BearerAccessTokenTimeout())
+		tokens, err := auth.NewBearerTokens(ctx, authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		time.Sleep(20 * time.Millisecond)
+		_, err = auth.LookupBearerAccount(ctx, tokens.AccessToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+
+		// Refreshing issues a new access token
+		refreshed, err := auth.RefreshBearerTokens(ctx, tokens.RefreshToken)
+		require.Nil(t, err)
+		_, err = auth.LookupBearerAccount(ctx, refreshed.AccessToken)
+		assert.Nil(t, err)
+	})
+	t.Run("Refresh", func(t *testing.T) {
+		auth, tokens := setup(t)
+		refreshed, err := auth.RefreshBearerTokens(ctx, tokens.RefreshToken)
+		require.Nil(t, err)
+		assert.NotEqual(t, tokens.AccessToken, refreshed.AccessToken)
+		assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+
+		// Previous tokens are replaced
+		_, err = auth.LookupBearerAccount(ctx, tokens.AccessToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+		_, err = auth.RefreshBearerTokens(ctx, tokens.RefreshToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+
+		account, err := auth.LookupBearerAccount(ctx, refreshed.AccessToken)
+		assert.Nil(t, err)
+		require.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+	})
+	t.Run("Refresh Token Expires", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetBearerRefreshTokenTimeout(10 * time.Millisecond)
+		assert.Equal(t, 10*time.Millisecond, auth.BearerRefreshTokenTimeout())
+		tokens, err := auth.NewBearerTokens(ctx, authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		time.Sleep(20 * time.Millisecond)
+		_, err = auth.RefreshBearerTokens(ctx, tokens.RefreshToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+
+		count, err := auth.DeleteExpiredSessions(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+	t.Run("Revoke Access Token", func(t *testing.T) {
+		auth, tokens := setup(t)
+		assert.Nil(t, auth.RevokeBearerToken(ctx, tokens.AccessToken))
+		_, err := auth.LookupBearerAccount(ctx, tokens.AccessToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+		_, err = auth.RefreshBearerTokens(ctx, tokens.RefreshToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, auth.RevokeBearerToken(ctx, tokens.AccessToken))
+	})
+	t.Run("Revoke Refresh Token", func(t *testing.T) {
+		auth, tokens := setup(t)
+		assert.Nil(t, auth.RevokeBearerToken(ctx, tokens.RefreshToken))
+		_, err := auth.LookupBearerAccount(ctx, tokens.AccessToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+		_, err = auth.RefreshBearerTokens(ctx, tokens.RefreshToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+	})
+	t.Run("Revoke All", func(t *testing.T) {
+		auth, tokens := setup(t)
+		other, err := auth.NewBearerTokens(ctx, authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		assert.Nil(t, auth.RevokeBearerTokens(ctx, authtest.TEST_USERNAME))
+		for _, access := range []string{tokens.AccessToken, other.AccessToken} {
+			_, err := auth.LookupBearerAccount(ctx, access)
+			assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+		}
+	})
+	t.Run("Deactivated Account", func(t *testing.T) {
+		auth, tokens := setup(t)
+		account, err := auth.LookupAccount(ctx, authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		assert.Nil(t, auth.DeactivateAccount(ctx, account))
+		_, err = auth.LookupBearerAccount(ctx, tokens.AccessToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+		_, err = auth.RefreshBearerTokens(ctx, tokens.RefreshToken)
+		assert.Equal(t, authgo.ErrBearerTokenInvalid, err)
+	})
+	t.Run("Context", func(t *testing.T) {
+		assert.Nil(t, authgo.BearerAccount(ctx))
+		account := &authgo.Account{
+			Username: authtest.TEST_USERNAME,
+		}
+		assert.Equal(t, account, authgo.BearerAccount(authgo.WithBearerAccount(ctx, account)))
+	})
+}
@@ -0,0 +1,115 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func CookiePolicy(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("Default", func(t *testing.T) {
+		auth := a(t)
+		for name, cookie := range map[string]*http.Cookie{
+			authgo.COOKIE_SIGN_UP:          auth.NewSignUpSessionCookie("foobar"),
+			authgo.COOKIE_SIGN_IN:          auth.NewSignInSessionCookie("foobar"),
+			authgo.COOKIE_ACCOUNT_PASSWORD: auth.NewAccountPasswordSessionCookie("foobar"),
+			authgo.COOKIE_ACCOUNT_RECOVERY: auth.NewAccountRecoverySessionCookie("foobar"),
+		} {
+			assert.Equal(t, name, cookie.Name)
+			assert.Equal(t, "/", cookie.Path)
+			assert.Empty(t, cookie.Domain)
+			assert.Equal(t, http.SameSiteLaxMode, cookie.SameSite)
+			assert.True(t, cookie.HttpOnly)
+			assert.True(t, cookie.Expires.After(time.Now()))
+		}
+	})
+	t.Run("Custom", func(t *testing.T) {
+		auth := a(t)
+		auth.SetCookiePolicy(&authgo.CookiePolicy{
+			Prefix: authgo.COOKIE_PREFIX_SECURE,
+			Names: map[string]string{
+				authgo.COOKIE_SIGN_IN: "session",
+			},
+			SameSite:   http.SameSiteStrictMode,
+			Domain:     "example.com",
+			Persistent: true,
+		})
+		for name, cookie := range map[string]*http.Cookie{
+			"__Secure-sign-up":          auth.NewSignUpSessionCookie("foobar"),
+			"__Secure-session":          auth.NewSignInSessionCookie("foobar"),
+			"__Secure-account-password": auth.NewAccountPasswordSessionCookie("foobar"),
+			"__Secure-account-recovery": auth.NewAccountRecoverySessionCookie("foobar"),
+		} {
+			assert.Equal(t, name, cookie.Name)
+			assert.Equal(t, "example.com", cookie.Domain)
+			assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+			assert.True(t, cookie.Secure)
+			assert.True(t, cookie.Expires.After(time.Now()))
+		}
+	})
+	t.Run("Host Prefix Ignores Domain", func(t *testing.T) {
+		auth := a(t)
+		auth.SetCookiePolicy(&authgo.CookiePolicy{
+			Prefix: authgo.COOKIE_PREFIX_HOST,
+			Domain: "example.com",
+		})
+		cookie := auth.NewSignInSessionCookie("foobar")
+		assert.Equal(t, "__Host-sign-in", cookie.Name)
+		assert.Equal(t, "/", cookie.Path)
+		assert.Empty(t, cookie.Domain)
+		assert.True(t, cookie.Secure)
+	})
+	t.Run("Session Cookies", func(t *testing.T) {
+		auth := a(t)
+		auth.SetCookiePolicy(&authgo.CookiePolicy{})
+		cookie := auth.NewSignInSessionCookie("foobar")
+		assert.True(t, cookie.Expires.IsZero())
+		assert.Equal(t, 0, cookie.MaxAge)
+	})
+	t.Run("Reads Custom Names", func(t *testing.T) {
+		auth := a(t)
+		auth.SetCookiePolicy(&authgo.CookiePolicy{
+			Prefix: authgo.COOKIE_PREFIX_HOST,
+			Names: map[string]string{
+				authgo.COOKIE_SIGN_IN: "session",
+			},
+		})
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(&http.Cookie{Name: authgo.COOKIE_SIGN_IN, Value: token})
+		assert.Nil(t, auth.CurrentSignInSession(request))
+
+		request = httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewSignInSessionCookie(token))
+		session := auth.CurrentSignInSession(request)
+		require.NotNil(t, session)
+		assert.Equal(t, token, session.Token)
+
+		authtest.AddCSRF(auth, request)
+		_, err := request.Cookie("__Host-csrf")
+		assert.Nil(t, err)
+		assert.Nil(t, auth.VerifyCSRF(request))
+	})
+	t.Run("Expired Cookie", func(t *testing.T) {
+		auth := a(t)
+		auth.SetCookiePolicy(&authgo.CookiePolicy{
+			Prefix:     authgo.COOKIE_PREFIX_SECURE,
+			Domain:     "example.com",
+			Persistent: true,
+		})
+		cookie := auth.CookiePolicy().ExpiredCookie(authgo.COOKIE_SIGN_IN)
+		assert.Equal(t, "__Secure-sign-in", cookie.Name)
+		assert.Empty(t, cookie.Value)
+		assert.Equal(t, "example.com", cookie.Domain)
+		assert.Equal(t, "/", cookie.Path)
+		assert.True(t, cookie.MaxAge < 0)
+		assert.True(t, cookie.Expires.Before(time.Now()))
+	})
+}
@@ -0,0 +1,142 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func CSRF(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	// page gets a token as a page would, returning it with the CSRF cookie set for it
+	page := func(t *testing.T, auth authgo.Authenticator) (string, *http.Cookie) {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		response := httptest.NewRecorder()
+		token := auth.CSRFToken(response, request)
+		assert.NotEmpty(t, token)
+		var csrf *http.Cookie
+		for _, c := range response.Result().Cookies() {
+			if c.Name == authgo.COOKIE_CSRF {
+				csrf = c
+			}
+		}
+		require.NotNil(t, csrf)
+		assert.True(t, csrf.HttpOnly)
+		return token, csrf
+	}
+	post := func(token string, cookies ...*http.Cookie) *http.Request {
+		values := url.Values{}
+		values.Add(authgo.CSRF_FIELD, token)
+		request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(values.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		for _, c := range cookies {
+			request.AddCookie(c)
+		}
+		return request
+	}
+	t.Run("Accepts Token", func(t *testing.T) {
+		auth := a(t)
+		token, csrf := page(t, auth)
+		assert.Nil(t, auth.VerifyCSRF(post(token, csrf)))
+	})
+	t.Run("Accepts Token In Header", func(t *testing.T) {
+		auth := a(t)
+		token, csrf := page(t, auth)
+		request := post("", csrf)
+		request.Header.Set(authgo.CSRF_HEADER, token)
+		assert.Nil(t, auth.VerifyCSRF(request))
+	})
+	t.Run("Reuses Cookie", func(t *testing.T) {
+		auth := a(t)
+		token, csrf := page(t, auth)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(csrf)
+		response := httptest.NewRecorder()
+		assert.Equal(t, token, auth.CSRFToken(response, request))
+		assert.Empty(t, response.Result().Cookies())
+	})
+	t.Run("Rejects Missing Token", func(t *testing.T) {
+		auth := a(t)
+		_, csrf := page(t, auth)
+		assert.Equal(t, authgo.ErrCSRFTokenInvalid, auth.VerifyCSRF(post("", csrf)))
+	})
+	t.Run("Rejects Missing Cookie", func(t *testing.T) {
+		auth := a(t)
+		token, _ := page(t, auth)
+		assert.Equal(t, authgo.ErrCSRFTokenInvalid, auth.VerifyCSRF(post(token)))
+	})
+	t.Run("Rejects Token From Another Cookie", func(t *testing.T) {
+		auth := a(t)
+		token, _ := page(t, auth)
+		_, csrf := page(t, auth)
+		assert.Equal(t, authgo.ErrCSRFTokenInvalid, auth.VerifyCSRF(post(token, csrf)))
+	})
+	t.Run("Rejects Token From Another Session", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		session, _ := authtest.SignIn(t, auth)
+		signIn := auth.NewSignInSessionCookie(session)
+		token, csrf := page(t, auth)
+		// Token was issued before signing in
+		assert.Equal(t, authgo.ErrCSRFTokenInvalid, auth.VerifyCSRF(post(token, csrf, signIn)))
+
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(csrf)
+		request.AddCookie(signIn)
+		token = auth.CSRFToken(httptest.NewRecorder(), request)
+		assert.Nil(t, auth.VerifyCSRF(post(token, csrf, signIn)))
+	})
+	t.Run("Uses Session Set On Response", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		_, csrf := page(t, auth)
+		session, _ := authtest.SignIn(t, auth)
+		signIn := auth.NewSignInSessionCookie(session)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(csrf)
+		response := httptest.NewRecorder()
+		http.SetCookie(response, signIn)
+		token := auth.CSRFToken(response, request)
+		assert.Nil(t, auth.VerifyCSRF(post(token, csrf, signIn)))
+	})
+	t.Run("Checks Origin", func(t *testing.T) {
+		auth := a(t)
+		token, csrf := page(t, auth)
+		request := post(token, csrf)
+		request.Header.Set("Origin", "http://"+request.Host)
+		assert.Nil(t, auth.VerifyCSRF(request))
+
+		request = post(token, csrf)
+		request.Header.Set("Origin", "https://evil.example.com")
+		assert.Equal(t, authgo.ErrCSRFOriginForbidden, auth.VerifyCSRF(request))
+
+		request = post(token, csrf)
+		request.Header.Set("Origin", "null")
+		assert.Equal(t, authgo.ErrCSRFOriginForbidden, auth.VerifyCSRF(request))
+
+		auth.SetTrustedOrigins([]string{"https://www.example.com"})
+		request = post(token, csrf)
+		request.Header.Set("Origin", "https://www.example.com")
+		assert.Nil(t, auth.VerifyCSRF(request))
+	})
+	t.Run("Checks Sec-Fetch-Site", func(t *testing.T) {
+		auth := a(t)
+		token, csrf := page(t, auth)
+		for site, expected := range map[string]error{
+			"same-origin": nil,
+			"none":        nil,
+			"same-site":   authgo.ErrCSRFOriginForbidden,
+			"cross-site":  authgo.ErrCSRFOriginForbidden,
+		} {
+			request := post(token, csrf)
+			request.Header.Set("Sec-Fetch-Site", site)
+			assert.Equal(t, expected, auth.VerifyCSRF(request), site)
+		}
+	})
+}
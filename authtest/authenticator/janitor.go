@@ -0,0 +1,108 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+// newExpiredSessions creates one session of each kind and waits for them all to expire.
+func newExpiredSessions(t *testing.T, auth authgo.Authenticator) (string, string, string, string) {
+	t.Helper()
+	authtest.NewTestAccount(t, auth)
+	auth.SetSignUpSessionTimeout(time.Nanosecond)
+	auth.SetSignInSessionTimeout(time.Nanosecond)
+	auth.SetAccountPasswordSessionTimeout(time.Nanosecond)
+	auth.SetAccountRecoverySessionTimeout(time.Nanosecond)
+	signUp, err := auth.NewSignUpSession(context.Background())
+	assert.Nil(t, err)
+	signIn, err := auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, true)
+	assert.Nil(t, err)
+	accountPassword, err := auth.NewAccountPasswordSession(context.Background(), authtest.TEST_USERNAME)
+	assert.Nil(t, err)
+	accountRecovery, err := auth.NewAccountRecoverySession(context.Background())
+	assert.Nil(t, err)
+	time.Sleep(time.Millisecond) // Sleep to ensure expiry
+	return signUp, signIn, accountPassword, accountRecovery
+}
+
+// assertSessionsDeleted extends the timeouts so that sessions which were merely expired, rather than deleted, would be found again.
+func assertSessionsDeleted(t *testing.T, auth authgo.Authenticator, signUp, signIn, accountPassword, accountRecovery string) {
+	t.Helper()
+	auth.SetSignUpSessionTimeout(time.Hour)
+	auth.SetSignInSessionTimeout(time.Hour)
+	auth.SetAccountPasswordSessionTimeout(time.Hour)
+	auth.SetAccountRecoverySessionTimeout(time.Hour)
+	assert.Nil(t, auth.LookupSignUpSession(context.Background(), signUp))
+	assert.Nil(t, auth.LookupSignInSession(context.Background(), signIn))
+	assert.Nil(t, auth.LookupAccountPasswordSession(context.Background(), accountPassword))
+	assert.Nil(t, auth.LookupAccountRecoverySession(context.Background(), accountRecovery))
+}
+
+func DeleteExpiredSessions(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("None", func(t *testing.T) {
+		auth := a(t)
+		count, err := auth.DeleteExpiredSessions(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), count)
+	})
+	t.Run("Expired", func(t *testing.T) {
+		auth := a(t)
+		signUp, signIn, accountPassword, accountRecovery := newExpiredSessions(t, auth)
+		count, err := auth.DeleteExpiredSessions(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), count)
+		assertSessionsDeleted(t, auth, signUp, signIn, accountPassword, accountRecovery)
+	})
+	t.Run("Unexpired", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		count, err := auth.DeleteExpiredSessions(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(0), count)
+		assert.NotNil(t, auth.LookupSignInSession(context.Background(), token))
+	})
+}
+
+func SessionJanitor(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("DeletesExpired", func(t *testing.T) {
+		auth := a(t)
+		signUp, signIn, accountPassword, accountRecovery := newExpiredSessions(t, auth)
+		auth.StartSessionJanitor(time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		auth.StopSessionJanitor()
+		assertSessionsDeleted(t, auth, signUp, signIn, accountPassword, accountRecovery)
+	})
+	t.Run("Stopped", func(t *testing.T) {
+		auth := a(t)
+		auth.StartSessionJanitor(time.Millisecond)
+		auth.StopSessionJanitor()
+		auth.StopSessionJanitor() // Stopping twice is harmless
+		signUp, signIn, accountPassword, accountRecovery := newExpiredSessions(t, auth)
+		time.Sleep(10 * time.Millisecond)
+		count, err := auth.DeleteExpiredSessions(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, int64(4), count)
+		assertSessionsDeleted(t, auth, signUp, signIn, accountPassword, accountRecovery)
+	})
+	t.Run("Restart", func(t *testing.T) {
+		auth := a(t)
+		signUp, signIn, accountPassword, accountRecovery := newExpiredSessions(t, auth)
+		auth.StartSessionJanitor(time.Hour)
+		auth.StartSessionJanitor(time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		auth.StopSessionJanitor()
+		assertSessionsDeleted(t, auth, signUp, signIn, accountPassword, accountRecovery)
+	})
+	t.Run("Invalid Interval", func(t *testing.T) {
+		auth := a(t)
+		for _, interval := range []time.Duration{0, -time.Second} {
+			auth.StartSessionJanitor(interval) // Falls back to the default interval rather than panicking
+			auth.StopSessionJanitor()
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Lockout(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	wrong := []byte("1234password")
+	right := []byte(authtest.TEST_PASSWORD)
+	authenticate := func(t *testing.T, auth authgo.Authenticator, ctx context.Context, username string, password []byte) error {
+		t.Helper()
+		_, err := auth.AuthenticateAccount(ctx, username, password)
+		return err
+	}
+	t.Run("Account", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetLockoutPolicy(&authgo.LockoutPolicy{
+			AccountThreshold: 3,
+			Duration:         time.Hour,
+			MaximumDuration:  time.Hour,
+		})
+		ctx := context.Background()
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, authgo.ErrCredentialsIncorrect, authenticate(t, auth, ctx, authtest.TEST_USERNAME, wrong))
+		}
+		// Even the right password is refused
+		assert.Equal(t, authgo.ErrAccountLocked, authenticate(t, auth, ctx, authtest.TEST_USERNAME, right))
+		assert.Equal(t, authgo.ErrAccountLocked, authenticate(t, auth, ctx, authtest.TEST_USERNAME, wrong))
+
+		assert.NoError(t, auth.UnlockAccount(ctx, authtest.TEST_USERNAME))
+		assert.NoError(t, authenticate(t, auth, ctx, authtest.TEST_USERNAME, right))
+	})
+	t.Run("SuccessResets", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetLockoutPolicy(&authgo.LockoutPolicy{
+			AccountThreshold: 3,
+			Duration:         time.Hour,
+			MaximumDuration:  time.Hour,
+		})
+		ctx := context.Background()
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, authgo.ErrCredentialsIncorrect, authenticate(t, auth, ctx, authtest.TEST_USERNAME, wrong))
+			assert.Equal(t, authgo.ErrCredentialsIncorrect, authenticate(t, auth, ctx, authtest.TEST_USERNAME, wrong))
+			assert.NoError(t, authenticate(t, auth, ctx, authtest.TEST_USERNAME, right))
+		}
+	})
+	t.Run("Expires", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		// Expiry and doubling are covered by LockoutPolicy.Locked, so only check the policy is applied
+		auth.SetLockoutPolicy(&authgo.LockoutPolicy{
+			AccountThreshold: 1,
+			Duration:         time.Nanosecond,
+			MaximumDuration:  time.Hour,
+		})
+		ctx := context.Background()
+		assert.Equal(t, authgo.ErrCredentialsIncorrect, authenticate(t, auth, ctx, authtest.TEST_USERNAME, wrong))
+		time.Sleep(time.Millisecond) // Sleep to ensure expiry
+		assert.NoError(t, authenticate(t, auth, ctx, authtest.TEST_USERNAME, right))
+	})
+	t.Run("Client", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetLockoutPolicy(&authgo.LockoutPolicy{
+			ClientThreshold: 3,
+			Duration:        time.Hour,
+			MaximumDuration: time.Hour,
+		})
+		attacker := authgo.WithClientAddress(context.Background(), "192.0.2.1")
+		for _, username := range []string{"bob", "carol", "dave"} {
+			assert.Equal(t, authgo.ErrCredentialsIncorrect, authenticate(t, auth, attacker, username, wrong))
+		}
+		assert.Equal(t, authgo.ErrAccountLocked, authenticate(t, auth, attacker, authtest.TEST_USERNAME, right))
+
+		// Other clients are unaffected
+		other := authgo.WithClientAddress(context.Background(), "198.51.100.1")
+		assert.NoError(t, authenticate(t, auth, other, authtest.TEST_USERNAME, right))
+
+		assert.NoError(t, auth.UnlockClient(context.Background(), "192.0.2.1"))
+		assert.NoError(t, authenticate(t, auth, attacker, authtest.TEST_USERNAME, right))
+	})
+	t.Run("SecondFactor", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		secret := authtest.EnableTOTP(t, auth)
+		auth.SetLockoutPolicy(&authgo.LockoutPolicy{
+			AccountThreshold: 3,
+			Duration:         time.Hour,
+			MaximumDuration:  time.Hour,
+		})
+		ctx := context.Background()
+		// A correct password does not reset failed codes
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, authenticate(t, auth, ctx, authtest.TEST_USERNAME, right))
+			assert.Equal(t, authgo.ErrTOTPCodeIncorrect, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, "000000"))
+		}
+		assert.Equal(t, authgo.ErrAccountLocked, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, authtest.NextTOTPCode(secret)))
+		assert.Equal(t, authgo.ErrAccountLocked, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, "aaaa-bbbb-cccc-dddd"))
+
+		assert.NoError(t, auth.UnlockAccount(ctx, authtest.TEST_USERNAME))
+		assert.NoError(t, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, authtest.NextTOTPCode(secret)))
+	})
+	t.Run("Forgotten", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetLockoutPolicy(&authgo.LockoutPolicy{
+			AccountThreshold: 2,
+			Duration:         time.Hour,
+			MaximumDuration:  100 * time.Millisecond,
+		})
+		ctx := context.Background()
+		assert.Equal(t, authgo.ErrCredentialsIncorrect, authenticate(t, auth, ctx, authtest.TEST_USERNAME, wrong))
+		time.Sleep(150 * time.Millisecond)
+
+		// Stale failure is not counted
+		assert.Equal(t, authgo.ErrCredentialsIncorrect, authenticate(t, auth, ctx, authtest.TEST_USERNAME, wrong))
+		assert.NoError(t, authenticate(t, auth, ctx, authtest.TEST_USERNAME, right))
+
+		// Janitor deletes stale failures
+		assert.Equal(t, authgo.ErrCredentialsIncorrect, authenticate(t, auth, ctx, authtest.TEST_USERNAME, wrong))
+		time.Sleep(150 * time.Millisecond)
+		count, err := auth.DeleteExpiredSessions(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+}
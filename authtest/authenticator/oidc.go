@@ -0,0 +1,231 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func OIDC(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	ctx := context.Background()
+	setup := func(t *testing.T) (authgo.Authenticator, *authtest.IdentityProvider) {
+		t.Helper()
+		auth := a(t)
+		idp := authtest.NewIdentityProvider(t)
+		auth.SetOIDCProviders([]*authgo.OIDCProvider{idp.Provider()})
+		return auth, idp
+	}
+	// signIn signs in with the identity provider in a new session, returning the session token
+	signIn := func(t *testing.T, auth authgo.Authenticator, idp *authtest.IdentityProvider) (string, *authgo.Account, error) {
+		t.Helper()
+		session, err := auth.NewSignInSession(ctx, "", false)
+		require.NoError(t, err)
+		u, err := auth.NewOIDCAuthorization(ctx, session, authtest.TEST_OIDC_PROVIDER, "/products")
+		require.NoError(t, err)
+		state, code, err := idp.Authorize(u)
+		require.NoError(t, err)
+		account, next, err := auth.AuthenticateOIDC(ctx, session, state, code)
+		assert.Equal(t, "/products", next)
+		return session, account, err
+	}
+	t.Run("Provider Not Found", func(t *testing.T) {
+		auth, _ := setup(t)
+		_, err := auth.NewOIDCAuthorization(ctx, "foobar", "other", "")
+		assert.Equal(t, authgo.ErrOIDCProviderNotFound, err)
+	})
+	t.Run("Creates Account", func(t *testing.T) {
+		for name, algorithm := range map[string]string{
+			"ES256": authgo.JWT_ALGORITHM_ES256,
+			"RS256": authgo.JWT_ALGORITHM_RS256,
+		} {
+			t.Run(name, func(t *testing.T) {
+				auth, idp := setup(t)
+				idp.Algorithm = algorithm
+				require.NoError(t, idp.RotateKey())
+				_, account, err := signIn(t, auth, idp)
+				require.NoError(t, err)
+				assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+				assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+				assert.True(t, auth.IsEmailVerified(ctx, authtest.TEST_EMAIL))
+
+				// Signing in again uses the same account
+				_, again, err := signIn(t, auth, idp)
+				require.NoError(t, err)
+				assert.Equal(t, account.ID, again.ID)
+			})
+		}
+	})
+	t.Run("Creates Account With Unique Username", func(t *testing.T) {
+		auth, idp := setup(t)
+		_, err := auth.NewAccount(ctx, "alice@example.net", authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+		require.NoError(t, err)
+		idp.PreferredUsername = "a.lice"
+		_, account, err := signIn(t, auth, idp)
+		require.NoError(t, err)
+		assert.Equal(t, "alice2", account.Username)
+	})
+	t.Run("Links Account With Verified Email", func(t *testing.T) {
+		auth, idp := setup(t)
+		acc := authtest.NewTestAccount(t, auth)
+		require.NoError(t, auth.SetEmailVerified(ctx, authtest.TEST_EMAIL, true))
+		notifier := authtest.NewEmailNotifier()
+		auth.SetEmailNotifier(notifier)
+		_, account, err := signIn(t, auth, idp)
+		require.NoError(t, err)
+		assert.Equal(t, acc.ID, account.ID)
+		require.Equal(t, 1, len(notifier.Notifications))
+		assert.Equal(t, authtest.TEST_EMAIL, notifier.Notifications[0].Email)
+	})
+	t.Run("Rejects Account With Unverified Email", func(t *testing.T) {
+		auth, idp := setup(t)
+		authtest.NewTestAccount(t, auth)
+		_, _, err := signIn(t, auth, idp)
+		assert.Equal(t, authgo.ErrEmailAlreadyRegistered, err)
+	})
+	t.Run("Rejects Unverified Provider Email", func(t *testing.T) {
+		auth, idp := setup(t)
+		authtest.NewTestAccount(t, auth)
+		require.NoError(t, auth.SetEmailVerified(ctx, authtest.TEST_EMAIL, true))
+		idp.EmailVerified = false
+		_, _, err := signIn(t, auth, idp)
+		assert.Equal(t, authgo.ErrOIDCEmailNotVerified, err)
+	})
+	t.Run("Links Signed In Account", func(t *testing.T) {
+		auth, idp := setup(t)
+		authtest.NewTestAccount(t, auth)
+		bob, err := auth.NewAccount(ctx, "bob@example.com", "bob", []byte(authtest.TEST_PASSWORD))
+		require.NoError(t, err)
+		session, err := auth.NewSignInSession(ctx, "bob", true)
+		require.NoError(t, err)
+		u, err := auth.NewOIDCAuthorization(ctx, session, authtest.TEST_OIDC_PROVIDER, "")
+		require.NoError(t, err)
+		state, code, err := idp.Authorize(u)
+		require.NoError(t, err)
+		account, _, err := auth.AuthenticateOIDC(ctx, session, state, code)
+		require.NoError(t, err)
+		assert.Equal(t, bob.ID, account.ID)
+
+		// The identity now signs in to bob, despite having alice's email address
+		_, account, err = signIn(t, auth, idp)
+		require.NoError(t, err)
+		assert.Equal(t, bob.ID, account.ID)
+	})
+	t.Run("Rejects Identity Linked To Another Account", func(t *testing.T) {
+		auth, idp := setup(t)
+		_, _, err := signIn(t, auth, idp)
+		require.NoError(t, err)
+		_, err = auth.NewAccount(ctx, "bob@example.com", "bob", []byte(authtest.TEST_PASSWORD))
+		require.NoError(t, err)
+		session, err := auth.NewSignInSession(ctx, "bob", true)
+		require.NoError(t, err)
+		u, err := auth.NewOIDCAuthorization(ctx, session, authtest.TEST_OIDC_PROVIDER, "")
+		require.NoError(t, err)
+		state, code, err := idp.Authorize(u)
+		require.NoError(t, err)
+		_, _, err = auth.AuthenticateOIDC(ctx, session, state, code)
+		assert.Equal(t, authgo.ErrOIDCIdentityAlreadyLinked, err)
+	})
+	t.Run("State", func(t *testing.T) {
+		auth, idp := setup(t)
+		session, err := auth.NewSignInSession(ctx, "", false)
+		require.NoError(t, err)
+		u, err := auth.NewOIDCAuthorization(ctx, session, authtest.TEST_OIDC_PROVIDER, "")
+		require.NoError(t, err)
+		state, code, err := idp.Authorize(u)
+		require.NoError(t, err)
+
+		_, _, err = auth.AuthenticateOIDC(ctx, session, "foobar", code)
+		assert.Equal(t, authgo.ErrOIDCAuthorizationExpired, err)
+
+		// The state belongs to the session which began the authorization
+		other, err := auth.NewSignInSession(ctx, "", false)
+		require.NoError(t, err)
+		_, _, err = auth.AuthenticateOIDC(ctx, other, state, code)
+		assert.Equal(t, authgo.ErrOIDCAuthorizationExpired, err)
+
+		_, _, err = auth.AuthenticateOIDC(ctx, session, state, code)
+		assert.NoError(t, err)
+
+		// The state can only be used once
+		_, _, err = auth.AuthenticateOIDC(ctx, session, state, code)
+		assert.Equal(t, authgo.ErrOIDCAuthorizationExpired, err)
+	})
+	t.Run("Code", func(t *testing.T) {
+		auth, idp := setup(t)
+		session, err := auth.NewSignInSession(ctx, "", false)
+		require.NoError(t, err)
+		u, err := auth.NewOIDCAuthorization(ctx, session, authtest.TEST_OIDC_PROVIDER, "")
+		require.NoError(t, err)
+		state, _, err := idp.Authorize(u)
+		require.NoError(t, err)
+		_, _, err = auth.AuthenticateOIDC(ctx, session, state, "foobar")
+		assert.True(t, errors.Is(err, authgo.ErrOIDCProviderFailed), err)
+	})
+	t.Run("ID Token", func(t *testing.T) {
+		for name, modify := range map[string]func(map[string]interface{}){
+			"Issuer": func(c map[string]interface{}) {
+				c["iss"] = "https://example.org"
+			},
+			"Audience": func(c map[string]interface{}) {
+				c["aud"] = "other"
+			},
+			"Authorized Party": func(c map[string]interface{}) {
+				c["aud"] = []string{authtest.TEST_OIDC_CLIENT_ID, "other"}
+				c["azp"] = "other"
+			},
+			"Expired": func(c map[string]interface{}) {
+				c["exp"] = time.Now().Add(-time.Hour).Unix()
+			},
+			"Issued In Future": func(c map[string]interface{}) {
+				c["iat"] = time.Now().Add(time.Hour).Unix()
+			},
+			"Nonce": func(c map[string]interface{}) {
+				c["nonce"] = "foobar"
+			},
+			"Subject": func(c map[string]interface{}) {
+				delete(c, "sub")
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				auth, idp := setup(t)
+				idp.Claims = modify
+				_, _, err := signIn(t, auth, idp)
+				assert.True(t, errors.Is(err, authgo.ErrOIDCTokenInvalid), err)
+			})
+		}
+		t.Run("Multiple Audiences", func(t *testing.T) {
+			auth, idp := setup(t)
+			idp.Claims = func(c map[string]interface{}) {
+				c["aud"] = []string{authtest.TEST_OIDC_CLIENT_ID, "other"}
+				c["azp"] = authtest.TEST_OIDC_CLIENT_ID
+			}
+			_, _, err := signIn(t, auth, idp)
+			assert.NoError(t, err)
+		})
+	})
+	t.Run("Key Rotation", func(t *testing.T) {
+		auth, idp := setup(t)
+		_, account, err := signIn(t, auth, idp)
+		require.NoError(t, err)
+		require.NoError(t, idp.RotateKey())
+		_, again, err := signIn(t, auth, idp)
+		require.NoError(t, err)
+		assert.Equal(t, account.ID, again.ID)
+	})
+	t.Run("Lockout", func(t *testing.T) {
+		auth, idp := setup(t)
+		_, _, err := signIn(t, auth, idp)
+		require.NoError(t, err)
+		for i := 0; i < authgo.DEFAULT_ACCOUNT_LOCKOUT_THRESHOLD; i++ {
+			_, err := auth.AuthenticateAccount(ctx, authtest.TEST_USERNAME, []byte("foobarfoobar"))
+			assert.Equal(t, authgo.ErrCredentialsIncorrect, err)
+		}
+		_, _, err = signIn(t, auth, idp)
+		assert.Equal(t, authgo.ErrAccountLocked, err)
+	})
+}
@@ -0,0 +1,414 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifyOIDCToken verifies the token with the authenticator's published keys, returning its header and claims.
+func verifyOIDCToken(t *testing.T, auth authgo.Authenticator, token string) (*authgo.JWTHeader, map[string]interface{}) {
+	t.Helper()
+	header, payload, input, signature, err := authgo.ParseJWT(token)
+	require.NoError(t, err)
+	keys, err := auth.OIDCJSONWebKeySet(context.Background())
+	require.NoError(t, err)
+	var key *authgo.JSONWebKey
+	for _, k := range keys.Keys {
+		if k.Kid == header.Kid {
+			key = k
+		}
+	}
+	require.NotNil(t, key, "key %s not published", header.Kid)
+	public, err := key.PublicKey()
+	require.NoError(t, err)
+	require.NoError(t, authgo.VerifyJWTSignature(header.Alg, public, input, signature))
+	claims := make(map[string]interface{})
+	require.NoError(t, json.Unmarshal(payload, &claims))
+	return header, claims
+}
+
+func OIDCServer(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	ctx := context.Background()
+	// redeem grants the request to the test user, and redeems the code for tokens
+	redeem := func(t *testing.T, auth authgo.Authenticator, client *authgo.OIDCClient, request *authgo.OIDCAuthorizationRequest) *authgo.OIDCTokens {
+		t.Helper()
+		_, err := auth.VerifyOIDCAuthorizationRequest(ctx, request)
+		require.NoError(t, err)
+		code, err := auth.NewOIDCCode(ctx, authtest.TEST_USERNAME, request)
+		require.NoError(t, err)
+		tokens, err := auth.RedeemOIDCCode(ctx, client, code, request.RedirectURI, authtest.TEST_OIDC_VERIFIER)
+		require.NoError(t, err)
+		return tokens
+	}
+	t.Run("Issuer Not Configured", func(t *testing.T) {
+		auth := a(t)
+		_, err := auth.OIDCConfiguration()
+		assert.Equal(t, authgo.ErrOIDCIssuerNotConfigured, err)
+		_, err = auth.VerifyOIDCAuthorizationRequest(ctx, &authgo.OIDCAuthorizationRequest{})
+		assert.Equal(t, authgo.ErrOIDCIssuerNotConfigured, err)
+	})
+	t.Run("Configuration", func(t *testing.T) {
+		auth := a(t)
+		auth.SetOIDCIssuer(authtest.TEST_OIDC_ISSUER + "/")
+		c, err := auth.OIDCConfiguration()
+		require.NoError(t, err)
+		assert.Equal(t, authtest.TEST_OIDC_ISSUER, c.Issuer)
+		assert.Equal(t, authtest.TEST_OIDC_ISSUER+"/authorize", c.AuthorizationEndpoint)
+		assert.Equal(t, authtest.TEST_OIDC_ISSUER+"/token", c.TokenEndpoint)
+		assert.Equal(t, authtest.TEST_OIDC_ISSUER+"/userinfo", c.UserInfoEndpoint)
+		assert.Equal(t, authtest.TEST_OIDC_ISSUER+"/jwks", c.JWKSURI)
+	})
+	t.Run("New Client", func(t *testing.T) {
+		auth := a(t)
+		client, secret, err := auth.NewOIDCClient(ctx, authtest.TEST_OIDC_CLIENT_NAME, []string{authtest.TEST_OIDC_CLIENT_REDIRECT_URI}, true)
+		require.NoError(t, err)
+		assert.NotEmpty(t, client.ID)
+		assert.NotEmpty(t, secret)
+		assert.True(t, client.Confidential())
+		found, err := auth.LookupOIDCClient(ctx, client.ID)
+		require.NoError(t, err)
+		assert.Equal(t, authtest.TEST_OIDC_CLIENT_NAME, found.Name)
+		assert.Equal(t, []string{authtest.TEST_OIDC_CLIENT_REDIRECT_URI}, found.RedirectURIs)
+		assert.NotEqual(t, []byte(secret), found.Secret)
+
+		public, secret, err := auth.NewOIDCClient(ctx, "Mobile App", []string{"com.example.app:/callback"}, false)
+		require.NoError(t, err)
+		assert.Empty(t, secret)
+		assert.False(t, public.Confidential())
+
+		require.NoError(t, auth.DeleteOIDCClient(ctx, client.ID))
+		_, err = auth.LookupOIDCClient(ctx, client.ID)
+		assert.Equal(t, authgo.ErrOIDCClientNotFound, err)
+		assert.Equal(t, authgo.ErrOIDCClientNotFound, auth.DeleteOIDCClient(ctx, client.ID))
+	})
+	t.Run("New Client Redirect URI", func(t *testing.T) {
+		auth := a(t)
+		for name, uris := range map[string][]string{
+			"None":     nil,
+			"Relative": {"/callback"},
+			"Fragment": {"https://app.example.com/callback#foo"},
+			"Space":    {"https://app.example.com/call back"},
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, _, err := auth.NewOIDCClient(ctx, authtest.TEST_OIDC_CLIENT_NAME, uris, true)
+				assert.True(t, errors.Is(err, authgo.ErrOIDCRedirectURIInvalid), err)
+			})
+		}
+	})
+	t.Run("Authenticate Client", func(t *testing.T) {
+		auth := a(t)
+		client, secret := authtest.NewOIDCClient(t, auth)
+		c, err := auth.AuthenticateOIDCClient(ctx, client.ID, secret)
+		require.NoError(t, err)
+		assert.Equal(t, client.ID, c.ID)
+		_, err = auth.AuthenticateOIDCClient(ctx, client.ID, "foobar")
+		assert.Equal(t, authgo.ErrOIDCClientUnauthorized, err)
+		_, err = auth.AuthenticateOIDCClient(ctx, client.ID, "")
+		assert.Equal(t, authgo.ErrOIDCClientUnauthorized, err)
+		_, err = auth.AuthenticateOIDCClient(ctx, "foobar", secret)
+		assert.Equal(t, authgo.ErrOIDCClientUnauthorized, err)
+
+		public, _, err := auth.NewOIDCClient(ctx, "Mobile App", []string{"com.example.app:/callback"}, false)
+		require.NoError(t, err)
+		_, err = auth.AuthenticateOIDCClient(ctx, public.ID, "")
+		assert.NoError(t, err)
+		_, err = auth.AuthenticateOIDCClient(ctx, public.ID, "foobar")
+		assert.Equal(t, authgo.ErrOIDCClientUnauthorized, err)
+	})
+	t.Run("Authorization Request", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			modify func(*authgo.OIDCAuthorizationRequest)
+			err    error
+		}{
+			"Client":                 {func(r *authgo.OIDCAuthorizationRequest) { r.ClientID = "foobar" }, authgo.ErrOIDCClientNotFound},
+			"Redirect URI":           {func(r *authgo.OIDCAuthorizationRequest) { r.RedirectURI = "https://evil.example.com/callback" }, authgo.ErrOIDCRedirectURIInvalid},
+			"Response Type":          {func(r *authgo.OIDCAuthorizationRequest) { r.ResponseType = "token" }, authgo.ErrOIDCResponseTypeUnsupported},
+			"Missing OpenID Scope":   {func(r *authgo.OIDCAuthorizationRequest) { r.Scope = "email" }, authgo.ErrOIDCScopeInvalid},
+			"Unsupported Scope":      {func(r *authgo.OIDCAuthorizationRequest) { r.Scope = "openid admin" }, authgo.ErrOIDCScopeInvalid},
+			"Missing Code Challenge": {func(r *authgo.OIDCAuthorizationRequest) { r.CodeChallenge = "" }, authgo.ErrOIDCRequestInvalid},
+			"Plain Code Challenge":   {func(r *authgo.OIDCAuthorizationRequest) { r.CodeChallengeMethod = "plain" }, authgo.ErrOIDCRequestInvalid},
+		} {
+			t.Run(name, func(t *testing.T) {
+				auth := a(t)
+				client, _ := authtest.NewOIDCClient(t, auth)
+				request := authtest.NewOIDCAuthorizationRequest(client)
+				tt.modify(request)
+				_, err := auth.VerifyOIDCAuthorizationRequest(ctx, request)
+				assert.True(t, errors.Is(err, tt.err), err)
+			})
+		}
+	})
+	t.Run("Redeem Code", func(t *testing.T) {
+		auth := a(t)
+		acc := authtest.NewTestAccount(t, auth)
+		require.NoError(t, auth.SetEmailVerified(ctx, authtest.TEST_EMAIL, true))
+		client, _ := authtest.NewOIDCClient(t, auth)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		assert.Equal(t, "Bearer", tokens.TokenType)
+		assert.Equal(t, int64(authgo.OIDC_ACCESS_TOKEN_TIMEOUT/time.Second), tokens.ExpiresIn)
+		assert.Equal(t, "openid email profile", tokens.Scope)
+		assert.NotEmpty(t, tokens.RefreshToken)
+
+		header, claims := verifyOIDCToken(t, auth, tokens.IDToken)
+		assert.Equal(t, authgo.JWT_ALGORITHM_RS256, header.Alg)
+		assert.Equal(t, authtest.TEST_OIDC_ISSUER, claims["iss"])
+		assert.Equal(t, client.ID, claims["aud"])
+		assert.Equal(t, authtest.TEST_USERNAME, claims["sub"])
+		assert.Equal(t, authtest.TEST_OIDC_NONCE, claims["nonce"])
+		assert.Equal(t, authtest.TEST_EMAIL, claims["email"])
+		assert.Equal(t, true, claims["email_verified"])
+		assert.Equal(t, authtest.TEST_USERNAME, claims["preferred_username"])
+
+		header, _ = verifyOIDCToken(t, auth, tokens.AccessToken)
+		assert.Equal(t, authgo.OIDC_ACCESS_TOKEN_TYPE, header.Typ)
+		account, scopes, err := auth.VerifyOIDCAccessToken(ctx, tokens.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, acc.ID, account.ID)
+		assert.Equal(t, []string{"openid", "email", "profile"}, scopes)
+
+		// The ID token is not an access token
+		_, _, err = auth.VerifyOIDCAccessToken(ctx, tokens.IDToken)
+		assert.True(t, errors.Is(err, authgo.ErrOIDCAccessTokenInvalid), err)
+	})
+	t.Run("User Info", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		info, err := auth.LookupOIDCUserInfo(ctx, tokens.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"sub":                authtest.TEST_USERNAME,
+			"email":              authtest.TEST_EMAIL,
+			"email_verified":     false,
+			"preferred_username": authtest.TEST_USERNAME,
+		}, info)
+
+		// Claims are limited to the granted scopes
+		request := authtest.NewOIDCAuthorizationRequest(client)
+		request.Scope = "openid"
+		tokens = redeem(t, auth, client, request)
+		info, err = auth.LookupOIDCUserInfo(ctx, tokens.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"sub": authtest.TEST_USERNAME,
+		}, info)
+	})
+	t.Run("Code", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			client   func(*testing.T, authgo.Authenticator, *authgo.OIDCClient) *authgo.OIDCClient
+			redirect string
+			verifier string
+		}{
+			"Client": {
+				client: func(t *testing.T, auth authgo.Authenticator, c *authgo.OIDCClient) *authgo.OIDCClient {
+					other, _, err := auth.NewOIDCClient(ctx, "Other", []string{authtest.TEST_OIDC_CLIENT_REDIRECT_URI}, true)
+					require.NoError(t, err)
+					return other
+				},
+			},
+			"Redirect URI": {
+				redirect: "https://app.example.com/other",
+			},
+			"Verifier": {
+				verifier: "foobar",
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				auth := a(t)
+				authtest.NewTestAccount(t, auth)
+				client, _ := authtest.NewOIDCClient(t, auth)
+				request := authtest.NewOIDCAuthorizationRequest(client)
+				code, err := auth.NewOIDCCode(ctx, authtest.TEST_USERNAME, request)
+				require.NoError(t, err)
+				c := client
+				if tt.client != nil {
+					c = tt.client(t, auth, client)
+				}
+				redirect := request.RedirectURI
+				if tt.redirect != "" {
+					redirect = tt.redirect
+				}
+				verifier := authtest.TEST_OIDC_VERIFIER
+				if tt.verifier != "" {
+					verifier = tt.verifier
+				}
+				_, err = auth.RedeemOIDCCode(ctx, c, code, redirect, verifier)
+				assert.True(t, errors.Is(err, authgo.ErrOIDCGrantInvalid), err)
+
+				// A failed attempt uses up the code
+				_, err = auth.RedeemOIDCCode(ctx, client, code, request.RedirectURI, authtest.TEST_OIDC_VERIFIER)
+				assert.True(t, errors.Is(err, authgo.ErrOIDCGrantInvalid), err)
+			})
+		}
+		t.Run("Single Use", func(t *testing.T) {
+			auth := a(t)
+			authtest.NewTestAccount(t, auth)
+			client, _ := authtest.NewOIDCClient(t, auth)
+			request := authtest.NewOIDCAuthorizationRequest(client)
+			code, err := auth.NewOIDCCode(ctx, authtest.TEST_USERNAME, request)
+			require.NoError(t, err)
+			_, err = auth.RedeemOIDCCode(ctx, client, code, request.RedirectURI, authtest.TEST_OIDC_VERIFIER)
+			require.NoError(t, err)
+			_, err = auth.RedeemOIDCCode(ctx, client, code, request.RedirectURI, authtest.TEST_OIDC_VERIFIER)
+			assert.True(t, errors.Is(err, authgo.ErrOIDCGrantInvalid), err)
+		})
+	})
+	t.Run("Refresh", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+
+		refreshed, err := auth.RefreshOIDCTokens(ctx, client, tokens.RefreshToken, "")
+		require.NoError(t, err)
+		assert.Equal(t, tokens.Scope, refreshed.Scope)
+		assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+		_, claims := verifyOIDCToken(t, auth, refreshed.IDToken)
+		assert.Equal(t, authtest.TEST_USERNAME, claims["sub"])
+		_, _, err = auth.VerifyOIDCAccessToken(ctx, refreshed.AccessToken)
+		assert.NoError(t, err)
+
+		// Refresh tokens are replaced when used
+		_, err = auth.RefreshOIDCTokens(ctx, client, tokens.RefreshToken, "")
+		assert.True(t, errors.Is(err, authgo.ErrOIDCGrantInvalid), err)
+
+		// Scopes cannot be extended
+		_, err = auth.RefreshOIDCTokens(ctx, client, refreshed.RefreshToken, "openid admin")
+		assert.True(t, errors.Is(err, authgo.ErrOIDCScopeInvalid), err)
+	})
+	t.Run("Refresh Reduces Scope", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		refreshed, err := auth.RefreshOIDCTokens(ctx, client, tokens.RefreshToken, "openid")
+		require.NoError(t, err)
+		assert.Equal(t, "openid", refreshed.Scope)
+		_, scopes, err := auth.VerifyOIDCAccessToken(ctx, refreshed.AccessToken)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"openid"}, scopes)
+	})
+	t.Run("Refresh Other Client", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		other, _, err := auth.NewOIDCClient(ctx, "Other", []string{authtest.TEST_OIDC_CLIENT_REDIRECT_URI}, true)
+		require.NoError(t, err)
+		_, err = auth.RefreshOIDCTokens(ctx, other, tokens.RefreshToken, "")
+		assert.True(t, errors.Is(err, authgo.ErrOIDCGrantInvalid), err)
+		// The token remains usable by its own client
+		_, err = auth.RefreshOIDCTokens(ctx, client, tokens.RefreshToken, "")
+		assert.NoError(t, err)
+	})
+	t.Run("Deactivated Account", func(t *testing.T) {
+		auth := a(t)
+		acc := authtest.NewTestAccount(t, auth)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		require.NoError(t, auth.DeactivateAccount(ctx, acc))
+		_, _, err := auth.VerifyOIDCAccessToken(ctx, tokens.AccessToken)
+		assert.True(t, errors.Is(err, authgo.ErrOIDCAccessTokenInvalid), err)
+		_, err = auth.RefreshOIDCTokens(ctx, client, tokens.RefreshToken, "")
+		assert.True(t, errors.Is(err, authgo.ErrOIDCGrantInvalid), err)
+	})
+	t.Run("Deleted Client", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		require.NoError(t, auth.DeleteOIDCClient(ctx, client.ID))
+		_, _, err := auth.VerifyOIDCAccessToken(ctx, tokens.AccessToken)
+		assert.True(t, errors.Is(err, authgo.ErrOIDCAccessTokenInvalid), err)
+	})
+	t.Run("Access Token", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		parts := strings.Split(tokens.AccessToken, ".")
+		for name, token := range map[string]string{
+			"Empty":     "",
+			"Malformed": "foobar",
+			"Signature": parts[0] + "." + parts[1] + ".c2lnbmF0dXJl",
+		} {
+			t.Run(name, func(t *testing.T) {
+				_, _, err := auth.VerifyOIDCAccessToken(ctx, token)
+				assert.True(t, errors.Is(err, authgo.ErrOIDCAccessTokenInvalid), err)
+			})
+		}
+		t.Run("Issuer", func(t *testing.T) {
+			auth.SetOIDCIssuer("https://example.org")
+			defer auth.SetOIDCIssuer(authtest.TEST_OIDC_ISSUER)
+			_, _, err := auth.VerifyOIDCAccessToken(ctx, tokens.AccessToken)
+			assert.True(t, errors.Is(err, authgo.ErrOIDCAccessTokenInvalid), err)
+		})
+	})
+	t.Run("Key Rotation", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		before, _ := verifyOIDCToken(t, auth, tokens.IDToken)
+
+		require.NoError(t, auth.RotateOIDCSigningKey(ctx))
+		keys, err := auth.OIDCJSONWebKeySet(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 2, len(keys.Keys))
+
+		// Tokens signed with the previous key remain valid
+		_, _, err = auth.VerifyOIDCAccessToken(ctx, tokens.AccessToken)
+		assert.NoError(t, err)
+
+		tokens = redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		after, _ := verifyOIDCToken(t, auth, tokens.IDToken)
+		assert.NotEqual(t, before.Kid, after.Kid)
+	})
+	t.Run("Key Expiry", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		auth.SetOIDCKeyRotation(10 * time.Millisecond)
+		tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+		header, _, _, _, err := authgo.ParseJWT(tokens.AccessToken)
+		require.NoError(t, err)
+		time.Sleep(25 * time.Millisecond) // Sleep until the key is no longer published
+
+		_, _, err = auth.VerifyOIDCAccessToken(ctx, tokens.AccessToken)
+		assert.True(t, errors.Is(err, authgo.ErrOIDCAccessTokenInvalid), err)
+
+		count, err := auth.DeleteExpiredSessions(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+
+		// A new key is created to replace it
+		keys, err := auth.OIDCJSONWebKeySet(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(keys.Keys))
+		assert.NotEqual(t, header.Kid, keys.Keys[0].Kid)
+	})
+	t.Run("Invalid Key Rotation", func(t *testing.T) {
+		for _, rotation := range []time.Duration{0, -time.Second} {
+			auth := a(t)
+			authtest.NewTestAccount(t, auth)
+			client, _ := authtest.NewOIDCClient(t, auth)
+			auth.SetOIDCKeyRotation(rotation)
+			assert.Equal(t, authgo.DEFAULT_OIDC_KEY_ROTATION, auth.OIDCKeyRotation())
+			tokens := redeem(t, auth, client, authtest.NewOIDCAuthorizationRequest(client))
+			_, _, err := auth.VerifyOIDCAccessToken(ctx, tokens.AccessToken)
+			assert.NoError(t, err)
+			count, err := auth.DeleteExpiredSessions(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), count)
+		}
+	})
+}
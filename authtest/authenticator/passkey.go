@@ -0,0 +1,376 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Passkey(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	ctx := context.Background()
+	t.Run("Register", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		authtest.NewTestAccount(t, auth)
+		device := authtest.NewPasskeyAuthenticator()
+
+		challenge, err := authgo.NewPasskeyChallenge()
+		assert.NoError(t, err)
+		options, err := auth.NewPasskeyCreationOptions(ctx, authtest.TEST_EMAIL, authtest.TEST_USERNAME, challenge)
+		assert.NoError(t, err)
+		assert.Equal(t, challenge, options.Challenge)
+		assert.Equal(t, authtest.TEST_RELYING_PARTY_ID, options.RP.ID)
+		assert.Equal(t, authtest.TEST_USERNAME, options.User.Name)
+		assert.NotEmpty(t, options.User.ID)
+		assert.Equal(t, "none", options.Attestation)
+		assert.Empty(t, options.ExcludeCredentials)
+
+		response, err := device.Create(options)
+		assert.NoError(t, err)
+		passkey, err := auth.VerifyPasskeyCreation(challenge, response)
+		assert.NoError(t, err)
+		require.NotNil(t, passkey)
+		assert.NotEmpty(t, passkey.ID)
+		assert.NotEmpty(t, passkey.PublicKey)
+		assert.Equal(t, authgo.DEFAULT_PASSKEY_NAME, passkey.Name)
+		passkey.Name = "Laptop"
+		assert.NoError(t, auth.AddPasskey(ctx, authtest.TEST_USERNAME, passkey))
+
+		passkeys, err := auth.LookupPasskeys(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		require.Equal(t, 1, len(passkeys))
+		assert.Equal(t, passkey.ID, passkeys[0].ID)
+		assert.Equal(t, "Laptop", passkeys[0].Name)
+		assert.Equal(t, passkey.PublicKey, passkeys[0].PublicKey)
+		assert.True(t, passkeys[0].Used.IsZero())
+
+		// Cannot register the same passkey twice
+		assert.Equal(t, authgo.ErrPasskeyAlreadyRegistered, auth.AddPasskey(ctx, authtest.TEST_USERNAME, passkey))
+
+		// Existing passkeys are excluded from further registrations
+		challenge, err = authgo.NewPasskeyChallenge()
+		assert.NoError(t, err)
+		options, err = auth.NewPasskeyCreationOptions(ctx, authtest.TEST_EMAIL, authtest.TEST_USERNAME, challenge)
+		assert.NoError(t, err)
+		require.Equal(t, 1, len(options.ExcludeCredentials))
+		assert.Equal(t, passkey.ID, options.ExcludeCredentials[0].ID)
+		_, err = device.Create(options)
+		assert.Equal(t, authtest.ErrPasskeyExcluded, err)
+	})
+	t.Run("Register Invalid", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			modify func(*authtest.PasskeyAuthenticator, *authgo.PasskeyCreationOptions) string
+			err    error
+		}{
+			"Wrong Challenge": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyCreationOptions) string {
+					issued := o.Challenge
+					o.Challenge = newPasskeyChallenge(t, time.Now())
+					return issued
+				},
+				err: authgo.ErrPasskeyInvalid,
+			},
+			"Expired Challenge": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyCreationOptions) string {
+					o.Challenge = newPasskeyChallenge(t, time.Now().Add(-2*authgo.PASSKEY_TIMEOUT))
+					return o.Challenge
+				},
+				err: authgo.ErrPasskeyExpired,
+			},
+			"Wrong Origin": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyCreationOptions) string {
+					d.Origin = "https://example.net"
+					return o.Challenge
+				},
+				err: authgo.ErrPasskeyInvalid,
+			},
+			"Wrong Relying Party": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyCreationOptions) string {
+					o.RP.ID = "example.net"
+					return o.Challenge
+				},
+				err: authgo.ErrPasskeyInvalid,
+			},
+			"User Not Verified": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyCreationOptions) string {
+					d.UserVerified = false
+					return o.Challenge
+				},
+				err: authgo.ErrPasskeyInvalid,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				auth := a(t)
+				auth.SetRelyingParty(authtest.NewRelyingParty())
+				authtest.NewTestAccount(t, auth)
+				device := authtest.NewPasskeyAuthenticator()
+				challenge, err := authgo.NewPasskeyChallenge()
+				assert.NoError(t, err)
+				options, err := auth.NewPasskeyCreationOptions(ctx, authtest.TEST_EMAIL, authtest.TEST_USERNAME, challenge)
+				assert.NoError(t, err)
+				issued := tt.modify(device, options)
+				response, err := device.Create(options)
+				assert.NoError(t, err)
+				_, err = auth.VerifyPasskeyCreation(issued, response)
+				assert.True(t, errors.Is(err, tt.err), err)
+			})
+		}
+	})
+	t.Run("Authenticate", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		authtest.NewTestAccount(t, auth)
+		device := authtest.NewPasskeyAuthenticator()
+		passkey := registerPasskey(t, auth, device)
+
+		account, err := authenticatePasskey(t, auth, device)
+		assert.NoError(t, err)
+		require.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+		assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+
+		passkeys, err := auth.LookupPasskeys(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		require.Equal(t, 1, len(passkeys))
+		assert.Equal(t, passkey.ID, passkeys[0].ID)
+		assert.Equal(t, int64(device.SignCount), passkeys[0].SignCount)
+		assert.False(t, passkeys[0].Used.IsZero())
+
+		// Each sign in increments the counter
+		_, err = authenticatePasskey(t, auth, device)
+		assert.NoError(t, err)
+	})
+	t.Run("Authenticate Invalid", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			modify func(*authtest.PasskeyAuthenticator, *authgo.PasskeyRequestOptions) string
+			err    error
+		}{
+			"Wrong Challenge": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyRequestOptions) string {
+					issued := o.Challenge
+					o.Challenge = newPasskeyChallenge(t, time.Now())
+					return issued
+				},
+				err: authgo.ErrPasskeyInvalid,
+			},
+			"Expired Challenge": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyRequestOptions) string {
+					o.Challenge = newPasskeyChallenge(t, time.Now().Add(-2*authgo.PASSKEY_TIMEOUT))
+					return o.Challenge
+				},
+				err: authgo.ErrPasskeyExpired,
+			},
+			"Wrong Origin": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyRequestOptions) string {
+					d.Origin = "https://example.net"
+					return o.Challenge
+				},
+				err: authgo.ErrPasskeyInvalid,
+			},
+			"User Not Verified": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyRequestOptions) string {
+					d.UserVerified = false
+					return o.Challenge
+				},
+				err: authgo.ErrPasskeyInvalid,
+			},
+			"Cloned": {
+				modify: func(d *authtest.PasskeyAuthenticator, o *authgo.PasskeyRequestOptions) string {
+					// A clone has not seen the latest signatures
+					d.SignCount = 0
+					return o.Challenge
+				},
+				err: authgo.ErrPasskeyCloned,
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				auth := a(t)
+				auth.SetRelyingParty(authtest.NewRelyingParty())
+				authtest.NewTestAccount(t, auth)
+				device := authtest.NewPasskeyAuthenticator()
+				registerPasskey(t, auth, device)
+				_, err := authenticatePasskey(t, auth, device)
+				assert.NoError(t, err)
+
+				challenge, err := authgo.NewPasskeyChallenge()
+				assert.NoError(t, err)
+				options, err := auth.NewPasskeyRequestOptions(challenge)
+				assert.NoError(t, err)
+				issued := tt.modify(device, options)
+				response, err := device.Get(options)
+				assert.NoError(t, err)
+				account, err := auth.AuthenticatePasskey(ctx, issued, response)
+				assert.True(t, errors.Is(err, tt.err), err)
+				assert.Nil(t, account)
+			})
+		}
+	})
+	t.Run("Authenticate Forged Signature", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		authtest.NewTestAccount(t, auth)
+		device := authtest.NewPasskeyAuthenticator()
+		registerPasskey(t, auth, device)
+
+		// Another authenticator cannot sign for the registered passkey
+		forger := authtest.NewPasskeyAuthenticator()
+		registerPasskey(t, auth, forger)
+		challenge, err := authgo.NewPasskeyChallenge()
+		assert.NoError(t, err)
+		options, err := auth.NewPasskeyRequestOptions(challenge)
+		assert.NoError(t, err)
+		genuine, err := device.Get(options)
+		assert.NoError(t, err)
+		forged, err := forger.Get(options)
+		assert.NoError(t, err)
+		var g, f authgo.PasskeyCredential
+		assert.NoError(t, json.Unmarshal(genuine, &g))
+		assert.NoError(t, json.Unmarshal(forged, &f))
+		f.ID, f.RawID = g.ID, g.RawID
+		forged, err = json.Marshal(&f)
+		assert.NoError(t, err)
+		_, err = auth.AuthenticatePasskey(ctx, challenge, forged)
+		assert.True(t, errors.Is(err, authgo.ErrPasskeyInvalid), err)
+	})
+	t.Run("Unrecognized", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		authtest.NewTestAccount(t, auth)
+		device := authtest.NewPasskeyAuthenticator()
+		passkey := registerPasskey(t, auth, device)
+		assert.NoError(t, auth.DeletePasskey(ctx, authtest.TEST_USERNAME, passkey.ID))
+
+		_, err := authenticatePasskey(t, auth, device)
+		assert.Equal(t, authgo.ErrPasskeyNotRecognized, err)
+	})
+	t.Run("Lockout", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		auth.SetLockoutPolicy(&authgo.LockoutPolicy{
+			AccountThreshold: 3,
+			Duration:         time.Hour,
+			MaximumDuration:  time.Hour,
+		})
+		authtest.NewTestAccount(t, auth)
+		device := authtest.NewPasskeyAuthenticator()
+		registerPasskey(t, auth, device)
+		device.UserVerified = false
+		for i := 0; i < 3; i++ {
+			_, err := authenticatePasskey(t, auth, device)
+			assert.True(t, errors.Is(err, authgo.ErrPasskeyInvalid), err)
+		}
+		device.UserVerified = true
+		_, err := authenticatePasskey(t, auth, device)
+		assert.Equal(t, authgo.ErrAccountLocked, err)
+		_, err = auth.AuthenticateAccount(ctx, authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+		assert.Equal(t, authgo.ErrAccountLocked, err)
+	})
+	t.Run("Passkey Account", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		device := authtest.NewPasskeyAuthenticator()
+		challenge, err := authgo.NewPasskeyChallenge()
+		assert.NoError(t, err)
+		options, err := auth.NewPasskeyCreationOptions(ctx, authtest.TEST_EMAIL, authtest.TEST_USERNAME, challenge)
+		assert.NoError(t, err)
+		response, err := device.Create(options)
+		assert.NoError(t, err)
+		passkey, err := auth.VerifyPasskeyCreation(challenge, response)
+		assert.NoError(t, err)
+		account, err := auth.NewPasskeyAccount(ctx, authtest.TEST_EMAIL, authtest.TEST_USERNAME, passkey)
+		assert.NoError(t, err)
+		require.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+
+		account, err = authenticatePasskey(t, auth, device)
+		assert.NoError(t, err)
+		require.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+
+		// Username and email remain unique
+		other := *passkey
+		other.ID = "other"
+		_, err = auth.NewPasskeyAccount(ctx, authtest.TEST_EMAIL, authtest.TEST_USERNAME, &other)
+		assert.Equal(t, authgo.ErrEmailAlreadyRegistered, err)
+
+		// No account is created for a passkey which is already registered
+		_, err = auth.NewPasskeyAccount(ctx, "bob@example.com", "bob", passkey)
+		assert.Equal(t, authgo.ErrPasskeyAlreadyRegistered, err)
+		_, err = auth.LookupAccount(ctx, "bob")
+		assert.Error(t, err)
+		_, err = auth.LookupUsernameForEmail(ctx, "bob@example.com")
+		assert.Error(t, err)
+	})
+	t.Run("Delete", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		authtest.NewTestAccount(t, auth)
+		passkey := registerPasskey(t, auth, authtest.NewPasskeyAuthenticator())
+
+		// Only the owner can delete a passkey
+		assert.Equal(t, authgo.ErrPasskeyNotRecognized, auth.DeletePasskey(ctx, "bob", passkey.ID))
+
+		assert.NoError(t, auth.DeletePasskey(ctx, authtest.TEST_USERNAME, passkey.ID))
+		passkeys, err := auth.LookupPasskeys(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		assert.Empty(t, passkeys)
+		assert.Equal(t, authgo.ErrPasskeyNotRecognized, auth.DeletePasskey(ctx, authtest.TEST_USERNAME, passkey.ID))
+	})
+	t.Run("Not Configured", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		assert.Nil(t, auth.RelyingParty())
+		challenge, err := authgo.NewPasskeyChallenge()
+		assert.NoError(t, err)
+		_, err = auth.NewPasskeyCreationOptions(ctx, authtest.TEST_EMAIL, authtest.TEST_USERNAME, challenge)
+		assert.Equal(t, authgo.ErrRelyingPartyNotConfigured, err)
+		_, err = auth.NewPasskeyRequestOptions(challenge)
+		assert.Equal(t, authgo.ErrRelyingPartyNotConfigured, err)
+		_, err = auth.AuthenticatePasskey(ctx, challenge, []byte(`{}`))
+		assert.Equal(t, authgo.ErrRelyingPartyNotConfigured, err)
+	})
+}
+
+func registerPasskey(t *testing.T, auth authgo.Authenticator, device *authtest.PasskeyAuthenticator) *authgo.Passkey {
+	t.Helper()
+	challenge, err := authgo.NewPasskeyChallenge()
+	assert.NoError(t, err)
+	options, err := auth.NewPasskeyCreationOptions(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, challenge)
+	assert.NoError(t, err)
+	response, err := device.Create(options)
+	assert.NoError(t, err)
+	passkey, err := auth.VerifyPasskeyCreation(challenge, response)
+	assert.NoError(t, err)
+	assert.NoError(t, auth.AddPasskey(context.Background(), authtest.TEST_USERNAME, passkey))
+	return passkey
+}
+
+func authenticatePasskey(t *testing.T, auth authgo.Authenticator, device *authtest.PasskeyAuthenticator) (*authgo.Account, error) {
+	t.Helper()
+	challenge, err := authgo.NewPasskeyChallenge()
+	assert.NoError(t, err)
+	options, err := auth.NewPasskeyRequestOptions(challenge)
+	assert.NoError(t, err)
+	response, err := device.Get(options)
+	assert.NoError(t, err)
+	return auth.AuthenticatePasskey(context.Background(), challenge, response)
+}
+
+// newPasskeyChallenge returns a challenge as if issued at the given time.
+func newPasskeyChallenge(t *testing.T, issued time.Time) string {
+	t.Helper()
+	challenge := make([]byte, authgo.PASSKEY_CHALLENGE_LENGTH)
+	binary.BigEndian.PutUint64(challenge, uint64(issued.Unix()))
+	_, err := rand.Read(challenge[8:])
+	assert.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(challenge)
+}
@@ -0,0 +1,168 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Passwordless(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	ctx := context.Background()
+	newSession := func(t *testing.T, auth authgo.Authenticator) string {
+		t.Helper()
+		token, err := auth.NewSignInSession(ctx, "", false)
+		assert.NoError(t, err)
+		return token
+	}
+	// enableLinks sends links instead of codes, returning the verifier which records them
+	enableLinks := func(auth authgo.Authenticator) *authtest.SignInLinkVerifier {
+		verifier := authtest.NewSignInLinkVerifier()
+		auth.SetEmailVerifier(verifier)
+		auth.SetSignInLinkURL(authtest.TEST_SIGN_IN_LINK_URL)
+		return verifier
+	}
+	// linkToken returns the token of the most recent link sent
+	linkToken := func(t *testing.T, verifier *authtest.SignInLinkVerifier) string {
+		t.Helper()
+		require.NotEmpty(t, verifier.Links)
+		link := verifier.Links[len(verifier.Links)-1]
+		assert.True(t, strings.HasPrefix(link, authtest.TEST_SIGN_IN_LINK_URL+"?token="), link)
+		u, err := url.Parse(link)
+		assert.NoError(t, err)
+		return u.Query().Get("token")
+	}
+	t.Run("Disabled", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		assert.False(t, auth.PasswordlessSignIn())
+		assert.Equal(t, authgo.ErrPasswordlessDisabled, auth.NewSignInChallenge(ctx, newSession(t, auth), authtest.TEST_USERNAME))
+	})
+	t.Run("Code", func(t *testing.T) {
+		for name, identity := range map[string]string{
+			"Username": authtest.TEST_USERNAME,
+			"Email":    authtest.TEST_EMAIL,
+		} {
+			t.Run(name, func(t *testing.T) {
+				auth := a(t)
+				authtest.NewTestAccount(t, auth)
+				auth.SetPasswordlessSignIn(true)
+				assert.False(t, auth.SendsSignInLinks())
+				session := newSession(t, auth)
+				assert.NoError(t, auth.NewSignInChallenge(ctx, session, identity))
+
+				_, err := auth.VerifySignInCode(ctx, session, "1234abcd")
+				assert.Equal(t, authgo.ErrSignInCodeIncorrect, err)
+
+				// Codes can only be redeemed by the session which requested them
+				_, err = auth.VerifySignInCode(ctx, newSession(t, auth), authtest.TEST_CHALLENGE)
+				assert.Equal(t, authgo.ErrSignInChallengeExpired, err)
+
+				account, err := auth.VerifySignInCode(ctx, session, authtest.TEST_CHALLENGE)
+				assert.NoError(t, err)
+				require.NotNil(t, account)
+				assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+
+				// Codes can only be used once
+				_, err = auth.VerifySignInCode(ctx, session, authtest.TEST_CHALLENGE)
+				assert.Equal(t, authgo.ErrSignInChallengeExpired, err)
+			})
+		}
+	})
+	t.Run("Code Expired", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordlessSignIn(true)
+		auth.SetSignInChallengeTimeout(time.Nanosecond)
+		session := newSession(t, auth)
+		assert.NoError(t, auth.NewSignInChallenge(ctx, session, authtest.TEST_USERNAME))
+		time.Sleep(time.Millisecond)
+		_, err := auth.VerifySignInCode(ctx, session, authtest.TEST_CHALLENGE)
+		assert.Equal(t, authgo.ErrSignInChallengeExpired, err)
+	})
+	t.Run("Unknown Identity", func(t *testing.T) {
+		auth := a(t)
+		auth.SetPasswordlessSignIn(true)
+		verifier := enableLinks(auth)
+		session := newSession(t, auth)
+		// Succeeds so as not to reveal that the account does not exist
+		assert.NoError(t, auth.NewSignInChallenge(ctx, session, authtest.TEST_USERNAME))
+		assert.NoError(t, auth.NewSignInChallenge(ctx, session, authtest.TEST_EMAIL))
+		assert.Empty(t, verifier.Links)
+		_, err := auth.VerifySignInCode(ctx, session, authtest.TEST_CHALLENGE)
+		assert.Equal(t, authgo.ErrSignInChallengeExpired, err)
+	})
+	t.Run("Link", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordlessSignIn(true)
+		verifier := enableLinks(auth)
+		assert.True(t, auth.SendsSignInLinks())
+		session := newSession(t, auth)
+		assert.NoError(t, auth.NewSignInChallenge(ctx, session, authtest.TEST_USERNAME))
+		token := linkToken(t, verifier)
+
+		// Links cannot be redeemed with a code
+		_, err := auth.VerifySignInCode(ctx, session, authtest.TEST_CHALLENGE)
+		assert.Equal(t, authgo.ErrSignInChallengeExpired, err)
+
+		_, err = auth.VerifySignInLink(ctx, "foobar")
+		assert.Equal(t, authgo.ErrSignInLinkInvalid, err)
+
+		account, err := auth.VerifySignInLink(ctx, token)
+		assert.NoError(t, err)
+		require.NotNil(t, account)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+
+		// Links can only be used once
+		_, err = auth.VerifySignInLink(ctx, token)
+		assert.Equal(t, authgo.ErrSignInLinkInvalid, err)
+	})
+	t.Run("Link Expired", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordlessSignIn(true)
+		verifier := enableLinks(auth)
+		auth.SetSignInChallengeTimeout(time.Nanosecond)
+		assert.NoError(t, auth.NewSignInChallenge(ctx, newSession(t, auth), authtest.TEST_USERNAME))
+		time.Sleep(time.Millisecond)
+		_, err := auth.VerifySignInLink(ctx, linkToken(t, verifier))
+		assert.Equal(t, authgo.ErrSignInChallengeExpired, err)
+	})
+	t.Run("Link Replaced", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordlessSignIn(true)
+		verifier := enableLinks(auth)
+		session := newSession(t, auth)
+		assert.NoError(t, auth.NewSignInChallenge(ctx, session, authtest.TEST_USERNAME))
+		first := linkToken(t, verifier)
+		assert.NoError(t, auth.NewSignInChallenge(ctx, session, authtest.TEST_USERNAME))
+		second := linkToken(t, verifier)
+		assert.NotEqual(t, first, second)
+
+		// Requesting another link replaces the previous one
+		_, err := auth.VerifySignInLink(ctx, first)
+		assert.Equal(t, authgo.ErrSignInLinkInvalid, err)
+		_, err = auth.VerifySignInLink(ctx, second)
+		assert.NoError(t, err)
+	})
+	t.Run("Lockout", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordlessSignIn(true)
+		session := newSession(t, auth)
+		assert.NoError(t, auth.NewSignInChallenge(ctx, session, authtest.TEST_USERNAME))
+		for i := 0; i < authgo.DEFAULT_ACCOUNT_LOCKOUT_THRESHOLD; i++ {
+			_, err := auth.VerifySignInCode(ctx, session, "1234abcd")
+			assert.Equal(t, authgo.ErrSignInCodeIncorrect, err)
+		}
+		_, err := auth.VerifySignInCode(ctx, session, authtest.TEST_CHALLENGE)
+		assert.Equal(t, authgo.ErrAccountLocked, err)
+	})
+}
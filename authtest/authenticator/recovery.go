@@ -0,0 +1,111 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func RecoveryCodes(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	ctx := context.Background()
+	t.Run("Generate", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		notifier := authtest.NewEmailNotifier()
+		auth.SetEmailNotifier(notifier)
+		assert.Nil(t, auth.LookupRecoveryCodes(ctx, authtest.TEST_USERNAME))
+
+		codes, err := auth.NewRecoveryCodes(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		assert.Equal(t, authgo.RECOVERY_CODE_COUNT, len(codes))
+		unique := make(map[string]bool)
+		for _, c := range codes {
+			assert.Equal(t, 19, len(c), c)
+			unique[c] = true
+		}
+		assert.Equal(t, len(codes), len(unique))
+
+		recovery := auth.LookupRecoveryCodes(ctx, authtest.TEST_USERNAME)
+		require.NotNil(t, recovery)
+		assert.Equal(t, authgo.RECOVERY_CODE_COUNT, recovery.Remaining)
+		assert.Empty(t, recovery.Used)
+		assert.False(t, recovery.Created.IsZero())
+
+		require.Equal(t, 1, len(notifier.Notifications))
+		assert.Equal(t, authtest.TEST_EMAIL, notifier.Notifications[0].Email)
+		assert.Equal(t, authtest.TEST_USERNAME, notifier.Notifications[0].Username)
+	})
+	t.Run("Verify", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		codes, err := auth.NewRecoveryCodes(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		notifier := authtest.NewEmailNotifier()
+		auth.SetEmailNotifier(notifier)
+
+		assert.NoError(t, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, codes[0]))
+		recovery := auth.LookupRecoveryCodes(ctx, authtest.TEST_USERNAME)
+		require.NotNil(t, recovery)
+		assert.Equal(t, authgo.RECOVERY_CODE_COUNT-1, recovery.Remaining)
+		assert.Equal(t, 1, len(recovery.Used))
+		require.Equal(t, 1, len(notifier.Notifications))
+		assert.Equal(t, authtest.TEST_EMAIL, notifier.Notifications[0].Email)
+		assert.Contains(t, notifier.Notifications[0].Message, "9 recovery codes remain")
+
+		// Each code can only be used once
+		assert.Equal(t, authgo.ErrRecoveryCodeIncorrect, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, codes[0]))
+
+		// Codes are accepted regardless of case, spacing and hyphens
+		assert.NoError(t, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, " "+strings.ToUpper(strings.ReplaceAll(codes[1], "-", " "))+" "))
+		assert.Equal(t, authgo.RECOVERY_CODE_COUNT-2, auth.LookupRecoveryCodes(ctx, authtest.TEST_USERNAME).Remaining)
+	})
+	t.Run("Incorrect", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		codes, err := auth.NewRecoveryCodes(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		assert.Equal(t, authgo.ErrRecoveryCodeIncorrect, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, "aaaa-bbbb-cccc-dddd"))
+		assert.Equal(t, authgo.ErrRecoveryCodeIncorrect, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, ""))
+
+		// Codes belong to a single account
+		_, err = auth.NewAccount(ctx, "bob@example.com", "bob", []byte(authtest.TEST_PASSWORD))
+		assert.NoError(t, err)
+		assert.Equal(t, authgo.ErrRecoveryCodeIncorrect, auth.VerifyRecoveryCode(ctx, "bob", codes[0]))
+		assert.Equal(t, authgo.ErrRecoveryCodeIncorrect, auth.VerifyRecoveryCode(ctx, "carol", codes[0]))
+
+		assert.Equal(t, authgo.RECOVERY_CODE_COUNT, auth.LookupRecoveryCodes(ctx, authtest.TEST_USERNAME).Remaining)
+	})
+	t.Run("Regenerate", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		old, err := auth.NewRecoveryCodes(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		assert.NoError(t, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, old[0]))
+
+		codes, err := auth.NewRecoveryCodes(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		recovery := auth.LookupRecoveryCodes(ctx, authtest.TEST_USERNAME)
+		require.NotNil(t, recovery)
+		assert.Equal(t, authgo.RECOVERY_CODE_COUNT, recovery.Remaining)
+		assert.Empty(t, recovery.Used)
+
+		// Previous codes no longer work
+		assert.Equal(t, authgo.ErrRecoveryCodeIncorrect, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, old[1]))
+		assert.NoError(t, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, codes[1]))
+	})
+	t.Run("Lockout", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		codes, err := auth.NewRecoveryCodes(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		for i := 0; i < authgo.DEFAULT_ACCOUNT_LOCKOUT_THRESHOLD; i++ {
+			assert.Equal(t, authgo.ErrRecoveryCodeIncorrect, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, "aaaa-bbbb-cccc-dddd"))
+		}
+		assert.Equal(t, authgo.ErrAccountLocked, auth.VerifyRecoveryCode(ctx, authtest.TEST_USERNAME, codes[0]))
+		assert.Equal(t, authgo.RECOVERY_CODE_COUNT, auth.LookupRecoveryCodes(ctx, authtest.TEST_USERNAME).Remaining)
+	})
+}
@@ -0,0 +1,163 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func SessionSealer(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	key := func(b byte) []byte {
+		k := make([]byte, 32)
+		for i := range k {
+			k[i] = b
+		}
+		return k
+	}
+	// serve runs the handler in a request with the cookies, returning the cookies set on the response
+	serve := func(t *testing.T, auth authgo.Authenticator, h http.HandlerFunc, cookies ...*http.Cookie) []*http.Cookie {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodPost, "/", nil)
+		for _, c := range cookies {
+			request.AddCookie(c)
+		}
+		response := httptest.NewRecorder()
+		auth.SealedSessionHandler(h).ServeHTTP(response, request)
+		return response.Result().Cookies()
+	}
+	// begin creates a sign up session as a handler would, returning its sealed cookie
+	begin := func(t *testing.T, auth authgo.Authenticator) *http.Cookie {
+		t.Helper()
+		cookies := serve(t, auth, func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			token, err := auth.NewSignUpSession(ctx)
+			require.Nil(t, err)
+			http.SetCookie(w, auth.NewSignUpSessionCookie(token))
+			assert.Nil(t, auth.SetSignUpSessionIdentity(ctx, token, authtest.TEST_EMAIL, authtest.TEST_USERNAME))
+			assert.Nil(t, auth.SetSignUpSessionChallenge(ctx, token, authtest.TEST_CHALLENGE))
+		})
+		require.Equal(t, 1, len(cookies))
+		assert.Equal(t, authgo.COOKIE_SIGN_UP, cookies[0].Name)
+		return cookies[0]
+	}
+	t.Run("Seals", func(t *testing.T) {
+		sealer := authgo.NewSessionSealer(key(1))
+		assert.True(t, sealer.Seals(authgo.COOKIE_SIGN_UP))
+		assert.True(t, sealer.Seals(authgo.COOKIE_ACCOUNT_PASSWORD))
+		assert.True(t, sealer.Seals(authgo.COOKIE_ACCOUNT_RECOVERY))
+		assert.False(t, sealer.Seals(authgo.COOKIE_SIGN_IN))
+		value, err := sealer.Seal(authgo.COOKIE_SIGN_UP, &authgo.SignUpSession{Username: authtest.TEST_USERNAME})
+		require.Nil(t, err)
+		assert.NotContains(t, value, authtest.TEST_USERNAME)
+		session := &authgo.SignUpSession{}
+		assert.Nil(t, sealer.Unseal(authgo.COOKIE_SIGN_UP, value, session))
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+		// Sealed sessions cannot be used as another kind of session
+		assert.Equal(t, authgo.ErrSealedSessionInvalid, sealer.Unseal(authgo.COOKIE_ACCOUNT_RECOVERY, value, &authgo.AccountRecoverySession{}))
+		// Sealed sessions cannot be changed
+		tampered := []byte(value)
+		tampered[len(tampered)/2] ^= 1
+		assert.Equal(t, authgo.ErrSealedSessionInvalid, sealer.Unseal(authgo.COOKIE_SIGN_UP, string(tampered), session))
+		_, err = authgo.NewSessionSealer().Seal(authgo.COOKIE_SIGN_UP, session)
+		assert.Equal(t, authgo.ErrSessionSealerKeyMissing, err)
+	})
+	t.Run("Rotates Keys", func(t *testing.T) {
+		value, err := authgo.NewSessionSealer(key(1)).Seal(authgo.COOKIE_SIGN_UP, &authgo.SignUpSession{Username: authtest.TEST_USERNAME})
+		require.Nil(t, err)
+		session := &authgo.SignUpSession{}
+		assert.Nil(t, authgo.NewSessionSealer(key(2), key(1)).Unseal(authgo.COOKIE_SIGN_UP, value, session))
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+		assert.Equal(t, authgo.ErrSealedSessionInvalid, authgo.NewSessionSealer(key(2)).Unseal(authgo.COOKIE_SIGN_UP, value, session))
+	})
+	t.Run("Creates Session", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSessionSealer(authgo.NewSessionSealer(key(1)))
+		cookie := begin(t, auth)
+		session := auth.LookupSignUpSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.Equal(t, authtest.TEST_EMAIL, session.Email)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+		assert.Equal(t, authtest.TEST_CHALLENGE, session.Challenge)
+	})
+	t.Run("Updates Session", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSessionSealer(authgo.NewSessionSealer(key(1)))
+		cookie := begin(t, auth)
+		cookies := serve(t, auth, func(w http.ResponseWriter, r *http.Request) {
+			session := auth.CurrentSignUpSession(r)
+			require.NotNil(t, session)
+			auth.SetSignUpSessionError(r.Context(), session.Token, "foobar")
+			// Changes are seen within the request
+			session = auth.CurrentSignUpSession(r)
+			require.NotNil(t, session)
+			assert.Equal(t, "foobar", session.Error)
+		}, cookie)
+		require.Equal(t, 1, len(cookies))
+		session := auth.LookupSignUpSession(context.Background(), cookies[0].Value)
+		require.NotNil(t, session)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+		assert.Equal(t, "foobar", session.Error)
+		// The original cookie is unchanged
+		session = auth.LookupSignUpSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.Empty(t, session.Error)
+	})
+	t.Run("Does Not Set Unchanged Session", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSessionSealer(authgo.NewSessionSealer(key(1)))
+		cookie := begin(t, auth)
+		cookies := serve(t, auth, func(w http.ResponseWriter, r *http.Request) {
+			assert.NotNil(t, auth.CurrentSignUpSession(r))
+			w.WriteHeader(http.StatusOK)
+		}, cookie)
+		assert.Empty(t, cookies)
+	})
+	t.Run("Rejects Changes Outside Handler", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSessionSealer(authgo.NewSessionSealer(key(1)))
+		_, err := auth.NewSignUpSession(context.Background())
+		assert.Equal(t, authgo.ErrSealedSessionNotWritable, err)
+		cookie := begin(t, auth)
+		assert.Equal(t, authgo.ErrSealedSessionNotWritable, auth.SetSignUpSessionReferrer(context.Background(), cookie.Value, "foobar"))
+	})
+	t.Run("Expires Session", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSessionSealer(authgo.NewSessionSealer(key(1)))
+		auth.SetSignUpSessionTimeout(time.Nanosecond)
+		cookie := begin(t, auth)
+		time.Sleep(time.Millisecond) // Sleep to ensure expiry
+		assert.Nil(t, auth.LookupSignUpSession(context.Background(), cookie.Value))
+	})
+	t.Run("Seals Selected Sessions", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSessionSealer(&authgo.SessionSealer{
+			Keys:     [][]byte{key(1)},
+			Sessions: []string{authgo.COOKIE_ACCOUNT_RECOVERY},
+		})
+		// Sign up sessions are still stored
+		token, err := auth.NewSignUpSession(context.Background())
+		require.Nil(t, err)
+		assert.Nil(t, auth.SetSignUpSessionReferrer(context.Background(), token, "foobar"))
+		session := auth.LookupSignUpSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.Equal(t, "foobar", session.Referrer)
+
+		_, err = auth.NewAccountRecoverySession(context.Background())
+		assert.Equal(t, authgo.ErrSealedSessionNotWritable, err)
+	})
+	t.Run("Finds Stored Sessions", func(t *testing.T) {
+		auth := a(t)
+		token, err := auth.NewAccountPasswordSession(context.Background(), authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		auth.SetSessionSealer(authgo.NewSessionSealer(key(1)))
+		session := auth.LookupAccountPasswordSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	})
+}
@@ -0,0 +1,142 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func SignInSessionTimeout(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	assert.True(t, auth.SignInSessionTimeout().Seconds() > 0)
+}
+
+func SetSignInSessionTimeout(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	auth.SetSignInSessionTimeout(time.Second * 5)
+	assert.True(t, auth.SignInSessionTimeout().Seconds() == 5)
+}
+
+func NewSignInSessionCookie(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := authgo.NewSessionToken()
+	assert.NoError(t, err)
+	cookie := auth.NewSignInSessionCookie(token)
+	assert.NotNil(t, cookie)
+	assert.Equal(t, authgo.COOKIE_SIGN_IN, cookie.Name)
+	assert.Equal(t, token, cookie.Value)
+}
+
+func CurrentSignInSession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("NoCookie", func(t *testing.T) {
+		auth := a(t)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.Nil(t, auth.CurrentSignInSession(request))
+	})
+	t.Run("NoSession", func(t *testing.T) {
+		auth := a(t)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		token, err := authgo.NewSessionToken()
+		assert.NoError(t, err)
+		cookie := auth.NewSignInSessionCookie(token)
+		assert.NotNil(t, cookie)
+		request.AddCookie(cookie)
+		assert.Nil(t, auth.CurrentSignInSession(request))
+	})
+	t.Run("ExpiredSession", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSignInSessionTimeout(time.Nanosecond)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewSignInSessionCookie(token))
+		time.Sleep(time.Millisecond) // Sleep to ensure expiry
+		assert.Nil(t, auth.CurrentSignInSession(request))
+	})
+	t.Run("Exists", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(auth.NewSignInSessionCookie(token))
+		session := auth.CurrentSignInSession(request)
+		require.NotNil(t, session)
+		assert.Equal(t, token, session.Token)
+		assert.Equal(t, session.Username, authtest.TEST_USERNAME)
+		assert.True(t, session.Authenticated)
+	})
+}
+
+func NewSignInSession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignInSession(context.Background(), "", false)
+	assert.NoError(t, err)
+	session := auth.LookupSignInSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Username)
+	assert.False(t, session.Authenticated)
+	assert.Empty(t, session.Error)
+}
+
+func LookupSignInSession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	assert.Nil(t, auth.LookupSignInSession(context.Background(), ""))
+}
+
+func SetSignInSessionError(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignInSession(context.Background(), "", false)
+	assert.NoError(t, err)
+	error := "ERR"
+	auth.SetSignInSessionError(context.Background(), token, error)
+	session := auth.LookupSignInSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Username)
+	assert.False(t, session.Authenticated)
+	assert.Equal(t, error, session.Error)
+}
+
+func SetSignInSessionUsername(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignInSession(context.Background(), "", false)
+	assert.NoError(t, err)
+	auth.SetSignInSessionUsername(context.Background(), token, authtest.TEST_USERNAME)
+	session := auth.LookupSignInSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	assert.False(t, session.Authenticated)
+	assert.Empty(t, session.Error)
+}
+
+func SetSignInSessionAuthenticated(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignInSession(context.Background(), "", false)
+	assert.NoError(t, err)
+	auth.SetSignInSessionAuthenticated(context.Background(), token, true)
+	session := auth.LookupSignInSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Username)
+	assert.True(t, session.Authenticated)
+	assert.Empty(t, session.Error)
+}
+
+func SetSignInSessionPasskeyChallenge(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignInSession(context.Background(), "", false)
+	assert.NoError(t, err)
+	challenge, err := authgo.NewPasskeyChallenge()
+	assert.NoError(t, err)
+	auth.SetSignInSessionPasskeyChallenge(context.Background(), token, challenge)
+	session := auth.LookupSignInSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Username)
+	assert.False(t, session.Authenticated)
+	assert.Equal(t, challenge, session.PasskeyChallenge)
+	assert.Empty(t, session.Error)
+}
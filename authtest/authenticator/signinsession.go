@@ -0,0 +1,155 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func SignInSessions(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	client := authgo.WithClientUserAgent(authgo.WithClientAddress(context.Background(), "192.0.2.1"), "Firefox")
+	t.Run("Records Client", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, err := auth.NewSignInSession(client, authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		session := auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.Equal(t, "192.0.2.1", session.Address)
+		assert.Equal(t, "Firefox", session.UserAgent)
+		assert.False(t, session.Active.IsZero())
+		assert.NotContains(t, session.ID(), token)
+	})
+	t.Run("Records Activity", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, err := auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.RemoteAddr = "198.51.100.1:1234"
+		request.Header.Set("User-Agent", "Safari")
+		request.AddCookie(auth.NewSignInSessionCookie(token))
+		require.NotNil(t, auth.CurrentAccount(httptest.NewRecorder(), request))
+		session := auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.Equal(t, "198.51.100.1", session.Address)
+		assert.Equal(t, "Safari", session.UserAgent)
+	})
+	t.Run("Truncates Client", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		// Multi-byte runes must not be split
+		agent := "a" + strings.Repeat("é", authgo.MAXIMUM_CLIENT_USER_AGENT_LENGTH)
+		address := strings.Repeat("1", authgo.MAXIMUM_CLIENT_ADDRESS_LENGTH+1)
+		token, err := auth.NewSignInSession(authgo.WithClientUserAgent(authgo.WithClientAddress(context.Background(), address), agent), authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		session := auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.Equal(t, address[:authgo.MAXIMUM_CLIENT_ADDRESS_LENGTH], session.Address)
+		assert.Equal(t, agent[:authgo.MAXIMUM_CLIENT_USER_AGENT_LENGTH-1], session.UserAgent)
+		assert.True(t, utf8.ValidString(session.UserAgent))
+
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.Header.Set("User-Agent", strings.Repeat("b", 2*authgo.MAXIMUM_CLIENT_USER_AGENT_LENGTH))
+		request.AddCookie(auth.NewSignInSessionCookie(token))
+		require.NotNil(t, auth.CurrentAccount(httptest.NewRecorder(), request))
+		session = auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.Equal(t, strings.Repeat("b", authgo.MAXIMUM_CLIENT_USER_AGENT_LENGTH), session.UserAgent)
+	})
+	t.Run("Lists Signed In Sessions", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		first, err := auth.NewSignInSession(client, authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		second, err := auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		// Sessions which have not signed in, or belong to other accounts, are not listed
+		_, err = auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, false)
+		require.Nil(t, err)
+		_, err = auth.NewSignInSession(context.Background(), "bob", true)
+		require.Nil(t, err)
+
+		sessions, err := auth.LookupSignInSessions(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		require.Equal(t, 2, len(sessions))
+		agents := map[string]string{}
+		for _, s := range sessions {
+			agents[s.Token] = s.UserAgent
+		}
+		assert.Equal(t, map[string]string{first: "Firefox", second: ""}, agents)
+		assert.NotEqual(t, sessions[0].ID(), sessions[1].ID())
+	})
+	t.Run("Does Not List Expired Sessions", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetSignInSessionTimeout(time.Nanosecond)
+		_, err := auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		time.Sleep(time.Millisecond) // Sleep to ensure expiry
+		sessions, err := auth.LookupSignInSessions(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		assert.Empty(t, sessions)
+	})
+	t.Run("Revokes Session", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		first, err := auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		second, err := auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		session := auth.LookupSignInSession(context.Background(), first)
+		require.NotNil(t, session)
+
+		assert.Nil(t, auth.RevokeSignInSession(context.Background(), authtest.TEST_USERNAME, session.ID()))
+		session = auth.LookupSignInSession(context.Background(), first)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+		session = auth.LookupSignInSession(context.Background(), second)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+
+		assert.Equal(t, authgo.ErrSignInSessionNotFound, auth.RevokeSignInSession(context.Background(), authtest.TEST_USERNAME, "foobar"))
+	})
+	t.Run("Does Not Revoke Session Of Another Account", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, err := auth.NewSignInSession(context.Background(), "bob", true)
+		require.Nil(t, err)
+		session := auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.Equal(t, authgo.ErrSignInSessionNotFound, auth.RevokeSignInSession(context.Background(), authtest.TEST_USERNAME, session.ID()))
+		session = auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+	})
+	t.Run("Revokes Other Sessions", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		current, err := auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		for i := 0; i < 2; i++ {
+			_, err := auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, true)
+			require.Nil(t, err)
+		}
+		other, err := auth.NewSignInSession(context.Background(), "bob", true)
+		require.Nil(t, err)
+
+		assert.Nil(t, auth.RevokeOtherSignInSessions(context.Background(), authtest.TEST_USERNAME, current))
+		sessions, err := auth.LookupSignInSessions(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		require.Equal(t, 1, len(sessions))
+		assert.Equal(t, current, sessions[0].Token)
+		session := auth.LookupSignInSession(context.Background(), other)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+	})
+}
@@ -0,0 +1,171 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func SignUpSessionTimeout(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	assert.True(t, auth.SignUpSessionTimeout().Seconds() > 0)
+}
+
+func SetSignUpSessionTimeout(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	auth.SetSignUpSessionTimeout(time.Second * 5)
+	assert.True(t, auth.SignUpSessionTimeout().Seconds() == 5)
+}
+
+func NewSignUpSessionCookie(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := authgo.NewSessionToken()
+	assert.NoError(t, err)
+	cookie := auth.NewSignUpSessionCookie(token)
+	assert.NotNil(t, cookie)
+	assert.Equal(t, authgo.COOKIE_SIGN_UP, cookie.Name)
+	assert.Equal(t, token, cookie.Value)
+}
+
+func CurrentSignUpSession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	t.Run("NoCookie", func(t *testing.T) {
+		auth := a(t)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		assert.Nil(t, auth.CurrentSignUpSession(request))
+	})
+	t.Run("NoSession", func(t *testing.T) {
+		auth := a(t)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		token, err := authgo.NewSessionToken()
+		assert.NoError(t, err)
+		cookie := auth.NewSignUpSessionCookie(token)
+		assert.NotNil(t, cookie)
+		request.AddCookie(cookie)
+		assert.Nil(t, auth.CurrentSignUpSession(request))
+	})
+	t.Run("ExpiredSession", func(t *testing.T) {
+		auth := a(t)
+		auth.SetSignUpSessionTimeout(time.Nanosecond)
+		authtest.NewTestAccount(t, auth)
+		token, err := auth.NewSignUpSession(context.Background())
+		assert.NoError(t, err)
+		cookie := auth.NewSignUpSessionCookie(token)
+		assert.NotNil(t, cookie)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(cookie)
+		time.Sleep(time.Millisecond) // Sleep to ensure expiry
+		assert.Nil(t, auth.CurrentSignUpSession(request))
+	})
+	t.Run("Exists", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, err := auth.NewSignUpSession(context.Background())
+		assert.NoError(t, err)
+		cookie := auth.NewSignUpSessionCookie(token)
+		assert.NotNil(t, cookie)
+		request := httptest.NewRequest(http.MethodGet, "/", nil)
+		request.AddCookie(cookie)
+		session := auth.CurrentSignUpSession(request)
+		require.NotNil(t, session)
+		assert.Equal(t, token, session.Token)
+	})
+}
+
+func NewSignUpSession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignUpSession(context.Background())
+	assert.NoError(t, err)
+	session := auth.LookupSignUpSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Email)
+	assert.Empty(t, session.Username)
+	assert.Empty(t, session.Referrer)
+	assert.Empty(t, session.Challenge)
+	assert.Empty(t, session.Error)
+}
+
+func LookupSignUpSession(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	assert.Nil(t, auth.LookupSignUpSession(context.Background(), ""))
+}
+
+func SetSignUpSessionError(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignUpSession(context.Background())
+	assert.NoError(t, err)
+	error := "ERR"
+	auth.SetSignUpSessionError(context.Background(), token, error)
+	session := auth.LookupSignUpSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Email)
+	assert.Empty(t, session.Username)
+	assert.Empty(t, session.Referrer)
+	assert.Empty(t, session.Challenge)
+	assert.Equal(t, error, session.Error)
+}
+
+func SetSignUpSessionIdentity(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignUpSession(context.Background())
+	assert.NoError(t, err)
+	auth.SetSignUpSessionIdentity(context.Background(), token, authtest.TEST_EMAIL, authtest.TEST_USERNAME)
+	session := auth.LookupSignUpSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Equal(t, authtest.TEST_EMAIL, session.Email)
+	assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	assert.Empty(t, session.Referrer)
+	assert.Empty(t, session.Challenge)
+	assert.Empty(t, session.Error)
+}
+
+func SetSignUpSessionReferrer(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignUpSession(context.Background())
+	assert.NoError(t, err)
+	referrer := "foobar"
+	auth.SetSignUpSessionReferrer(context.Background(), token, referrer)
+	session := auth.LookupSignUpSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Email)
+	assert.Empty(t, session.Username)
+	assert.Equal(t, referrer, session.Referrer)
+	assert.Empty(t, session.Challenge)
+	assert.Empty(t, session.Error)
+}
+
+func SetSignUpSessionChallenge(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignUpSession(context.Background())
+	assert.NoError(t, err)
+	challenge := "abcd1234"
+	auth.SetSignUpSessionChallenge(context.Background(), token, challenge)
+	session := auth.LookupSignUpSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Email)
+	assert.Empty(t, session.Username)
+	assert.Empty(t, session.Referrer)
+	assert.Equal(t, challenge, session.Challenge)
+	assert.Empty(t, session.Error)
+}
+
+func SetSignUpSessionPasskeyChallenge(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	auth := a(t)
+	token, err := auth.NewSignUpSession(context.Background())
+	assert.NoError(t, err)
+	challenge, err := authgo.NewPasskeyChallenge()
+	assert.NoError(t, err)
+	auth.SetSignUpSessionPasskeyChallenge(context.Background(), token, challenge)
+	session := auth.LookupSignUpSession(context.Background(), token)
+	require.NotNil(t, session)
+	assert.Empty(t, session.Email)
+	assert.Empty(t, session.Username)
+	assert.Empty(t, session.Challenge)
+	assert.Equal(t, challenge, session.PasskeyChallenge)
+	assert.Empty(t, session.Error)
+}
@@ -0,0 +1,102 @@
+package authenticator
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"context"
+	"encoding/base32"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TOTP(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	ctx := context.Background()
+	t.Run("Enroll", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetTOTPIssuer("Example")
+		assert.False(t, auth.IsTOTPEnabled(ctx, authtest.TEST_USERNAME))
+		assert.Nil(t, auth.LookupTOTPEnrollment(ctx, authtest.TEST_USERNAME))
+
+		enrollment, err := auth.NewTOTP(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		require.NotNil(t, enrollment)
+		assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/Example:"+authtest.TEST_USERNAME+"?"), enrollment.URI)
+		assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+		assert.Equal(t, enrollment, auth.LookupTOTPEnrollment(ctx, authtest.TEST_USERNAME))
+
+		// Not enabled until confirmed
+		assert.False(t, auth.IsTOTPEnabled(ctx, authtest.TEST_USERNAME))
+		assert.Equal(t, authgo.ErrTOTPNotEnabled, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, "123456"))
+
+		secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+		assert.NoError(t, err)
+		assert.Equal(t, authgo.ErrTOTPCodeIncorrect, auth.ConfirmTOTP(ctx, authtest.TEST_USERNAME, authgo.TOTPCode(secret, time.Now().Add(-time.Hour))))
+		assert.False(t, auth.IsTOTPEnabled(ctx, authtest.TEST_USERNAME))
+		assert.NoError(t, auth.ConfirmTOTP(ctx, authtest.TEST_USERNAME, authgo.TOTPCode(secret, time.Now())))
+		assert.True(t, auth.IsTOTPEnabled(ctx, authtest.TEST_USERNAME))
+		assert.Nil(t, auth.LookupTOTPEnrollment(ctx, authtest.TEST_USERNAME))
+
+		// Cannot confirm twice
+		assert.Equal(t, authgo.ErrTOTPNotEnrolled, auth.ConfirmTOTP(ctx, authtest.TEST_USERNAME, authgo.TOTPCode(secret, time.Now())))
+	})
+	t.Run("Verify", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		secret := authtest.EnableTOTP(t, auth)
+
+		// Code used to confirm cannot be reused
+		assert.Equal(t, authgo.ErrTOTPCodeIncorrect, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, authgo.TOTPCode(secret, time.Now())))
+		// Expired code
+		assert.Equal(t, authgo.ErrTOTPCodeIncorrect, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, authgo.TOTPCode(secret, time.Now().Add(-2*authgo.TOTP_PERIOD))))
+		// Wrong code
+		assert.Equal(t, authgo.ErrTOTPCodeIncorrect, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, "abcdef"))
+
+		code := authtest.NextTOTPCode(secret)
+		assert.NoError(t, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, code))
+		// Replay
+		assert.Equal(t, authgo.ErrTOTPCodeIncorrect, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, code))
+	})
+	t.Run("Lockout", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		secret := authtest.EnableTOTP(t, auth)
+		auth.SetLockoutPolicy(&authgo.LockoutPolicy{
+			AccountThreshold: 3,
+			Duration:         time.Hour,
+			MaximumDuration:  time.Hour,
+		})
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, authgo.ErrTOTPCodeIncorrect, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, "000000"))
+		}
+		assert.Equal(t, authgo.ErrAccountLocked, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, authtest.NextTOTPCode(secret)))
+	})
+	t.Run("Reenroll", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		old := authtest.EnableTOTP(t, auth)
+
+		// Existing app continues to work until the new one is confirmed
+		enrollment, err := auth.NewTOTP(ctx, authtest.TEST_USERNAME)
+		assert.NoError(t, err)
+		assert.True(t, auth.IsTOTPEnabled(ctx, authtest.TEST_USERNAME))
+		secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+		assert.NoError(t, err)
+		assert.NotEqual(t, old, secret)
+		assert.NoError(t, auth.ConfirmTOTP(ctx, authtest.TEST_USERNAME, authgo.TOTPCode(secret, time.Now())))
+
+		assert.Equal(t, authgo.ErrTOTPCodeIncorrect, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, authtest.NextTOTPCode(old)))
+		assert.NoError(t, auth.VerifyTOTP(ctx, authtest.TEST_USERNAME, authtest.NextTOTPCode(secret)))
+	})
+	t.Run("Disable", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		authtest.EnableTOTP(t, auth)
+		assert.NoError(t, auth.DisableTOTP(ctx, authtest.TEST_USERNAME))
+		assert.False(t, auth.IsTOTPEnabled(ctx, authtest.TEST_USERNAME))
+		assert.NoError(t, auth.DisableTOTP(ctx, authtest.TEST_USERNAME))
+	})
+}
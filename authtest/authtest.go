@@ -3,40 +3,104 @@ package authtest
 import (
 	"aletheiaware.com/authgo"
 	"aletheiaware.com/authgo/database"
+	"context"
+	"crypto/rand"
+	"encoding/base32"
 	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
 	"testing"
+	"time"
 )
 
 const (
 	TEST_USER_ID  = int64(1)
 	TEST_EMAIL    = "alice@example.com"
 	TEST_USERNAME = "alice"
-	TEST_PASSWORD = "password1234"
+	TEST_PASSWORD = "olive-copper-kettle-42"
 )
 
 func NewAuthenticator(t *testing.T) authgo.Authenticator {
 	t.Helper()
 	db := database.NewInMemory()
 	ev := NewEmailVerifier()
-	return authgo.NewAuthenticator(db, ev)
+	a := authgo.NewAuthenticator(db, db, ev)
+	a.SetPasswordHasher(NewPasswordHasher())
+	a.SetEmailNotifier(NewEmailNotifier())
+	return a
+}
+
+// NewSealedAuthenticator returns an Authenticator which seals sign up, account password, and account recovery sessions into cookies, failing the test if any are written to the database.
+func NewSealedAuthenticator(t *testing.T) authgo.Authenticator {
+	t.Helper()
+	db := database.NewInMemory()
+	ev := NewEmailVerifier()
+	a := authgo.NewAuthenticator(db, &sealedSessionStore{InMemory: db, t: t}, ev)
+	a.SetPasswordHasher(NewPasswordHasher())
+	a.SetEmailNotifier(NewEmailNotifier())
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	assert.Nil(t, err)
+	a.SetSessionSealer(authgo.NewSessionSealer(key))
+	return a
+}
+
+type sealedSessionStore struct {
+	*database.InMemory
+	t *testing.T
+}
+
+func (s *sealedSessionStore) CreateSignUpSession(ctx context.Context, session *authgo.SignUpSession) (int64, error) {
+	s.t.Error("Sealed Sign Up Session Written To Database")
+	return s.InMemory.CreateSignUpSession(ctx, session)
+}
+
+func (s *sealedSessionStore) CreateAccountPasswordSession(ctx context.Context, session *authgo.AccountPasswordSession) (int64, error) {
+	s.t.Error("Sealed Account Password Session Written To Database")
+	return s.InMemory.CreateAccountPasswordSession(ctx, session)
+}
+
+func (s *sealedSessionStore) CreateAccountRecoverySession(ctx context.Context, session *authgo.AccountRecoverySession) (int64, error) {
+	s.t.Error("Sealed Account Recovery Session Written To Database")
+	return s.InMemory.CreateAccountRecoverySession(ctx, session)
+}
+
+// NewPasswordHasher returns a hasher which is fast, and so insecure, for use in tests.
+func NewPasswordHasher() authgo.PasswordHasher {
+	return authgo.NewBcryptHasher(bcrypt.MinCost)
 }
 
 func NewTestAccount(t *testing.T, a authgo.Authenticator) *authgo.Account {
-	acc, err := a.NewAccount(TEST_EMAIL, TEST_USERNAME, []byte(TEST_PASSWORD))
+	acc, err := a.NewAccount(context.Background(), TEST_EMAIL, TEST_USERNAME, []byte(TEST_PASSWORD))
 	assert.Nil(t, err)
 	return acc
 }
 
+// EnableTOTP enrolls and confirms an authenticator app for the test account, returning its secret.
+func EnableTOTP(t *testing.T, a authgo.Authenticator) []byte {
+	t.Helper()
+	enrollment, err := a.NewTOTP(context.Background(), TEST_USERNAME)
+	assert.Nil(t, err)
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+	assert.Nil(t, err)
+	assert.Nil(t, a.ConfirmTOTP(context.Background(), TEST_USERNAME, authgo.TOTPCode(secret, time.Now())))
+	return secret
+}
+
+// NextTOTPCode returns a code which has not yet been used, as the current one was used to confirm enrollment.
+func NextTOTPCode(secret []byte) string {
+	return authgo.TOTPCode(secret, time.Now().Add(authgo.TOTP_PERIOD))
+}
+
 func SignIn(t *testing.T, a authgo.Authenticator) (string, *authgo.Account) {
 	t.Helper()
-	token, err := a.NewSignInSession(TEST_USERNAME, true)
+	token, err := a.NewSignInSession(context.Background(), TEST_USERNAME, true)
 	assert.Nil(t, err)
-	account, err := a.AuthenticateAccount(TEST_USERNAME, []byte(TEST_PASSWORD))
+	account, err := a.AuthenticateAccount(context.Background(), TEST_USERNAME, []byte(TEST_PASSWORD))
 	assert.Nil(t, err)
 	return token, account
 }
 
 func SignOut(t *testing.T, a authgo.Authenticator, token string) {
 	t.Helper()
-	a.SetSignInSessionAuthenticated(token, false)
+	a.SetSignInSessionAuthenticated(context.Background(), token, false)
 }
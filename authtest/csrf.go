@@ -0,0 +1,18 @@
+package authtest
+
+import (
+	"aletheiaware.com/authgo"
+	"net/http"
+	"net/http/httptest"
+)
+
+// AddCSRF adds the CSRF cookie and token to the request, as a form on the website's own page would.
+// It must be called after the request's other cookies are added, as the token is bound to the sign in session.
+func AddCSRF(a authgo.Authenticator, request *http.Request) {
+	response := httptest.NewRecorder()
+	token := a.CSRFToken(response, request)
+	for _, c := range response.Result().Cookies() {
+		request.AddCookie(c)
+	}
+	request.Header.Set(authgo.CSRF_HEADER, token)
+}
@@ -1,8 +1,14 @@
 package authtest
 
-import "aletheiaware.com/authgo"
+import (
+	"aletheiaware.com/authgo"
+	"sync"
+)
 
-const TEST_CHALLENGE = "abcd1234"
+const (
+	TEST_CHALLENGE        = "abcd1234"
+	TEST_SIGN_IN_LINK_URL = "https://example.com/sign-in-link"
+)
 
 func NewEmailVerifier() authgo.EmailVerifier {
 	return &emailVerifier{}
@@ -13,3 +19,49 @@ type emailVerifier struct{}
 func (v *emailVerifier) Verify(email, username string) (string, error) {
 	return TEST_CHALLENGE, authgo.ValidateEmail(email)
 }
+
+// SignInLinkVerifier sends the same code as NewEmailVerifier, and records sign in links instead of sending them, for use in tests.
+type SignInLinkVerifier struct {
+	sync.Mutex
+	Links []string
+}
+
+func NewSignInLinkVerifier() *SignInLinkVerifier {
+	return &SignInLinkVerifier{}
+}
+
+func (v *SignInLinkVerifier) Verify(email, username string) (string, error) {
+	return TEST_CHALLENGE, authgo.ValidateEmail(email)
+}
+
+func (v *SignInLinkVerifier) SendSignInLink(email, username, link string) error {
+	v.Lock()
+	defer v.Unlock()
+	v.Links = append(v.Links, link)
+	return authgo.ValidateEmail(email)
+}
+
+// EmailNotifier records notifications instead of sending them, for use in tests.
+type EmailNotifier struct {
+	sync.Mutex
+	Notifications []*Notification
+}
+
+type Notification struct {
+	Email, Username, Message string
+}
+
+func NewEmailNotifier() *EmailNotifier {
+	return &EmailNotifier{}
+}
+
+func (n *EmailNotifier) Notify(email, username, message string) error {
+	n.Lock()
+	defer n.Unlock()
+	n.Notifications = append(n.Notifications, &Notification{
+		Email:    email,
+		Username: username,
+		Message:  message,
+	})
+	return nil
+}
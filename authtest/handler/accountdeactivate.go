@@ -52,6 +52,7 @@ func AccountDeactivate(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		handler.AttachAccountDeactivateHandler(mux, auth, tmpl)
 		request := httptest.NewRequest(http.MethodPost, "/account-deactivate", nil)
 		request.AddCookie(auth.NewSignInSessionCookie(token))
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
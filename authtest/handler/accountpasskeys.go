@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func AccountPasskeys(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	tmpl, err := template.New("account-passkeys.go.html").Parse(`{{range .Passkeys}}{{.Name}} {{end}}{{.Error}}`)
+	assert.Nil(t, err)
+	// register requests the options and posts the credential created by the device
+	register := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, cookie *http.Cookie, device *authtest.PasskeyAuthenticator, name, password, code string) *http.Response {
+		t.Helper()
+		var options authgo.PasskeyCreationOptions
+		decodePasskeyOptions(t, submitForm(t, auth, mux, "/account-passkeys-options", url.Values{}, cookie), &options)
+		assert.Equal(t, authtest.TEST_USERNAME, options.User.Name)
+		credential, err := device.Create(&options)
+		assert.Nil(t, err)
+		values := url.Values{}
+		values.Add("action", "register")
+		values.Add("credential", string(credential))
+		values.Add("name", name)
+		values.Add("password", password)
+		values.Add("code", code)
+		return submitForm(t, auth, mux, "/account-passkeys", values, cookie)
+	}
+	t.Run("Redirects When Not Signed In", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		mux := http.NewServeMux()
+		handler.AttachAccountPasskeysHandler(mux, auth, tmpl)
+		request := httptest.NewRequest(http.MethodGet, "/account-passkeys", nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		assertLocation(t, response.Result(), "/sign-in?next=%2Faccount-passkeys")
+	})
+	t.Run("Options Returns 401 When Not Signed In", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		mux := http.NewServeMux()
+		handler.AttachAccountPasskeysHandler(mux, auth, tmpl)
+		result := submitForm(t, auth, mux, "/account-passkeys-options", url.Values{})
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	})
+	t.Run("Register Delete", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		notifier := authtest.NewEmailNotifier()
+		auth.SetEmailNotifier(notifier)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		mux := http.NewServeMux()
+		handler.AttachAccountPasskeysHandler(mux, auth, tmpl)
+		assert.Empty(t, getBody(t, mux, "/account-passkeys", cookie))
+
+		device := authtest.NewPasskeyAuthenticator()
+		assertLocation(t, register(t, auth, mux, cookie, device, "Laptop", authtest.TEST_PASSWORD, ""), "/account-passkeys")
+		assert.Equal(t, "Laptop ", getBody(t, mux, "/account-passkeys", cookie))
+		require.Equal(t, 1, len(notifier.Notifications))
+		assert.Contains(t, notifier.Notifications[0].Message, "Laptop")
+		session := auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.Empty(t, session.PasskeyChallenge)
+
+		// The same device cannot register twice
+		var options authgo.PasskeyCreationOptions
+		decodePasskeyOptions(t, submitForm(t, auth, mux, "/account-passkeys-options", url.Values{}, cookie), &options)
+		assert.Equal(t, 1, len(options.ExcludeCredentials))
+		_, err := device.Create(&options)
+		assert.Equal(t, authtest.ErrPasskeyExcluded, err)
+
+		passkeys, err := auth.LookupPasskeys(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		require.Equal(t, 1, len(passkeys))
+		values := url.Values{}
+		values.Add("action", "delete")
+		values.Add("id", passkeys[0].ID)
+		assertLocation(t, submitForm(t, auth, mux, "/account-passkeys", values, cookie), "/account-passkeys")
+		assert.Empty(t, getBody(t, mux, "/account-passkeys", cookie))
+		assert.Equal(t, 2, len(notifier.Notifications))
+	})
+	t.Run("Register Requires Password", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		mux := http.NewServeMux()
+		handler.AttachAccountPasskeysHandler(mux, auth, tmpl)
+		for _, password := range []string{"", "1234password"} {
+			result := register(t, auth, mux, cookie, authtest.NewPasskeyAuthenticator(), "Laptop", password, "")
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			body, err := io.ReadAll(result.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, authgo.ErrCredentialsIncorrect.Error(), string(body))
+		}
+		passkeys, err := auth.LookupPasskeys(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		assert.Empty(t, passkeys)
+	})
+	t.Run("Register Requires Authentication Code", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		authtest.NewTestAccount(t, auth)
+		secret := authtest.EnableTOTP(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		mux := http.NewServeMux()
+		handler.AttachAccountPasskeysHandler(mux, auth, tmpl)
+		for _, code := range []string{"", "000000"} {
+			result := register(t, auth, mux, cookie, authtest.NewPasskeyAuthenticator(), "Laptop", authtest.TEST_PASSWORD, code)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			body, err := io.ReadAll(result.Body)
+			assert.Nil(t, err)
+			assert.Equal(t, authgo.ErrTOTPCodeIncorrect.Error(), string(body))
+		}
+		assertLocation(t, register(t, auth, mux, cookie, authtest.NewPasskeyAuthenticator(), "Laptop", authtest.TEST_PASSWORD, authtest.NextTOTPCode(secret)), "/account-passkeys")
+		assert.Equal(t, "Laptop ", getBody(t, mux, "/account-passkeys", cookie))
+	})
+	t.Run("Register Without Challenge", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		mux := http.NewServeMux()
+		handler.AttachAccountPasskeysHandler(mux, auth, tmpl)
+		challenge, err := authgo.NewPasskeyChallenge()
+		assert.Nil(t, err)
+		options, err := auth.NewPasskeyCreationOptions(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, challenge)
+		assert.Nil(t, err)
+		credential, err := authtest.NewPasskeyAuthenticator().Create(options)
+		assert.Nil(t, err)
+		values := url.Values{}
+		values.Add("action", "register")
+		values.Add("credential", string(credential))
+		values.Add("password", authtest.TEST_PASSWORD)
+		result := submitForm(t, auth, mux, "/account-passkeys", values, cookie)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, authgo.ErrPasskeyExpired.Error(), string(body))
+	})
+	t.Run("Rejects Unknown Action", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachAccountPasskeysHandler(mux, auth, tmpl)
+		values := url.Values{}
+		values.Add("action", "foo")
+		result := submitForm(t, auth, mux, "/account-passkeys", values, auth.NewSignInSessionCookie(token))
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+}
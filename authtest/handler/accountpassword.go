@@ -52,13 +52,15 @@ func AccountPassword(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		token, _ := authtest.SignIn(t, auth)
 		mux := http.NewServeMux()
 		handler.AttachAccountPasswordHandler(mux, auth, tmpl)
+		newPassword := "violet-marble-stapler-97"
 		values := url.Values{}
-		values.Add("password", authtest.TEST_PASSWORD)
-		values.Add("confirmation", authtest.TEST_PASSWORD)
+		values.Add("password", newPassword)
+		values.Add("confirmation", newPassword)
 		reader := strings.NewReader(values.Encode())
 		request := httptest.NewRequest(http.MethodPost, "/account-password", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		request.AddCookie(auth.NewSignInSessionCookie(token))
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -82,6 +84,27 @@ func AccountPassword(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 				},
 				result: authgo.ErrPasswordTooShort.Error(),
 			},
+			"Password Too Weak": {
+				form: map[string]string{
+					"password":     "password1234",
+					"confirmation": "password1234",
+				},
+				result: authgo.ErrPasswordTooWeak.Error(),
+			},
+			"Password Contains Username": {
+				form: map[string]string{
+					"password":     "olive-" + authtest.TEST_USERNAME + "-kettle-42",
+					"confirmation": "olive-" + authtest.TEST_USERNAME + "-kettle-42",
+				},
+				result: authgo.ErrPasswordContainsUsername.Error(),
+			},
+			"Password Previously Used": {
+				form: map[string]string{
+					"password":     authtest.TEST_PASSWORD,
+					"confirmation": authtest.TEST_PASSWORD,
+				},
+				result: authgo.ErrPasswordPreviouslyUsed.Error(),
+			},
 			"Passwords Do Not Match": {
 				form: map[string]string{
 					"password":     authtest.TEST_PASSWORD,
@@ -105,6 +128,7 @@ func AccountPassword(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 				request := httptest.NewRequest(http.MethodPost, "/account-password", reader)
 				request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 				request.AddCookie(signInCookie)
+				authtest.AddCSRF(auth, request)
 				response := httptest.NewRecorder()
 				mux.ServeHTTP(response, request)
 				result := response.Result()
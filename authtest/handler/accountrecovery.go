@@ -4,7 +4,9 @@ import (
 	"aletheiaware.com/authgo"
 	"aletheiaware.com/authgo/authtest"
 	"aletheiaware.com/authgo/handler"
+	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"html/template"
 	"io"
 	"net/http"
@@ -49,12 +51,13 @@ func AccountRecovery(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 	t.Run("Redirects When Email Is Registered", func(t *testing.T) {
 		auth := a(t)
 		acc := authtest.NewTestAccount(t, auth)
-		assert.Nil(t, auth.SetEmailVerified(acc.Email, true))
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), acc.Email, true))
 		mux := http.NewServeMux()
 		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
 		reader := strings.NewReader("email=" + authtest.TEST_EMAIL)
 		request := httptest.NewRequest(http.MethodPost, "/account-recovery", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -76,6 +79,7 @@ func AccountRecovery(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		reader := strings.NewReader("email=" + authtest.TEST_EMAIL)
 		request := httptest.NewRequest(http.MethodPost, "/account-recovery", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -105,6 +109,7 @@ func AccountRecovery(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		reader := strings.NewReader("email=" + authtest.TEST_EMAIL)
 		request := httptest.NewRequest(http.MethodPost, "/account-recovery", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -121,7 +126,7 @@ func AccountRecoveryVerification(t *testing.T, a func(*testing.T) authgo.Authent
 	t.Run("Returns 200 When Recovering", func(t *testing.T) {
 		auth := a(t)
 		authtest.NewTestAccount(t, auth)
-		token, err := auth.NewAccountRecoverySession()
+		token, err := auth.NewAccountRecoverySession(context.Background())
 		assert.Nil(t, err)
 		mux := http.NewServeMux()
 		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
@@ -151,13 +156,13 @@ func AccountRecoveryVerification(t *testing.T, a func(*testing.T) authgo.Authent
 	t.Run("Redirects After Recovery", func(t *testing.T) {
 		auth := a(t)
 		authtest.NewTestAccount(t, auth)
-		token, err := auth.NewAccountRecoverySession()
+		token, err := auth.NewAccountRecoverySession(context.Background())
 		assert.Nil(t, err)
-		err = auth.SetAccountRecoverySessionEmail(token, authtest.TEST_EMAIL)
+		err = auth.SetAccountRecoverySessionEmail(context.Background(), token, authtest.TEST_EMAIL)
 		assert.Nil(t, err)
-		err = auth.SetAccountRecoverySessionUsername(token, authtest.TEST_USERNAME)
+		err = auth.SetAccountRecoverySessionUsername(context.Background(), token, authtest.TEST_USERNAME)
 		assert.Nil(t, err)
-		err = auth.SetAccountRecoverySessionChallenge(token, authtest.TEST_CHALLENGE)
+		err = auth.SetAccountRecoverySessionChallenge(context.Background(), token, authtest.TEST_CHALLENGE)
 		assert.Nil(t, err)
 		mux := http.NewServeMux()
 		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
@@ -167,6 +172,7 @@ func AccountRecoveryVerification(t *testing.T, a func(*testing.T) authgo.Authent
 		request := httptest.NewRequest(http.MethodPost, "/account-recovery-verification", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		request.AddCookie(auth.NewAccountRecoverySessionCookie(token))
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -177,18 +183,48 @@ func AccountRecoveryVerification(t *testing.T, a func(*testing.T) authgo.Authent
 		u, err := result.Location()
 		assert.Nil(t, err)
 		assert.Equal(t, "/account-password", u.String())
-		username, authenticated, _, errmsg, ok := auth.LookupSignInSession(cookies[0].Value)
-		assert.Equal(t, authtest.TEST_USERNAME, username)
-		assert.True(t, authenticated)
-		assert.Empty(t, errmsg)
-		assert.True(t, ok)
+		session := auth.LookupSignInSession(context.Background(), cookies[0].Value)
+		require.NotNil(t, session)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+		assert.True(t, session.Authenticated)
+		assert.Empty(t, session.Error)
+	})
+	t.Run("Requires Second Factor", func(t *testing.T) {
+		auth := a(t)
+		account := authtest.NewTestAccount(t, auth)
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), account.Email, true))
+		secret := authtest.EnableTOTP(t, auth)
+		token, err := auth.NewAccountRecoverySession(context.Background())
+		assert.Nil(t, err)
+		assert.Nil(t, auth.SetAccountRecoverySessionUsername(context.Background(), token, authtest.TEST_USERNAME))
+		assert.Nil(t, auth.SetAccountRecoverySessionChallenge(context.Background(), token, authtest.TEST_CHALLENGE))
+		mux := http.NewServeMux()
+		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		values := url.Values{}
+		values.Add("verification", authtest.TEST_CHALLENGE)
+		result := submitForm(t, auth, mux, "/account-recovery-verification", values, auth.NewAccountRecoverySessionCookie(token))
+		assertLocation(t, result, "/sign-in-totp?next=%2Faccount-password")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+		assert.True(t, session.PendingSecondFactor)
+
+		values = url.Values{}
+		values.Add("code", authtest.NextTOTPCode(secret))
+		assertLocation(t, submitForm(t, auth, mux, "/sign-in-totp?next=%2Faccount-password", values, cookie), "/account-password")
+		session = auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
 	})
 	t.Run("Redirects When Challenge Is Incorrect", func(t *testing.T) {
 		auth := a(t)
-		token, err := auth.NewAccountRecoverySession()
+		token, err := auth.NewAccountRecoverySession(context.Background())
 		assert.Nil(t, err)
 		cookie := auth.NewAccountRecoverySessionCookie(token)
-		err = auth.SetAccountRecoverySessionChallenge(token, authtest.TEST_CHALLENGE)
+		err = auth.SetAccountRecoverySessionChallenge(context.Background(), token, authtest.TEST_CHALLENGE)
 		assert.Nil(t, err)
 		mux := http.NewServeMux()
 		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
@@ -198,6 +234,7 @@ func AccountRecoveryVerification(t *testing.T, a func(*testing.T) authgo.Authent
 		request := httptest.NewRequest(http.MethodPost, "/account-recovery-verification", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		request.AddCookie(cookie)
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -218,3 +255,111 @@ func AccountRecoveryVerification(t *testing.T, a func(*testing.T) authgo.Authent
 		assert.Equal(t, authgo.ErrEmailVerificationIncorrect.Error(), string(body))
 	})
 }
+
+func AccountRecoveryCode(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	tmpl, err := template.New("account-recovery-code.go.html").Parse(`{{.Username}} {{.Error}}`)
+	assert.Nil(t, err)
+	form := func(username, code string) url.Values {
+		values := url.Values{}
+		values.Add("username", username)
+		values.Add("code", code)
+		return values
+	}
+	t.Run("Returns 200 When Not Signed In", func(t *testing.T) {
+		auth := a(t)
+		mux := http.NewServeMux()
+		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
+		assert.Equal(t, " ", getBody(t, mux, "/account-recovery-code"))
+	})
+	t.Run("Redirects After Recovery", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		codes, err := auth.NewRecoveryCodes(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		mux := http.NewServeMux()
+		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
+		result := submitForm(t, auth, mux, "/account-recovery-code?next=%2Fproducts", form(authtest.TEST_USERNAME, codes[0]))
+		assertLocation(t, result, "/account-password?next=%2Fproducts")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+		assert.True(t, session.Authenticated)
+		assert.Equal(t, authgo.RECOVERY_CODE_COUNT-1, auth.LookupRecoveryCodes(context.Background(), authtest.TEST_USERNAME).Remaining)
+	})
+	t.Run("Does Not Require Second Factor", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		authtest.EnableTOTP(t, auth)
+		codes, err := auth.NewRecoveryCodes(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		mux := http.NewServeMux()
+		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
+		result := submitForm(t, auth, mux, "/account-recovery-code", form(authtest.TEST_USERNAME, codes[0]))
+		// The recovery code stands in for the authentication code
+		assertLocation(t, result, "/account-password")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+		assert.False(t, session.PendingSecondFactor)
+	})
+	t.Run("Redirects When Code Is Incorrect", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		codes, err := auth.NewRecoveryCodes(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		mux := http.NewServeMux()
+		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
+		result := submitForm(t, auth, mux, "/account-recovery-code", form(authtest.TEST_USERNAME, "aaaa-bbbb-cccc-dddd"))
+		assertLocation(t, result, "/account-recovery-code")
+		assert.Nil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_ACCOUNT_RECOVERY)
+		require.NotNil(t, cookie)
+
+		// Subsequent Get request should show error
+		assert.Equal(t, " "+authgo.ErrRecoveryCodeIncorrect.Error(), getBody(t, mux, "/account-recovery-code", cookie))
+
+		// Codes of other accounts are not accepted
+		_, err = auth.NewAccount(context.Background(), "bob@example.com", "bob", []byte(authtest.TEST_PASSWORD))
+		assert.Nil(t, err)
+		assertLocation(t, submitForm(t, auth, mux, "/account-recovery-code", form("bob", codes[0]), cookie), "/account-recovery-code")
+		assert.Equal(t, " "+authgo.ErrRecoveryCodeIncorrect.Error(), getBody(t, mux, "/account-recovery-code", cookie))
+	})
+	t.Run("Does Not Recover With Incorrect Code And Empty Verification", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		_, err := auth.NewRecoveryCodes(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		mux := http.NewServeMux()
+		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
+		result := submitForm(t, auth, mux, "/account-recovery-code", form(authtest.TEST_USERNAME, "aaaa-bbbb-cccc-dddd"))
+		assertLocation(t, result, "/account-recovery-code")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_ACCOUNT_RECOVERY)
+		require.NotNil(t, cookie)
+		session := auth.LookupAccountRecoverySession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.Empty(t, session.Username)
+
+		// The session has no challenge, so an empty verification must not sign in
+		values := url.Values{}
+		values.Add("verification", "")
+		result = submitForm(t, auth, mux, "/account-recovery-verification", values, cookie)
+		assertLocation(t, result, "/account-recovery")
+		assert.Nil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+	})
+	t.Run("Redirects When Account Deactivated", func(t *testing.T) {
+		auth := a(t)
+		account := authtest.NewTestAccount(t, auth)
+		codes, err := auth.NewRecoveryCodes(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		assert.Nil(t, auth.DeactivateAccount(context.Background(), account))
+		mux := http.NewServeMux()
+		handler.AttachAccountRecoveryHandler(mux, auth, tmpl)
+		result := submitForm(t, auth, mux, "/account-recovery-code", form(authtest.TEST_USERNAME, codes[0]))
+		assertLocation(t, result, "/account-recovery-code")
+		assert.Nil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+	})
+}
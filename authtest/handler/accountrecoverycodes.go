@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func AccountRecoveryCodes(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	tmpl, err := template.New("account-recovery-codes.go.html").Parse(`{{with .RecoveryCodes}}{{.Remaining}}{{end}}{{range .Codes}} {{.}}{{end}}`)
+	assert.Nil(t, err)
+	t.Run("Redirects When Not Signed In", func(t *testing.T) {
+		auth := a(t)
+		mux := http.NewServeMux()
+		handler.AttachAccountRecoveryCodesHandler(mux, auth, tmpl)
+		request := httptest.NewRequest(http.MethodGet, "/account-recovery-codes", nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		assertLocation(t, response.Result(), "/sign-in?next=%2Faccount-recovery-codes")
+	})
+	t.Run("Generates Codes", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		mux := http.NewServeMux()
+		handler.AttachAccountRecoveryCodesHandler(mux, auth, tmpl)
+		assert.Empty(t, getBody(t, mux, "/account-recovery-codes", cookie))
+
+		result := submitForm(t, auth, mux, "/account-recovery-codes", url.Values{}, cookie)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, "no-store", result.Header.Get("Cache-Control"))
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		parts := strings.Fields(string(body))
+		require.Equal(t, 1+authgo.RECOVERY_CODE_COUNT, len(parts))
+		assert.Equal(t, "10", parts[0])
+
+		// Codes are only shown once
+		assert.Equal(t, "10", getBody(t, mux, "/account-recovery-codes", cookie))
+
+		assert.Nil(t, auth.VerifyRecoveryCode(context.Background(), authtest.TEST_USERNAME, parts[1]))
+		assert.Equal(t, "9", getBody(t, mux, "/account-recovery-codes", cookie))
+	})
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func AccountSessions(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	tmpl, err := template.New("account-sessions.go.html").Parse(`{{.Error}}{{range .Sessions}}{{if eq .ID $.Current}}*{{end}}{{.ID}}:{{.UserAgent}};{{end}}`)
+	assert.Nil(t, err)
+	setup := func(t *testing.T) (authgo.Authenticator, *http.Cookie, *http.ServeMux) {
+		t.Helper()
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		mux := http.NewServeMux()
+		handler.AttachAccountSessionsHandler(mux, auth, tmpl)
+		return auth, cookie, mux
+	}
+	other := func(t *testing.T, auth authgo.Authenticator) *authgo.SignInSession {
+		t.Helper()
+		token, err := auth.NewSignInSession(authgo.WithClientUserAgent(context.Background(), "Safari"), authtest.TEST_USERNAME, true)
+		require.Nil(t, err)
+		session := auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		return session
+	}
+	t.Run("Redirects When Not Signed In", func(t *testing.T) {
+		auth := a(t)
+		mux := http.NewServeMux()
+		handler.AttachAccountSessionsHandler(mux, auth, tmpl)
+		request := httptest.NewRequest(http.MethodGet, "/account-sessions", nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		assertLocation(t, response.Result(), "/sign-in?next=%2Faccount-sessions")
+	})
+	t.Run("Lists Sessions", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		session := other(t, auth)
+		current := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, current)
+		body := getBody(t, mux, "/account-sessions", cookie)
+		assert.Contains(t, body, "*"+current.ID()+":;")
+		assert.Contains(t, body, session.ID()+":Safari;")
+		assert.NotContains(t, body, "*"+session.ID())
+		assert.NotContains(t, body, cookie.Value)
+		assert.NotContains(t, body, session.Token)
+	})
+	t.Run("Revokes Session", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		session := other(t, auth)
+		values := url.Values{}
+		values.Set("action", "revoke")
+		values.Set("id", session.ID())
+		assertLocation(t, submitForm(t, auth, mux, "/account-sessions", values, cookie), "/account-sessions")
+		assert.NotContains(t, getBody(t, mux, "/account-sessions", cookie), session.ID())
+		session = auth.LookupSignInSession(context.Background(), session.Token)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+	})
+	t.Run("Shows Error When Session Not Found", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		values := url.Values{}
+		values.Set("action", "revoke")
+		values.Set("id", "foobar")
+		result := submitForm(t, auth, mux, "/account-sessions", values, cookie)
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(string(body), authgo.ErrSignInSessionNotFound.Error()), string(body))
+	})
+	t.Run("Revokes Other Sessions", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		first := other(t, auth)
+		second := other(t, auth)
+		values := url.Values{}
+		values.Set("action", "revoke-others")
+		assertLocation(t, submitForm(t, auth, mux, "/account-sessions", values, cookie), "/account-sessions")
+		sessions, err := auth.LookupSignInSessions(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		require.Equal(t, 1, len(sessions))
+		assert.Equal(t, cookie.Value, sessions[0].Token)
+		for _, s := range []*authgo.SignInSession{first, second} {
+			session := auth.LookupSignInSession(context.Background(), s.Token)
+			require.NotNil(t, session)
+			assert.False(t, session.Authenticated)
+		}
+	})
+	t.Run("Revoked Session Is Signed Out", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		session := other(t, auth)
+		values := url.Values{}
+		values.Set("action", "revoke-others")
+		assertLocation(t, submitForm(t, auth, mux, "/account-sessions", values, cookie), "/account-sessions")
+		request := httptest.NewRequest(http.MethodGet, "/account-sessions", nil)
+		request.AddCookie(auth.NewSignInSessionCookie(session.Token))
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		assertLocation(t, response.Result(), "/sign-in?next=%2Faccount-sessions")
+	})
+	t.Run("Rejects Unknown Action", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		values := url.Values{}
+		values.Set("action", "foobar")
+		result := submitForm(t, auth, mux, "/account-sessions", values, cookie)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+}
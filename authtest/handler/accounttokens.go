@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func AccountTokens(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	tmpl, err := template.New("account-tokens.go.html").Parse(`{{.Error}}{{range .Tokens}}{{.Name}}:{{.ID}}:{{range .Scopes}}{{.}},{{end}}{{if not .Expires.IsZero}}expires{{end}};{{end}}{{with .NewToken}}Token {{.}}{{end}}`)
+	assert.Nil(t, err)
+	setup := func(t *testing.T) (authgo.Authenticator, *http.Cookie, *http.ServeMux) {
+		t.Helper()
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPersonalAccessTokenScopes([]string{"products:read", "products:write"})
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		mux := http.NewServeMux()
+		handler.AttachAccountTokensHandler(mux, auth, tmpl)
+		return auth, cookie, mux
+	}
+	create := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, cookie *http.Cookie, name, expires string, scopes ...string) string {
+		t.Helper()
+		values := url.Values{}
+		values.Set("action", "create")
+		values.Set("name", name)
+		values.Set("expires", expires)
+		for _, s := range scopes {
+			values.Add("scope", s)
+		}
+		result := submitForm(t, auth, mux, "/account-tokens", values, cookie)
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		return string(body)
+	}
+	t.Run("Redirects When Not Signed In", func(t *testing.T) {
+		auth := a(t)
+		mux := http.NewServeMux()
+		handler.AttachAccountTokensHandler(mux, auth, tmpl)
+		request := httptest.NewRequest(http.MethodGet, "/account-tokens", nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		assertLocation(t, response.Result(), "/sign-in?next=%2Faccount-tokens")
+	})
+	t.Run("Creates Token", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		assert.Empty(t, getBody(t, mux, "/account-tokens", cookie))
+
+		body := create(t, auth, mux, cookie, "Deploy", "30", "products:read")
+		parts := strings.SplitN(body, "Token ", 2)
+		require.Equal(t, 2, len(parts), body)
+		assert.True(t, strings.HasPrefix(parts[0], "Deploy:"), body)
+		assert.True(t, strings.HasSuffix(parts[0], ":products:read,expires;"), body)
+		token := parts[1]
+
+		// Tokens are only shown once
+		assert.NotContains(t, getBody(t, mux, "/account-tokens", cookie), token)
+
+		account, pat, err := auth.AuthenticatePersonalAccessToken(context.Background(), token)
+		assert.Nil(t, err)
+		assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+		assert.True(t, pat.Expires.After(time.Now().AddDate(0, 0, 29)))
+	})
+	t.Run("Creates Token That Never Expires", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		body := create(t, auth, mux, cookie, "Deploy", "")
+		assert.True(t, strings.HasPrefix(body, "Deploy:"), body)
+		assert.Contains(t, body, ":;Token "+authgo.PERSONAL_ACCESS_TOKEN_PREFIX)
+	})
+	t.Run("Shows Error", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		assert.Equal(t, authgo.ErrPersonalAccessTokenNameMissing.Error(), create(t, auth, mux, cookie, "", ""))
+		assert.Equal(t, authgo.ErrPersonalAccessTokenExpiryInvalid.Error(), create(t, auth, mux, cookie, "Deploy", "never"))
+		assert.Contains(t, create(t, auth, mux, cookie, "Deploy", "", "admin"), authgo.ErrPersonalAccessTokenScopeInvalid.Error())
+	})
+	t.Run("Deletes Token", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		token, err := auth.NewPersonalAccessToken(context.Background(), authtest.TEST_USERNAME, "Deploy", nil, time.Time{})
+		require.Nil(t, err)
+		tokens, err := auth.LookupPersonalAccessTokens(context.Background(), authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(tokens))
+
+		values := url.Values{}
+		values.Set("action", "delete")
+		values.Set("id", tokens[0].ID)
+		assertLocation(t, submitForm(t, auth, mux, "/account-tokens", values, cookie), "/account-tokens")
+		assert.Empty(t, getBody(t, mux, "/account-tokens", cookie))
+		_, _, err = auth.AuthenticatePersonalAccessToken(context.Background(), token)
+		assert.Equal(t, authgo.ErrPersonalAccessTokenInvalid, err)
+	})
+	t.Run("Scope", func(t *testing.T) {
+		auth, _, _ := setup(t)
+		mux := http.NewServeMux()
+		mux.Handle("/api/products", handler.RequireBearerScope(auth, "products:write", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})))
+		post := func(token string) *http.Response {
+			request := httptest.NewRequest(http.MethodPost, "/api/products", nil)
+			request.Header.Set("Authorization", "Bearer "+token)
+			response := httptest.NewRecorder()
+			mux.ServeHTTP(response, request)
+			return response.Result()
+		}
+		read, err := auth.NewPersonalAccessToken(context.Background(), authtest.TEST_USERNAME, "Read", []string{"products:read"}, time.Time{})
+		require.Nil(t, err)
+		result := post(read)
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+		assert.Contains(t, result.Header.Get("WWW-Authenticate"), `error="insufficient_scope"`)
+
+		write, err := auth.NewPersonalAccessToken(context.Background(), authtest.TEST_USERNAME, "Write", []string{"products:write"}, time.Time{})
+		require.Nil(t, err)
+		assert.Equal(t, http.StatusNoContent, post(write).StatusCode)
+
+		// Access tokens allow every scope
+		tokens, err := auth.NewBearerTokens(context.Background(), authtest.TEST_USERNAME)
+		require.Nil(t, err)
+		assert.Equal(t, http.StatusNoContent, post(tokens.AccessToken).StatusCode)
+
+		assert.Equal(t, http.StatusUnauthorized, post("invalid").StatusCode)
+	})
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"encoding/base32"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func AccountTOTP(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	tmpl, err := template.New("account-totp.go.html").Parse(`{{.Enabled}}{{with .Enrollment}} {{.Secret}}{{end}}{{with .Error}} {{.}}{{end}}`)
+	assert.Nil(t, err)
+	get := func(t *testing.T, mux *http.ServeMux, cookie *http.Cookie) string {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodGet, "/account-totp", nil)
+		request.AddCookie(cookie)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		return string(body)
+	}
+	post := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, cookie *http.Cookie, action, code string, current ...string) *http.Response {
+		t.Helper()
+		values := url.Values{}
+		values.Add("action", action)
+		values.Add("code", code)
+		for _, c := range current {
+			values.Add("current", c)
+		}
+		request := httptest.NewRequest(http.MethodPost, "/account-totp", strings.NewReader(values.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(cookie)
+		authtest.AddCSRF(auth, request)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response.Result()
+	}
+	assertRedirect := func(t *testing.T, result *http.Response) {
+		t.Helper()
+		assert.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "/account-totp", u.String())
+	}
+	assertError := func(t *testing.T, result *http.Response, expected string) {
+		t.Helper()
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(body))
+	}
+	t.Run("Redirects When Not Signed In", func(t *testing.T) {
+		auth := a(t)
+		mux := http.NewServeMux()
+		handler.AttachAccountTOTPHandler(mux, auth, tmpl)
+		request := httptest.NewRequest(http.MethodGet, "/account-totp", nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		assert.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "/sign-in?next=%2Faccount-totp", u.String())
+	})
+	t.Run("Enroll Confirm Disable", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		mux := http.NewServeMux()
+		handler.AttachAccountTOTPHandler(mux, auth, tmpl)
+		assert.Equal(t, "false", get(t, mux, cookie))
+
+		assertRedirect(t, post(t, auth, mux, cookie, "enroll", ""))
+		enrollment := auth.LookupTOTPEnrollment(context.Background(), authtest.TEST_USERNAME)
+		if !assert.NotNil(t, enrollment) {
+			return
+		}
+		assert.Equal(t, "false "+enrollment.Secret, get(t, mux, cookie))
+		secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+		assert.Nil(t, err)
+
+		assertError(t, post(t, auth, mux, cookie, "confirm", "000000"), "false "+enrollment.Secret+" "+authgo.ErrTOTPCodeIncorrect.Error())
+		assertRedirect(t, post(t, auth, mux, cookie, "confirm", authgo.TOTPCode(secret, time.Now())))
+		assert.Equal(t, "true", get(t, mux, cookie))
+
+		assertError(t, post(t, auth, mux, cookie, "disable", "000000"), "true "+authgo.ErrTOTPCodeIncorrect.Error())
+		assertRedirect(t, post(t, auth, mux, cookie, "disable", authtest.NextTOTPCode(secret)))
+		assert.Equal(t, "false", get(t, mux, cookie))
+	})
+	t.Run("Replace Requires Current Code", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		current := authtest.EnableTOTP(t, auth)
+		codes, err := auth.NewRecoveryCodes(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		mux := http.NewServeMux()
+		handler.AttachAccountTOTPHandler(mux, auth, tmpl)
+		enroll := func(t *testing.T) ([]byte, string) {
+			t.Helper()
+			assertRedirect(t, post(t, auth, mux, cookie, "enroll", ""))
+			enrollment := auth.LookupTOTPEnrollment(context.Background(), authtest.TEST_USERNAME)
+			if !assert.NotNil(t, enrollment) {
+				t.FailNow()
+			}
+			secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(enrollment.Secret)
+			assert.Nil(t, err)
+			return secret, enrollment.Secret
+		}
+
+		// A stolen session cannot replace the app
+		replacement, encoded := enroll(t)
+		code := authgo.TOTPCode(replacement, time.Now())
+		assertError(t, post(t, auth, mux, cookie, "confirm", code), "true "+encoded+" "+authgo.ErrTOTPCodeIncorrect.Error())
+		assertError(t, post(t, auth, mux, cookie, "confirm", code, "000000"), "true "+encoded+" "+authgo.ErrTOTPCodeIncorrect.Error())
+		assertError(t, post(t, auth, mux, cookie, "confirm", code, "aaaa-bbbb-cccc-dddd"), "true "+encoded+" "+authgo.ErrRecoveryCodeIncorrect.Error())
+
+		// The current app, or a recovery code, allows it to be replaced
+		assertRedirect(t, post(t, auth, mux, cookie, "confirm", code, authtest.NextTOTPCode(current)))
+		assert.Equal(t, "true", get(t, mux, cookie))
+		replacement, _ = enroll(t)
+		assertRedirect(t, post(t, auth, mux, cookie, "confirm", authgo.TOTPCode(replacement, time.Now()), codes[0]))
+		assert.Equal(t, "true", get(t, mux, cookie))
+	})
+	t.Run("Rejects Unknown Action", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachAccountTOTPHandler(mux, auth, tmpl)
+		result := post(t, auth, mux, auth.NewSignInSessionCookie(token), "foo", "")
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	})
+}
@@ -0,0 +1,510 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func API(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	newMux := func(auth authgo.Authenticator) *http.ServeMux {
+		mux := http.NewServeMux()
+		handler.AttachAPIHandlers(mux, auth, &handler.CORS{
+			Origins: []string{"https://app.example.com"},
+			MaxAge:  time.Hour,
+		})
+		return mux
+	}
+	t.Run("WhoAmI", func(t *testing.T) {
+		t.Run("Returns 401 When Not Signed In", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			result := getAPI(t, mux, "/api/whoami")
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.Equal(t, "sign_in_required", decodeAPIError(t, result))
+		})
+		t.Run("Returns Account When Signed In", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			authtest.NewTestAccount(t, auth)
+			token, _ := authtest.SignIn(t, auth)
+			result := getAPI(t, mux, "/api/whoami", auth.NewSignInSessionCookie(token))
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			account := decodeAPIAccount(t, result)
+			assert.Equal(t, authtest.TEST_USERNAME, account.Username)
+			assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+		})
+		t.Run("Returns 405 For Other Methods", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			result := postAPI(t, mux, "/api/whoami", nil)
+			assert.Equal(t, http.StatusMethodNotAllowed, result.StatusCode)
+			assert.Equal(t, http.MethodGet, result.Header.Get("Allow"))
+			assert.Equal(t, "method_not_allowed", decodeAPIError(t, result))
+		})
+	})
+	t.Run("SignUp", func(t *testing.T) {
+		body := map[string]string{
+			"email":        authtest.TEST_EMAIL,
+			"username":     authtest.TEST_USERNAME,
+			"password":     authtest.TEST_PASSWORD,
+			"confirmation": authtest.TEST_PASSWORD,
+		}
+		t.Run("Signs In After Verification", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			result := postAPI(t, mux, "/api/sign-up", body)
+			assert.Equal(t, http.StatusAccepted, result.StatusCode)
+			signUp := findCookie(result.Cookies(), authgo.COOKIE_SIGN_UP)
+			require.NotNil(t, signUp)
+			assert.False(t, auth.IsEmailVerified(context.Background(), authtest.TEST_EMAIL))
+
+			result = postAPI(t, mux, "/api/sign-up-verification", map[string]string{
+				"verification": "wrong",
+			}, signUp)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+			assert.Equal(t, "verification_incorrect", decodeAPIError(t, result))
+
+			result = postAPI(t, mux, "/api/sign-up-verification", map[string]string{
+				"verification": authtest.TEST_CHALLENGE,
+			}, signUp)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.Equal(t, authtest.TEST_USERNAME, decodeAPIAccount(t, result).Username)
+			assert.True(t, auth.IsEmailVerified(context.Background(), authtest.TEST_EMAIL))
+			signIn := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+			require.NotNil(t, signIn)
+			assert.Equal(t, http.StatusOK, getAPI(t, mux, "/api/whoami", signIn).StatusCode)
+		})
+		t.Run("Returns Error Code When Invalid", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			result := postAPI(t, mux, "/api/sign-up", map[string]string{
+				"email":        authtest.TEST_EMAIL,
+				"username":     authtest.TEST_USERNAME,
+				"password":     authtest.TEST_PASSWORD,
+				"confirmation": "mismatch",
+			})
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+			e := decodeAPIErrorMessage(t, result)
+			assert.Equal(t, "passwords_do_not_match", e.Code)
+			assert.Equal(t, authgo.ErrPasswordsDoNotMatch.Error(), e.Message)
+			assert.Nil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_UP))
+		})
+		t.Run("Returns 409 When Username Registered", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			authtest.NewTestAccount(t, auth)
+			result := postAPI(t, mux, "/api/sign-up", map[string]string{
+				"email":        "bob@example.com",
+				"username":     authtest.TEST_USERNAME,
+				"password":     authtest.TEST_PASSWORD,
+				"confirmation": authtest.TEST_PASSWORD,
+			})
+			assert.Equal(t, http.StatusConflict, result.StatusCode)
+			assert.Equal(t, "username_already_registered", decodeAPIError(t, result))
+		})
+		t.Run("Returns 400 Without Session", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			result := postAPI(t, mux, "/api/sign-up-verification", map[string]string{
+				"verification": authtest.TEST_CHALLENGE,
+			})
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+			assert.Equal(t, "session_not_found", decodeAPIError(t, result))
+		})
+	})
+	t.Run("SignIn", func(t *testing.T) {
+		body := map[string]string{
+			"username": authtest.TEST_USERNAME,
+			"password": authtest.TEST_PASSWORD,
+		}
+		t.Run("Sets Cookie", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			acc := authtest.NewTestAccount(t, auth)
+			assert.Nil(t, auth.SetEmailVerified(context.Background(), acc.Email, true))
+			result := postAPI(t, mux, "/api/sign-in", body)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.Equal(t, authtest.TEST_USERNAME, decodeAPIAccount(t, result).Username)
+			signIn := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+			require.NotNil(t, signIn)
+			session := auth.LookupSignInSession(context.Background(), signIn.Value)
+			require.NotNil(t, session)
+			assert.True(t, session.Authenticated)
+		})
+		t.Run("Replaces Previous Session", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			acc := authtest.NewTestAccount(t, auth)
+			assert.Nil(t, auth.SetEmailVerified(context.Background(), acc.Email, true))
+			token, _ := authtest.SignIn(t, auth)
+			result := postAPI(t, mux, "/api/sign-in", body, auth.NewSignInSessionCookie(token))
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			signIn := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+			require.NotNil(t, signIn)
+			assert.NotEqual(t, token, signIn.Value)
+			session := auth.LookupSignInSession(context.Background(), token)
+			require.NotNil(t, session)
+			assert.False(t, session.Authenticated)
+		})
+		t.Run("Returns 401 When Credentials Incorrect", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			authtest.NewTestAccount(t, auth)
+			result := postAPI(t, mux, "/api/sign-in", map[string]string{
+				"username": authtest.TEST_USERNAME,
+				"password": "wrong",
+			})
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.Equal(t, "credentials_incorrect", decodeAPIError(t, result))
+			assert.Nil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+		})
+		t.Run("Requires Authentication Code When Two-Factor Enabled", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			acc := authtest.NewTestAccount(t, auth)
+			assert.Nil(t, auth.SetEmailVerified(context.Background(), acc.Email, true))
+			secret := authtest.EnableTOTP(t, auth)
+			result := postAPI(t, mux, "/api/sign-in", body)
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.Equal(t, "totp_required", decodeAPIError(t, result))
+			assert.Nil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+
+			result = postAPI(t, mux, "/api/sign-in", map[string]string{
+				"username": authtest.TEST_USERNAME,
+				"password": authtest.TEST_PASSWORD,
+				"code":     "000000",
+			})
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.Equal(t, "totp_code_incorrect", decodeAPIError(t, result))
+
+			result = postAPI(t, mux, "/api/sign-in", map[string]string{
+				"username": authtest.TEST_USERNAME,
+				"password": authtest.TEST_PASSWORD,
+				"code":     authtest.NextTOTPCode(secret),
+			})
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.NotNil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+		})
+		t.Run("Requires Verification When Email Not Verified", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			authtest.NewTestAccount(t, auth)
+			result := postAPI(t, mux, "/api/sign-in", body)
+			assert.Equal(t, http.StatusForbidden, result.StatusCode)
+			assert.Equal(t, "email_not_verified", decodeAPIError(t, result))
+			assert.Nil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+			signUp := findCookie(result.Cookies(), authgo.COOKIE_SIGN_UP)
+			require.NotNil(t, signUp)
+
+			result = postAPI(t, mux, "/api/sign-up-verification", map[string]string{
+				"verification": authtest.TEST_CHALLENGE,
+			}, signUp)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.NotNil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+		})
+	})
+	t.Run("SignOut", func(t *testing.T) {
+		t.Run("Returns 401 When Not Signed In", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			result := postAPI(t, mux, "/api/sign-out", nil)
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.Equal(t, "sign_in_required", decodeAPIError(t, result))
+		})
+		t.Run("Signs Out", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			authtest.NewTestAccount(t, auth)
+			token, _ := authtest.SignIn(t, auth)
+			cookie := auth.NewSignInSessionCookie(token)
+			result := postAPI(t, mux, "/api/sign-out", nil, cookie)
+			assert.Equal(t, http.StatusNoContent, result.StatusCode)
+			assert.Equal(t, http.StatusUnauthorized, getAPI(t, mux, "/api/whoami", cookie).StatusCode)
+		})
+	})
+	t.Run("AccountPassword", func(t *testing.T) {
+		t.Run("Returns 401 When Not Signed In", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			result := postAPI(t, mux, "/api/account-password", map[string]string{
+				"password":     "violet-marble-stapler-97",
+				"confirmation": "violet-marble-stapler-97",
+			})
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.Equal(t, "sign_in_required", decodeAPIError(t, result))
+		})
+		t.Run("Changes Password", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			authtest.NewTestAccount(t, auth)
+			token, _ := authtest.SignIn(t, auth)
+			cookie := auth.NewSignInSessionCookie(token)
+			result := postAPI(t, mux, "/api/account-password", map[string]string{
+				"password":     "violet-marble-stapler-97",
+				"confirmation": "violet-marble-stapler-98",
+			}, cookie)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+			assert.Equal(t, "passwords_do_not_match", decodeAPIError(t, result))
+
+			result = postAPI(t, mux, "/api/account-password", map[string]string{
+				"password":     "violet-marble-stapler-97",
+				"confirmation": "violet-marble-stapler-97",
+			}, cookie)
+			assert.Equal(t, http.StatusNoContent, result.StatusCode)
+			_, err := auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte("violet-marble-stapler-97"))
+			assert.Nil(t, err)
+		})
+	})
+	t.Run("AccountRecovery", func(t *testing.T) {
+		t.Run("Signs In After Verification", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			authtest.NewTestAccount(t, auth)
+			result := postAPI(t, mux, "/api/account-recovery", map[string]string{
+				"email": authtest.TEST_EMAIL,
+			})
+			assert.Equal(t, http.StatusAccepted, result.StatusCode)
+			recovery := findCookie(result.Cookies(), authgo.COOKIE_ACCOUNT_RECOVERY)
+			require.NotNil(t, recovery)
+
+			result = postAPI(t, mux, "/api/account-recovery-verification", map[string]string{
+				"verification": "wrong",
+			}, recovery)
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+			assert.Equal(t, "verification_incorrect", decodeAPIError(t, result))
+
+			result = postAPI(t, mux, "/api/account-recovery-verification", map[string]string{
+				"verification": authtest.TEST_CHALLENGE,
+			}, recovery)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.Equal(t, authtest.TEST_USERNAME, decodeAPIAccount(t, result).Username)
+			assert.NotNil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+		})
+		t.Run("Requires Authentication Code When Two-Factor Enabled", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			authtest.NewTestAccount(t, auth)
+			secret := authtest.EnableTOTP(t, auth)
+			result := postAPI(t, mux, "/api/account-recovery", map[string]string{
+				"email": authtest.TEST_EMAIL,
+			})
+			assert.Equal(t, http.StatusAccepted, result.StatusCode)
+			recovery := findCookie(result.Cookies(), authgo.COOKIE_ACCOUNT_RECOVERY)
+			require.NotNil(t, recovery)
+
+			result = postAPI(t, mux, "/api/account-recovery-verification", map[string]string{
+				"verification": authtest.TEST_CHALLENGE,
+			}, recovery)
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.Equal(t, "totp_required", decodeAPIError(t, result))
+			assert.Nil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+
+			result = postAPI(t, mux, "/api/account-recovery-verification", map[string]string{
+				"verification": authtest.TEST_CHALLENGE,
+				"code":         "000000",
+			}, recovery)
+			assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+			assert.Equal(t, "totp_code_incorrect", decodeAPIError(t, result))
+			assert.Nil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+
+			result = postAPI(t, mux, "/api/account-recovery-verification", map[string]string{
+				"verification": authtest.TEST_CHALLENGE,
+				"code":         authtest.NextTOTPCode(secret),
+			}, recovery)
+			assert.Equal(t, http.StatusOK, result.StatusCode)
+			assert.NotNil(t, findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN))
+		})
+		t.Run("Returns 404 When Email Not Registered", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			result := postAPI(t, mux, "/api/account-recovery", map[string]string{
+				"email": authtest.TEST_EMAIL,
+			})
+			assert.Equal(t, http.StatusNotFound, result.StatusCode)
+			assert.Equal(t, "email_not_registered", decodeAPIError(t, result))
+		})
+	})
+	t.Run("AccountDeactivate", func(t *testing.T) {
+		auth := a(t)
+		mux := newMux(auth)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := auth.NewSignInSessionCookie(token)
+		result := postAPI(t, mux, "/api/account-deactivate", nil, cookie)
+		assert.Equal(t, http.StatusNoContent, result.StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, getAPI(t, mux, "/api/whoami", cookie).StatusCode)
+		_, err := auth.LookupAccount(context.Background(), authtest.TEST_USERNAME)
+		assert.NotNil(t, err)
+	})
+	t.Run("Request", func(t *testing.T) {
+		t.Run("Returns 415 When Not JSON", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			authtest.NewTestAccount(t, auth)
+			token, _ := authtest.SignIn(t, auth)
+			request := httptest.NewRequest(http.MethodPost, "/api/sign-out", strings.NewReader(""))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			request.AddCookie(auth.NewSignInSessionCookie(token))
+			response := httptest.NewRecorder()
+			mux.ServeHTTP(response, request)
+			result := response.Result()
+			assert.Equal(t, http.StatusUnsupportedMediaType, result.StatusCode)
+			assert.Equal(t, "unsupported_media_type", decodeAPIError(t, result))
+			session := auth.LookupSignInSession(context.Background(), token)
+			require.NotNil(t, session)
+			assert.True(t, session.Authenticated)
+		})
+		t.Run("Returns 400 When JSON Invalid", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			request := httptest.NewRequest(http.MethodPost, "/api/sign-in", strings.NewReader("{"))
+			request.Header.Set("Content-Type", "application/json")
+			response := httptest.NewRecorder()
+			mux.ServeHTTP(response, request)
+			result := response.Result()
+			assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+			assert.Equal(t, "invalid_request", decodeAPIError(t, result))
+		})
+	})
+	t.Run("CORS", func(t *testing.T) {
+		t.Run("Allows Configured Origin", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			request := httptest.NewRequest(http.MethodOptions, "/api/sign-in", nil)
+			request.Header.Set("Origin", "https://app.example.com")
+			request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			request.Header.Set("Access-Control-Request-Headers", "content-type")
+			response := httptest.NewRecorder()
+			mux.ServeHTTP(response, request)
+			result := response.Result()
+			assert.Equal(t, http.StatusNoContent, result.StatusCode)
+			assert.Equal(t, "https://app.example.com", result.Header.Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, "true", result.Header.Get("Access-Control-Allow-Credentials"))
+			assert.Contains(t, result.Header.Get("Access-Control-Allow-Methods"), http.MethodPost)
+			assert.Equal(t, "Content-Type", result.Header.Get("Access-Control-Allow-Headers"))
+			assert.Equal(t, "3600", result.Header.Get("Access-Control-Max-Age"))
+
+			request = httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+			request.Header.Set("Origin", "https://app.example.com")
+			response = httptest.NewRecorder()
+			mux.ServeHTTP(response, request)
+			result = response.Result()
+			assert.Equal(t, "https://app.example.com", result.Header.Get("Access-Control-Allow-Origin"))
+		})
+		t.Run("Rejects Other Origins", func(t *testing.T) {
+			auth := a(t)
+			mux := newMux(auth)
+			request := httptest.NewRequest(http.MethodOptions, "/api/sign-in", nil)
+			request.Header.Set("Origin", "https://evil.example.com")
+			request.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			response := httptest.NewRecorder()
+			mux.ServeHTTP(response, request)
+			result := response.Result()
+			assert.Equal(t, http.StatusForbidden, result.StatusCode)
+			assert.Empty(t, result.Header.Get("Access-Control-Allow-Origin"))
+
+			request = httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+			request.Header.Set("Origin", "https://evil.example.com")
+			response = httptest.NewRecorder()
+			mux.ServeHTTP(response, request)
+			result = response.Result()
+			assert.Empty(t, result.Header.Get("Access-Control-Allow-Origin"))
+			assert.Empty(t, result.Header.Get("Access-Control-Allow-Credentials"))
+		})
+	})
+	t.Run("OpenAPI", func(t *testing.T) {
+		auth := a(t)
+		mux := newMux(auth)
+		result := getAPI(t, mux, "/api/openapi.json")
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+		var document struct {
+			OpenAPI string `json:"openapi"`
+			Paths   map[string]map[string]struct {
+				OperationID string                     `json:"operationId"`
+				RequestBody *json.RawMessage           `json:"requestBody"`
+				Responses   map[string]json.RawMessage `json:"responses"`
+			} `json:"paths"`
+		}
+		assert.Nil(t, json.NewDecoder(result.Body).Decode(&document))
+		assert.Equal(t, handler.OPENAPI_VERSION, document.OpenAPI)
+		operations := handler.APIOperations()
+		assert.Equal(t, len(operations), len(document.Paths))
+		for _, o := range operations {
+			path, ok := document.Paths[o.Path]
+			require.True(t, ok, o.Path)
+			operation, ok := path[strings.ToLower(o.Method)]
+			require.True(t, ok, o.Path)
+			assert.Equal(t, o.ID, operation.OperationID)
+			assert.Equal(t, o.Request != nil, operation.RequestBody != nil, o.Path)
+			assert.Contains(t, operation.Responses, "500", o.Path)
+		}
+		signIn := document.Paths["/api/sign-in"]["post"]
+		assert.Contains(t, signIn.Responses, "200")
+		assert.Contains(t, signIn.Responses, "401")
+		assert.Contains(t, signIn.Responses, "403")
+		assert.Contains(t, string(signIn.Responses["401"]), "totp_required")
+	})
+}
+
+// postAPI posts the body as JSON to the path with the cookies, returning the result.
+func postAPI(t *testing.T, mux *http.ServeMux, path string, body interface{}, cookies ...*http.Cookie) *http.Response {
+	t.Helper()
+	var buffer bytes.Buffer
+	if body != nil {
+		assert.Nil(t, json.NewEncoder(&buffer).Encode(body))
+	}
+	request := httptest.NewRequest(http.MethodPost, path, &buffer)
+	request.Header.Set("Content-Type", "application/json")
+	for _, c := range cookies {
+		request.AddCookie(c)
+	}
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, request)
+	return response.Result()
+}
+
+// getAPI gets the path with the cookies, returning the result.
+func getAPI(t *testing.T, mux *http.ServeMux, path string, cookies ...*http.Cookie) *http.Response {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, c := range cookies {
+		request.AddCookie(c)
+	}
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, request)
+	return response.Result()
+}
+
+func decodeAPIAccount(t *testing.T, result *http.Response) *handler.APIAccount {
+	t.Helper()
+	assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+	account := &handler.APIAccount{}
+	assert.Nil(t, json.NewDecoder(result.Body).Decode(account))
+	return account
+}
+
+// decodeAPIError decodes the error code from an unsuccessful response.
+func decodeAPIError(t *testing.T, result *http.Response) string {
+	t.Helper()
+	return decodeAPIErrorMessage(t, result).Code
+}
+
+func decodeAPIErrorMessage(t *testing.T, result *http.Response) *handler.APIError {
+	t.Helper()
+	assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+	e := &handler.APIError{}
+	assert.Nil(t, json.NewDecoder(result.Body).Decode(e))
+	return e
+}
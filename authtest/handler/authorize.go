@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func Authorize(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	tmpl, err := template.New("authorize.go.html").Parse(`{{.Client.Name}}{{range .Scopes}} {{.}}{{end}}`)
+	assert.Nil(t, err)
+	setup := func(t *testing.T) (authgo.Authenticator, *authgo.OIDCClient, *http.ServeMux) {
+		t.Helper()
+		auth := a(t)
+		client, _ := authtest.NewOIDCClient(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachAuthorizeHandler(mux, auth, tmpl)
+		return auth, client, mux
+	}
+	signIn := func(t *testing.T, auth authgo.Authenticator) *http.Cookie {
+		t.Helper()
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		return auth.NewSignInSessionCookie(token)
+	}
+	get := func(t *testing.T, mux *http.ServeMux, values url.Values, cookies ...*http.Cookie) *http.Response {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodGet, "/authorize?"+values.Encode(), nil)
+		for _, c := range cookies {
+			request.AddCookie(c)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response.Result()
+	}
+	t.Run("Not Found When Issuer Not Configured", func(t *testing.T) {
+		auth, client, mux := setup(t)
+		auth.SetOIDCIssuer("")
+		result := get(t, mux, authtest.NewOIDCAuthorizationRequest(client).Values())
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+	})
+	t.Run("Redirects When Not Signed In", func(t *testing.T) {
+		_, client, mux := setup(t)
+		values := authtest.NewOIDCAuthorizationRequest(client).Values()
+		result := get(t, mux, values)
+		assertLocation(t, result, "/sign-in?next="+url.QueryEscape("/authorize?"+values.Encode()))
+	})
+	t.Run("Shows Error When Client Not Found", func(t *testing.T) {
+		auth, client, mux := setup(t)
+		cookie := signIn(t, auth)
+		request := authtest.NewOIDCAuthorizationRequest(client)
+		request.ClientID = "unknown"
+		result := get(t, mux, request.Values(), cookie)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Empty(t, result.Header.Get("Location"))
+	})
+	t.Run("Shows Error When Redirect URI Invalid", func(t *testing.T) {
+		auth, client, mux := setup(t)
+		cookie := signIn(t, auth)
+		request := authtest.NewOIDCAuthorizationRequest(client)
+		request.RedirectURI = "https://attacker.example.com/callback"
+		result := get(t, mux, request.Values(), cookie)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Empty(t, result.Header.Get("Location"))
+	})
+	t.Run("Redirects With Error When Scope Invalid", func(t *testing.T) {
+		_, client, mux := setup(t)
+		request := authtest.NewOIDCAuthorizationRequest(client)
+		request.Scope = "email"
+		result := get(t, mux, request.Values())
+		require.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "app.example.com", u.Host)
+		assert.Equal(t, "invalid_scope", u.Query().Get("error"))
+		assert.Equal(t, authtest.TEST_OIDC_STATE, u.Query().Get("state"))
+	})
+	t.Run("Shows Consent", func(t *testing.T) {
+		auth, client, mux := setup(t)
+		cookie := signIn(t, auth)
+		body := getBody(t, mux, "/authorize?"+authtest.NewOIDCAuthorizationRequest(client).Values().Encode(), cookie)
+		assert.Equal(t, authtest.TEST_OIDC_CLIENT_NAME+" openid email profile", body)
+	})
+	t.Run("Redirects With Error When Denied", func(t *testing.T) {
+		auth, client, mux := setup(t)
+		cookie := signIn(t, auth)
+		values := authtest.NewOIDCAuthorizationRequest(client).Values()
+		values.Set("consent", "deny")
+		result := submitForm(t, auth, mux, "/authorize", values, cookie)
+		require.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "access_denied", u.Query().Get("error"))
+		assert.Equal(t, authtest.TEST_OIDC_STATE, u.Query().Get("state"))
+		assert.Empty(t, u.Query().Get("code"))
+	})
+	t.Run("Redirects With Code When Allowed", func(t *testing.T) {
+		auth, client, mux := setup(t)
+		cookie := signIn(t, auth)
+		values := authtest.NewOIDCAuthorizationRequest(client).Values()
+		values.Set("consent", "allow")
+		result := submitForm(t, auth, mux, "/authorize", values, cookie)
+		require.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, authtest.TEST_OIDC_CLIENT_REDIRECT_URI, u.Scheme+"://"+u.Host+u.Path)
+		assert.Empty(t, u.Query().Get("error"))
+		assert.Equal(t, authtest.TEST_OIDC_STATE, u.Query().Get("state"))
+		tokens, err := auth.RedeemOIDCCode(context.Background(), client, u.Query().Get("code"), authtest.TEST_OIDC_CLIENT_REDIRECT_URI, authtest.TEST_OIDC_VERIFIER)
+		assert.Nil(t, err)
+		assert.NotEmpty(t, tokens.AccessToken)
+	})
+}
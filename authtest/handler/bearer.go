@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func BearerToken(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	setup := func(t *testing.T) (authgo.Authenticator, *http.ServeMux) {
+		t.Helper()
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachBearerTokenHandler(mux, auth)
+		mux.Handle("/api/greeting", handler.RequireBearerAccount(auth, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, "Hello %s", authgo.BearerAccount(r.Context()).Username)
+		})))
+		return auth, mux
+	}
+	passwordValues := func() url.Values {
+		values := url.Values{}
+		values.Set("grant_type", "password")
+		values.Set("username", authtest.TEST_USERNAME)
+		values.Set("password", authtest.TEST_PASSWORD)
+		return values
+	}
+	decodeTokens := func(t *testing.T, result *http.Response) *authgo.BearerTokens {
+		t.Helper()
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, "no-store", result.Header.Get("Cache-Control"))
+		tokens := &authgo.BearerTokens{}
+		assert.Nil(t, json.NewDecoder(result.Body).Decode(tokens))
+		assert.Equal(t, "Bearer", tokens.TokenType)
+		return tokens
+	}
+	greeting := func(t *testing.T, mux *http.ServeMux, authorization string) *http.Response {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodGet, "/api/greeting", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response.Result()
+	}
+	t.Run("Password", func(t *testing.T) {
+		_, mux := setup(t)
+		tokens := decodeTokens(t, postForm(t, mux, "/bearer-token", passwordValues()))
+		result := greeting(t, mux, "Bearer "+tokens.AccessToken)
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, "Hello "+authtest.TEST_USERNAME, string(body))
+	})
+	t.Run("Password Incorrect", func(t *testing.T) {
+		_, mux := setup(t)
+		values := passwordValues()
+		values.Set("password", "wrong")
+		result := postForm(t, mux, "/bearer-token", values)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Equal(t, "invalid_grant", decodePasskeyError(t, result))
+	})
+	t.Run("Password Requires Second Factor", func(t *testing.T) {
+		auth, mux := setup(t)
+		secret := authtest.EnableTOTP(t, auth)
+		result := postForm(t, mux, "/bearer-token", passwordValues())
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Equal(t, "invalid_grant", decodePasskeyError(t, result))
+
+		values := passwordValues()
+		values.Set("code", authtest.NextTOTPCode(secret))
+		decodeTokens(t, postForm(t, mux, "/bearer-token", values))
+	})
+	t.Run("Grant Unsupported", func(t *testing.T) {
+		_, mux := setup(t)
+		values := url.Values{}
+		values.Set("grant_type", "client_credentials")
+		result := postForm(t, mux, "/bearer-token", values)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Equal(t, "unsupported_grant_type", decodePasskeyError(t, result))
+	})
+	t.Run("Refresh", func(t *testing.T) {
+		_, mux := setup(t)
+		tokens := decodeTokens(t, postForm(t, mux, "/bearer-token", passwordValues()))
+		values := url.Values{}
+		values.Set("grant_type", "refresh_token")
+		values.Set("refresh_token", tokens.RefreshToken)
+		refreshed := decodeTokens(t, postForm(t, mux, "/bearer-token", values))
+		assert.Equal(t, http.StatusUnauthorized, greeting(t, mux, "Bearer "+tokens.AccessToken).StatusCode)
+		assert.Equal(t, http.StatusOK, greeting(t, mux, "Bearer "+refreshed.AccessToken).StatusCode)
+
+		// Refresh tokens are single use
+		result := postForm(t, mux, "/bearer-token", values)
+		assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+		assert.Equal(t, "invalid_grant", decodePasskeyError(t, result))
+	})
+	t.Run("Revoke", func(t *testing.T) {
+		_, mux := setup(t)
+		tokens := decodeTokens(t, postForm(t, mux, "/bearer-token", passwordValues()))
+		values := url.Values{}
+		values.Set("token", tokens.RefreshToken)
+		assert.Equal(t, http.StatusOK, postForm(t, mux, "/bearer-token-revoke", values).StatusCode)
+		assert.Equal(t, http.StatusUnauthorized, greeting(t, mux, "Bearer "+tokens.AccessToken).StatusCode)
+
+		// Revoking an unknown token succeeds
+		assert.Equal(t, http.StatusOK, postForm(t, mux, "/bearer-token-revoke", values).StatusCode)
+	})
+	t.Run("Requires Token", func(t *testing.T) {
+		_, mux := setup(t)
+		result := greeting(t, mux, "")
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		assert.Equal(t, `Bearer realm="api"`, result.Header.Get("WWW-Authenticate"))
+
+		result = greeting(t, mux, "Bearer invalid")
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		assert.Contains(t, result.Header.Get("WWW-Authenticate"), `error="invalid_token"`)
+	})
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func CSRF(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	fs := fstest.MapFS{
+		"account-deactivate.go.html": {
+			Data: []byte(`{{.CSRF}}`),
+		},
+		"sign-out.go.html": {
+			Data: []byte(`{{.CSRF}}`),
+		},
+	}
+	tmpl, err := template.ParseFS(fs, "*.go.html")
+	assert.Nil(t, err)
+	setup := func(t *testing.T) (authgo.Authenticator, *http.Cookie, *http.ServeMux) {
+		t.Helper()
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachSignOutHandler(mux, auth, tmpl)
+		handler.AttachAccountDeactivateHandler(mux, auth, tmpl)
+		return auth, auth.NewSignInSessionCookie(token), mux
+	}
+	// page gets the page, returning the token in its form and the CSRF cookie
+	page := func(t *testing.T, mux *http.ServeMux, path string, cookie *http.Cookie) (string, *http.Cookie) {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodGet, path, nil)
+		request.AddCookie(cookie)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		csrf := findCookie(result.Cookies(), authgo.COOKIE_CSRF)
+		require.NotNil(t, csrf)
+		return response.Body.String(), csrf
+	}
+	// forge posts the form as another website would
+	forge := func(t *testing.T, mux *http.ServeMux, path string, values url.Values, origin string, cookies ...*http.Cookie) *http.Response {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(values.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if origin != "" {
+			request.Header.Set("Origin", origin)
+		}
+		for _, c := range cookies {
+			request.AddCookie(c)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response.Result()
+	}
+	assertSignedIn := func(t *testing.T, auth authgo.Authenticator, cookie *http.Cookie) {
+		t.Helper()
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+	}
+	t.Run("Submits Form With Token", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		token, csrf := page(t, mux, "/sign-out", cookie)
+		values := url.Values{}
+		values.Add(authgo.CSRF_FIELD, token)
+		result := forge(t, mux, "/sign-out", values, "http://example.com", cookie, csrf)
+		assertLocation(t, result, "/")
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+	})
+	t.Run("Rejects Forged Sign Out", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		result := forge(t, mux, "/sign-out", url.Values{}, "", cookie)
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+		assertSignedIn(t, auth, cookie)
+	})
+	t.Run("Rejects Forged Deactivation", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		_, csrf := page(t, mux, "/account-deactivate", cookie)
+		values := url.Values{}
+		values.Add(authgo.CSRF_FIELD, "forged")
+		result := forge(t, mux, "/account-deactivate", values, "", cookie, csrf)
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+		_, err := auth.LookupAccount(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		assertSignedIn(t, auth, cookie)
+	})
+	t.Run("Rejects Token From Other Website", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		token, csrf := page(t, mux, "/account-deactivate", cookie)
+		values := url.Values{}
+		values.Add(authgo.CSRF_FIELD, token)
+		result := forge(t, mux, "/account-deactivate", values, "https://evil.example.com", cookie, csrf)
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+		_, err := auth.LookupAccount(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+	})
+	t.Run("Rejects Cross-Site Request", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		token, csrf := page(t, mux, "/sign-out", cookie)
+		values := url.Values{}
+		values.Add(authgo.CSRF_FIELD, token)
+		request := httptest.NewRequest(http.MethodPost, "/sign-out", strings.NewReader(values.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("Sec-Fetch-Site", "cross-site")
+		request.AddCookie(cookie)
+		request.AddCookie(csrf)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		assert.Equal(t, http.StatusForbidden, response.Result().StatusCode)
+		assertSignedIn(t, auth, cookie)
+	})
+	t.Run("Rejects Token From Another Session", func(t *testing.T) {
+		auth, cookie, mux := setup(t)
+		token, csrf := page(t, mux, "/sign-out", cookie)
+		other, _ := authtest.SignIn(t, auth)
+		otherCookie := auth.NewSignInSessionCookie(other)
+		values := url.Values{}
+		values.Add(authgo.CSRF_FIELD, token)
+		result := forge(t, mux, "/sign-out", values, "", otherCookie, csrf)
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+		assertSignedIn(t, auth, otherCookie)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"aletheiaware.com/authgo"
 	"aletheiaware.com/authgo/authtest"
 	"aletheiaware.com/authgo/handler"
+	"context"
 	"github.com/stretchr/testify/assert"
 	"html/template"
 	"io"
@@ -36,12 +37,12 @@ func AccountPasswordSignOutSignInAccount(t *testing.T, a func(*testing.T) authgo
 
 	auth := a(t)
 	authtest.NewTestAccount(t, auth)
-	assert.Nil(t, auth.SetEmailVerified(authtest.TEST_EMAIL, true))
+	assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
 	mux := http.NewServeMux()
 	handler.AttachAuthenticationHandlers(mux, auth, tmpl)
 	token, _ := authtest.SignIn(t, auth)
 	signInCookie := auth.NewSignInSessionCookie(token)
-	newPassword := "foobarfoobar"
+	newPassword := "violet-marble-stapler-97"
 
 	// Account Password
 	values := url.Values{}
@@ -51,6 +52,7 @@ func AccountPasswordSignOutSignInAccount(t *testing.T, a func(*testing.T) authgo
 	request := httptest.NewRequest(http.MethodPost, "/account-password", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	request.AddCookie(signInCookie)
+	authtest.AddCSRF(auth, request)
 	response := httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result := response.Result()
@@ -66,6 +68,7 @@ func AccountPasswordSignOutSignInAccount(t *testing.T, a func(*testing.T) authgo
 	// Sign Out
 	request = httptest.NewRequest(http.MethodPost, "/sign-out", nil)
 	request.AddCookie(signInCookie)
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result = response.Result()
@@ -78,6 +81,7 @@ func AccountPasswordSignOutSignInAccount(t *testing.T, a func(*testing.T) authgo
 	reader = strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + newPassword)
 	request = httptest.NewRequest(http.MethodPost, "/sign-in", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result = response.Result()
@@ -126,7 +130,7 @@ func AccountRecoveryAccountPasswordAccount(t *testing.T, a func(*testing.T) auth
 	authtest.NewTestAccount(t, auth)
 	mux := http.NewServeMux()
 	handler.AttachAuthenticationHandlers(mux, auth, tmpl)
-	newPassword := "foobarfoobar"
+	newPassword := "violet-marble-stapler-97"
 
 	// Account Recovery
 	values := url.Values{}
@@ -134,6 +138,7 @@ func AccountRecoveryAccountPasswordAccount(t *testing.T, a func(*testing.T) auth
 	reader := strings.NewReader(values.Encode())
 	request := httptest.NewRequest(http.MethodPost, "/account-recovery", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	authtest.AddCSRF(auth, request)
 	response := httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result := response.Result()
@@ -152,6 +157,7 @@ func AccountRecoveryAccountPasswordAccount(t *testing.T, a func(*testing.T) auth
 	request = httptest.NewRequest(http.MethodPost, "/account-recovery-verification", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	request.AddCookie(cookies[0])
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result = response.Result()
@@ -171,6 +177,7 @@ func AccountRecoveryAccountPasswordAccount(t *testing.T, a func(*testing.T) auth
 	request = httptest.NewRequest(http.MethodPost, "/account-password", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	request.AddCookie(cookies[0])
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result = response.Result()
@@ -207,7 +214,7 @@ func SignInTokenGetsRefreshed(t *testing.T, a func(*testing.T) authgo.Authentica
 	auth := a(t)
 	auth.SetSignInSessionTimeout(15 * time.Second)
 	authtest.NewTestAccount(t, auth)
-	assert.Nil(t, auth.SetEmailVerified(authtest.TEST_EMAIL, true))
+	assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
 	mux := http.NewServeMux()
 	handler.AttachAuthenticationHandlers(mux, auth, tmpl)
 	token, _ := authtest.SignIn(t, auth)
@@ -217,6 +224,8 @@ func SignInTokenGetsRefreshed(t *testing.T, a func(*testing.T) authgo.Authentica
 	time.Sleep(5 * time.Second)
 	request := httptest.NewRequest(http.MethodGet, "/account", nil)
 	request.AddCookie(signInCookie)
+	// Browsers already have a CSRF cookie from previous pages
+	authtest.AddCSRF(auth, request)
 	response := httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result := response.Result()
@@ -231,6 +240,7 @@ func SignInTokenGetsRefreshed(t *testing.T, a func(*testing.T) authgo.Authentica
 	time.Sleep(5 * time.Second)
 	request = httptest.NewRequest(http.MethodGet, "/account", nil)
 	request.AddCookie(signInCookie)
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result = response.Result()
@@ -277,6 +287,7 @@ func SignUpSignOutSignInAccount(t *testing.T, a func(*testing.T) authgo.Authenti
 	reader := strings.NewReader(values.Encode())
 	request := httptest.NewRequest(http.MethodPost, "/sign-up", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	authtest.AddCSRF(auth, request)
 	response := httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result := response.Result()
@@ -295,6 +306,7 @@ func SignUpSignOutSignInAccount(t *testing.T, a func(*testing.T) authgo.Authenti
 	request = httptest.NewRequest(http.MethodPost, "/sign-up-verification", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	request.AddCookie(cookies[0])
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result = response.Result()
@@ -309,6 +321,7 @@ func SignUpSignOutSignInAccount(t *testing.T, a func(*testing.T) authgo.Authenti
 	// Sign Out
 	request = httptest.NewRequest(http.MethodPost, "/sign-out", nil)
 	request.AddCookie(cookies[0])
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result = response.Result()
@@ -321,6 +334,7 @@ func SignUpSignOutSignInAccount(t *testing.T, a func(*testing.T) authgo.Authenti
 	reader = strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + authtest.TEST_PASSWORD)
 	request = httptest.NewRequest(http.MethodPost, "/sign-in", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result = response.Result()
@@ -357,6 +371,7 @@ func AccountDeactivateSignIn(t *testing.T, a func(*testing.T) authgo.Authenticat
 	handler.AttachAccountDeactivateHandler(mux, auth, tmpl)
 	request := httptest.NewRequest(http.MethodPost, "/account-deactivate", nil)
 	request.AddCookie(auth.NewSignInSessionCookie(token))
+	authtest.AddCSRF(auth, request)
 	response := httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	assert.Equal(t, http.StatusFound, response.Result().StatusCode)
@@ -365,6 +380,7 @@ func AccountDeactivateSignIn(t *testing.T, a func(*testing.T) authgo.Authenticat
 	reader := strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + authtest.TEST_PASSWORD)
 	request = httptest.NewRequest(http.MethodPost, "/sign-in", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result := response.Result()
@@ -398,6 +414,7 @@ func AccountDeactivateSignUp(t *testing.T, a func(*testing.T) authgo.Authenticat
 	handler.AttachAccountDeactivateHandler(mux, auth, tmpl)
 	request := httptest.NewRequest(http.MethodPost, "/account-deactivate", nil)
 	request.AddCookie(auth.NewSignInSessionCookie(token))
+	authtest.AddCSRF(auth, request)
 	response := httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	assert.Equal(t, http.StatusFound, response.Result().StatusCode)
@@ -406,6 +423,7 @@ func AccountDeactivateSignUp(t *testing.T, a func(*testing.T) authgo.Authenticat
 	reader := strings.NewReader("email=" + authtest.TEST_EMAIL + "&username=" + authtest.TEST_USERNAME + "&password=" + authtest.TEST_PASSWORD + "&confirmation=" + authtest.TEST_PASSWORD)
 	request = httptest.NewRequest(http.MethodPost, "/sign-up", reader)
 	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	authtest.AddCSRF(auth, request)
 	response = httptest.NewRecorder()
 	mux.ServeHTTP(response, request)
 	result := response.Result()
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func OpenID(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	setup := func(t *testing.T) (authgo.Authenticator, *authgo.OIDCClient, string, *http.ServeMux) {
+		t.Helper()
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		client, secret := authtest.NewOIDCClient(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachOpenIDHandler(mux, auth)
+		return auth, client, secret, mux
+	}
+	newCode := func(t *testing.T, auth authgo.Authenticator, client *authgo.OIDCClient) string {
+		t.Helper()
+		code, err := auth.NewOIDCCode(context.Background(), authtest.TEST_USERNAME, authtest.NewOIDCAuthorizationRequest(client))
+		require.Nil(t, err)
+		return code
+	}
+	codeValues := func(code string) url.Values {
+		values := url.Values{}
+		values.Set("grant_type", "authorization_code")
+		values.Set("code", code)
+		values.Set("redirect_uri", authtest.TEST_OIDC_CLIENT_REDIRECT_URI)
+		values.Set("code_verifier", authtest.TEST_OIDC_VERIFIER)
+		return values
+	}
+	token := func(t *testing.T, mux *http.ServeMux, values url.Values, id, secret string) *http.Response {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(values.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.SetBasicAuth(url.QueryEscape(id), url.QueryEscape(secret))
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response.Result()
+	}
+	decodeTokens := func(t *testing.T, result *http.Response) *authgo.OIDCTokens {
+		t.Helper()
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, "no-store", result.Header.Get("Cache-Control"))
+		tokens := &authgo.OIDCTokens{}
+		assert.Nil(t, json.NewDecoder(result.Body).Decode(tokens))
+		return tokens
+	}
+	decodeError := func(t *testing.T, result *http.Response, status int) string {
+		t.Helper()
+		assert.Equal(t, status, result.StatusCode)
+		return decodePasskeyError(t, result)
+	}
+	userInfo := func(t *testing.T, mux *http.ServeMux, authorization string) *http.Response {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response.Result()
+	}
+	t.Run("Not Found When Issuer Not Configured", func(t *testing.T) {
+		auth, _, _, mux := setup(t)
+		auth.SetOIDCIssuer("")
+		for _, path := range []string{"/.well-known/openid-configuration", "/jwks", "/token", "/userinfo"} {
+			request := httptest.NewRequest(http.MethodGet, path, nil)
+			response := httptest.NewRecorder()
+			mux.ServeHTTP(response, request)
+			assert.Equal(t, http.StatusNotFound, response.Result().StatusCode, path)
+		}
+	})
+	t.Run("Configuration", func(t *testing.T) {
+		_, _, _, mux := setup(t)
+		c := &authgo.OIDCConfiguration{}
+		assert.Nil(t, json.Unmarshal([]byte(getBody(t, mux, "/.well-known/openid-configuration")), c))
+		assert.Equal(t, authtest.TEST_OIDC_ISSUER, c.Issuer)
+		assert.Equal(t, authtest.TEST_OIDC_ISSUER+"/token", c.TokenEndpoint)
+		assert.Equal(t, authtest.TEST_OIDC_ISSUER+"/jwks", c.JWKSURI)
+	})
+	t.Run("JSON Web Key Set", func(t *testing.T) {
+		_, _, _, mux := setup(t)
+		keys := &authgo.JSONWebKeySet{}
+		assert.Nil(t, json.Unmarshal([]byte(getBody(t, mux, "/jwks")), keys))
+		require.Equal(t, 1, len(keys.Keys))
+		assert.Equal(t, "RSA", keys.Keys[0].Kty)
+	})
+	t.Run("Token", func(t *testing.T) {
+		auth, client, secret, mux := setup(t)
+		tokens := decodeTokens(t, token(t, mux, codeValues(newCode(t, auth, client)), client.ID, secret))
+		assert.Equal(t, "Bearer", tokens.TokenType)
+		assert.NotEmpty(t, tokens.AccessToken)
+		assert.NotEmpty(t, tokens.RefreshToken)
+		assert.NotEmpty(t, tokens.IDToken)
+	})
+	t.Run("Token Client Credentials In Form", func(t *testing.T) {
+		auth, client, secret, mux := setup(t)
+		values := codeValues(newCode(t, auth, client))
+		values.Set("client_id", client.ID)
+		values.Set("client_secret", secret)
+		decodeTokens(t, postForm(t, mux, "/token", values))
+	})
+	t.Run("Token Client Unauthorized", func(t *testing.T) {
+		auth, client, _, mux := setup(t)
+		result := token(t, mux, codeValues(newCode(t, auth, client)), client.ID, "wrong")
+		assert.NotEmpty(t, result.Header.Get("WWW-Authenticate"))
+		assert.Equal(t, "invalid_client", decodeError(t, result, http.StatusUnauthorized))
+	})
+	t.Run("Token Code Invalid", func(t *testing.T) {
+		_, client, secret, mux := setup(t)
+		result := token(t, mux, codeValues("unknown"), client.ID, secret)
+		assert.Equal(t, "invalid_grant", decodeError(t, result, http.StatusBadRequest))
+	})
+	t.Run("Token Grant Unsupported", func(t *testing.T) {
+		_, client, secret, mux := setup(t)
+		values := url.Values{}
+		values.Set("grant_type", "password")
+		result := token(t, mux, values, client.ID, secret)
+		assert.Equal(t, "unsupported_grant_type", decodeError(t, result, http.StatusBadRequest))
+	})
+	t.Run("Token Refresh", func(t *testing.T) {
+		auth, client, secret, mux := setup(t)
+		tokens := decodeTokens(t, token(t, mux, codeValues(newCode(t, auth, client)), client.ID, secret))
+		values := url.Values{}
+		values.Set("grant_type", "refresh_token")
+		values.Set("refresh_token", tokens.RefreshToken)
+		refreshed := decodeTokens(t, token(t, mux, values, client.ID, secret))
+		assert.NotEmpty(t, refreshed.AccessToken)
+		assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+
+		// Refresh tokens are single use
+		result := token(t, mux, values, client.ID, secret)
+		assert.Equal(t, "invalid_grant", decodeError(t, result, http.StatusBadRequest))
+	})
+	t.Run("User Info", func(t *testing.T) {
+		auth, client, secret, mux := setup(t)
+		tokens := decodeTokens(t, token(t, mux, codeValues(newCode(t, auth, client)), client.ID, secret))
+		result := userInfo(t, mux, "Bearer "+tokens.AccessToken)
+		require.Equal(t, http.StatusOK, result.StatusCode)
+		info := map[string]interface{}{}
+		assert.Nil(t, json.NewDecoder(result.Body).Decode(&info))
+		assert.Equal(t, authtest.TEST_USERNAME, info["sub"])
+		assert.Equal(t, authtest.TEST_EMAIL, info["email"])
+		assert.Equal(t, authtest.TEST_USERNAME, info["preferred_username"])
+	})
+	t.Run("User Info Token Missing", func(t *testing.T) {
+		_, _, _, mux := setup(t)
+		result := userInfo(t, mux, "")
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		assert.NotEmpty(t, result.Header.Get("WWW-Authenticate"))
+	})
+	t.Run("User Info Token Invalid", func(t *testing.T) {
+		_, _, _, mux := setup(t)
+		result := userInfo(t, mux, "Bearer invalid")
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+		assert.Contains(t, result.Header.Get("WWW-Authenticate"), "invalid_token")
+	})
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// postForm posts the values to the path with the cookies, returning the result.
+func postForm(t *testing.T, mux *http.ServeMux, path string, values url.Values, cookies ...*http.Cookie) *http.Response {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(values.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, c := range cookies {
+		request.AddCookie(c)
+	}
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, request)
+	return response.Result()
+}
+
+// submitForm posts the values to the path with the cookies and CSRF token, as a form on the website's own page would, returning the result.
+func submitForm(t *testing.T, a authgo.Authenticator, mux *http.ServeMux, path string, values url.Values, cookies ...*http.Cookie) *http.Response {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, path, strings.NewReader(values.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, c := range cookies {
+		request.AddCookie(c)
+	}
+	authtest.AddCSRF(a, request)
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, request)
+	return response.Result()
+}
+
+// getBody gets the path with the cookies, returning the body of the page.
+func getBody(t *testing.T, mux *http.ServeMux, path string, cookies ...*http.Cookie) string {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodGet, path, nil)
+	for _, c := range cookies {
+		request.AddCookie(c)
+	}
+	response := httptest.NewRecorder()
+	mux.ServeHTTP(response, request)
+	result := response.Result()
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	body, err := io.ReadAll(result.Body)
+	assert.Nil(t, err)
+	return string(body)
+}
+
+// decodePasskeyOptions decodes the options of a passkey ceremony from a successful response.
+func decodePasskeyOptions(t *testing.T, result *http.Response, options interface{}) {
+	t.Helper()
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+	assert.Nil(t, json.NewDecoder(result.Body).Decode(options))
+}
+
+// decodePasskeyError decodes the error message from an unsuccessful response.
+func decodePasskeyError(t *testing.T, result *http.Response) string {
+	t.Helper()
+	var e struct {
+		Error string `json:"error"`
+	}
+	assert.Nil(t, json.NewDecoder(result.Body).Decode(&e))
+	return e.Error
+}
+
+func assertLocation(t *testing.T, result *http.Response, expected string) {
+	t.Helper()
+	assert.Equal(t, http.StatusFound, result.StatusCode)
+	u, err := result.Location()
+	assert.Nil(t, err)
+	assert.Equal(t, expected, u.String())
+}
+
+func findCookie(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
@@ -4,11 +4,13 @@ import (
 	"aletheiaware.com/authgo"
 	"aletheiaware.com/authgo/authtest"
 	"aletheiaware.com/authgo/handler"
+	"context"
 	"github.com/stretchr/testify/assert"
 	"html/template"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -48,12 +50,14 @@ func SignIn(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 	t.Run("Redirects When Credentials Are Correct", func(t *testing.T) {
 		auth := a(t)
 		acc := authtest.NewTestAccount(t, auth)
-		assert.Nil(t, auth.SetEmailVerified(acc.Email, true))
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), acc.Email, true))
 		mux := http.NewServeMux()
 		handler.AttachSignInHandler(mux, auth, tmpl)
 		reader := strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + authtest.TEST_PASSWORD)
 		request := httptest.NewRequest(http.MethodPost, "/sign-in", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.Header.Set("User-Agent", "Firefox")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -67,16 +71,23 @@ func SignIn(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		u, err := result.Location()
 		assert.Nil(t, err)
 		assert.Equal(t, "/account", u.String())
+		// The session records the client
+		session := auth.LookupSignInSession(context.Background(), cookies[0].Value)
+		if assert.NotNil(t, session) {
+			assert.Equal(t, "192.0.2.1", session.Address)
+			assert.Equal(t, "Firefox", session.UserAgent)
+		}
 	})
 	t.Run("Custom Redirect After Sign In", func(t *testing.T) {
 		auth := a(t)
 		acc := authtest.NewTestAccount(t, auth)
-		assert.Nil(t, auth.SetEmailVerified(acc.Email, true))
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), acc.Email, true))
 		mux := http.NewServeMux()
 		handler.AttachSignInHandler(mux, auth, tmpl)
 		reader := strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + authtest.TEST_PASSWORD)
 		request := httptest.NewRequest(http.MethodPost, "/sign-in?next=%2Ffoobar", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -91,6 +102,21 @@ func SignIn(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		assert.Nil(t, err)
 		assert.Equal(t, "/foobar", u.String())
 	})
+	t.Run("Ignores Redirect To Other Website After Sign In", func(t *testing.T) {
+		auth := a(t)
+		acc := authtest.NewTestAccount(t, auth)
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), acc.Email, true))
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		for _, next := range []string{"https://evil.example.com", "//evil.example.com", "/\\evil.example.com"} {
+			values := url.Values{}
+			values.Add("username", authtest.TEST_USERNAME)
+			values.Add("password", authtest.TEST_PASSWORD)
+			values.Add("next", next)
+			result := submitForm(t, auth, mux, "/sign-in", values)
+			assertLocation(t, result, "/account")
+		}
+	})
 	t.Run("Redirects When Post Requested Before Get", func(t *testing.T) {
 		auth := a(t)
 		mux := http.NewServeMux()
@@ -98,6 +124,7 @@ func SignIn(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		reader := strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + authtest.TEST_PASSWORD)
 		request := httptest.NewRequest(http.MethodPost, "/sign-in", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -113,6 +140,7 @@ func SignIn(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		reader := strings.NewReader("username=foobar&password=" + authtest.TEST_PASSWORD)
 		request := httptest.NewRequest(http.MethodPost, "/sign-in", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -143,6 +171,7 @@ func SignIn(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		reader := strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=foobarfoobar")
 		request := httptest.NewRequest(http.MethodPost, "/sign-in", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -165,6 +194,46 @@ func SignIn(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		assert.Nil(t, err)
 		assert.Equal(t, authgo.ErrCredentialsIncorrect.Error(), string(body))
 	})
+	t.Run("Redirects When Account Is Locked", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.LockoutPolicy().AccountThreshold = 1
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		var cookie *http.Cookie
+		for _, password := range []string{"foobarfoobar", authtest.TEST_PASSWORD} {
+			reader := strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + password)
+			request := httptest.NewRequest(http.MethodPost, "/sign-in", reader)
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if cookie != nil {
+				request.AddCookie(cookie)
+			}
+			authtest.AddCSRF(auth, request)
+			response := httptest.NewRecorder()
+			mux.ServeHTTP(response, request)
+			result := response.Result()
+			assert.Equal(t, http.StatusFound, result.StatusCode)
+			u, err := result.Location()
+			assert.Nil(t, err)
+			assert.Equal(t, "/sign-in", u.String())
+			if cookie == nil {
+				cookies := result.Cookies()
+				assert.Equal(t, 1, len(cookies))
+				cookie = cookies[0]
+			}
+		}
+
+		// Subsequent Get request should show error
+		request := httptest.NewRequest(http.MethodGet, "/sign-in", nil)
+		request.AddCookie(cookie)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, authgo.ErrAccountLocked.Error(), string(body))
+	})
 	t.Run("Redirects When Email Is Not Verified", func(t *testing.T) {
 		auth := a(t)
 		authtest.NewTestAccount(t, auth)
@@ -173,6 +242,7 @@ func SignIn(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		reader := strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + authtest.TEST_PASSWORD)
 		request := httptest.NewRequest(http.MethodPost, "/sign-in", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func SignInOIDC(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	fs := fstest.MapFS{
+		"sign-in.go.html": {
+			Data: []byte(`{{.Error}}`),
+		},
+		"sign-in-totp.go.html": {
+			Data: []byte(`{{.Error}}`),
+		},
+	}
+	tmpl, err := template.ParseFS(fs, "*.go.html")
+	assert.Nil(t, err)
+	setup := func(t *testing.T) (authgo.Authenticator, *authtest.IdentityProvider, *http.ServeMux) {
+		t.Helper()
+		auth := a(t)
+		idp := authtest.NewIdentityProvider(t)
+		auth.SetOIDCProviders([]*authgo.OIDCProvider{idp.Provider()})
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		handler.AttachSignInOIDCHandler(mux, auth)
+		return auth, idp, mux
+	}
+	// begin submits the sign in form for the provider, returning the sign in cookie and the authorization URL
+	begin := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, idp *authtest.IdentityProvider, next string, cookies ...*http.Cookie) (*http.Cookie, string) {
+		t.Helper()
+		values := url.Values{}
+		values.Add("provider", authtest.TEST_OIDC_PROVIDER)
+		if next != "" {
+			values.Add("next", next)
+		}
+		result := submitForm(t, auth, mux, "/sign-in-oidc", values, cookies...)
+		require.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(u.String(), idp.Server.URL+"/authorize?"), u.String())
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		if cookie == nil {
+			require.NotEmpty(t, cookies)
+			cookie = cookies[0]
+		}
+		return cookie, u.String()
+	}
+	callback := func(t *testing.T, mux *http.ServeMux, query string, cookie *http.Cookie) *http.Response {
+		t.Helper()
+		request := httptest.NewRequest(http.MethodGet, "/sign-in-oidc-callback?"+query, nil)
+		if cookie != nil {
+			request.AddCookie(cookie)
+		}
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response.Result()
+	}
+	authorize := func(t *testing.T, idp *authtest.IdentityProvider, u string) string {
+		t.Helper()
+		state, code, err := idp.Authorize(u)
+		require.Nil(t, err)
+		values := url.Values{}
+		values.Add("state", state)
+		values.Add("code", code)
+		return values.Encode()
+	}
+	t.Run("Signs In After Callback", func(t *testing.T) {
+		auth, idp, mux := setup(t)
+		cookie, u := begin(t, auth, mux, idp, "/products")
+
+		assertLocation(t, callback(t, mux, authorize(t, idp, u), cookie), "/products")
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	})
+	t.Run("Links When Signed In", func(t *testing.T) {
+		auth, idp, mux := setup(t)
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		cookie := &http.Cookie{Name: authgo.COOKIE_SIGN_IN, Value: token}
+		// A different email address would otherwise create a new account
+		idp.Email = "alice@example.net"
+		_, u := begin(t, auth, mux, idp, "", cookie)
+
+		assertLocation(t, callback(t, mux, authorize(t, idp, u), cookie), "/account")
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+
+		// The identity can now be used to sign in
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
+		cookie, u = begin(t, auth, mux, idp, "")
+		assertLocation(t, callback(t, mux, authorize(t, idp, u), cookie), "/account")
+		session = auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	})
+	t.Run("Requires Second Factor", func(t *testing.T) {
+		auth, idp, mux := setup(t)
+		authtest.NewTestAccount(t, auth)
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
+		authtest.EnableTOTP(t, auth)
+		cookie, u := begin(t, auth, mux, idp, "")
+
+		assertLocation(t, callback(t, mux, authorize(t, idp, u), cookie), "/sign-in-totp")
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+		assert.True(t, session.PendingSecondFactor)
+	})
+	t.Run("Redirects When Provider Not Found", func(t *testing.T) {
+		auth, _, mux := setup(t)
+		values := url.Values{}
+		values.Add("provider", "other")
+		result := submitForm(t, auth, mux, "/sign-in-oidc", values)
+		assertLocation(t, result, "/sign-in")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		assert.Equal(t, authgo.ErrOIDCProviderNotFound.Error(), getBody(t, mux, "/sign-in", cookie))
+	})
+	t.Run("Redirects When Denied", func(t *testing.T) {
+		auth, idp, mux := setup(t)
+		cookie, _ := begin(t, auth, mux, idp, "")
+
+		assertLocation(t, callback(t, mux, "error=access_denied", cookie), "/sign-in")
+		assert.Equal(t, authgo.ErrOIDCAuthorizationDenied.Error(), getBody(t, mux, "/sign-in", cookie))
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+	})
+	t.Run("Rejects Callback In Another Session", func(t *testing.T) {
+		auth, idp, mux := setup(t)
+		_, u := begin(t, auth, mux, idp, "")
+		query := authorize(t, idp, u)
+
+		// Without a session
+		assertLocation(t, callback(t, mux, query, nil), "/sign-in")
+
+		// With a session that did not begin the sign in, as when another site tricks the user into following its callback
+		token, err := auth.NewSignInSession(context.Background(), "", false)
+		require.Nil(t, err)
+		cookie := &http.Cookie{Name: authgo.COOKIE_SIGN_IN, Value: token}
+		assertLocation(t, callback(t, mux, query, cookie), "/sign-in")
+		assert.Equal(t, authgo.ErrOIDCAuthorizationExpired.Error(), getBody(t, mux, "/sign-in", cookie))
+
+		session := auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+	})
+}
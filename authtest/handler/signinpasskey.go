@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func SignInPasskey(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	tmpl, err := template.New("sign-in-passkey.go.html").Parse(`{{.Error}}`)
+	assert.Nil(t, err)
+	// newAccount creates the test account with a passkey on the returned authenticator
+	newAccount := func(t *testing.T, auth authgo.Authenticator) *authtest.PasskeyAuthenticator {
+		t.Helper()
+		authtest.NewTestAccount(t, auth)
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
+		device := authtest.NewPasskeyAuthenticator()
+		challenge, err := authgo.NewPasskeyChallenge()
+		assert.Nil(t, err)
+		options, err := auth.NewPasskeyCreationOptions(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, challenge)
+		assert.Nil(t, err)
+		response, err := device.Create(options)
+		assert.Nil(t, err)
+		passkey, err := auth.VerifyPasskeyCreation(challenge, response)
+		assert.Nil(t, err)
+		assert.Nil(t, auth.AddPasskey(context.Background(), authtest.TEST_USERNAME, passkey))
+		return device
+	}
+	// begin requests the options, returning the sign in session cookie and the credential signed by the device
+	begin := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, device *authtest.PasskeyAuthenticator) (*http.Cookie, string) {
+		t.Helper()
+		result := submitForm(t, auth, mux, "/sign-in-passkey-options", url.Values{})
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		var options authgo.PasskeyRequestOptions
+		decodePasskeyOptions(t, result, &options)
+		assert.Equal(t, authtest.TEST_RELYING_PARTY_ID, options.RPID)
+		assert.Equal(t, "required", options.UserVerification)
+		credential, err := device.Get(&options)
+		assert.Nil(t, err)
+		return cookie, string(credential)
+	}
+	t.Run("Returns 200", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		mux := http.NewServeMux()
+		handler.AttachSignInPasskeyHandler(mux, auth, tmpl)
+		assert.Empty(t, getBody(t, mux, "/sign-in-passkey"))
+	})
+	t.Run("Redirects After Sign In", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		device := newAccount(t, auth)
+		// Passkeys verify the user so two-factor authentication is not required
+		authtest.EnableTOTP(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachSignInPasskeyHandler(mux, auth, tmpl)
+		cookie, credential := begin(t, auth, mux, device)
+		values := url.Values{}
+		values.Add("credential", credential)
+		values.Add("next", "/products")
+		result := submitForm(t, auth, mux, "/sign-in-passkey", values, cookie)
+		assertLocation(t, result, "/products")
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+		assert.True(t, session.Authenticated)
+		assert.Empty(t, session.PasskeyChallenge)
+	})
+	t.Run("Redirects When Challenge Reused", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		device := newAccount(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachSignInPasskeyHandler(mux, auth, tmpl)
+		cookie, credential := begin(t, auth, mux, device)
+		values := url.Values{}
+		values.Add("credential", credential)
+		assertLocation(t, submitForm(t, auth, mux, "/sign-in-passkey", values, cookie), "/account")
+		authtest.SignOut(t, auth, cookie.Value)
+
+		// Replaying the credential fails
+		assertLocation(t, submitForm(t, auth, mux, "/sign-in-passkey", values, cookie), "/sign-in-passkey")
+		assert.Equal(t, authgo.ErrPasskeyExpired.Error(), getBody(t, mux, "/sign-in-passkey", cookie))
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+	})
+	t.Run("Redirects When Passkey Invalid", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		device := newAccount(t, auth)
+		device.Origin = "https://example.net"
+		mux := http.NewServeMux()
+		handler.AttachSignInPasskeyHandler(mux, auth, tmpl)
+		cookie, credential := begin(t, auth, mux, device)
+		values := url.Values{}
+		values.Add("credential", credential)
+		assertLocation(t, submitForm(t, auth, mux, "/sign-in-passkey", values, cookie), "/sign-in-passkey")
+		assert.Equal(t, authgo.ErrPasskeyInvalid.Error()+": origin https://example.net", getBody(t, mux, "/sign-in-passkey", cookie))
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.Empty(t, session.Username)
+		assert.False(t, session.Authenticated)
+	})
+	t.Run("Returns 404 When Not Configured", func(t *testing.T) {
+		auth := a(t)
+		mux := http.NewServeMux()
+		handler.AttachSignInPasskeyHandler(mux, auth, tmpl)
+		result := submitForm(t, auth, mux, "/sign-in-passkey-options", url.Values{})
+		assert.Equal(t, http.StatusNotFound, result.StatusCode)
+		assert.Equal(t, authgo.ErrRelyingPartyNotConfigured.Error(), decodePasskeyError(t, result))
+	})
+}
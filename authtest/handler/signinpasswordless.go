@@ -0,0 +1,246 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"net/http"
+	"net/url"
+	"testing"
+	"testing/fstest"
+)
+
+func SignInCode(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	fs := fstest.MapFS{
+		"sign-in.go.html": {
+			Data: []byte(`{{.Error}}`),
+		},
+		"sign-in-code.go.html": {
+			Data: []byte(`{{.Link}}{{.Error}}`),
+		},
+		"sign-in-totp.go.html": {
+			Data: []byte(`{{.Error}}`),
+		},
+	}
+	tmpl, err := template.ParseFS(fs, "*.go.html")
+	assert.Nil(t, err)
+	// requestCode submits the identity without a password, returning the sign in cookie.
+	requestCode := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, identity string) *http.Cookie {
+		t.Helper()
+		values := url.Values{}
+		values.Add("username", identity)
+		result := submitForm(t, auth, mux, "/sign-in", values)
+		assertLocation(t, result, "/sign-in-code")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		return cookie
+	}
+	submitCode := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, cookie *http.Cookie, code string) *http.Response {
+		t.Helper()
+		values := url.Values{}
+		values.Add("code", code)
+		return submitForm(t, auth, mux, "/sign-in-code", values, cookie)
+	}
+	t.Run("Requires Password When Disabled", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		values := url.Values{}
+		values.Add("username", authtest.TEST_USERNAME)
+		result := submitForm(t, auth, mux, "/sign-in", values)
+		assertLocation(t, result, "/sign-in")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		assert.Equal(t, authgo.ErrCredentialsIncorrect.Error(), getBody(t, mux, "/sign-in", cookie))
+
+		assertLocation(t, submitCode(t, auth, mux, cookie, authtest.TEST_CHALLENGE), "/sign-in")
+	})
+	t.Run("Redirects When Not Signing In", func(t *testing.T) {
+		auth := a(t)
+		auth.SetPasswordlessSignIn(true)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		assertLocation(t, submitCode(t, auth, mux, &http.Cookie{Name: authgo.COOKIE_SIGN_IN, Value: "foobar"}, authtest.TEST_CHALLENGE), "/sign-in")
+	})
+	t.Run("Signs In After Code", func(t *testing.T) {
+		for name, identity := range map[string]string{
+			"Username": authtest.TEST_USERNAME,
+			"Email":    authtest.TEST_EMAIL,
+		} {
+			t.Run(name, func(t *testing.T) {
+				auth := a(t)
+				authtest.NewTestAccount(t, auth)
+				assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
+				auth.SetPasswordlessSignIn(true)
+				mux := http.NewServeMux()
+				handler.AttachSignInHandler(mux, auth, tmpl)
+				cookie := requestCode(t, auth, mux, identity)
+				assert.Equal(t, "false", getBody(t, mux, "/sign-in-code", cookie))
+
+				assertLocation(t, submitCode(t, auth, mux, cookie, authtest.TEST_CHALLENGE), "/account")
+
+				session := auth.LookupSignInSession(context.Background(), cookie.Value)
+				require.NotNil(t, session)
+				assert.True(t, session.Authenticated)
+				assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+			})
+		}
+	})
+	t.Run("Redirects When Code Is Wrong", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordlessSignIn(true)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		cookie := requestCode(t, auth, mux, authtest.TEST_USERNAME)
+
+		assertLocation(t, submitCode(t, auth, mux, cookie, "1234abcd"), "/sign-in-code")
+		assert.Equal(t, "false"+authgo.ErrSignInCodeIncorrect.Error(), getBody(t, mux, "/sign-in-code", cookie))
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+	})
+	t.Run("Does Not Reveal Unknown Account", func(t *testing.T) {
+		auth := a(t)
+		auth.SetPasswordlessSignIn(true)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		cookie := requestCode(t, auth, mux, authtest.TEST_USERNAME)
+		assert.Equal(t, "false", getBody(t, mux, "/sign-in-code", cookie))
+
+		assertLocation(t, submitCode(t, auth, mux, cookie, authtest.TEST_CHALLENGE), "/sign-in-code")
+		assert.Equal(t, "false"+authgo.ErrSignInChallengeExpired.Error(), getBody(t, mux, "/sign-in-code", cookie))
+	})
+	t.Run("Requires Second Factor", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		authtest.EnableTOTP(t, auth)
+		auth.SetPasswordlessSignIn(true)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		cookie := requestCode(t, auth, mux, authtest.TEST_USERNAME)
+
+		assertLocation(t, submitCode(t, auth, mux, cookie, authtest.TEST_CHALLENGE), "/sign-in-totp")
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+		assert.True(t, session.PendingSecondFactor)
+	})
+}
+
+func SignInLink(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	fs := fstest.MapFS{
+		"sign-in.go.html": {
+			Data: []byte(`{{.Error}}`),
+		},
+		"sign-in-code.go.html": {
+			Data: []byte(`{{.Link}}{{.Error}}`),
+		},
+		"sign-in-link.go.html": {
+			Data: []byte(`{{.Token}}{{.Error}}`),
+		},
+	}
+	tmpl, err := template.ParseFS(fs, "*.go.html")
+	assert.Nil(t, err)
+	// requestLink submits the username without a password, returning the sign in cookie and the token of the link sent.
+	requestLink := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux) (*http.Cookie, string) {
+		t.Helper()
+		verifier := authtest.NewSignInLinkVerifier()
+		auth.SetEmailVerifier(verifier)
+		auth.SetSignInLinkURL(authtest.TEST_SIGN_IN_LINK_URL)
+		values := url.Values{}
+		values.Add("username", authtest.TEST_USERNAME)
+		result := submitForm(t, auth, mux, "/sign-in", values)
+		assertLocation(t, result, "/sign-in-code")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		require.Equal(t, 1, len(verifier.Links))
+		u, err := url.Parse(verifier.Links[0])
+		assert.Nil(t, err)
+		return cookie, u.Query().Get("token")
+	}
+	submitLink := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, token string, cookies ...*http.Cookie) *http.Response {
+		t.Helper()
+		values := url.Values{}
+		values.Add("token", token)
+		return submitForm(t, auth, mux, "/sign-in-link", values, cookies...)
+	}
+	t.Run("Asks To Check Email", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		auth.SetPasswordlessSignIn(true)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		cookie, _ := requestLink(t, auth, mux)
+		assert.Equal(t, "true", getBody(t, mux, "/sign-in-code", cookie))
+	})
+	t.Run("Get Does Not Use Link", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
+		auth.SetPasswordlessSignIn(true)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		cookie, token := requestLink(t, auth, mux)
+
+		// Email clients may prefetch the link
+		assert.Equal(t, token, getBody(t, mux, "/sign-in-link?token="+token))
+		assert.Equal(t, token, getBody(t, mux, "/sign-in-link?token="+token))
+
+		assertLocation(t, submitLink(t, auth, mux, token, cookie), "/account")
+	})
+	t.Run("Signs In On Another Device", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
+		auth.SetPasswordlessSignIn(true)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		requester, token := requestLink(t, auth, mux)
+
+		result := submitLink(t, auth, mux, token)
+		assertLocation(t, result, "/account")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		assert.NotEqual(t, requester.Value, cookie.Value)
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	})
+	t.Run("Link Can Only Be Used Once", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
+		auth.SetPasswordlessSignIn(true)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		_, token := requestLink(t, auth, mux)
+
+		assertLocation(t, submitLink(t, auth, mux, token), "/account")
+
+		result := submitLink(t, auth, mux, token)
+		assertLocation(t, result, "/sign-in")
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		assert.Equal(t, authgo.ErrSignInLinkInvalid.Error(), getBody(t, mux, "/sign-in", cookie))
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+	})
+	t.Run("Redirects When Disabled", func(t *testing.T) {
+		auth := a(t)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		assertLocation(t, submitLink(t, auth, mux, "foobar"), "/sign-in")
+	})
+}
@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func SignInTOTP(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	fs := fstest.MapFS{
+		"sign-in.go.html": {
+			Data: []byte(`{{.Error}}`),
+		},
+		"sign-in-totp.go.html": {
+			Data: []byte(`{{.Error}}`),
+		},
+	}
+	tmpl, err := template.ParseFS(fs, "*.go.html")
+	assert.Nil(t, err)
+	// signInWithPassword signs in to an account with two-factor authentication enabled, returning the sign in cookie.
+	signInWithPassword := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux) *http.Cookie {
+		t.Helper()
+		reader := strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + authtest.TEST_PASSWORD)
+		request := httptest.NewRequest(http.MethodPost, "/sign-in", reader)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		assert.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "/sign-in-totp", u.String())
+		cookies := result.Cookies()
+		require.Equal(t, 1, len(cookies))
+		assert.Equal(t, authgo.COOKIE_SIGN_IN, cookies[0].Name)
+		return cookies[0]
+	}
+	submitCode := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, cookie *http.Cookie, code string) *http.Response {
+		t.Helper()
+		values := url.Values{}
+		values.Add("code", code)
+		request := httptest.NewRequest(http.MethodPost, "/sign-in-totp", strings.NewReader(values.Encode()))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(cookie)
+		authtest.AddCSRF(auth, request)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		return response.Result()
+	}
+	t.Run("Redirects When Not Pending", func(t *testing.T) {
+		auth := a(t)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		request := httptest.NewRequest(http.MethodGet, "/sign-in-totp", nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		assert.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "/sign-in", u.String())
+	})
+	t.Run("Signs In After Code", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		assert.Nil(t, auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true))
+		secret := authtest.EnableTOTP(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		cookie := signInWithPassword(t, auth, mux)
+
+		// Password alone does not authenticate
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+		assert.True(t, session.PendingSecondFactor)
+
+		request := httptest.NewRequest(http.MethodGet, "/sign-in-totp", nil)
+		request.AddCookie(cookie)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		assert.Equal(t, http.StatusOK, response.Result().StatusCode)
+
+		result := submitCode(t, auth, mux, cookie, authtest.NextTOTPCode(secret))
+		assert.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "/account", u.String())
+
+		session = auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+		assert.False(t, session.PendingSecondFactor)
+	})
+	t.Run("Redirects When Code Is Wrong", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		authtest.EnableTOTP(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		cookie := signInWithPassword(t, auth, mux)
+
+		result := submitCode(t, auth, mux, cookie, "000000")
+		assert.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "/sign-in-totp", u.String())
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.False(t, session.Authenticated)
+
+		// Subsequent Get request should show error
+		request := httptest.NewRequest(http.MethodGet, "/sign-in-totp", nil)
+		request.AddCookie(cookie)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result = response.Result()
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, authgo.ErrTOTPCodeIncorrect.Error(), string(body))
+	})
+	t.Run("Changing Username Clears Pending", func(t *testing.T) {
+		auth := a(t)
+		authtest.NewTestAccount(t, auth)
+		authtest.EnableTOTP(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		cookie := signInWithPassword(t, auth, mux)
+
+		reader := strings.NewReader("username=bob&password=foobarfoobar")
+		request := httptest.NewRequest(http.MethodPost, "/sign-in", reader)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		request.AddCookie(cookie)
+		authtest.AddCSRF(auth, request)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+
+		session := auth.LookupSignInSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.Equal(t, "bob", session.Username)
+		assert.False(t, session.Authenticated)
+		assert.False(t, session.PendingSecondFactor)
+
+		request = httptest.NewRequest(http.MethodGet, "/sign-in-totp", nil)
+		request.AddCookie(cookie)
+		response = httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		assert.Equal(t, http.StatusFound, result.StatusCode)
+		u, err := result.Location()
+		assert.Nil(t, err)
+		assert.Equal(t, "/sign-in", u.String())
+	})
+}
@@ -4,11 +4,14 @@ import (
 	"aletheiaware.com/authgo"
 	"aletheiaware.com/authgo/authtest"
 	"aletheiaware.com/authgo/handler"
+	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"html/template"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"testing"
 )
 
@@ -39,6 +42,7 @@ func SignOut(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		handler.AttachSignOutHandler(mux, auth, tmpl)
 		request := httptest.NewRequest(http.MethodPost, "/sign-out", nil)
 		request.AddCookie(auth.NewSignInSessionCookie(token))
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -46,11 +50,35 @@ func SignOut(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		u, err := result.Location()
 		assert.Nil(t, err)
 		assert.Equal(t, "/", u.String())
-		username, authenticated, _, errmsg, ok := auth.LookupSignInSession(token)
-		assert.Equal(t, username, authtest.TEST_USERNAME)
-		assert.False(t, authenticated)
-		assert.Empty(t, errmsg)
-		assert.True(t, ok)
+		session := auth.LookupSignInSession(context.Background(), token)
+		require.NotNil(t, session)
+		assert.Equal(t, session.Username, authtest.TEST_USERNAME)
+		assert.False(t, session.Authenticated)
+		assert.Empty(t, session.Error)
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_IN)
+		require.NotNil(t, cookie)
+		assert.Empty(t, cookie.Value)
+		assert.True(t, cookie.MaxAge < 0)
+	})
+	t.Run("Clears Cookie With Policy After Sign Out", func(t *testing.T) {
+		auth := a(t)
+		auth.SetCookiePolicy(&authgo.CookiePolicy{
+			Prefix:     authgo.COOKIE_PREFIX_SECURE,
+			Domain:     "example.com",
+			Persistent: true,
+		})
+		authtest.NewTestAccount(t, auth)
+		token, _ := authtest.SignIn(t, auth)
+		mux := http.NewServeMux()
+		handler.AttachSignOutHandler(mux, auth, tmpl)
+		result := submitForm(t, auth, mux, "/sign-out", url.Values{}, auth.NewSignInSessionCookie(token))
+		assertLocation(t, result, "/")
+		cookie := findCookie(result.Cookies(), "__Secure-sign-in")
+		require.NotNil(t, cookie)
+		assert.Empty(t, cookie.Value)
+		assert.Equal(t, "example.com", cookie.Domain)
+		assert.Equal(t, "/", cookie.Path)
+		assert.True(t, cookie.MaxAge < 0)
 	})
 	t.Run("Redirects When Not Signed In", func(t *testing.T) {
 		auth := a(t)
@@ -4,7 +4,9 @@ import (
 	"aletheiaware.com/authgo"
 	"aletheiaware.com/authgo/authtest"
 	"aletheiaware.com/authgo/handler"
+	"context"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"html/template"
 	"io"
 	"net/http"
@@ -58,6 +60,7 @@ func SignUp(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		reader := strings.NewReader(values.Encode())
 		request := httptest.NewRequest(http.MethodPost, "/sign-up", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -68,13 +71,13 @@ func SignUp(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		u, err := result.Location()
 		assert.Nil(t, err)
 		assert.Equal(t, "/sign-up-verification", u.String())
-		email, username, referrer, challenge, errmsg, ok := auth.LookupSignUpSession(cookies[0].Value)
-		assert.Equal(t, authtest.TEST_EMAIL, email)
-		assert.Equal(t, authtest.TEST_USERNAME, username)
-		assert.Empty(t, referrer)
-		assert.Equal(t, authtest.TEST_CHALLENGE, challenge)
-		assert.Empty(t, errmsg)
-		assert.True(t, ok)
+		session := auth.LookupSignUpSession(context.Background(), cookies[0].Value)
+		require.NotNil(t, session)
+		assert.Equal(t, authtest.TEST_EMAIL, session.Email)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+		assert.Empty(t, session.Referrer)
+		assert.Equal(t, authtest.TEST_CHALLENGE, session.Challenge)
+		assert.Empty(t, session.Error)
 	})
 	t.Run("Redirects When Form Data Is Invalid", func(t *testing.T) {
 		usernameShort := strings.Repeat("x", authgo.MINIMUM_USERNAME_LENGTH-1)
@@ -151,6 +154,33 @@ func SignUp(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 				},
 				result: authgo.ErrPasswordTooShort.Error() + authtest.TEST_EMAIL + authtest.TEST_USERNAME,
 			},
+			"Password Too Weak": {
+				form: map[string]string{
+					"email":        authtest.TEST_EMAIL,
+					"username":     authtest.TEST_USERNAME,
+					"password":     "password1234",
+					"confirmation": "password1234",
+				},
+				result: authgo.ErrPasswordTooWeak.Error() + authtest.TEST_EMAIL + authtest.TEST_USERNAME,
+			},
+			"Password Contains Username": {
+				form: map[string]string{
+					"email":        authtest.TEST_EMAIL,
+					"username":     authtest.TEST_USERNAME,
+					"password":     "olive-" + authtest.TEST_USERNAME + "-kettle-42",
+					"confirmation": "olive-" + authtest.TEST_USERNAME + "-kettle-42",
+				},
+				result: authgo.ErrPasswordContainsUsername.Error() + authtest.TEST_EMAIL + authtest.TEST_USERNAME,
+			},
+			"Password Contains Email": {
+				form: map[string]string{
+					"email":        "bobby@example.com",
+					"username":     "robert",
+					"password":     "olive-bobby-kettle-42",
+					"confirmation": "olive-bobby-kettle-42",
+				},
+				result: authgo.ErrPasswordContainsEmail.Error() + "bobby@example.com" + "robert",
+			},
 			"Passwords Do Not Match": {
 				form: map[string]string{
 					"email":        authtest.TEST_EMAIL,
@@ -165,7 +195,7 @@ func SignUp(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 				auth := a(t)
 				mux := http.NewServeMux()
 				handler.AttachSignUpHandler(mux, auth, tmpl)
-				_, err := auth.NewAccount(existingEmail, existingUsername, []byte(authtest.TEST_PASSWORD))
+				_, err := auth.NewAccount(context.Background(), existingEmail, existingUsername, []byte(authtest.TEST_PASSWORD))
 				assert.Nil(t, err)
 				values := url.Values{}
 				for k, v := range tt.form {
@@ -174,6 +204,7 @@ func SignUp(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 				reader := strings.NewReader(values.Encode())
 				request := httptest.NewRequest(http.MethodPost, "/sign-up", reader)
 				request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+				authtest.AddCSRF(auth, request)
 				response := httptest.NewRecorder()
 				mux.ServeHTTP(response, request)
 				result := response.Result()
@@ -208,7 +239,7 @@ func SignUpVerification(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		mux := http.NewServeMux()
 		handler.AttachSignUpHandler(mux, auth, tmpl)
 		authtest.NewTestAccount(t, auth)
-		token, err := auth.NewSignUpSession()
+		token, err := auth.NewSignUpSession(context.Background())
 		assert.Nil(t, err)
 		request := httptest.NewRequest(http.MethodGet, "/sign-up-verification", nil)
 		request.AddCookie(auth.NewSignUpSessionCookie(token))
@@ -238,11 +269,11 @@ func SignUpVerification(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		mux := http.NewServeMux()
 		handler.AttachSignUpHandler(mux, auth, tmpl)
 		authtest.NewTestAccount(t, auth)
-		token, err := auth.NewSignUpSession()
+		token, err := auth.NewSignUpSession(context.Background())
 		assert.Nil(t, err)
-		err = auth.SetSignUpSessionIdentity(token, authtest.TEST_EMAIL, authtest.TEST_USERNAME)
+		err = auth.SetSignUpSessionIdentity(context.Background(), token, authtest.TEST_EMAIL, authtest.TEST_USERNAME)
 		assert.Nil(t, err)
-		err = auth.SetSignUpSessionChallenge(token, authtest.TEST_CHALLENGE)
+		err = auth.SetSignUpSessionChallenge(context.Background(), token, authtest.TEST_CHALLENGE)
 		assert.Nil(t, err)
 		values := url.Values{}
 		values.Add("verification", authtest.TEST_CHALLENGE)
@@ -250,6 +281,7 @@ func SignUpVerification(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		request := httptest.NewRequest(http.MethodPost, "/sign-up-verification", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		request.AddCookie(auth.NewSignUpSessionCookie(token))
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
@@ -260,21 +292,21 @@ func SignUpVerification(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		u, err := result.Location()
 		assert.Nil(t, err)
 		assert.Equal(t, "/account", u.String())
-		username, authenticated, _, errmsg, ok := auth.LookupSignInSession(cookies[0].Value)
-		assert.Equal(t, authtest.TEST_USERNAME, username)
-		assert.True(t, authenticated)
-		assert.Empty(t, errmsg)
-		assert.True(t, ok)
-		assert.True(t, auth.IsEmailVerified(authtest.TEST_EMAIL))
+		session := auth.LookupSignInSession(context.Background(), cookies[0].Value)
+		require.NotNil(t, session)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+		assert.True(t, session.Authenticated)
+		assert.Empty(t, session.Error)
+		assert.True(t, auth.IsEmailVerified(context.Background(), authtest.TEST_EMAIL))
 	})
 	t.Run("Redirects When Challenge Is Incorrect", func(t *testing.T) {
 		auth := a(t)
 		mux := http.NewServeMux()
 		handler.AttachSignUpHandler(mux, auth, tmpl)
-		token, err := auth.NewSignUpSession()
+		token, err := auth.NewSignUpSession(context.Background())
 		assert.Nil(t, err)
 		cookie := auth.NewSignUpSessionCookie(token)
-		err = auth.SetSignUpSessionChallenge(token, authtest.TEST_CHALLENGE)
+		err = auth.SetSignUpSessionChallenge(context.Background(), token, authtest.TEST_CHALLENGE)
 		assert.Nil(t, err)
 		values := url.Values{}
 		values.Add("verification", "1234abcd")
@@ -282,6 +314,7 @@ func SignUpVerification(t *testing.T, a func(*testing.T) authgo.Authenticator) {
 		request := httptest.NewRequest(http.MethodPost, "/sign-up-verification", reader)
 		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		request.AddCookie(cookie)
+		authtest.AddCSRF(auth, request)
 		response := httptest.NewRecorder()
 		mux.ServeHTTP(response, request)
 		result := response.Result()
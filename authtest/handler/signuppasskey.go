@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"html/template"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func SignUpPasskey(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	tmpl, err := template.New("sign-up-passkey.go.html").Parse(`{{.Error}}`)
+	assert.Nil(t, err)
+	identity := func(email, username string) url.Values {
+		values := url.Values{}
+		values.Add("email", email)
+		values.Add("username", username)
+		return values
+	}
+	// begin requests the options, returning the sign up session cookie and the credential created by the device
+	begin := func(t *testing.T, auth authgo.Authenticator, mux *http.ServeMux, device *authtest.PasskeyAuthenticator) (*http.Cookie, string) {
+		t.Helper()
+		result := submitForm(t, auth, mux, "/sign-up-passkey-options", identity(authtest.TEST_EMAIL, authtest.TEST_USERNAME))
+		cookie := findCookie(result.Cookies(), authgo.COOKIE_SIGN_UP)
+		require.NotNil(t, cookie)
+		var options authgo.PasskeyCreationOptions
+		decodePasskeyOptions(t, result, &options)
+		assert.Equal(t, authtest.TEST_USERNAME, options.User.Name)
+		assert.Equal(t, "none", options.Attestation)
+		credential, err := device.Create(&options)
+		assert.Nil(t, err)
+		return cookie, string(credential)
+	}
+	t.Run("Returns 200", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		mux := http.NewServeMux()
+		handler.AttachSignUpPasskeyHandler(mux, auth, tmpl)
+		assert.Empty(t, getBody(t, mux, "/sign-up-passkey"))
+	})
+	t.Run("Redirects After Sign Up", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		mux := http.NewServeMux()
+		handler.AttachSignUpPasskeyHandler(mux, auth, tmpl)
+		device := authtest.NewPasskeyAuthenticator()
+		cookie, credential := begin(t, auth, mux, device)
+		values := url.Values{}
+		values.Add("credential", credential)
+		values.Add("name", "Phone")
+		assertLocation(t, submitForm(t, auth, mux, "/sign-up-passkey", values, cookie), "/sign-up-verification")
+
+		session := auth.LookupSignUpSession(context.Background(), cookie.Value)
+		require.NotNil(t, session)
+		assert.Equal(t, authtest.TEST_CHALLENGE, session.Challenge)
+		assert.Empty(t, session.PasskeyChallenge)
+		passkeys, err := auth.LookupPasskeys(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		require.Equal(t, 1, len(passkeys))
+		assert.Equal(t, "Phone", passkeys[0].Name)
+
+		// The account has no usable password
+		_, err = auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+		assert.Equal(t, authgo.ErrCredentialsIncorrect, err)
+	})
+	t.Run("Returns 400 When Identity Invalid", func(t *testing.T) {
+		for name, tt := range map[string]struct {
+			email, username string
+			result          string
+		}{
+			"Email Invalid": {
+				email:    "alice",
+				username: authtest.TEST_USERNAME,
+				result:   authgo.ErrEmailInvalid.Error(),
+			},
+			"Username Invalid": {
+				email:    authtest.TEST_EMAIL,
+				username: "alice!",
+				result:   authgo.ErrUsernameInvalid.Error(),
+			},
+			"Email Already Registered": {
+				email:    authtest.TEST_EMAIL,
+				username: "bob",
+				result:   authgo.ErrEmailAlreadyRegistered.Error(),
+			},
+			"Username Already Registered": {
+				email:    "bob@example.com",
+				username: authtest.TEST_USERNAME,
+				result:   authgo.ErrUsernameAlreadyRegistered.Error(),
+			},
+		} {
+			t.Run(name, func(t *testing.T) {
+				auth := a(t)
+				auth.SetRelyingParty(authtest.NewRelyingParty())
+				authtest.NewTestAccount(t, auth)
+				mux := http.NewServeMux()
+				handler.AttachSignUpPasskeyHandler(mux, auth, tmpl)
+				result := submitForm(t, auth, mux, "/sign-up-passkey-options", identity(tt.email, tt.username))
+				assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+				assert.Equal(t, tt.result, decodePasskeyError(t, result))
+			})
+		}
+	})
+	t.Run("Redirects When Passkey Invalid", func(t *testing.T) {
+		auth := a(t)
+		auth.SetRelyingParty(authtest.NewRelyingParty())
+		mux := http.NewServeMux()
+		handler.AttachSignUpPasskeyHandler(mux, auth, tmpl)
+		device := authtest.NewPasskeyAuthenticator()
+		device.UserVerified = false
+		cookie, credential := begin(t, auth, mux, device)
+		values := url.Values{}
+		values.Add("credential", credential)
+		assertLocation(t, submitForm(t, auth, mux, "/sign-up-passkey", values, cookie), "/sign-up-passkey")
+		assert.Equal(t, authgo.ErrPasskeyInvalid.Error()+": user not verified", getBody(t, mux, "/sign-up-passkey", cookie))
+		_, err := auth.LookupAccount(context.Background(), authtest.TEST_USERNAME)
+		assert.Equal(t, authgo.ErrUsernameNotRegistered, err)
+	})
+}
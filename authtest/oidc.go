@@ -0,0 +1,289 @@
+package authtest
+
+import (
+	"aletheiaware.com/authgo"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	TEST_OIDC_PROVIDER      = "test"
+	TEST_OIDC_CLIENT_ID     = "example"
+	TEST_OIDC_CLIENT_SECRET = "secret"
+	TEST_OIDC_SUBJECT       = "1234567890"
+	TEST_OIDC_REDIRECT_URL  = "https://example.com/sign-in-oidc-callback"
+
+	TEST_OIDC_ISSUER              = "https://example.com"
+	TEST_OIDC_CLIENT_NAME         = "Example App"
+	TEST_OIDC_CLIENT_REDIRECT_URI = "https://app.example.com/callback"
+	TEST_OIDC_STATE               = "efgh5678"
+	TEST_OIDC_NONCE               = "ijkl9012"
+	TEST_OIDC_VERIFIER            = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+var oidcEncoding = base64.RawURLEncoding
+
+// IdentityProvider is an OpenID Connect provider served in-process, which signs in a single user without asking, for use in tests.
+type IdentityProvider struct {
+	sync.Mutex
+	Server *httptest.Server
+	// Algorithm is the algorithm ID tokens are signed with, RS256 or ES256.
+	Algorithm string
+	// Subject, Email, EmailVerified and PreferredUsername identify the user who signs in.
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	// Claims, if set, can alter the claims of each ID token before it is signed.
+	Claims func(map[string]interface{})
+	keyID  string
+	key    crypto.Signer
+	codes  map[string]*identityProviderCode
+}
+
+type identityProviderCode struct {
+	redirect  string
+	nonce     string
+	challenge string
+}
+
+// NewIdentityProvider starts a provider signing ID tokens with ES256, which is stopped when the test finishes.
+func NewIdentityProvider(t *testing.T) *IdentityProvider {
+	t.Helper()
+	p := &IdentityProvider{
+		Algorithm:     authgo.JWT_ALGORITHM_ES256,
+		Subject:       TEST_OIDC_SUBJECT,
+		Email:         TEST_EMAIL,
+		EmailVerified: true,
+		codes:         make(map[string]*identityProviderCode),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/authorize", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Use IdentityProvider.Authorize", http.StatusNotImplemented)
+	})
+	mux.HandleFunc("/token", p.token)
+	mux.HandleFunc("/jwks", p.jwks)
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Server.Close)
+	if err := p.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// Provider returns the configuration for signing in with the provider.
+func (p *IdentityProvider) Provider() *authgo.OIDCProvider {
+	return &authgo.OIDCProvider{
+		ID:           TEST_OIDC_PROVIDER,
+		Name:         "Test",
+		Issuer:       p.Server.URL,
+		ClientID:     TEST_OIDC_CLIENT_ID,
+		ClientSecret: TEST_OIDC_CLIENT_SECRET,
+		RedirectURL:  TEST_OIDC_REDIRECT_URL,
+		Client:       p.Server.Client(),
+	}
+}
+
+// RotateKey replaces the signing key with a new one of the provider's algorithm.
+func (p *IdentityProvider) RotateKey() error {
+	p.Lock()
+	defer p.Unlock()
+	var (
+		key crypto.Signer
+		err error
+	)
+	switch p.Algorithm {
+	case authgo.JWT_ALGORITHM_RS256:
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	default:
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	}
+	if err != nil {
+		return err
+	}
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	p.key = key
+	p.keyID = oidcEncoding.EncodeToString(id)
+	return nil
+}
+
+// Authorize signs the user in as though they had followed the authorization URL, returning the state and code the user is redirected back with.
+func (p *IdentityProvider) Authorize(authorizationURL string) (string, string, error) {
+	u, err := url.Parse(authorizationURL)
+	if err != nil {
+		return "", "", err
+	}
+	if !strings.HasPrefix(authorizationURL, p.Server.URL+"/authorize?") {
+		return "", "", fmt.Errorf("Unexpected Authorization URL: %s", authorizationURL)
+	}
+	query := u.Query()
+	for k, v := range map[string]string{
+		"response_type":         "code",
+		"client_id":             TEST_OIDC_CLIENT_ID,
+		"code_challenge_method": "S256",
+	} {
+		if query.Get(k) != v {
+			return "", "", fmt.Errorf("Unexpected %s: %s", k, query.Get(k))
+		}
+	}
+	if !strings.Contains(" "+query.Get("scope")+" ", " openid ") {
+		return "", "", fmt.Errorf("Missing openid scope: %s", query.Get("scope"))
+	}
+	code := make([]byte, 16)
+	if _, err := rand.Read(code); err != nil {
+		return "", "", err
+	}
+	c := oidcEncoding.EncodeToString(code)
+	p.Lock()
+	p.codes[c] = &identityProviderCode{
+		redirect:  query.Get("redirect_uri"),
+		nonce:     query.Get("nonce"),
+		challenge: query.Get("code_challenge"),
+	}
+	p.Unlock()
+	return query.Get("state"), c, nil
+}
+
+func (p *IdentityProvider) discovery(w http.ResponseWriter, r *http.Request) {
+	writeIdentityProviderJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.Server.URL,
+		"authorization_endpoint":                p.Server.URL + "/authorize",
+		"token_endpoint":                        p.Server.URL + "/token",
+		"jwks_uri":                              p.Server.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{p.Algorithm},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *IdentityProvider) jwks(w http.ResponseWriter, r *http.Request) {
+	p.Lock()
+	defer p.Unlock()
+	key, err := authgo.NewJSONWebKey(p.keyID, p.key.Public())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeIdentityProviderJSON(w, http.StatusOK, &authgo.JSONWebKeySet{
+		Keys: []*authgo.JSONWebKey{key},
+	})
+}
+
+func (p *IdentityProvider) token(w http.ResponseWriter, r *http.Request) {
+	fail := func(e string) {
+		writeIdentityProviderJSON(w, http.StatusBadRequest, map[string]string{
+			"error": e,
+		})
+	}
+	if r.Method != http.MethodPost || r.FormValue("grant_type") != "authorization_code" {
+		fail("unsupported_grant_type")
+		return
+	}
+	if id, secret, ok := r.BasicAuth(); !ok || id != TEST_OIDC_CLIENT_ID || secret != TEST_OIDC_CLIENT_SECRET {
+		fail("invalid_client")
+		return
+	}
+	p.Lock()
+	code, ok := p.codes[r.FormValue("code")]
+	// Codes can only be used once
+	delete(p.codes, r.FormValue("code"))
+	p.Unlock()
+	if !ok || code.redirect != r.FormValue("redirect_uri") {
+		fail("invalid_grant")
+		return
+	}
+	hash := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if oidcEncoding.EncodeToString(hash[:]) != code.challenge {
+		fail("invalid_grant")
+		return
+	}
+	token, err := p.IDToken(code.nonce)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeIdentityProviderJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": "unused",
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     token,
+	})
+}
+
+// IDToken returns a signed ID token for the user with the given nonce.
+func (p *IdentityProvider) IDToken(nonce string) (string, error) {
+	p.Lock()
+	defer p.Unlock()
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":            p.Server.URL,
+		"sub":            p.Subject,
+		"aud":            TEST_OIDC_CLIENT_ID,
+		"exp":            now.Add(time.Hour).Unix(),
+		"iat":            now.Unix(),
+		"nonce":          nonce,
+		"email":          p.Email,
+		"email_verified": p.EmailVerified,
+	}
+	if p.PreferredUsername != "" {
+		claims["preferred_username"] = p.PreferredUsername
+	}
+	if p.Claims != nil {
+		p.Claims(claims)
+	}
+	return authgo.SignJWT(&authgo.JWTHeader{
+		Kid: p.keyID,
+		Typ: "JWT",
+	}, claims, p.key)
+}
+
+func writeIdentityProviderJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// NewOIDCClient makes the authenticator an identity provider, and registers a confidential client with it, returning the client and its secret.
+func NewOIDCClient(t *testing.T, a authgo.Authenticator) (*authgo.OIDCClient, string) {
+	t.Helper()
+	a.SetOIDCIssuer(TEST_OIDC_ISSUER)
+	client, secret, err := a.NewOIDCClient(context.Background(), TEST_OIDC_CLIENT_NAME, []string{TEST_OIDC_CLIENT_REDIRECT_URI}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, secret
+}
+
+// NewOIDCAuthorizationRequest returns a request from the client for the user's email address and username, with the code challenge of TEST_OIDC_VERIFIER.
+func NewOIDCAuthorizationRequest(client *authgo.OIDCClient) *authgo.OIDCAuthorizationRequest {
+	return &authgo.OIDCAuthorizationRequest{
+		ClientID:            client.ID,
+		RedirectURI:         TEST_OIDC_CLIENT_REDIRECT_URI,
+		ResponseType:        "code",
+		Scope:               "openid email profile",
+		State:               TEST_OIDC_STATE,
+		Nonce:               TEST_OIDC_NONCE,
+		CodeChallenge:       authgo.OIDCCodeChallenge(TEST_OIDC_VERIFIER),
+		CodeChallengeMethod: "S256",
+	}
+}
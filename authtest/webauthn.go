@@ -0,0 +1,264 @@
+package authtest
+
+import (
+	"aletheiaware.com/authgo"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+const (
+	TEST_RELYING_PARTY_ID = "example.com"
+	TEST_ORIGIN           = "https://example.com"
+)
+
+var (
+	ErrPasskeyExcluded = errors.New("Passkey Excluded")
+	ErrPasskeyMissing  = errors.New("Passkey Missing")
+)
+
+var passkeyEncoding = base64.RawURLEncoding
+
+func NewRelyingParty() *authgo.RelyingParty {
+	return &authgo.RelyingParty{
+		ID:      TEST_RELYING_PARTY_ID,
+		Name:    "Example",
+		Origins: []string{TEST_ORIGIN},
+	}
+}
+
+// PasskeyAuthenticator is a software authenticator which holds its ES256 keys in memory and signs in-process, for use in tests.
+type PasskeyAuthenticator struct {
+	// Origin is reported to the relying party as the origin of each ceremony.
+	Origin string
+	// UserVerified is reported to the relying party as whether the user was verified with a biometric or PIN.
+	UserVerified bool
+	// SignCount is the signature counter, shared by all credentials and incremented by each assertion.
+	SignCount   uint32
+	credentials []*softwareCredential
+}
+
+type softwareCredential struct {
+	id         []byte
+	rpID       string
+	userHandle []byte
+	key        *ecdsa.PrivateKey
+}
+
+func NewPasskeyAuthenticator() *PasskeyAuthenticator {
+	return &PasskeyAuthenticator{
+		Origin:       TEST_ORIGIN,
+		UserVerified: true,
+	}
+}
+
+// Create performs navigator.credentials.create() with the options, returning the JSON encoded credential.
+func (p *PasskeyAuthenticator) Create(options *authgo.PasskeyCreationOptions) ([]byte, error) {
+	for _, e := range options.ExcludeCredentials {
+		if p.credential(options.RP.ID, e.ID) != nil {
+			return nil, ErrPasskeyExcluded
+		}
+	}
+	userHandle, err := passkeyEncoding.DecodeString(options.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	c := &softwareCredential{
+		id:         id,
+		rpID:       options.RP.ID,
+		userHandle: userHandle,
+		key:        key,
+	}
+	// A discoverable credential replaces any other for the same account
+	for i, existing := range p.credentials {
+		if existing.rpID == c.rpID && string(existing.userHandle) == string(c.userHandle) {
+			p.credentials = append(p.credentials[:i], p.credentials[i+1:]...)
+			break
+		}
+	}
+	p.credentials = append(p.credentials, c)
+
+	clientData, err := p.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+	publicKey := encodeCBOR(map[int]interface{}{
+		1:  2,
+		3:  authgo.COSE_ALGORITHM_ES256,
+		-1: 1,
+		-2: x,
+		-3: y,
+	})
+	attested := make([]byte, 18, 18+len(id)+len(publicKey))
+	binary.BigEndian.PutUint16(attested[16:], uint16(len(id)))
+	attested = append(attested, id...)
+	attested = append(attested, publicKey...)
+	authData := p.authenticatorData(c.rpID, 0x40)
+	authData = append(authData, attested...)
+	attestation := encodeCBOR(map[string]interface{}{
+		"fmt":      "none",
+		"attStmt":  map[string]interface{}{},
+		"authData": authData,
+	})
+	return json.Marshal(&authgo.PasskeyCredential{
+		ID:    passkeyEncoding.EncodeToString(id),
+		RawID: passkeyEncoding.EncodeToString(id),
+		Type:  "public-key",
+		Response: authgo.PasskeyCredentialResponse{
+			ClientDataJSON:    passkeyEncoding.EncodeToString(clientData),
+			AttestationObject: passkeyEncoding.EncodeToString(attestation),
+		},
+	})
+}
+
+// Get performs navigator.credentials.get() with the options, returning the JSON encoded credential.
+// The most recently created credential for the relying party is used unless the options restrict the choice.
+func (p *PasskeyAuthenticator) Get(options *authgo.PasskeyRequestOptions) ([]byte, error) {
+	var c *softwareCredential
+	if len(options.AllowCredentials) == 0 {
+		for _, existing := range p.credentials {
+			if existing.rpID == options.RPID {
+				c = existing
+			}
+		}
+	} else {
+		for _, a := range options.AllowCredentials {
+			if c = p.credential(options.RPID, a.ID); c != nil {
+				break
+			}
+		}
+	}
+	if c == nil {
+		return nil, ErrPasskeyMissing
+	}
+	p.SignCount++
+	clientData, err := p.clientData("webauthn.get", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	authData := p.authenticatorData(c.rpID, 0)
+	hash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), hash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&authgo.PasskeyCredential{
+		ID:    passkeyEncoding.EncodeToString(c.id),
+		RawID: passkeyEncoding.EncodeToString(c.id),
+		Type:  "public-key",
+		Response: authgo.PasskeyCredentialResponse{
+			ClientDataJSON:    passkeyEncoding.EncodeToString(clientData),
+			AuthenticatorData: passkeyEncoding.EncodeToString(authData),
+			Signature:         passkeyEncoding.EncodeToString(signature),
+			UserHandle:        passkeyEncoding.EncodeToString(c.userHandle),
+		},
+	})
+}
+
+func (p *PasskeyAuthenticator) credential(rpID, id string) *softwareCredential {
+	for _, c := range p.credentials {
+		if c.rpID == rpID && passkeyEncoding.EncodeToString(c.id) == id {
+			return c
+		}
+	}
+	return nil
+}
+
+func (p *PasskeyAuthenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      p.Origin,
+		"crossOrigin": false,
+	})
+}
+
+func (p *PasskeyAuthenticator) authenticatorData(rpID string, flags byte) []byte {
+	flags |= 0x01 // User Present
+	if p.UserVerified {
+		flags |= 0x04
+	}
+	hash := sha256.Sum256([]byte(rpID))
+	data := make([]byte, 37)
+	copy(data, hash[:])
+	data[32] = flags
+	binary.BigEndian.PutUint32(data[33:], p.SignCount)
+	return data
+}
+
+// encodeCBOR encodes the subset of CBOR needed for attestation objects and COSE keys.
+func encodeCBOR(v interface{}) []byte {
+	switch v := v.(type) {
+	case int:
+		if v < 0 {
+			return cborHead(1, uint64(-1-v))
+		}
+		return cborHead(0, uint64(v))
+	case []byte:
+		return append(cborHead(2, uint64(len(v))), v...)
+	case string:
+		return append(cborHead(3, uint64(len(v))), v...)
+	case map[int]interface{}:
+		keys := make([]int, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Ints(keys)
+		data := cborHead(5, uint64(len(v)))
+		for _, k := range keys {
+			data = append(data, encodeCBOR(k)...)
+			data = append(data, encodeCBOR(v[k])...)
+		}
+		return data
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		data := cborHead(5, uint64(len(v)))
+		for _, k := range keys {
+			data = append(data, encodeCBOR(k)...)
+			data = append(data, encodeCBOR(v[k])...)
+		}
+		return data
+	default:
+		panic("unsupported CBOR type")
+	}
+}
+
+func cborHead(major byte, n uint64) []byte {
+	switch {
+	case n < 24:
+		return []byte{major<<5 | byte(n)}
+	case n <= 0xff:
+		return []byte{major<<5 | 24, byte(n)}
+	case n <= 0xffff:
+		head := []byte{major<<5 | 25, 0, 0}
+		binary.BigEndian.PutUint16(head[1:], uint16(n))
+		return head
+	default:
+		head := []byte{major<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(head[1:], uint32(n))
+		return head
+	}
+}
@@ -0,0 +1,237 @@
+package authgo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// BEARER_TOKEN_LENGTH is the number of random bytes in each access and refresh token.
+	BEARER_TOKEN_LENGTH = 32
+	// DEFAULT_BEARER_ACCESS_TOKEN_TIMEOUT is how long an access token is valid for.
+	DEFAULT_BEARER_ACCESS_TOKEN_TIMEOUT = 15 * time.Minute
+	// DEFAULT_BEARER_REFRESH_TOKEN_TIMEOUT is how long a refresh token is valid for, each refresh replaces it with a new one.
+	DEFAULT_BEARER_REFRESH_TOKEN_TIMEOUT = 30 * 24 * time.Hour
+)
+
+var (
+	ErrBearerTokenInvalid = errors.New("Invalid Or Expired Token")
+)
+
+// BearerToken is a grant of an access token and a refresh token to an API client, stored as hashes of the tokens.
+type BearerToken struct {
+	ID          string
+	Username    string
+	AccessHash  string
+	RefreshHash string
+	Created     time.Time
+	// Refreshed is when the tokens were last issued, from which they expire.
+	Refreshed time.Time
+}
+
+// BearerTokens are the tokens issued to an API client.
+type BearerTokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+func NewBearerToken() (string, error) {
+	token := make([]byte, BEARER_TOKEN_LENGTH)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+// HashBearerToken returns the hash stored in place of the token.
+// Tokens are random rather than chosen by users, so a fast hash is enough to make them unrecoverable from the database.
+func HashBearerToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+// BearerTokenFromRequest returns the token in the request's Authorization header, or empty if there is none.
+func BearerTokenFromRequest(r *http.Request) string {
+	const prefix = "bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(header[len(prefix):])
+}
+
+type bearerAccountKey struct{}
+
+// WithBearerAccount returns a copy of the context carrying the account authenticated by the request's bearer token.
+func WithBearerAccount(ctx context.Context, account *Account) context.Context {
+	return context.WithValue(ctx, bearerAccountKey{}, account)
+}
+
+// BearerAccount returns the account carried by the context, or nil if there is none.
+func BearerAccount(ctx context.Context) *Account {
+	account, _ := ctx.Value(bearerAccountKey{}).(*Account)
+	return account
+}
+
+func (a *authenticator) BearerAccessTokenTimeout() time.Duration {
+	return a.bearerAccessTimeout
+}
+
+func (a *authenticator) SetBearerAccessTokenTimeout(timeout time.Duration) {
+	a.bearerAccessTimeout = timeout
+}
+
+func (a *authenticator) BearerRefreshTokenTimeout() time.Duration {
+	return a.bearerRefreshTimeout
+}
+
+func (a *authenticator) SetBearerRefreshTokenTimeout(timeout time.Duration) {
+	a.bearerRefreshTimeout = timeout
+}
+
+// CurrentBearerAccount returns the account authenticated by the request's Authorization header, or nil if there is none or the token is invalid.
+// If the header holds a personal access token it is also returned, and callers must check it has the scope they need; access tokens allow every scope.
+func (a *authenticator) CurrentBearerAccount(r *http.Request) (*Account, *PersonalAccessToken) {
+	token := BearerTokenFromRequest(r)
+	if token == "" {
+		return nil, nil
+	}
+	if IsPersonalAccessToken(token) {
+		account, pat, err := a.AuthenticatePersonalAccessToken(r.Context(), token)
+		if err != nil {
+			log.Println(err)
+			return nil, nil
+		}
+		return account, pat
+	}
+	account, err := a.LookupBearerAccount(r.Context(), token)
+	if err != nil {
+		log.Println(err)
+		return nil, nil
+	}
+	return account, nil
+}
+
+// NewBearerTokens issues an access token and a refresh token for the account.
+// The caller is responsible for authenticating the user first.
+func (a *authenticator) NewBearerTokens(ctx context.Context, username string) (*BearerTokens, error) {
+	id, err := NewBearerToken()
+	if err != nil {
+		return nil, err
+	}
+	access, refresh, err := newBearerTokenPair()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if _, err := a.accounts.CreateBearerToken(ctx, &BearerToken{
+		ID:          id,
+		Username:    username,
+		AccessHash:  HashBearerToken(access),
+		RefreshHash: HashBearerToken(refresh),
+		Created:     now,
+		Refreshed:   now,
+	}); err != nil {
+		return nil, err
+	}
+	return a.bearerTokens(access, refresh), nil
+}
+
+// RefreshBearerTokens replaces the access token and refresh token of the grant the refresh token belongs to.
+// Each refresh token can only be used once.
+func (a *authenticator) RefreshBearerTokens(ctx context.Context, refresh string) (*BearerTokens, error) {
+	token, err := a.accounts.SelectBearerTokenByRefreshHash(ctx, HashBearerToken(refresh))
+	if err != nil {
+		return nil, ErrBearerTokenInvalid
+	}
+	now := time.Now()
+	if token.Refreshed.Add(a.bearerRefreshTimeout).Before(now) {
+		return nil, ErrBearerTokenInvalid
+	}
+	if _, err := a.LookupAccount(ctx, token.Username); err != nil {
+		return nil, ErrBearerTokenInvalid
+	}
+	access, next, err := newBearerTokenPair()
+	if err != nil {
+		return nil, err
+	}
+	// Only the first of concurrent refreshes with the same token succeeds
+	if _, err := a.accounts.UpdateBearerToken(ctx, token.RefreshHash, HashBearerToken(access), HashBearerToken(next), now); err != nil {
+		return nil, ErrBearerTokenInvalid
+	}
+	return a.bearerTokens(access, next), nil
+}
+
+// LookupBearerAccount returns the account the access token was issued for.
+func (a *authenticator) LookupBearerAccount(ctx context.Context, access string) (*Account, error) {
+	token, err := a.accounts.SelectBearerTokenByAccessHash(ctx, HashBearerToken(access))
+	if err != nil {
+		return nil, ErrBearerTokenInvalid
+	}
+	if token.Refreshed.Add(a.bearerAccessTimeout).Before(time.Now()) {
+		return nil, ErrBearerTokenInvalid
+	}
+	account, err := a.LookupAccount(ctx, token.Username)
+	if err != nil {
+		return nil, ErrBearerTokenInvalid
+	}
+	return account, nil
+}
+
+// RevokeBearerToken revokes the grant of the access token or refresh token, so neither token can be used again.
+// Personal access tokens are also revoked, so that anyone who finds a leaked token can revoke it.
+func (a *authenticator) RevokeBearerToken(ctx context.Context, token string) error {
+	hash := HashBearerToken(token)
+	if IsPersonalAccessToken(token) {
+		username, t, err := a.accounts.SelectPersonalAccessToken(ctx, hash)
+		if err != nil {
+			return ErrBearerTokenInvalid
+		}
+		return a.DeletePersonalAccessToken(ctx, username, t.ID)
+	}
+	t, err := a.accounts.SelectBearerTokenByAccessHash(ctx, hash)
+	if err != nil {
+		t, err = a.accounts.SelectBearerTokenByRefreshHash(ctx, hash)
+		if err != nil {
+			return ErrBearerTokenInvalid
+		}
+	}
+	_, err = a.accounts.DeleteBearerToken(ctx, t.ID)
+	return err
+}
+
+// RevokeBearerTokens revokes every grant issued for the account.
+func (a *authenticator) RevokeBearerTokens(ctx context.Context, username string) error {
+	_, err := a.accounts.DeleteBearerTokens(ctx, username)
+	return err
+}
+
+func (a *authenticator) bearerTokens(access, refresh string) *BearerTokens {
+	return &BearerTokens{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(a.bearerAccessTimeout / time.Second),
+		RefreshToken: refresh,
+	}
+}
+
+func newBearerTokenPair() (string, string, error) {
+	access, err := NewBearerToken()
+	if err != nil {
+		return "", "", err
+	}
+	refresh, err := NewBearerToken()
+	if err != nil {
+		return "", "", err
+	}
+	return access, refresh, nil
+}
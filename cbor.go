@@ -0,0 +1,121 @@
+package authgo
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+var ErrInvalidCBOR = errors.New("Invalid CBOR")
+
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the first CBOR item in data, returning the item and the number of bytes it occupied.
+//
+// Only the subset of CBOR used by WebAuthn is supported; integers are returned as int64, byte strings as []byte,
+// text strings as string, arrays as []interface{}, and maps as map[interface{}]interface{}.
+func decodeCBOR(data []byte) (interface{}, int, error) {
+	return decodeCBORItem(data, 0)
+}
+
+func decodeCBORItem(data []byte, depth int) (interface{}, int, error) {
+	if len(data) == 0 || depth > maxCBORDepth {
+		return nil, 0, ErrInvalidCBOR
+	}
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, 1, nil
+		case 21:
+			return true, 1, nil
+		case 22:
+			return nil, 1, nil
+		default:
+			return nil, 0, ErrInvalidCBOR
+		}
+	}
+	argument, n, err := decodeCBORArgument(data, info)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch major {
+	case 0:
+		if argument > 1<<63-1 {
+			return nil, 0, ErrInvalidCBOR
+		}
+		return int64(argument), n, nil
+	case 1:
+		if argument > 1<<63-1 {
+			return nil, 0, ErrInvalidCBOR
+		}
+		return -1 - int64(argument), n, nil
+	case 2, 3:
+		if argument > uint64(len(data)-n) {
+			return nil, 0, ErrInvalidCBOR
+		}
+		end := n + int(argument)
+		if major == 3 {
+			return string(data[n:end]), end, nil
+		}
+		return append([]byte(nil), data[n:end]...), end, nil
+	case 4:
+		if argument > uint64(len(data)) {
+			return nil, 0, ErrInvalidCBOR
+		}
+		items := make([]interface{}, 0, argument)
+		for i := uint64(0); i < argument; i++ {
+			item, m, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			items = append(items, item)
+			n += m
+		}
+		return items, n, nil
+	case 5:
+		if argument > uint64(len(data)) {
+			return nil, 0, ErrInvalidCBOR
+		}
+		items := make(map[interface{}]interface{}, argument)
+		for i := uint64(0); i < argument; i++ {
+			key, m, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, 0, ErrInvalidCBOR
+			}
+			value, m, err := decodeCBORItem(data[n:], depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			n += m
+			items[key] = value
+		}
+		return items, n, nil
+	default:
+		// Tags and indefinite lengths are not used by WebAuthn
+		return nil, 0, ErrInvalidCBOR
+	}
+}
+
+func decodeCBORArgument(data []byte, info byte) (uint64, int, error) {
+	switch {
+	case info < 24:
+		return uint64(info), 1, nil
+	case info == 24 && len(data) >= 2:
+		return uint64(data[1]), 2, nil
+	case info == 25 && len(data) >= 3:
+		return uint64(binary.BigEndian.Uint16(data[1:])), 3, nil
+	case info == 26 && len(data) >= 5:
+		return uint64(binary.BigEndian.Uint32(data[1:])), 5, nil
+	case info == 27 && len(data) >= 9:
+		return binary.BigEndian.Uint64(data[1:]), 9, nil
+	default:
+		return 0, 0, ErrInvalidCBOR
+	}
+}
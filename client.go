@@ -0,0 +1,73 @@
+package authgo
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"unicode/utf8"
+)
+
+const (
+	// MAXIMUM_CLIENT_ADDRESS_LENGTH and MAXIMUM_CLIENT_USER_AGENT_LENGTH limit what is stored of the client of a sign in session, in bytes.
+	MAXIMUM_CLIENT_ADDRESS_LENGTH    = 64
+	MAXIMUM_CLIENT_USER_AGENT_LENGTH = 512
+)
+
+type clientAddressKey struct{}
+
+type clientUserAgentKey struct{}
+
+// WithClientAddress returns a copy of the context carrying the address of the client making the request.
+func WithClientAddress(ctx context.Context, address string) context.Context {
+	return context.WithValue(ctx, clientAddressKey{}, address)
+}
+
+// ClientAddress returns the address of the client carried by the context, or the empty string if there is none.
+func ClientAddress(ctx context.Context) string {
+	address, _ := ctx.Value(clientAddressKey{}).(string)
+	return address
+}
+
+// WithClientUserAgent returns a copy of the context carrying the user agent of the client making the request.
+func WithClientUserAgent(ctx context.Context, agent string) context.Context {
+	return context.WithValue(ctx, clientUserAgentKey{}, agent)
+}
+
+// ClientUserAgent returns the user agent of the client carried by the context, or the empty string if there is none.
+func ClientUserAgent(ctx context.Context) string {
+	agent, _ := ctx.Value(clientUserAgentKey{}).(string)
+	return agent
+}
+
+// WithClient returns a copy of the request's context carrying the request's remote address and user agent, unless the context already carries them.
+func WithClient(r *http.Request) context.Context {
+	ctx := r.Context()
+	if ClientAddress(ctx) == "" {
+		ctx = WithClientAddress(ctx, RemoteAddress(r))
+	}
+	if ClientUserAgent(ctx) == "" {
+		ctx = WithClientUserAgent(ctx, r.UserAgent())
+	}
+	return ctx
+}
+
+// RemoteAddress returns the host part of the request's remote address.
+// Servers behind a proxy should instead set the client address with WithClientAddress.
+func RemoteAddress(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// truncate returns at most the first length bytes of s, without splitting a rune.
+func truncate(s string, length int) string {
+	if len(s) <= length {
+		return s
+	}
+	for length > 0 && !utf8.RuneStart(s[length]) {
+		length--
+	}
+	return s[:length]
+}
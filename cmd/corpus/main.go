@@ -0,0 +1,49 @@
+package main
+
+import (
+	"aletheiaware.com/authgo"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+)
+
+var (
+	input  = flag.String("input", "", "file of compromised passwords, one per line (default stdin)")
+	output = flag.String("output", "", "file to write the password corpus to")
+	hashed = flag.Bool("hashed", false, "input lines are hex encoded SHA-1 digests, optionally followed by :count")
+)
+
+func main() {
+	flag.Parse()
+
+	if *output == "" {
+		log.Fatal("Missing Output")
+	}
+
+	var r io.Reader = os.Stdin
+	if *input != "" {
+		f, err := os.Open(*input)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	w, err := os.Create(*output)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	count, err := authgo.GeneratePasswordCorpus(r, w, *hashed)
+	if err != nil {
+		w.Close()
+		log.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Wrote", count, "passwords to", *output)
+}
@@ -0,0 +1,89 @@
+// Passkey ceremonies for the sign up, sign in, and account passkeys pages.
+// Options are fetched from the server, passed to the browser's authenticator, and the resulting credential is posted back in the form's hidden "credential" field.
+
+function base64URLToBuffer(value) {
+    const base64 = value.replace(/-/g, "+").replace(/_/g, "/");
+    const binary = atob(base64.padEnd(base64.length + (4 - base64.length % 4) % 4, "="));
+    return Uint8Array.from(binary, c => c.charCodeAt(0)).buffer;
+}
+
+function bufferToBase64URL(buffer) {
+    const binary = String.fromCharCode(...new Uint8Array(buffer));
+    return btoa(binary).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+// fetchPasskeyOptions posts the body to the url, sending the CSRF token from the form in a header.
+async function fetchPasskeyOptions(form, url, body) {
+    const response = await fetch(url, {
+        method: "POST",
+        credentials: "same-origin",
+        headers: {"X-CSRF-Token": form.elements["csrf"].value},
+        body: body,
+    });
+    const json = await response.json();
+    if (!response.ok) {
+        throw new Error(json.error || response.statusText);
+    }
+    return json;
+}
+
+function encodePasskeyCredential(credential) {
+    const response = {
+        clientDataJSON: bufferToBase64URL(credential.response.clientDataJSON),
+    };
+    if (credential.response.attestationObject) {
+        response.attestationObject = bufferToBase64URL(credential.response.attestationObject);
+    }
+    if (credential.response.authenticatorData) {
+        response.authenticatorData = bufferToBase64URL(credential.response.authenticatorData);
+        response.signature = bufferToBase64URL(credential.response.signature);
+        if (credential.response.userHandle) {
+            response.userHandle = bufferToBase64URL(credential.response.userHandle);
+        }
+    }
+    return JSON.stringify({
+        id: credential.id,
+        rawId: bufferToBase64URL(credential.rawId),
+        type: credential.type,
+        response: response,
+    });
+}
+
+function showPasskeyError(form, error) {
+    let p = form.querySelector(".error");
+    if (!p) {
+        p = document.createElement("p");
+        p.className = "error";
+        form.prepend(p);
+    }
+    p.textContent = error.message;
+}
+
+// createPasskey registers a new passkey with the options from the url, then submits the form.
+async function createPasskey(form, url, body) {
+    try {
+        const options = await fetchPasskeyOptions(form, url, body);
+        options.challenge = base64URLToBuffer(options.challenge);
+        options.user.id = base64URLToBuffer(options.user.id);
+        (options.excludeCredentials || []).forEach(c => c.id = base64URLToBuffer(c.id));
+        const credential = await navigator.credentials.create({publicKey: options});
+        form.elements["credential"].value = encodePasskeyCredential(credential);
+        form.submit();
+    } catch (error) {
+        showPasskeyError(form, error);
+    }
+}
+
+// getPasskey signs in with a passkey using the options from the url, then submits the form.
+async function getPasskey(form, url) {
+    try {
+        const options = await fetchPasskeyOptions(form, url, new FormData());
+        options.challenge = base64URLToBuffer(options.challenge);
+        (options.allowCredentials || []).forEach(c => c.id = base64URLToBuffer(c.id));
+        const credential = await navigator.credentials.get({publicKey: options});
+        form.elements["credential"].value = encodePasskeyCredential(credential);
+        form.submit();
+    } catch (error) {
+        showPasskeyError(form, error);
+    }
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/cmd/example/model"
+	authhandler "aletheiaware.com/authgo/handler"
+	"aletheiaware.com/netgo/handler"
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+func AttachProductsAPIHandler(m *http.ServeMux, a authgo.Authenticator, p model.ProductManager) {
+	m.Handle("/api/products", handler.Log(authhandler.RequireBearerScope(a, "products:read", ProductsAPI(p))))
+}
+
+func ProductsAPI(p model.ProductManager) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ps := p.AllProducts()
+		if ps == nil {
+			ps = []*model.Product{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(ps); err != nil {
+			log.Println(err)
+			return
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package handler_test
+
+import (
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/cmd/example/handler"
+	"aletheiaware.com/authgo/cmd/example/model"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestProductsAPI(t *testing.T) {
+	t.Run("Returns 401 Without Token", func(t *testing.T) {
+		auth := authtest.NewAuthenticator(t)
+		pm := model.NewInMemoryProductManager()
+		mux := http.NewServeMux()
+		handler.AttachProductsAPIHandler(mux, auth, pm)
+		request := httptest.NewRequest(http.MethodGet, "/api/products", nil)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	})
+	t.Run("Returns 403 Without Scope", func(t *testing.T) {
+		auth := authtest.NewAuthenticator(t)
+		auth.SetPersonalAccessTokenScopes([]string{"products:read"})
+		authtest.NewTestAccount(t, auth)
+		token, err := auth.NewPersonalAccessToken(context.Background(), authtest.TEST_USERNAME, "Script", nil, time.Time{})
+		assert.Nil(t, err)
+		pm := model.NewInMemoryProductManager()
+		mux := http.NewServeMux()
+		handler.AttachProductsAPIHandler(mux, auth, pm)
+		request := httptest.NewRequest(http.MethodGet, "/api/products", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		assert.Equal(t, http.StatusForbidden, result.StatusCode)
+	})
+	t.Run("Returns 200 With Scope", func(t *testing.T) {
+		auth := authtest.NewAuthenticator(t)
+		auth.SetPersonalAccessTokenScopes([]string{"products:read"})
+		authtest.NewTestAccount(t, auth)
+		token, err := auth.NewPersonalAccessToken(context.Background(), authtest.TEST_USERNAME, "Script", []string{"products:read"}, time.Time{})
+		assert.Nil(t, err)
+		pm := model.NewInMemoryProductManager()
+		pm.AddProduct(&model.Product{
+			ID:   "10",
+			Name: "FooBar",
+		})
+		mux := http.NewServeMux()
+		handler.AttachProductsAPIHandler(mux, auth, pm)
+		request := httptest.NewRequest(http.MethodGet, "/api/products", nil)
+		request.Header.Set("Authorization", "Bearer "+token)
+		response := httptest.NewRecorder()
+		mux.ServeHTTP(response, request)
+		result := response.Result()
+		assert.Equal(t, http.StatusOK, result.StatusCode)
+		assert.Equal(t, "application/json", result.Header.Get("Content-Type"))
+		body, err := io.ReadAll(result.Body)
+		assert.Nil(t, err)
+		assert.Equal(t, `[{"ID":"10","Name":"FooBar"}]`+"\n", string(body))
+	})
+}
@@ -9,14 +9,18 @@ import (
 	authhandler "aletheiaware.com/authgo/handler"
 	"aletheiaware.com/netgo"
 	nethandler "aletheiaware.com/netgo/handler"
+	"context"
 	"crypto/tls"
 	"embed"
+	"errors"
 	"fmt"
 	"html/template"
 	"io/fs"
 	"log"
 	"net/http"
+	"os"
 	"path"
+	"strings"
 	"time"
 )
 
@@ -55,23 +59,94 @@ func main() {
 	}
 
 	// Create Database
-	db := database.NewInMemory()
+	var db authgo.Database
+	if path := os.Getenv("DATABASE_FILE"); path != "" {
+		f, err := database.OpenFile(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		db = f
+	} else {
+		db = database.NewInMemory()
+	}
 
 	// Create Email Verifier
 	ev := authtest.NewEmailVerifier()
 
 	// Create an Authenticator
-	auth := authgo.NewAuthenticator(db, ev)
+	auth := authgo.NewAuthenticator(db, db, ev)
+
+	// Name the issuer shown in authenticator apps
+	auth.SetTOTPIssuer("Example")
+
+	// Identify the website to passkey authenticators
+	host := os.Getenv("HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	scheme := "http"
+	if netgo.IsSecure() {
+		scheme = "https"
+	}
+	auth.SetRelyingParty(&authgo.RelyingParty{
+		ID:      host,
+		Name:    "Example",
+		Origins: []string{scheme + "://" + host},
+	})
+
+	// Optionally allow signing in with an OpenID Connect identity provider
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		auth.SetOIDCProviders([]*authgo.OIDCProvider{
+			{
+				ID:           "oidc",
+				Name:         os.Getenv("OIDC_NAME"),
+				Issuer:       issuer,
+				ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+				ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+				RedirectURL:  scheme + "://" + host + "/sign-in-oidc-callback",
+			},
+		})
+	}
+
+	// Act as an OpenID Connect identity provider for other websites
+	auth.SetOIDCIssuer(scheme + "://" + host)
+	if uri := os.Getenv("OIDC_CLIENT_REDIRECT_URI"); uri != "" {
+		client, secret, err := auth.NewOIDCClient(context.Background(), "Example Client", []string{uri}, true)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Println("OIDC Client ID:", client.ID, "Secret:", secret)
+	}
+
+	// Allow signing in with just a username or email address, and a code sent by email
+	auth.SetPasswordlessSignIn(true)
+
+	// Allow personal access tokens to read products
+	auth.SetPersonalAccessTokenScopes([]string{"products:read"})
+
+	// Periodically delete expired sessions
+	auth.StartSessionJanitor(time.Hour)
+	defer auth.StopSessionJanitor()
 
 	// Add Demo Account
-	if _, err := auth.NewAccount(authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD)); err != nil {
+	if _, err := auth.NewAccount(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD)); err != nil && !errors.Is(err, authgo.ErrEmailAlreadyRegistered) {
 		log.Fatal(err)
 	}
-	auth.SetEmailVerified(authtest.TEST_EMAIL, true)
+	auth.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true)
 
 	// Attach Authentication Handlers
 	authhandler.AttachAuthenticationHandlers(mux, auth, templates)
 
+	// Attach JSON API Handlers, optionally allowing scripts on other websites to call them
+	cors := &authhandler.CORS{
+		MaxAge: time.Hour,
+	}
+	if origins := os.Getenv("API_ORIGINS"); origins != "" {
+		cors.Origins = strings.Split(origins, ",")
+	}
+	authhandler.AttachAPIHandlers(mux, auth, cors)
+
 	// Create Product Manager
 	products := model.NewInMemoryProductManager()
 
@@ -91,6 +166,9 @@ func main() {
 	// Handle Individual Product
 	handler.AttachProductHandler(mux, auth, products, templates)
 
+	// Handle All Products for API Clients
+	handler.AttachProductsAPIHandler(mux, auth, products)
+
 	// Handle Index
 	handler.AttachIndexHandler(mux, auth, templates)
 
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"aletheiaware.com/authgo/database"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	_ "github.com/mattn/go-sqlite3"
+	"log"
+	"os"
+)
+
+var (
+	driver  = flag.String("driver", "sqlite3", "database/sql driver name")
+	source  = flag.String("source", "", "database/sql data source name")
+	dialect = flag.String("dialect", "sqlite", "SQL dialect (sqlite, mysql, postgres)")
+	version = flag.Int("version", 0, "schema version to migrate to (default latest)")
+	status  = flag.Bool("status", false, "print the current schema version and exit")
+)
+
+func main() {
+	flag.Parse()
+
+	ctx := context.Background()
+
+	var d *database.Dialect
+	switch *dialect {
+	case database.SQLite.Name:
+		d = database.SQLite
+	case database.MySQL.Name:
+		d = database.MySQL
+	case database.PostgreSQL.Name:
+		d = database.PostgreSQL
+	default:
+		log.Fatal("Unsupported Dialect: ", *dialect)
+	}
+
+	conn, err := sql.Open(*driver, *source)
+	if err != nil {
+		log.Fatal(err)
+	}
+	db := database.NewSQL(conn, d)
+	defer db.Close()
+
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *status {
+		fmt.Println(current)
+		os.Exit(0)
+	}
+
+	if *version == 0 {
+		err = db.Migrate(ctx)
+	} else {
+		err = db.MigrateTo(ctx, *version)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	latest, err := db.SchemaVersion(ctx)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Migrated from version", current, "to", latest)
+}
@@ -0,0 +1,60 @@
+package authgo_test
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/database"
+	"aletheiaware.com/authgo/handler"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowDatabase is an account store which blocks user queries until the context is done.
+type slowDatabase struct {
+	*database.InMemory
+}
+
+func (db *slowDatabase) SelectUser(ctx context.Context, username string) (int64, string, []byte, time.Time, error) {
+	<-ctx.Done()
+	return 0, "", nil, time.Time{}, ctx.Err()
+}
+
+func TestAuthenticator_Context(t *testing.T) {
+	t.Run("Deadline", func(t *testing.T) {
+		auth := authgo.NewAuthenticator(&slowDatabase{database.NewInMemory()}, database.NewInMemory(), authtest.NewEmailVerifier())
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		account, err := auth.LookupAccount(ctx, authtest.TEST_USERNAME)
+		assert.Nil(t, account)
+		assert.Equal(t, context.DeadlineExceeded, err)
+	})
+	t.Run("ClientDisconnects", func(t *testing.T) {
+		auth := authgo.NewAuthenticator(&slowDatabase{database.NewInMemory()}, database.NewInMemory(), authtest.NewEmailVerifier())
+		tmpl, err := template.New("sign-in.go.html").Parse(`{{.Error}}`)
+		assert.Nil(t, err)
+		mux := http.NewServeMux()
+		handler.AttachSignInHandler(mux, auth, tmpl)
+		ctx, cancel := context.WithCancel(context.Background())
+		reader := strings.NewReader("username=" + authtest.TEST_USERNAME + "&password=" + authtest.TEST_PASSWORD)
+		request := httptest.NewRequest(http.MethodPost, "/sign-in", reader).WithContext(ctx)
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		response := httptest.NewRecorder()
+		done := make(chan struct{})
+		go func() {
+			mux.ServeHTTP(response, request)
+			close(done)
+		}()
+		cancel() // Client disconnects
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Handler did not abandon the query")
+		}
+	})
+}
@@ -11,6 +11,14 @@ const (
 	COOKIE_SIGN_UP          = "sign-up"
 	COOKIE_ACCOUNT_PASSWORD = "account-password"
 	COOKIE_ACCOUNT_RECOVERY = "account-recovery"
+	COOKIE_CSRF             = "csrf"
+)
+
+const (
+	// COOKIE_PREFIX_HOST requires the cookie to be secure, and restricts it to the host which set it.
+	COOKIE_PREFIX_HOST = "__Host-"
+	// COOKIE_PREFIX_SECURE requires the cookie to be secure.
+	COOKIE_PREFIX_SECURE = "__Secure-"
 )
 
 func NewCookie(name, value string, timeout time.Duration) *http.Cookie {
@@ -23,3 +31,71 @@ func NewCookie(name, value string, timeout time.Duration) *http.Cookie {
 		HttpOnly: true,
 	}
 }
+
+// CookiePolicy controls the names and attributes of the cookies set by the Authenticator.
+type CookiePolicy struct {
+	Prefix     string            // Prepended to each name, such as COOKIE_PREFIX_HOST
+	Names      map[string]string // Replaces the default names, such as COOKIE_SIGN_IN, with custom names
+	SameSite   http.SameSite
+	Domain     string // Shares cookies with subdomains, ignored with COOKIE_PREFIX_HOST
+	Persistent bool   // Keeps cookies until their sessions expire, otherwise browsers delete them when closed
+}
+
+func NewCookiePolicy() *CookiePolicy {
+	return &CookiePolicy{
+		SameSite:   http.SameSiteLaxMode,
+		Persistent: true,
+	}
+}
+
+// Name returns the name of the cookie with the given default name.
+func (p *CookiePolicy) Name(name string) string {
+	if n, ok := p.Names[name]; ok && n != "" {
+		name = n
+	}
+	return p.Prefix + name
+}
+
+// NewCookie returns a cookie with the given default name and value, which expires after the timeout if persistent.
+func (p *CookiePolicy) NewCookie(name, value string, timeout time.Duration) *http.Cookie {
+	c := &http.Cookie{
+		Name:     p.Name(name),
+		Value:    value,
+		Path:     "/",
+		Secure:   netgo.IsSecure() || p.Prefix == COOKIE_PREFIX_HOST || p.Prefix == COOKIE_PREFIX_SECURE,
+		HttpOnly: true,
+		SameSite: p.SameSite,
+	}
+	if p.Prefix != COOKIE_PREFIX_HOST {
+		c.Domain = p.Domain
+	}
+	if p.Persistent {
+		c.Expires = time.Now().Add(timeout)
+	}
+	return c
+}
+
+// ExpiredCookie returns a cookie which deletes the cookie with the given default name from the browser.
+func (p *CookiePolicy) ExpiredCookie(name string) *http.Cookie {
+	c := p.NewCookie(name, "", 0)
+	c.Expires = time.Unix(0, 0)
+	c.MaxAge = -1
+	return c
+}
+
+// Cookie returns the request's cookie with the given default name, or nil if there is none.
+func (p *CookiePolicy) Cookie(r *http.Request, name string) *http.Cookie {
+	c, err := r.Cookie(p.Name(name))
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+func (a *authenticator) CookiePolicy() *CookiePolicy {
+	return a.cookies
+}
+
+func (a *authenticator) SetCookiePolicy(policy *CookiePolicy) {
+	a.cookies = policy
+}
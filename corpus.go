@@ -0,0 +1,136 @@
+package authgo
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+var ErrInvalidPasswordCorpus = errors.New("Invalid Password Corpus")
+
+// PasswordCorpus is a collection of compromised passwords.
+type PasswordCorpus interface {
+	Contains([]byte) (bool, error)
+}
+
+// OpenPasswordCorpus opens a file of sorted SHA-1 digests, as written by GeneratePasswordCorpus.
+// The file is searched in place, so even large corpora use little memory.
+func OpenPasswordCorpus(path string) (*FilePasswordCorpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size()%sha1.Size != 0 {
+		f.Close()
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPasswordCorpus, path)
+	}
+	return &FilePasswordCorpus{
+		file:  f,
+		count: int(info.Size() / sha1.Size),
+	}, nil
+}
+
+// FilePasswordCorpus implements PasswordCorpus with a binary search of a file of sorted SHA-1 digests.
+type FilePasswordCorpus struct {
+	file  *os.File
+	count int
+}
+
+func (c *FilePasswordCorpus) Close() error {
+	return c.file.Close()
+}
+
+// Len returns the number of passwords in the corpus.
+func (c *FilePasswordCorpus) Len() int {
+	return c.count
+}
+
+func (c *FilePasswordCorpus) Contains(password []byte) (bool, error) {
+	digest := sha1.Sum(password)
+	buffer := make([]byte, sha1.Size)
+	var err error
+	i := sort.Search(c.count, func(i int) bool {
+		if err != nil {
+			return true
+		}
+		if _, err = c.file.ReadAt(buffer, int64(i)*sha1.Size); err != nil {
+			return true
+		}
+		return bytes.Compare(buffer, digest[:]) >= 0
+	})
+	if err != nil {
+		return false, err
+	}
+	if i == c.count {
+		return false, nil
+	}
+	if _, err := c.file.ReadAt(buffer, int64(i)*sha1.Size); err != nil {
+		return false, err
+	}
+	return bytes.Equal(buffer, digest[:]), nil
+}
+
+// GeneratePasswordCorpus reads one password per line and writes the sorted, deduplicated SHA-1 digests.
+// If hashed is true each line is instead a hex encoded SHA-1 digest, optionally followed by a colon and a count, as in the Pwned Passwords downloads.
+// The digests are held in memory while sorting, requiring 20 bytes per password.
+// It returns the number of digests written.
+func GeneratePasswordCorpus(r io.Reader, w io.Writer, hashed bool) (int, error) {
+	var digests [][sha1.Size]byte
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if !hashed {
+			if text != "" {
+				digests = append(digests, sha1.Sum([]byte(text)))
+			}
+			continue
+		}
+		if i := strings.IndexByte(text, ':'); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		var digest [sha1.Size]byte
+		if n, err := hex.Decode(digest[:], []byte(text)); err != nil || n != sha1.Size {
+			return 0, fmt.Errorf("%w: line %d", ErrInvalidPasswordCorpus, line)
+		}
+		digests = append(digests, digest)
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	sort.Slice(digests, func(i, j int) bool {
+		return bytes.Compare(digests[i][:], digests[j][:]) < 0
+	})
+	writer := bufio.NewWriter(w)
+	count := 0
+	for i, d := range digests {
+		if i > 0 && d == digests[i-1] {
+			continue
+		}
+		if _, err := writer.Write(d[:]); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	if err := writer.Flush(); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -0,0 +1,90 @@
+package authgo_test
+
+import (
+	"aletheiaware.com/authgo"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newPasswordCorpus(t *testing.T, passwords ...string) *authgo.FilePasswordCorpus {
+	t.Helper()
+	var buffer bytes.Buffer
+	_, err := authgo.GeneratePasswordCorpus(strings.NewReader(strings.Join(passwords, "\n")), &buffer, false)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "corpus")
+	assert.NoError(t, os.WriteFile(path, buffer.Bytes(), 0600))
+	corpus, err := authgo.OpenPasswordCorpus(path)
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		corpus.Close()
+	})
+	return corpus
+}
+
+func Test_PasswordCorpus(t *testing.T) {
+	passwords := []string{"password", "123456", "qwerty", "letmein", "password"}
+	t.Run("Plain", func(t *testing.T) {
+		corpus := newPasswordCorpus(t, passwords...)
+		assert.Equal(t, 4, corpus.Len())
+		for _, p := range passwords {
+			ok, err := corpus.Contains([]byte(p))
+			assert.NoError(t, err)
+			assert.True(t, ok, p)
+		}
+		for _, p := range []string{"", "Password", "olive-copper-kettle-42", "zzzzzz"} {
+			ok, err := corpus.Contains([]byte(p))
+			assert.NoError(t, err)
+			assert.False(t, ok, p)
+		}
+	})
+	t.Run("Hashed", func(t *testing.T) {
+		var input strings.Builder
+		for i, p := range passwords {
+			digest := sha1.Sum([]byte(p))
+			input.WriteString(strings.ToUpper(hex.EncodeToString(digest[:])))
+			if i%2 == 0 {
+				input.WriteString(":42")
+			}
+			input.WriteString("\r\n")
+		}
+		var plain, hashed bytes.Buffer
+		n, err := authgo.GeneratePasswordCorpus(strings.NewReader(input.String()), &hashed, true)
+		assert.NoError(t, err)
+		assert.Equal(t, 4, n)
+		_, err = authgo.GeneratePasswordCorpus(strings.NewReader(strings.Join(passwords, "\n")), &plain, false)
+		assert.NoError(t, err)
+		assert.Equal(t, plain.Bytes(), hashed.Bytes())
+	})
+	t.Run("Sorted", func(t *testing.T) {
+		var buffer bytes.Buffer
+		_, err := authgo.GeneratePasswordCorpus(strings.NewReader(strings.Join(passwords, "\n")), &buffer, false)
+		assert.NoError(t, err)
+		data := buffer.Bytes()
+		for i := sha1.Size; i < len(data); i += sha1.Size {
+			assert.Equal(t, -1, bytes.Compare(data[i-sha1.Size:i], data[i:i+sha1.Size]))
+		}
+	})
+	t.Run("Empty", func(t *testing.T) {
+		corpus := newPasswordCorpus(t)
+		assert.Equal(t, 0, corpus.Len())
+		ok, err := corpus.Contains([]byte("password"))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+	t.Run("InvalidDigest", func(t *testing.T) {
+		_, err := authgo.GeneratePasswordCorpus(strings.NewReader("5BAA61E4\n"), &bytes.Buffer{}, true)
+		assert.ErrorIs(t, err, authgo.ErrInvalidPasswordCorpus)
+	})
+	t.Run("InvalidFile", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "corpus")
+		assert.NoError(t, os.WriteFile(path, []byte("not a corpus"), 0600))
+		_, err := authgo.OpenPasswordCorpus(path)
+		assert.ErrorIs(t, err, authgo.ErrInvalidPasswordCorpus)
+	})
+}
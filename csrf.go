@@ -0,0 +1,129 @@
+package authgo
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+const (
+	// CSRF_FIELD is the name of the form field carrying the CSRF token.
+	CSRF_FIELD = "csrf"
+	// CSRF_HEADER is the name of the header carrying the CSRF token, for requests made by scripts.
+	CSRF_HEADER         = "X-CSRF-Token"
+	CSRF_SECRET_LENGTH  = 32
+	CSRF_COOKIE_TIMEOUT = 365 * 24 * time.Hour
+)
+
+var (
+	ErrCSRFTokenInvalid    = errors.New("Invalid Or Missing CSRF Token")
+	ErrCSRFOriginForbidden = errors.New("Cross-Origin Request Forbidden")
+)
+
+func (a *authenticator) TrustedOrigins() []string {
+	return a.origins
+}
+
+// SetTrustedOrigins sets the origins, other than the website's own, which may post forms to the website, such as "https://www.example.com".
+func (a *authenticator) SetTrustedOrigins(origins []string) {
+	a.origins = origins
+}
+
+// CSRFToken returns the token which forms must include in their CSRF_FIELD, setting the cookie holding the secret it is derived from if there is none.
+// The token is bound to the sign in session, so it changes when the user signs in with a new session.
+func (a *authenticator) CSRFToken(w http.ResponseWriter, r *http.Request) string {
+	secret := a.csrfSecret(r)
+	if secret == nil {
+		secret = make([]byte, CSRF_SECRET_LENGTH)
+		if _, err := rand.Read(secret); err != nil {
+			return ""
+		}
+		http.SetCookie(w, a.cookies.NewCookie(COOKIE_CSRF, base64.RawURLEncoding.EncodeToString(secret), CSRF_COOKIE_TIMEOUT))
+	}
+	// Use the session of the response if the handler has just replaced it
+	session := responseCookie(w, a.cookies.Name(COOKIE_SIGN_IN))
+	if session == nil {
+		session = a.cookies.Cookie(r, COOKIE_SIGN_IN)
+	}
+	return csrfToken(secret, session)
+}
+
+// VerifyCSRF returns an error if the request came from another origin, or does not carry the CSRF token in its CSRF_FIELD or CSRF_HEADER.
+func (a *authenticator) VerifyCSRF(r *http.Request) error {
+	if origin := r.Header.Get("Origin"); origin != "" {
+		if !a.isTrustedOrigin(r, origin) {
+			return ErrCSRFOriginForbidden
+		}
+	} else {
+		// Browsers which do not send Origin may still say where the request came from
+		switch r.Header.Get("Sec-Fetch-Site") {
+		case "", "same-origin", "none":
+		default:
+			return ErrCSRFOriginForbidden
+		}
+	}
+	secret := a.csrfSecret(r)
+	if secret == nil {
+		return ErrCSRFTokenInvalid
+	}
+	token := r.Header.Get(CSRF_HEADER)
+	if token == "" {
+		token = r.PostFormValue(CSRF_FIELD)
+	}
+	session := a.cookies.Cookie(r, COOKIE_SIGN_IN)
+	if !hmac.Equal([]byte(token), []byte(csrfToken(secret, session))) {
+		return ErrCSRFTokenInvalid
+	}
+	return nil
+}
+
+func (a *authenticator) isTrustedOrigin(r *http.Request, origin string) bool {
+	if u, err := url.Parse(origin); err == nil && u.Host != "" && u.Host == r.Host {
+		return true
+	}
+	for _, o := range a.origins {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *authenticator) csrfSecret(r *http.Request) []byte {
+	c := a.cookies.Cookie(r, COOKIE_CSRF)
+	if c == nil {
+		return nil
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil || len(secret) != CSRF_SECRET_LENGTH {
+		return nil
+	}
+	return secret
+}
+
+func csrfToken(secret []byte, session *http.Cookie) string {
+	mac := hmac.New(sha256.New, secret)
+	if session != nil {
+		mac.Write([]byte(session.Value))
+	}
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// responseCookie returns the last cookie with the name already set on the response, or nil if there is none.
+func responseCookie(w http.ResponseWriter, name string) *http.Cookie {
+	response := &http.Response{
+		Header: w.Header(),
+	}
+	var cookie *http.Cookie
+	for _, c := range response.Cookies() {
+		if c.Name == name {
+			cookie = c
+		}
+	}
+	return cookie
+}
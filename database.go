@@ -1,40 +1,138 @@
 package authgo
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
-type Database interface {
+// AccountStore persists accounts.
+type AccountStore interface {
+	Close() error
+
+	CreateUser(context.Context, string, string, []byte, time.Time) (int64, error)
+	SelectUser(context.Context, string) (int64, string, []byte, time.Time, error)
+	SelectUsernameByEmail(context.Context, string) (string, error)
+	ChangePassword(context.Context, string, []byte) (int64, error)
+	DeactivateUser(context.Context, string, time.Time) (int64, error)
+
+	CreatePasswordHistory(context.Context, string, []byte, time.Time) (int64, error)
+	SelectPasswordHistory(context.Context, string) ([][]byte, error)
+	PrunePasswordHistory(context.Context, string, int) (int64, error)
+
+	SelectAuthenticationFailures(context.Context, string) (int64, time.Time, error)
+	IncrementAuthenticationFailures(context.Context, string, time.Time) (int64, error)
+	DeleteAuthenticationFailures(context.Context, string) (int64, error)
+	DeleteExpiredAuthenticationFailures(context.Context, time.Time) (int64, error)
+
+	SelectTOTP(context.Context, string) ([]byte, []byte, int64, error)
+	UpdateTOTPPending(context.Context, string, []byte) (int64, error)
+	ConfirmTOTP(context.Context, string, int64) (int64, error)
+	UpdateTOTPCounter(context.Context, string, int64) (int64, error)
+	DeleteTOTP(context.Context, string) (int64, error)
+
+	CreatePasskey(context.Context, string, *Passkey) (int64, error)
+	SelectPasskey(context.Context, string) (string, *Passkey, error)
+	SelectPasskeys(context.Context, string) ([]*Passkey, error)
+	UpdatePasskeySignCount(context.Context, string, int64, time.Time) (int64, error)
+	DeletePasskey(context.Context, string, string) (int64, error)
+
+	CreateRecoveryCodes(context.Context, string, [][]byte, time.Time) (int64, error)
+	SelectRecoveryCodes(context.Context, string) (time.Time, []time.Time, error)
+	UpdateRecoveryCodeUsed(context.Context, string, []byte, time.Time) (int64, error)
+
+	CreateOIDCIdentity(context.Context, string, string, string, time.Time) (int64, error)
+	SelectOIDCIdentity(context.Context, string, string) (string, error)
+
+	CreateOIDCClient(context.Context, *OIDCClient) (int64, error)
+	SelectOIDCClient(context.Context, string) (*OIDCClient, error)
+	DeleteOIDCClient(context.Context, string) (int64, error)
+
+	CreateOIDCSigningKey(context.Context, *OIDCSigningKey) (int64, error)
+	SelectOIDCSigningKeys(context.Context) ([]*OIDCSigningKey, error)
+	DeleteExpiredOIDCSigningKeys(context.Context, time.Time) (int64, error)
+
+	CreateOIDCRefreshToken(context.Context, *OIDCRefreshToken) (int64, error)
+	SelectOIDCRefreshToken(context.Context, string) (*OIDCRefreshToken, error)
+	DeleteOIDCRefreshToken(context.Context, string) (int64, error)
+	DeleteExpiredOIDCRefreshTokens(context.Context, time.Time) (int64, error)
+
+	CreateBearerToken(context.Context, *BearerToken) (int64, error)
+	SelectBearerTokenByAccessHash(context.Context, string) (*BearerToken, error)
+	SelectBearerTokenByRefreshHash(context.Context, string) (*BearerToken, error)
+	UpdateBearerToken(context.Context, string, string, string, time.Time) (int64, error)
+	DeleteBearerToken(context.Context, string) (int64, error)
+	DeleteBearerTokens(context.Context, string) (int64, error)
+	DeleteExpiredBearerTokens(context.Context, time.Time) (int64, error)
+
+	CreatePersonalAccessToken(context.Context, string, *PersonalAccessToken) (int64, error)
+	SelectPersonalAccessToken(context.Context, string) (string, *PersonalAccessToken, error)
+	SelectPersonalAccessTokens(context.Context, string) ([]*PersonalAccessToken, error)
+	UpdatePersonalAccessTokenUsed(context.Context, string, time.Time) (int64, error)
+	DeletePersonalAccessToken(context.Context, string, string) (int64, error)
+	DeletePersonalAccessTokens(context.Context, string) (int64, error)
+	DeleteExpiredPersonalAccessTokens(context.Context, time.Time) (int64, error)
+
+	IsEmailVerified(context.Context, string) (bool, error)
+	SetEmailVerified(context.Context, string, bool) (int64, error)
+}
+
+// SessionStore persists short-lived sessions.
+type SessionStore interface {
 	Close() error
 
-	CreateUser(string, string, []byte, time.Time) (int64, error)
-	SelectUser(string) (int64, string, []byte, time.Time, error)
-	SelectUsernameByEmail(string) (string, error)
-	ChangePassword(string, []byte) (int64, error)
-	DeactivateUser(string, time.Time) (int64, error)
-
-	IsEmailVerified(string) (bool, error)
-	SetEmailVerified(string, bool) (int64, error)
-
-	CreateSignUpSession(string, time.Time) (int64, error)
-	SelectSignUpSession(string) (string, string, string, string, string, time.Time, error)
-	UpdateSignUpSessionError(string, string) (int64, error)
-	UpdateSignUpSessionIdentity(string, string, string) (int64, error)
-	UpdateSignUpSessionReferrer(string, string) (int64, error)
-	UpdateSignUpSessionChallenge(string, string) (int64, error)
-
-	CreateSignInSession(string, string, bool, time.Time) (int64, error)
-	SelectSignInSession(string) (string, string, time.Time, bool, error)
-	UpdateSignInSessionError(string, string) (int64, error)
-	UpdateSignInSessionUsername(string, string) (int64, error)
-	UpdateSignInSessionAuthenticated(string, bool) (int64, error)
-
-	CreateAccountPasswordSession(string, string, time.Time) (int64, error)
-	SelectAccountPasswordSession(string) (string, string, time.Time, error)
-	UpdateAccountPasswordSessionError(string, string) (int64, error)
-
-	CreateAccountRecoverySession(string, time.Time) (int64, error)
-	SelectAccountRecoverySession(string) (string, string, string, string, time.Time, error)
-	UpdateAccountRecoverySessionError(string, string) (int64, error)
-	UpdateAccountRecoverySessionEmail(string, string) (int64, error)
-	UpdateAccountRecoverySessionUsername(string, string) (int64, error)
-	UpdateAccountRecoverySessionChallenge(string, string) (int64, error)
+	CreateSignUpSession(context.Context, *SignUpSession) (int64, error)
+	SelectSignUpSession(context.Context, string) (*SignUpSession, error)
+	UpdateSignUpSessionError(context.Context, string, string) (int64, error)
+	UpdateSignUpSessionIdentity(context.Context, string, string, string) (int64, error)
+	UpdateSignUpSessionReferrer(context.Context, string, string) (int64, error)
+	UpdateSignUpSessionChallenge(context.Context, string, string) (int64, error)
+	UpdateSignUpSessionPasskeyChallenge(context.Context, string, string) (int64, error)
+	DeleteExpiredSignUpSessions(context.Context, time.Time) (int64, error)
+
+	CreateSignInSession(context.Context, *SignInSession) (int64, error)
+	SelectSignInSession(context.Context, string) (*SignInSession, error)
+	SelectSignInSessions(context.Context, string) ([]*SignInSession, error)
+	UpdateSignInSessionError(context.Context, string, string) (int64, error)
+	UpdateSignInSessionUsername(context.Context, string, string) (int64, error)
+	UpdateSignInSessionAuthenticated(context.Context, string, bool) (int64, error)
+	UpdateSignInSessionPendingSecondFactor(context.Context, string, bool) (int64, error)
+	UpdateSignInSessionPasskeyChallenge(context.Context, string, string) (int64, error)
+	UpdateSignInSessionActivity(context.Context, string, string, string, time.Time) (int64, error)
+	DeleteExpiredSignInSessions(context.Context, time.Time) (int64, error)
+	DeauthenticateSignInSessions(context.Context, string) (int64, error)
+
+	CreateAccountPasswordSession(context.Context, *AccountPasswordSession) (int64, error)
+	SelectAccountPasswordSession(context.Context, string) (*AccountPasswordSession, error)
+	UpdateAccountPasswordSessionError(context.Context, string, string) (int64, error)
+	DeleteExpiredAccountPasswordSessions(context.Context, time.Time) (int64, error)
+
+	CreateAccountRecoverySession(context.Context, *AccountRecoverySession) (int64, error)
+	SelectAccountRecoverySession(context.Context, string) (*AccountRecoverySession, error)
+	UpdateAccountRecoverySessionError(context.Context, string, string) (int64, error)
+	UpdateAccountRecoverySessionEmail(context.Context, string, string) (int64, error)
+	UpdateAccountRecoverySessionUsername(context.Context, string, string) (int64, error)
+	UpdateAccountRecoverySessionChallenge(context.Context, string, string) (int64, error)
+	DeleteExpiredAccountRecoverySessions(context.Context, time.Time) (int64, error)
+
+	CreateSignInChallenge(context.Context, *SignInChallenge) (int64, error)
+	SelectSignInChallenge(context.Context, string) (*SignInChallenge, error)
+	SelectSignInChallengeForSession(context.Context, string) (*SignInChallenge, error)
+	DeleteSignInChallenge(context.Context, string) (int64, error)
+	DeleteExpiredSignInChallenges(context.Context, time.Time) (int64, error)
+
+	CreateOIDCAuthorization(context.Context, *OIDCAuthorization) (int64, error)
+	SelectOIDCAuthorization(context.Context, string) (*OIDCAuthorization, error)
+	DeleteOIDCAuthorization(context.Context, string) (int64, error)
+	DeleteExpiredOIDCAuthorizations(context.Context, time.Time) (int64, error)
+
+	CreateOIDCCode(context.Context, *OIDCCode) (int64, error)
+	SelectOIDCCode(context.Context, string) (*OIDCCode, error)
+	DeleteOIDCCode(context.Context, string) (int64, error)
+	DeleteExpiredOIDCCodes(context.Context, time.Time) (int64, error)
+}
+
+// Database persists both accounts and sessions, and so can be used as either store.
+type Database interface {
+	AccountStore
+	SessionStore
 }
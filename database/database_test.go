@@ -0,0 +1,70 @@
+package database_test
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest/authenticator"
+	"testing"
+)
+
+// testAuthenticator runs the authenticator test suites against an Authenticator backed by each database implementation.
+func testAuthenticator(t *testing.T, a func(*testing.T) authgo.Authenticator) {
+	for name, test := range map[string]func(*testing.T, func(*testing.T) authgo.Authenticator){
+		"CurrentAccount":                     authenticator.CurrentAccount,
+		"NewAccount":                         authenticator.NewAccount,
+		"LookupAccount":                      authenticator.LookupAccount,
+		"AuthenticateAccount":                authenticator.AuthenticateAccount,
+		"LookupUsernameForEmail":             authenticator.LookupUsernameForEmail,
+		"ChangePassword":                     authenticator.ChangePassword,
+		"DeactivateAccount":                  authenticator.DeactivateAccount,
+		"SetPasswordHasher":                  authenticator.SetPasswordHasher,
+		"RehashPassword":                     authenticator.RehashPassword,
+		"PasswordHistory":                    authenticator.PasswordHistory,
+		"Lockout":                            authenticator.Lockout,
+		"TOTP":                               authenticator.TOTP,
+		"Passkey":                            authenticator.Passkey,
+		"RecoveryCodes":                      authenticator.RecoveryCodes,
+		"Passwordless":                       authenticator.Passwordless,
+		"OIDC":                               authenticator.OIDC,
+		"OIDCServer":                         authenticator.OIDCServer,
+		"BearerToken":                        authenticator.BearerToken,
+		"PersonalAccessToken":                authenticator.PersonalAccessToken,
+		"CSRF":                               authenticator.CSRF,
+		"CookiePolicy":                       authenticator.CookiePolicy,
+		"SessionSealer":                      authenticator.SessionSealer,
+		"SignInSessions":                     authenticator.SignInSessions,
+		"IsEmailVerified":                    authenticator.IsEmailVerified,
+		"SetEmailVerified":                   authenticator.SetEmailVerified,
+		"CurrentSignUpSession":               authenticator.CurrentSignUpSession,
+		"NewSignUpSession":                   authenticator.NewSignUpSession,
+		"LookupSignUpSession":                authenticator.LookupSignUpSession,
+		"SetSignUpSessionError":              authenticator.SetSignUpSessionError,
+		"SetSignUpSessionIdentity":           authenticator.SetSignUpSessionIdentity,
+		"SetSignUpSessionReferrer":           authenticator.SetSignUpSessionReferrer,
+		"SetSignUpSessionChallenge":          authenticator.SetSignUpSessionChallenge,
+		"SetSignUpSessionPasskeyChallenge":   authenticator.SetSignUpSessionPasskeyChallenge,
+		"CurrentSignInSession":               authenticator.CurrentSignInSession,
+		"NewSignInSession":                   authenticator.NewSignInSession,
+		"LookupSignInSession":                authenticator.LookupSignInSession,
+		"SetSignInSessionError":              authenticator.SetSignInSessionError,
+		"SetSignInSessionUsername":           authenticator.SetSignInSessionUsername,
+		"SetSignInSessionAuthenticated":      authenticator.SetSignInSessionAuthenticated,
+		"SetSignInSessionPasskeyChallenge":   authenticator.SetSignInSessionPasskeyChallenge,
+		"CurrentAccountPasswordSession":      authenticator.CurrentAccountPasswordSession,
+		"NewAccountPasswordSession":          authenticator.NewAccountPasswordSession,
+		"LookupAccountPasswordSession":       authenticator.LookupAccountPasswordSession,
+		"SetAccountPasswordSessionError":     authenticator.SetAccountPasswordSessionError,
+		"CurrentAccountRecoverySession":      authenticator.CurrentAccountRecoverySession,
+		"NewAccountRecoverySession":          authenticator.NewAccountRecoverySession,
+		"LookupAccountRecoverySession":       authenticator.LookupAccountRecoverySession,
+		"SetAccountRecoverySessionError":     authenticator.SetAccountRecoverySessionError,
+		"SetAccountRecoverySessionEmail":     authenticator.SetAccountRecoverySessionEmail,
+		"SetAccountRecoverySessionUsername":  authenticator.SetAccountRecoverySessionUsername,
+		"SetAccountRecoverySessionChallenge": authenticator.SetAccountRecoverySessionChallenge,
+		"DeleteExpiredSessions":              authenticator.DeleteExpiredSessions,
+		"SessionJanitor":                     authenticator.SessionJanitor,
+	} {
+		t.Run(name, func(t *testing.T) {
+			test(t, a)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package database
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect captures the differences between the SQL databases supported by SQL.
+type Dialect struct {
+	// Name of the dialect.
+	Name string
+	// PrimaryKey is the column definition of an auto incrementing integer primary key.
+	PrimaryKey string
+	// Binary is the column type used to store byte slices.
+	Binary string
+	// Timestamp is the column type used to store times.
+	Timestamp string
+	// Returning is true when inserts must use a RETURNING clause to obtain the id of the new row.
+	Returning bool
+	// Numbered is true when placeholders are numbered ($1, $2, ...) instead of positional (?).
+	Numbered bool
+	// DuplicateKey is true when upserts must use ON DUPLICATE KEY UPDATE instead of ON CONFLICT.
+	DuplicateKey bool
+}
+
+var (
+	SQLite = &Dialect{
+		Name:       "sqlite",
+		PrimaryKey: "INTEGER PRIMARY KEY AUTOINCREMENT",
+		Binary:     "BLOB",
+		Timestamp:  "TIMESTAMP",
+	}
+	// MySQL requires the driver to be configured with parseTime=true and clientFoundRows=true.
+	MySQL = &Dialect{
+		Name:         "mysql",
+		PrimaryKey:   "BIGINT AUTO_INCREMENT PRIMARY KEY",
+		Binary:       "VARBINARY(255)",
+		Timestamp:    "DATETIME(6)",
+		DuplicateKey: true,
+	}
+	PostgreSQL = &Dialect{
+		Name:       "postgres",
+		PrimaryKey: "BIGSERIAL PRIMARY KEY",
+		Binary:     "BYTEA",
+		Timestamp:  "TIMESTAMP WITH TIME ZONE",
+		Returning:  true,
+		Numbered:   true,
+	}
+)
+
+// Rebind converts a query written with ? placeholders into the placeholder syntax of the dialect.
+func (d *Dialect) Rebind(query string) string {
+	if !d.Numbered {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+// Expand replaces the column type variables in a schema statement with those of the dialect.
+func (d *Dialect) Expand(statement string) string {
+	return strings.NewReplacer(
+		"$PRIMARY_KEY", d.PrimaryKey,
+		"$BINARY", d.Binary,
+		"$TIMESTAMP", d.Timestamp,
+	).Replace(statement)
+}
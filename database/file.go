@@ -0,0 +1,873 @@
+package database
+
+import (
+	"aletheiaware.com/authgo"
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	fileHeaderLength = 8
+	// fileSnapshotOp is the operation of a record holding the entire state, written by compaction.
+	fileSnapshotOp = "Snapshot"
+	// FILE_COMPACT_LENGTH is the default length the log must reach before it is compacted.
+	FILE_COMPACT_LENGTH = 1 << 24
+)
+
+var ErrCorruptFile = errors.New("Corrupt File")
+
+// File implements authgo.Database as an embedded, single-file database.
+//
+// The state is held in memory and every change is appended to a log, which is replayed when the file is opened.
+// Each record is a 4 byte length, a 4 byte CRC-32 checksum, and the JSON encoded operation.
+// A torn or corrupt final record, such as one left by a crash part way through a write, is truncated on open.
+// Damage to any other record fails to open with ErrCorruptFile, leaving the file untouched.
+//
+// Once the log grows past the compact length, and on open, it is compacted by replacing it with a single snapshot of the state.
+// The snapshot is written to a temporary file which is renamed over the log, so a crash leaves either the old log or the new one.
+type File struct {
+	sync.Mutex    // Serializes writes so the log records changes in the order they were applied
+	memory        *InMemory
+	file          *os.File
+	size          int64 // Length of the log
+	compactLength int64 // Length of the log at which it is compacted
+	minimumLength int64
+	err           error
+}
+
+type fileRecord struct {
+	Op   string            `json:"op"`
+	Args []json.RawMessage `json:"args"`
+}
+
+// OpenFile opens the database at the given path, creating it if it does not exist.
+func OpenFile(path string) (*File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	// A temporary file left by a crash during compaction is incomplete, and the log is still intact
+	if err := os.Remove(path + ".tmp"); err != nil && !os.IsNotExist(err) {
+		f.Close()
+		return nil, err
+	}
+	db := &File{
+		memory:        NewInMemory(),
+		file:          f,
+		compactLength: FILE_COMPACT_LENGTH,
+		minimumLength: FILE_COMPACT_LENGTH,
+	}
+	if err := db.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if db.size >= db.compactLength {
+		if err := db.compact(); err != nil {
+			db.file.Close()
+			return nil, err
+		}
+	}
+	return db, nil
+}
+
+// SetCompactLength sets the length the log must reach before it is compacted.
+// The log is also left to grow to twice the length of its last snapshot, so that a large state is not compacted on every write.
+func (db *File) SetCompactLength(length int64) {
+	db.Lock()
+	defer db.Unlock()
+	db.minimumLength = length
+	db.compactLength = length
+}
+
+// Compact replaces the log with a snapshot of the state, reclaiming the space of changes which have been superseded or deleted.
+func (db *File) Compact() error {
+	db.Lock()
+	defer db.Unlock()
+	if db.err != nil {
+		return db.err
+	}
+	return db.compact()
+}
+
+func (db *File) compact() error {
+	db.memory.RLock()
+	state, err := json.Marshal(db.memory)
+	var lastId []byte
+	if err == nil {
+		lastId, err = json.Marshal(db.memory.lastId)
+	}
+	db.memory.RUnlock()
+	if err != nil {
+		return err
+	}
+	buffer, err := encodeFileRecord(&fileRecord{
+		Op:   fileSnapshotOp,
+		Args: []json.RawMessage{state, lastId},
+	})
+	if err != nil {
+		return err
+	}
+	path := db.file.Name()
+	temporary := path + ".tmp"
+	t, err := os.OpenFile(temporary, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := t.Write(buffer); err != nil {
+		t.Close()
+		os.Remove(temporary)
+		return err
+	}
+	if err := t.Sync(); err != nil {
+		t.Close()
+		os.Remove(temporary)
+		return err
+	}
+	if err := t.Close(); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	if err := os.Rename(temporary, path); err != nil {
+		os.Remove(temporary)
+		return err
+	}
+	if d, err := os.Open(filepath.Dir(path)); err == nil {
+		// Persist the rename
+		if err := d.Sync(); err != nil {
+			log.Println(err)
+		}
+		d.Close()
+	}
+	// The log was replaced, so further writes must be appended to the new file
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		db.err = err
+		return err
+	}
+	db.file.Close()
+	db.file = f
+	log.Println("Compacted", db.file.Name(), "from", db.size, "to", len(buffer), "bytes")
+	db.size = int64(len(buffer))
+	db.compactLength = 2 * db.size
+	if db.compactLength < db.minimumLength {
+		db.compactLength = db.minimumLength
+	}
+	return nil
+}
+
+func (db *File) Close() error {
+	db.Lock()
+	defer db.Unlock()
+	if db.err == nil {
+		db.err = os.ErrClosed
+	}
+	return db.file.Close()
+}
+
+func (db *File) Ping() error {
+	db.Lock()
+	defer db.Unlock()
+	return db.err
+}
+
+func (db *File) replay() error {
+	info, err := db.file.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+	r := bufio.NewReader(db.file)
+	header := make([]byte, fileHeaderLength)
+	var offset int64
+	for {
+		if _, err := io.ReadFull(r, header); err == io.EOF {
+			return nil
+		} else if err == io.ErrUnexpectedEOF {
+			return db.truncate(offset)
+		} else if err != nil {
+			return err
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		end := offset + fileHeaderLength + int64(length)
+		if end > size {
+			return db.truncate(offset)
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(r, payload); err == io.EOF || err == io.ErrUnexpectedEOF {
+			return db.truncate(offset)
+		} else if err != nil {
+			return err
+		}
+		if crc32.ChecksumIEEE(payload) != binary.BigEndian.Uint32(header[4:8]) {
+			return db.discard(offset, end, size)
+		}
+		var record fileRecord
+		if err := json.Unmarshal(payload, &record); err != nil {
+			return db.discard(offset, end, size)
+		}
+		if err := db.apply(&record); err != nil {
+			return fmt.Errorf("%w: %s at offset %d: %v", ErrCorruptFile, record.Op, offset, err)
+		}
+		offset = end
+		db.size = offset
+	}
+}
+
+// discard truncates a damaged record at the given offset if it is the final record, as a crash can only tear the last write.
+// A damaged record followed by others is not truncated, as doing so would lose the changes they record.
+func (db *File) discard(offset, end, size int64) error {
+	if end < size {
+		return fmt.Errorf("%w: damaged record at offset %d", ErrCorruptFile, offset)
+	}
+	return db.truncate(offset)
+}
+
+// truncate discards the log from the given offset onwards.
+func (db *File) truncate(offset int64) error {
+	info, err := db.file.Stat()
+	if err != nil {
+		return err
+	}
+	log.Println("Truncated", info.Size()-offset, "bytes from", db.file.Name())
+	db.size = offset
+	return db.file.Truncate(offset)
+}
+
+// apply replays a record by calling the InMemory method of the same name, or by restoring a snapshot.
+func (db *File) apply(record *fileRecord) error {
+	if record.Op == fileSnapshotOp {
+		return db.restore(record)
+	}
+	method := reflect.ValueOf(db.memory).MethodByName(record.Op)
+	if !method.IsValid() {
+		return errors.New("unknown operation")
+	}
+	t := method.Type()
+	if t.NumIn() != len(record.Args)+1 || t.NumOut() != 2 {
+		return errors.New("incorrect arguments")
+	}
+	in := []reflect.Value{
+		reflect.ValueOf(context.Background()),
+	}
+	for i, a := range record.Args {
+		v := reflect.New(t.In(i + 1))
+		if err := json.Unmarshal(a, v.Interface()); err != nil {
+			return err
+		}
+		in = append(in, v.Elem())
+	}
+	if err, _ := method.Call(in)[1].Interface().(error); err != nil {
+		return err
+	}
+	return nil
+}
+
+// restore replaces the state with the snapshot in the record.
+func (db *File) restore(record *fileRecord) error {
+	if len(record.Args) != 2 {
+		return errors.New("incorrect arguments")
+	}
+	memory := NewInMemory()
+	if err := json.Unmarshal(record.Args[0], memory); err != nil {
+		return err
+	}
+	if err := json.Unmarshal(record.Args[1], &memory.lastId); err != nil {
+		return err
+	}
+	db.memory = memory
+	return nil
+}
+
+// write applies a change to memory and, if anything changed, appends it to the log.
+// If the log cannot be written, memory no longer matches the file and all further writes fail.
+func (db *File) write(op string, apply func() (int64, error), args ...interface{}) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if db.err != nil {
+		return 0, db.err
+	}
+	result, err := apply()
+	if err != nil || result == 0 {
+		return result, err
+	}
+	record := &fileRecord{
+		Op: op,
+	}
+	for _, a := range args {
+		data, err := json.Marshal(a)
+		if err != nil {
+			db.err = err
+			return 0, err
+		}
+		record.Args = append(record.Args, data)
+	}
+	buffer, err := encodeFileRecord(record)
+	if err != nil {
+		db.err = err
+		return 0, err
+	}
+	if _, err := db.file.Write(buffer); err != nil {
+		db.err = err
+		return 0, err
+	}
+	if err := db.file.Sync(); err != nil {
+		db.err = err
+		return 0, err
+	}
+	db.size += int64(len(buffer))
+	if db.size >= db.compactLength {
+		// The change is already in the log, so failing to compact does not fail the write
+		if err := db.compact(); err != nil {
+			log.Println(err)
+		}
+	}
+	return result, nil
+}
+
+// encodeFileRecord returns the record prefixed by its length and checksum.
+func encodeFileRecord(record *fileRecord) ([]byte, error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	buffer := make([]byte, fileHeaderLength, fileHeaderLength+len(payload))
+	binary.BigEndian.PutUint32(buffer[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buffer[4:8], crc32.ChecksumIEEE(payload))
+	return append(buffer, payload...), nil
+}
+
+func (db *File) CreateUser(ctx context.Context, email, username string, password []byte, created time.Time) (int64, error) {
+	return db.write("CreateUser", func() (int64, error) {
+		return db.memory.CreateUser(ctx, email, username, password, created)
+	}, email, username, password, created)
+}
+
+func (db *File) SelectUser(ctx context.Context, username string) (int64, string, []byte, time.Time, error) {
+	return db.memory.SelectUser(ctx, username)
+}
+
+func (db *File) SelectUsernameByEmail(ctx context.Context, email string) (string, error) {
+	return db.memory.SelectUsernameByEmail(ctx, email)
+}
+
+func (db *File) ChangePassword(ctx context.Context, username string, password []byte) (int64, error) {
+	return db.write("ChangePassword", func() (int64, error) {
+		return db.memory.ChangePassword(ctx, username, password)
+	}, username, password)
+}
+
+func (db *File) CreatePasswordHistory(ctx context.Context, username string, password []byte, created time.Time) (int64, error) {
+	return db.write("CreatePasswordHistory", func() (int64, error) {
+		return db.memory.CreatePasswordHistory(ctx, username, password, created)
+	}, username, password, created)
+}
+
+func (db *File) SelectPasswordHistory(ctx context.Context, username string) ([][]byte, error) {
+	return db.memory.SelectPasswordHistory(ctx, username)
+}
+
+func (db *File) PrunePasswordHistory(ctx context.Context, username string, keep int) (int64, error) {
+	return db.write("PrunePasswordHistory", func() (int64, error) {
+		return db.memory.PrunePasswordHistory(ctx, username, keep)
+	}, username, keep)
+}
+
+func (db *File) SelectAuthenticationFailures(ctx context.Context, subject string) (int64, time.Time, error) {
+	return db.memory.SelectAuthenticationFailures(ctx, subject)
+}
+
+func (db *File) IncrementAuthenticationFailures(ctx context.Context, subject string, failed time.Time) (int64, error) {
+	return db.write("IncrementAuthenticationFailures", func() (int64, error) {
+		return db.memory.IncrementAuthenticationFailures(ctx, subject, failed)
+	}, subject, failed)
+}
+
+func (db *File) DeleteAuthenticationFailures(ctx context.Context, subject string) (int64, error) {
+	return db.write("DeleteAuthenticationFailures", func() (int64, error) {
+		return db.memory.DeleteAuthenticationFailures(ctx, subject)
+	}, subject)
+}
+
+func (db *File) DeleteExpiredAuthenticationFailures(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredAuthenticationFailures", func() (int64, error) {
+		return db.memory.DeleteExpiredAuthenticationFailures(ctx, before)
+	}, before)
+}
+
+func (db *File) SelectTOTP(ctx context.Context, username string) ([]byte, []byte, int64, error) {
+	return db.memory.SelectTOTP(ctx, username)
+}
+
+func (db *File) UpdateTOTPPending(ctx context.Context, username string, pending []byte) (int64, error) {
+	return db.write("UpdateTOTPPending", func() (int64, error) {
+		return db.memory.UpdateTOTPPending(ctx, username, pending)
+	}, username, pending)
+}
+
+func (db *File) ConfirmTOTP(ctx context.Context, username string, counter int64) (int64, error) {
+	return db.write("ConfirmTOTP", func() (int64, error) {
+		return db.memory.ConfirmTOTP(ctx, username, counter)
+	}, username, counter)
+}
+
+func (db *File) UpdateTOTPCounter(ctx context.Context, username string, counter int64) (int64, error) {
+	return db.write("UpdateTOTPCounter", func() (int64, error) {
+		return db.memory.UpdateTOTPCounter(ctx, username, counter)
+	}, username, counter)
+}
+
+func (db *File) DeleteTOTP(ctx context.Context, username string) (int64, error) {
+	return db.write("DeleteTOTP", func() (int64, error) {
+		return db.memory.DeleteTOTP(ctx, username)
+	}, username)
+}
+
+func (db *File) CreatePasskey(ctx context.Context, username string, passkey *authgo.Passkey) (int64, error) {
+	return db.write("CreatePasskey", func() (int64, error) {
+		return db.memory.CreatePasskey(ctx, username, passkey)
+	}, username, passkey)
+}
+
+func (db *File) SelectPasskey(ctx context.Context, id string) (string, *authgo.Passkey, error) {
+	return db.memory.SelectPasskey(ctx, id)
+}
+
+func (db *File) SelectPasskeys(ctx context.Context, username string) ([]*authgo.Passkey, error) {
+	return db.memory.SelectPasskeys(ctx, username)
+}
+
+func (db *File) UpdatePasskeySignCount(ctx context.Context, id string, count int64, used time.Time) (int64, error) {
+	return db.write("UpdatePasskeySignCount", func() (int64, error) {
+		return db.memory.UpdatePasskeySignCount(ctx, id, count, used)
+	}, id, count, used)
+}
+
+func (db *File) DeletePasskey(ctx context.Context, username, id string) (int64, error) {
+	return db.write("DeletePasskey", func() (int64, error) {
+		return db.memory.DeletePasskey(ctx, username, id)
+	}, username, id)
+}
+
+func (db *File) CreateRecoveryCodes(ctx context.Context, username string, hashes [][]byte, created time.Time) (int64, error) {
+	return db.write("CreateRecoveryCodes", func() (int64, error) {
+		return db.memory.CreateRecoveryCodes(ctx, username, hashes, created)
+	}, username, hashes, created)
+}
+
+func (db *File) SelectRecoveryCodes(ctx context.Context, username string) (time.Time, []time.Time, error) {
+	return db.memory.SelectRecoveryCodes(ctx, username)
+}
+
+func (db *File) UpdateRecoveryCodeUsed(ctx context.Context, username string, hash []byte, used time.Time) (int64, error) {
+	return db.write("UpdateRecoveryCodeUsed", func() (int64, error) {
+		return db.memory.UpdateRecoveryCodeUsed(ctx, username, hash, used)
+	}, username, hash, used)
+}
+
+func (db *File) CreateOIDCIdentity(ctx context.Context, username, provider, subject string, created time.Time) (int64, error) {
+	return db.write("CreateOIDCIdentity", func() (int64, error) {
+		return db.memory.CreateOIDCIdentity(ctx, username, provider, subject, created)
+	}, username, provider, subject, created)
+}
+
+func (db *File) SelectOIDCIdentity(ctx context.Context, provider, subject string) (string, error) {
+	return db.memory.SelectOIDCIdentity(ctx, provider, subject)
+}
+
+func (db *File) CreateOIDCClient(ctx context.Context, client *authgo.OIDCClient) (int64, error) {
+	return db.write("CreateOIDCClient", func() (int64, error) {
+		return db.memory.CreateOIDCClient(ctx, client)
+	}, client)
+}
+
+func (db *File) SelectOIDCClient(ctx context.Context, id string) (*authgo.OIDCClient, error) {
+	return db.memory.SelectOIDCClient(ctx, id)
+}
+
+func (db *File) DeleteOIDCClient(ctx context.Context, id string) (int64, error) {
+	return db.write("DeleteOIDCClient", func() (int64, error) {
+		return db.memory.DeleteOIDCClient(ctx, id)
+	}, id)
+}
+
+func (db *File) CreateOIDCSigningKey(ctx context.Context, key *authgo.OIDCSigningKey) (int64, error) {
+	return db.write("CreateOIDCSigningKey", func() (int64, error) {
+		return db.memory.CreateOIDCSigningKey(ctx, key)
+	}, key)
+}
+
+func (db *File) SelectOIDCSigningKeys(ctx context.Context) ([]*authgo.OIDCSigningKey, error) {
+	return db.memory.SelectOIDCSigningKeys(ctx)
+}
+
+func (db *File) DeleteExpiredOIDCSigningKeys(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredOIDCSigningKeys", func() (int64, error) {
+		return db.memory.DeleteExpiredOIDCSigningKeys(ctx, before)
+	}, before)
+}
+
+func (db *File) CreateOIDCRefreshToken(ctx context.Context, token *authgo.OIDCRefreshToken) (int64, error) {
+	return db.write("CreateOIDCRefreshToken", func() (int64, error) {
+		return db.memory.CreateOIDCRefreshToken(ctx, token)
+	}, token)
+}
+
+func (db *File) SelectOIDCRefreshToken(ctx context.Context, hash string) (*authgo.OIDCRefreshToken, error) {
+	return db.memory.SelectOIDCRefreshToken(ctx, hash)
+}
+
+func (db *File) DeleteOIDCRefreshToken(ctx context.Context, hash string) (int64, error) {
+	return db.write("DeleteOIDCRefreshToken", func() (int64, error) {
+		return db.memory.DeleteOIDCRefreshToken(ctx, hash)
+	}, hash)
+}
+
+func (db *File) DeleteExpiredOIDCRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredOIDCRefreshTokens", func() (int64, error) {
+		return db.memory.DeleteExpiredOIDCRefreshTokens(ctx, before)
+	}, before)
+}
+
+func (db *File) CreateBearerToken(ctx context.Context, token *authgo.BearerToken) (int64, error) {
+	return db.write("CreateBearerToken", func() (int64, error) {
+		return db.memory.CreateBearerToken(ctx, token)
+	}, token)
+}
+
+func (db *File) SelectBearerTokenByAccessHash(ctx context.Context, hash string) (*authgo.BearerToken, error) {
+	return db.memory.SelectBearerTokenByAccessHash(ctx, hash)
+}
+
+func (db *File) SelectBearerTokenByRefreshHash(ctx context.Context, hash string) (*authgo.BearerToken, error) {
+	return db.memory.SelectBearerTokenByRefreshHash(ctx, hash)
+}
+
+func (db *File) UpdateBearerToken(ctx context.Context, refresh, nextAccess, nextRefresh string, refreshed time.Time) (int64, error) {
+	return db.write("UpdateBearerToken", func() (int64, error) {
+		return db.memory.UpdateBearerToken(ctx, refresh, nextAccess, nextRefresh, refreshed)
+	}, refresh, nextAccess, nextRefresh, refreshed)
+}
+
+func (db *File) DeleteBearerToken(ctx context.Context, id string) (int64, error) {
+	return db.write("DeleteBearerToken", func() (int64, error) {
+		return db.memory.DeleteBearerToken(ctx, id)
+	}, id)
+}
+
+func (db *File) DeleteBearerTokens(ctx context.Context, username string) (int64, error) {
+	return db.write("DeleteBearerTokens", func() (int64, error) {
+		return db.memory.DeleteBearerTokens(ctx, username)
+	}, username)
+}
+
+func (db *File) DeleteExpiredBearerTokens(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredBearerTokens", func() (int64, error) {
+		return db.memory.DeleteExpiredBearerTokens(ctx, before)
+	}, before)
+}
+
+func (db *File) CreatePersonalAccessToken(ctx context.Context, username string, token *authgo.PersonalAccessToken) (int64, error) {
+	return db.write("CreatePersonalAccessToken", func() (int64, error) {
+		return db.memory.CreatePersonalAccessToken(ctx, username, token)
+	}, username, token)
+}
+
+func (db *File) SelectPersonalAccessToken(ctx context.Context, hash string) (string, *authgo.PersonalAccessToken, error) {
+	return db.memory.SelectPersonalAccessToken(ctx, hash)
+}
+
+func (db *File) SelectPersonalAccessTokens(ctx context.Context, username string) ([]*authgo.PersonalAccessToken, error) {
+	return db.memory.SelectPersonalAccessTokens(ctx, username)
+}
+
+func (db *File) UpdatePersonalAccessTokenUsed(ctx context.Context, id string, used time.Time) (int64, error) {
+	return db.write("UpdatePersonalAccessTokenUsed", func() (int64, error) {
+		return db.memory.UpdatePersonalAccessTokenUsed(ctx, id, used)
+	}, id, used)
+}
+
+func (db *File) DeletePersonalAccessToken(ctx context.Context, username, id string) (int64, error) {
+	return db.write("DeletePersonalAccessToken", func() (int64, error) {
+		return db.memory.DeletePersonalAccessToken(ctx, username, id)
+	}, username, id)
+}
+
+func (db *File) DeletePersonalAccessTokens(ctx context.Context, username string) (int64, error) {
+	return db.write("DeletePersonalAccessTokens", func() (int64, error) {
+		return db.memory.DeletePersonalAccessTokens(ctx, username)
+	}, username)
+}
+
+func (db *File) DeleteExpiredPersonalAccessTokens(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredPersonalAccessTokens", func() (int64, error) {
+		return db.memory.DeleteExpiredPersonalAccessTokens(ctx, before)
+	}, before)
+}
+
+func (db *File) IsEmailVerified(ctx context.Context, email string) (bool, error) {
+	return db.memory.IsEmailVerified(ctx, email)
+}
+
+func (db *File) SetEmailVerified(ctx context.Context, email string, verified bool) (int64, error) {
+	return db.write("SetEmailVerified", func() (int64, error) {
+		return db.memory.SetEmailVerified(ctx, email, verified)
+	}, email, verified)
+}
+
+func (db *File) DeactivateUser(ctx context.Context, username string, deleted time.Time) (int64, error) {
+	return db.write("DeactivateUser", func() (int64, error) {
+		return db.memory.DeactivateUser(ctx, username, deleted)
+	}, username, deleted)
+}
+
+func (db *File) CreateSignUpSession(ctx context.Context, session *authgo.SignUpSession) (int64, error) {
+	return db.write("CreateSignUpSession", func() (int64, error) {
+		return db.memory.CreateSignUpSession(ctx, session)
+	}, session)
+}
+
+func (db *File) SelectSignUpSession(ctx context.Context, token string) (*authgo.SignUpSession, error) {
+	return db.memory.SelectSignUpSession(ctx, token)
+}
+
+func (db *File) UpdateSignUpSessionError(ctx context.Context, token string, errmsg string) (int64, error) {
+	return db.write("UpdateSignUpSessionError", func() (int64, error) {
+		return db.memory.UpdateSignUpSessionError(ctx, token, errmsg)
+	}, token, errmsg)
+}
+
+func (db *File) UpdateSignUpSessionIdentity(ctx context.Context, token, email, username string) (int64, error) {
+	return db.write("UpdateSignUpSessionIdentity", func() (int64, error) {
+		return db.memory.UpdateSignUpSessionIdentity(ctx, token, email, username)
+	}, token, email, username)
+}
+
+func (db *File) UpdateSignUpSessionReferrer(ctx context.Context, token, referrer string) (int64, error) {
+	return db.write("UpdateSignUpSessionReferrer", func() (int64, error) {
+		return db.memory.UpdateSignUpSessionReferrer(ctx, token, referrer)
+	}, token, referrer)
+}
+
+func (db *File) UpdateSignUpSessionPasskeyChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	return db.write("UpdateSignUpSessionPasskeyChallenge", func() (int64, error) {
+		return db.memory.UpdateSignUpSessionPasskeyChallenge(ctx, token, challenge)
+	}, token, challenge)
+}
+
+func (db *File) UpdateSignUpSessionChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	return db.write("UpdateSignUpSessionChallenge", func() (int64, error) {
+		return db.memory.UpdateSignUpSessionChallenge(ctx, token, challenge)
+	}, token, challenge)
+}
+
+func (db *File) DeleteExpiredSignUpSessions(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredSignUpSessions", func() (int64, error) {
+		return db.memory.DeleteExpiredSignUpSessions(ctx, before)
+	}, before)
+}
+
+func (db *File) CreateSignInSession(ctx context.Context, session *authgo.SignInSession) (int64, error) {
+	return db.write("CreateSignInSession", func() (int64, error) {
+		return db.memory.CreateSignInSession(ctx, session)
+	}, session)
+}
+
+func (db *File) SelectSignInSession(ctx context.Context, token string) (*authgo.SignInSession, error) {
+	return db.memory.SelectSignInSession(ctx, token)
+}
+
+func (db *File) SelectSignInSessions(ctx context.Context, username string) ([]*authgo.SignInSession, error) {
+	return db.memory.SelectSignInSessions(ctx, username)
+}
+
+func (db *File) UpdateSignInSessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	return db.write("UpdateSignInSessionError", func() (int64, error) {
+		return db.memory.UpdateSignInSessionError(ctx, token, errmsg)
+	}, token, errmsg)
+}
+
+func (db *File) UpdateSignInSessionUsername(ctx context.Context, token, username string) (int64, error) {
+	return db.write("UpdateSignInSessionUsername", func() (int64, error) {
+		return db.memory.UpdateSignInSessionUsername(ctx, token, username)
+	}, token, username)
+}
+
+func (db *File) UpdateSignInSessionAuthenticated(ctx context.Context, token string, authenticated bool) (int64, error) {
+	return db.write("UpdateSignInSessionAuthenticated", func() (int64, error) {
+		return db.memory.UpdateSignInSessionAuthenticated(ctx, token, authenticated)
+	}, token, authenticated)
+}
+
+func (db *File) DeleteExpiredSignInSessions(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredSignInSessions", func() (int64, error) {
+		return db.memory.DeleteExpiredSignInSessions(ctx, before)
+	}, before)
+}
+
+func (db *File) UpdateSignInSessionPasskeyChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	return db.write("UpdateSignInSessionPasskeyChallenge", func() (int64, error) {
+		return db.memory.UpdateSignInSessionPasskeyChallenge(ctx, token, challenge)
+	}, token, challenge)
+}
+
+func (db *File) UpdateSignInSessionPendingSecondFactor(ctx context.Context, token string, pending bool) (int64, error) {
+	return db.write("UpdateSignInSessionPendingSecondFactor", func() (int64, error) {
+		return db.memory.UpdateSignInSessionPendingSecondFactor(ctx, token, pending)
+	}, token, pending)
+}
+
+func (db *File) UpdateSignInSessionActivity(ctx context.Context, token, address, agent string, active time.Time) (int64, error) {
+	return db.write("UpdateSignInSessionActivity", func() (int64, error) {
+		return db.memory.UpdateSignInSessionActivity(ctx, token, address, agent, active)
+	}, token, address, agent, active)
+}
+
+func (db *File) DeauthenticateSignInSessions(ctx context.Context, username string) (int64, error) {
+	return db.write("DeauthenticateSignInSessions", func() (int64, error) {
+		return db.memory.DeauthenticateSignInSessions(ctx, username)
+	}, username)
+}
+
+func (db *File) CreateAccountPasswordSession(ctx context.Context, session *authgo.AccountPasswordSession) (int64, error) {
+	return db.write("CreateAccountPasswordSession", func() (int64, error) {
+		return db.memory.CreateAccountPasswordSession(ctx, session)
+	}, session)
+}
+
+func (db *File) SelectAccountPasswordSession(ctx context.Context, token string) (*authgo.AccountPasswordSession, error) {
+	return db.memory.SelectAccountPasswordSession(ctx, token)
+}
+
+func (db *File) UpdateAccountPasswordSessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	return db.write("UpdateAccountPasswordSessionError", func() (int64, error) {
+		return db.memory.UpdateAccountPasswordSessionError(ctx, token, errmsg)
+	}, token, errmsg)
+}
+
+func (db *File) DeleteExpiredAccountPasswordSessions(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredAccountPasswordSessions", func() (int64, error) {
+		return db.memory.DeleteExpiredAccountPasswordSessions(ctx, before)
+	}, before)
+}
+
+func (db *File) CreateAccountRecoverySession(ctx context.Context, session *authgo.AccountRecoverySession) (int64, error) {
+	return db.write("CreateAccountRecoverySession", func() (int64, error) {
+		return db.memory.CreateAccountRecoverySession(ctx, session)
+	}, session)
+}
+
+func (db *File) SelectAccountRecoverySession(ctx context.Context, token string) (*authgo.AccountRecoverySession, error) {
+	return db.memory.SelectAccountRecoverySession(ctx, token)
+}
+
+func (db *File) UpdateAccountRecoverySessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	return db.write("UpdateAccountRecoverySessionError", func() (int64, error) {
+		return db.memory.UpdateAccountRecoverySessionError(ctx, token, errmsg)
+	}, token, errmsg)
+}
+
+func (db *File) UpdateAccountRecoverySessionEmail(ctx context.Context, token, email string) (int64, error) {
+	return db.write("UpdateAccountRecoverySessionEmail", func() (int64, error) {
+		return db.memory.UpdateAccountRecoverySessionEmail(ctx, token, email)
+	}, token, email)
+}
+
+func (db *File) UpdateAccountRecoverySessionUsername(ctx context.Context, token, username string) (int64, error) {
+	return db.write("UpdateAccountRecoverySessionUsername", func() (int64, error) {
+		return db.memory.UpdateAccountRecoverySessionUsername(ctx, token, username)
+	}, token, username)
+}
+
+func (db *File) UpdateAccountRecoverySessionChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	return db.write("UpdateAccountRecoverySessionChallenge", func() (int64, error) {
+		return db.memory.UpdateAccountRecoverySessionChallenge(ctx, token, challenge)
+	}, token, challenge)
+}
+
+func (db *File) DeleteExpiredAccountRecoverySessions(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredAccountRecoverySessions", func() (int64, error) {
+		return db.memory.DeleteExpiredAccountRecoverySessions(ctx, before)
+	}, before)
+}
+
+func (db *File) CreateSignInChallenge(ctx context.Context, challenge *authgo.SignInChallenge) (int64, error) {
+	return db.write("CreateSignInChallenge", func() (int64, error) {
+		return db.memory.CreateSignInChallenge(ctx, challenge)
+	}, challenge)
+}
+
+func (db *File) SelectSignInChallenge(ctx context.Context, token string) (*authgo.SignInChallenge, error) {
+	return db.memory.SelectSignInChallenge(ctx, token)
+}
+
+func (db *File) SelectSignInChallengeForSession(ctx context.Context, session string) (*authgo.SignInChallenge, error) {
+	return db.memory.SelectSignInChallengeForSession(ctx, session)
+}
+
+func (db *File) DeleteSignInChallenge(ctx context.Context, token string) (int64, error) {
+	return db.write("DeleteSignInChallenge", func() (int64, error) {
+		return db.memory.DeleteSignInChallenge(ctx, token)
+	}, token)
+}
+
+func (db *File) DeleteExpiredSignInChallenges(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredSignInChallenges", func() (int64, error) {
+		return db.memory.DeleteExpiredSignInChallenges(ctx, before)
+	}, before)
+}
+
+func (db *File) CreateOIDCAuthorization(ctx context.Context, authorization *authgo.OIDCAuthorization) (int64, error) {
+	return db.write("CreateOIDCAuthorization", func() (int64, error) {
+		return db.memory.CreateOIDCAuthorization(ctx, authorization)
+	}, authorization)
+}
+
+func (db *File) SelectOIDCAuthorization(ctx context.Context, state string) (*authgo.OIDCAuthorization, error) {
+	return db.memory.SelectOIDCAuthorization(ctx, state)
+}
+
+func (db *File) DeleteOIDCAuthorization(ctx context.Context, state string) (int64, error) {
+	return db.write("DeleteOIDCAuthorization", func() (int64, error) {
+		return db.memory.DeleteOIDCAuthorization(ctx, state)
+	}, state)
+}
+
+func (db *File) DeleteExpiredOIDCAuthorizations(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredOIDCAuthorizations", func() (int64, error) {
+		return db.memory.DeleteExpiredOIDCAuthorizations(ctx, before)
+	}, before)
+}
+
+func (db *File) CreateOIDCCode(ctx context.Context, code *authgo.OIDCCode) (int64, error) {
+	return db.write("CreateOIDCCode", func() (int64, error) {
+		return db.memory.CreateOIDCCode(ctx, code)
+	}, code)
+}
+
+func (db *File) SelectOIDCCode(ctx context.Context, hash string) (*authgo.OIDCCode, error) {
+	return db.memory.SelectOIDCCode(ctx, hash)
+}
+
+func (db *File) DeleteOIDCCode(ctx context.Context, hash string) (int64, error) {
+	return db.write("DeleteOIDCCode", func() (int64, error) {
+		return db.memory.DeleteOIDCCode(ctx, hash)
+	}, hash)
+}
+
+func (db *File) DeleteExpiredOIDCCodes(ctx context.Context, before time.Time) (int64, error) {
+	return db.write("DeleteExpiredOIDCCodes", func() (int64, error) {
+		return db.memory.DeleteExpiredOIDCCodes(ctx, before)
+	}, before)
+}
@@ -0,0 +1,399 @@
+package database_test
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/database"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func openFile(t *testing.T, path string) *database.File {
+	t.Helper()
+	db, err := database.OpenFile(path)
+	require.Nil(t, err)
+	return db
+}
+
+func newFileAuthenticator(t *testing.T) authgo.Authenticator {
+	t.Helper()
+	db := openFile(t, filepath.Join(t.TempDir(), "auth.log"))
+	t.Cleanup(func() {
+		db.Close()
+	})
+	a := authgo.NewAuthenticator(db, db, authtest.NewEmailVerifier())
+	a.SetPasswordHasher(authtest.NewPasswordHasher())
+	return a
+}
+
+func fileSize(t *testing.T, path string) int64 {
+	t.Helper()
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	return info.Size()
+}
+
+func TestFile_Authenticator(t *testing.T) {
+	testAuthenticator(t, newFileAuthenticator)
+}
+
+func TestFile_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	created := time.Now()
+
+	db := openFile(t, path)
+	id, err := db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), created)
+	assert.Nil(t, err)
+	_, err = db.CreateUser(context.Background(), "bob@example.com", "bob", []byte("hash"), created)
+	assert.Nil(t, err)
+	_, err = db.ChangePassword(context.Background(), authtest.TEST_USERNAME, []byte("hash2"))
+	assert.Nil(t, err)
+	_, err = db.SetEmailVerified(context.Background(), authtest.TEST_EMAIL, true)
+	assert.Nil(t, err)
+	_, err = db.DeactivateUser(context.Background(), "bob", created)
+	assert.Nil(t, err)
+	_, err = db.CreateSignInSession(context.Background(), &authgo.SignInSession{
+		Token:    "token",
+		Username: authtest.TEST_USERNAME,
+		Created:  created,
+	})
+	assert.Nil(t, err)
+	_, err = db.UpdateSignInSessionAuthenticated(context.Background(), "token", true)
+	assert.Nil(t, err)
+	_, err = db.CreateSignUpSession(context.Background(), &authgo.SignUpSession{
+		Token:   "expired",
+		Created: created.Add(-time.Hour),
+	})
+	assert.Nil(t, err)
+	count, err := db.DeleteExpiredSignUpSessions(context.Background(), created)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), count)
+	assert.Nil(t, db.Close())
+
+	db = openFile(t, path)
+	defer db.Close()
+	i, email, password, c, err := db.SelectUser(context.Background(), authtest.TEST_USERNAME)
+	assert.Nil(t, err)
+	assert.Equal(t, id, i)
+	assert.Equal(t, authtest.TEST_EMAIL, email)
+	assert.Equal(t, []byte("hash2"), password)
+	assert.True(t, created.Equal(c))
+	verified, err := db.IsEmailVerified(context.Background(), authtest.TEST_EMAIL)
+	assert.Nil(t, err)
+	assert.True(t, verified)
+	_, _, _, _, err = db.SelectUser(context.Background(), "bob")
+	assert.Equal(t, authgo.ErrUsernameNotRegistered, err)
+	session, err := db.SelectSignInSession(context.Background(), "token")
+	assert.Nil(t, err)
+	assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	assert.True(t, session.Authenticated)
+	assert.True(t, created.Equal(session.Created))
+	_, err = db.SelectSignUpSession(context.Background(), "expired")
+	assert.Equal(t, database.ErrNoSuchRecord, err)
+
+	// Ids continue from where they left off
+	id, err = db.CreateUser(context.Background(), "carol@example.com", "carol", []byte("hash"), created)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), id)
+}
+
+func TestFile_Recovery(t *testing.T) {
+	// Each corruption damages the last record, which follows a record ending at size
+	for name, corrupt := range map[string]func(t *testing.T, path string, size int64){
+		"TornHeader": func(t *testing.T, path string, size int64) {
+			require.Nil(t, os.Truncate(path, size+3))
+		},
+		"TornPayload": func(t *testing.T, path string, size int64) {
+			require.Nil(t, os.Truncate(path, fileSize(t, path)-1))
+		},
+		"Checksum": func(t *testing.T, path string, size int64) {
+			f, err := os.OpenFile(path, os.O_RDWR, 0)
+			require.Nil(t, err)
+			defer f.Close()
+			_, err = f.WriteAt([]byte{'X'}, fileSize(t, path)-2)
+			require.Nil(t, err)
+		},
+		"Garbage": func(t *testing.T, path string, size int64) {
+			require.Nil(t, os.Truncate(path, size))
+			f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+			require.Nil(t, err)
+			defer f.Close()
+			_, err = f.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0, 1, 2, 3})
+			require.Nil(t, err)
+		},
+	} {
+		for mode, compact := range map[string]bool{"": false, "Compacted": true} {
+			testFileRecovery(t, name+mode, compact, corrupt)
+		}
+	}
+}
+
+func testFileRecovery(t *testing.T, name string, compact bool, corrupt func(t *testing.T, path string, size int64)) {
+	t.Run(name, func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "auth.log")
+		db := openFile(t, path)
+		_, err := db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), time.Now())
+		assert.Nil(t, err)
+		if compact {
+			// The damaged record follows a snapshot
+			assert.Nil(t, db.Compact())
+		}
+		size := fileSize(t, path)
+		_, err = db.CreateSignInSession(context.Background(), &authgo.SignInSession{
+			Token:    "token",
+			Username: authtest.TEST_USERNAME,
+			Created:  time.Now(),
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, db.Close())
+
+		corrupt(t, path, size)
+
+		// The damaged record is discarded and everything before it is kept
+		db = openFile(t, path)
+		assert.Equal(t, size, fileSize(t, path))
+		_, _, _, _, err = db.SelectUser(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		_, err = db.SelectSignInSession(context.Background(), "token")
+		assert.Equal(t, database.ErrNoSuchRecord, err)
+
+		// The log can be appended to again
+		_, err = db.CreateSignInSession(context.Background(), &authgo.SignInSession{
+			Token:    "token2",
+			Username: authtest.TEST_USERNAME,
+			Created:  time.Now(),
+		})
+		assert.Nil(t, err)
+		assert.Nil(t, db.Close())
+
+		db = openFile(t, path)
+		defer db.Close()
+		_, err = db.SelectSignInSession(context.Background(), "token2")
+		assert.Nil(t, err)
+	})
+}
+
+func TestFile_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	created := time.Now()
+	db := openFile(t, path)
+	_, err := db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), created)
+	assert.Nil(t, err)
+	_, err = db.ChangePassword(context.Background(), authtest.TEST_USERNAME, []byte("hash2"))
+	assert.Nil(t, err)
+	_, err = db.CreateSignInSession(context.Background(), &authgo.SignInSession{
+		Token:    "token",
+		Username: authtest.TEST_USERNAME,
+		Created:  created,
+	})
+	assert.Nil(t, err)
+	for i := 0; i < 100; i++ {
+		_, err = db.CreateSignUpSession(context.Background(), &authgo.SignUpSession{
+			Token:   fmt.Sprintf("expired%d", i),
+			Created: created.Add(-time.Hour),
+		})
+		assert.Nil(t, err)
+	}
+	_, err = db.DeleteExpiredSignUpSessions(context.Background(), created)
+	assert.Nil(t, err)
+	size := fileSize(t, path)
+
+	assert.Nil(t, db.Compact())
+	assert.True(t, fileSize(t, path) < size)
+
+	// Changes after compaction are appended to the new log
+	_, err = db.UpdateSignInSessionAuthenticated(context.Background(), "token", true)
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	db = openFile(t, path)
+	defer db.Close()
+	_, _, password, c, err := db.SelectUser(context.Background(), authtest.TEST_USERNAME)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("hash2"), password)
+	assert.True(t, created.Equal(c))
+	session, err := db.SelectSignInSession(context.Background(), "token")
+	assert.Nil(t, err)
+	assert.True(t, session.Authenticated)
+	_, err = db.SelectSignUpSession(context.Background(), "expired0")
+	assert.Equal(t, database.ErrNoSuchRecord, err)
+
+	// Ids continue from where they left off
+	id, err := db.CreateUser(context.Background(), "bob@example.com", "bob", []byte("hash"), created)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), id)
+}
+
+func TestFile_CompactLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	db := openFile(t, path)
+	db.SetCompactLength(4096)
+	for i := 0; i < 1000; i++ {
+		token := fmt.Sprintf("token%d", i)
+		_, err := db.CreateSignInSession(context.Background(), &authgo.SignInSession{
+			Token:    token,
+			Username: authtest.TEST_USERNAME,
+			Created:  time.Now().Add(-time.Hour),
+		})
+		require.Nil(t, err)
+		_, err = db.DeleteExpiredSignInSessions(context.Background(), time.Now())
+		require.Nil(t, err)
+	}
+	_, err := db.CreateSignInSession(context.Background(), &authgo.SignInSession{
+		Token:    "token",
+		Username: authtest.TEST_USERNAME,
+		Created:  time.Now(),
+	})
+	require.Nil(t, err)
+	// The log is compacted rather than growing with every change
+	assert.True(t, fileSize(t, path) < 8192)
+	assert.Nil(t, db.Close())
+
+	db = openFile(t, path)
+	defer db.Close()
+	_, err = db.SelectSignInSession(context.Background(), "token")
+	assert.Nil(t, err)
+	_, err = db.SelectSignInSession(context.Background(), "token0")
+	assert.Equal(t, database.ErrNoSuchRecord, err)
+}
+
+func TestFile_CompactInterrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	db := openFile(t, path)
+	_, err := db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), time.Now())
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+
+	// A crash before the snapshot is renamed leaves a partial temporary file, which is ignored
+	require.Nil(t, os.WriteFile(path+".tmp", []byte{0, 0, 1}, 0600))
+	db = openFile(t, path)
+	defer db.Close()
+	_, _, _, _, err = db.SelectUser(context.Background(), authtest.TEST_USERNAME)
+	assert.Nil(t, err)
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFile_CorruptMiddleRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "auth.log")
+	db := openFile(t, path)
+	_, err := db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), time.Now())
+	assert.Nil(t, err)
+	size := fileSize(t, path)
+	_, err = db.ChangePassword(context.Background(), authtest.TEST_USERNAME, []byte("hash2"))
+	assert.Nil(t, err)
+	_, err = db.CreateUser(context.Background(), "bob@example.com", "bob", []byte("hash"), time.Now())
+	assert.Nil(t, err)
+	assert.Nil(t, db.Close())
+	total := fileSize(t, path)
+
+	// Damage the payload of the middle record
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	require.Nil(t, err)
+	_, err = f.WriteAt([]byte{'X'}, size+10)
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	_, err = database.OpenFile(path)
+	assert.True(t, errors.Is(err, database.ErrCorruptFile), err)
+
+	// The records after the damaged one are kept
+	assert.Equal(t, total, fileSize(t, path))
+}
+
+// TestFile_Crash writes to the database in a child process which exits without closing the file.
+func TestFile_Crash(t *testing.T) {
+	if path := os.Getenv("AUTHGO_FILE_CRASH"); path != "" {
+		db, err := database.OpenFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		db.SetCompactLength(512) // Compact while writing
+		for i := 0; i < 10; i++ {
+			if _, err := db.CreateSignInSession(context.Background(), &authgo.SignInSession{
+				Token:    fmt.Sprintf("token%d", i),
+				Username: authtest.TEST_USERNAME,
+				Created:  time.Now(),
+			}); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(2)
+			}
+		}
+		os.Exit(1)
+	}
+	path := filepath.Join(t.TempDir(), "auth.log")
+	cmd := exec.Command(os.Args[0], "-test.run=^TestFile_Crash$")
+	cmd.Env = append(os.Environ(), "AUTHGO_FILE_CRASH="+path)
+	output, err := cmd.CombinedOutput()
+	exit, ok := err.(*exec.ExitError)
+	require.True(t, ok, string(output))
+	require.Equal(t, 1, exit.ExitCode(), string(output))
+
+	db := openFile(t, path)
+	defer db.Close()
+	for i := 0; i < 10; i++ {
+		session, err := db.SelectSignInSession(context.Background(), fmt.Sprintf("token%d", i))
+		assert.Nil(t, err)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	}
+}
+
+func TestFile_ConcurrentReaders(t *testing.T) {
+	db := openFile(t, filepath.Join(t.TempDir(), "auth.log"))
+	defer db.Close()
+	_, err := db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), time.Now())
+	assert.Nil(t, err)
+	const writes = 50
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < writes; i++ {
+			_, err := db.CreateSignInSession(context.Background(), &authgo.SignInSession{
+				Token:    fmt.Sprintf("token%d", i),
+				Username: authtest.TEST_USERNAME,
+				Created:  time.Now(),
+			})
+			assert.Nil(t, err)
+		}
+	}()
+	for r := 0; r < 4; r++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < writes; i++ {
+				_, _, _, _, err := db.SelectUser(context.Background(), authtest.TEST_USERNAME)
+				assert.Nil(t, err)
+				if session, err := db.SelectSignInSession(context.Background(), fmt.Sprintf("token%d", i)); err == nil {
+					assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+				} else {
+					assert.Equal(t, database.ErrNoSuchRecord, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	for i := 0; i < writes; i++ {
+		_, err := db.SelectSignInSession(context.Background(), fmt.Sprintf("token%d", i))
+		assert.Nil(t, err)
+	}
+}
+
+func TestFile_Closed(t *testing.T) {
+	db := openFile(t, filepath.Join(t.TempDir(), "auth.log"))
+	assert.Nil(t, db.Close())
+	_, err := db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), time.Now())
+	assert.Equal(t, os.ErrClosed, err)
+	_, _, _, _, err = db.SelectUser(context.Background(), authtest.TEST_USERNAME)
+	assert.Equal(t, authgo.ErrUsernameNotRegistered, err)
+}
@@ -2,7 +2,9 @@ package database
 
 import (
 	"aletheiaware.com/authgo"
+	"context"
 	"errors"
+	"sort"
 	"sync"
 	"time"
 )
@@ -12,6 +14,7 @@ var (
 	nextId          = int64(1)
 )
 
+// Deprecated: InMemory allocates ids per instance.
 func NextId() int64 {
 	n := nextId
 	nextId = nextId + 1
@@ -27,18 +30,61 @@ func NewInMemory() *InMemory {
 		AccountVerified:   make(map[string]bool),
 		AccountCreated:    make(map[string]time.Time),
 		AccountDeleted:    make(map[string]time.Time),
+		PasswordHistory:   make(map[string][][]byte),
+		FailureCount:      make(map[string]int64),
+		FailureTime:       make(map[string]time.Time),
+		TOTPSecret:        make(map[string][]byte),
+		TOTPPending:       make(map[string][]byte),
+		TOTPCounter:       make(map[string]int64),
+		PasskeyUsername:   make(map[string]string),
+		PasskeyName:       make(map[string]string),
+		PasskeyPublicKey:  make(map[string][]byte),
+		PasskeySignCount:  make(map[string]int64),
+		PasskeyCreated:    make(map[string]time.Time),
+		PasskeyUsed:       make(map[string]time.Time),
+		RecoveryCodeTime:  make(map[string]time.Time),
+		RecoveryCodeUsed:  make(map[string]map[string]time.Time),
+		OIDCIdentity:      make(map[string]map[string]string),
+		ClientName:        make(map[string]string),
+		ClientSecret:      make(map[string][]byte),
+		ClientRedirects:   make(map[string][]string),
+		ClientCreated:     make(map[string]time.Time),
+		SigningKey:        make(map[string][]byte),
+		SigningKeyCreated: make(map[string]time.Time),
+		RefreshClient:     make(map[string]string),
+		RefreshUsername:   make(map[string]string),
+		RefreshScope:      make(map[string]string),
+		RefreshCreated:    make(map[string]time.Time),
+		BearerUsername:    make(map[string]string),
+		BearerAccess:      make(map[string]string),
+		BearerRefresh:     make(map[string]string),
+		BearerCreated:     make(map[string]time.Time),
+		BearerRefreshed:   make(map[string]time.Time),
+		TokenUsername:     make(map[string]string),
+		TokenName:         make(map[string]string),
+		TokenHash:         make(map[string]string),
+		TokenScopes:       make(map[string][]string),
+		TokenExpires:      make(map[string]time.Time),
+		TokenCreated:      make(map[string]time.Time),
+		TokenUsed:         make(map[string]time.Time),
 		SignupToken:       make(map[string]bool),
 		SignupCreated:     make(map[string]time.Time),
 		SignupEmail:       make(map[string]string),
 		SignupUsername:    make(map[string]string),
 		SignupReferrer:    make(map[string]string),
 		SignupChallenge:   make(map[string]string),
+		SignupPasskey:     make(map[string]string),
 		SignupError:       make(map[string]string),
 		SigninToken:       make(map[string]bool),
 		SigninCreated:     make(map[string]time.Time),
 		SigninUsername:    make(map[string]string),
 		SigninAuth:        make(map[string]bool),
+		SigninPending:     make(map[string]bool),
+		SigninPasskey:     make(map[string]string),
 		SigninError:       make(map[string]string),
+		SigninAddress:     make(map[string]string),
+		SigninAgent:       make(map[string]string),
+		SigninActive:      make(map[string]time.Time),
 		ResetToken:        make(map[string]bool),
 		ResetCreated:      make(map[string]time.Time),
 		ResetUsername:     make(map[string]string),
@@ -49,6 +95,23 @@ func NewInMemory() *InMemory {
 		RecoveryUsername:  make(map[string]string),
 		RecoveryChallenge: make(map[string]string),
 		RecoveryError:     make(map[string]string),
+		ChallengeSession:  make(map[string]string),
+		ChallengeUsername: make(map[string]string),
+		ChallengeCode:     make(map[string]string),
+		ChallengeCreated:  make(map[string]time.Time),
+		OIDCSession:       make(map[string]string),
+		OIDCProvider:      make(map[string]string),
+		OIDCNonce:         make(map[string]string),
+		OIDCVerifier:      make(map[string]string),
+		OIDCNext:          make(map[string]string),
+		OIDCCreated:       make(map[string]time.Time),
+		CodeClient:        make(map[string]string),
+		CodeUsername:      make(map[string]string),
+		CodeRedirect:      make(map[string]string),
+		CodeScope:         make(map[string]string),
+		CodeNonce:         make(map[string]string),
+		CodeChallenge:     make(map[string]string),
+		CodeCreated:       make(map[string]time.Time),
 	}
 }
 
@@ -61,18 +124,61 @@ type InMemory struct {
 	AccountVerified   map[string]bool
 	AccountCreated    map[string]time.Time
 	AccountDeleted    map[string]time.Time
+	PasswordHistory   map[string][][]byte
+	FailureCount      map[string]int64
+	FailureTime       map[string]time.Time
+	TOTPSecret        map[string][]byte
+	TOTPPending       map[string][]byte
+	TOTPCounter       map[string]int64
+	PasskeyUsername   map[string]string
+	PasskeyName       map[string]string
+	PasskeyPublicKey  map[string][]byte
+	PasskeySignCount  map[string]int64
+	PasskeyCreated    map[string]time.Time
+	PasskeyUsed       map[string]time.Time
+	RecoveryCodeTime  map[string]time.Time
+	RecoveryCodeUsed  map[string]map[string]time.Time // Maps username to code hash to time used, zero if unused
+	OIDCIdentity      map[string]map[string]string    // Maps provider to subject to username
+	ClientName        map[string]string
+	ClientSecret      map[string][]byte
+	ClientRedirects   map[string][]string
+	ClientCreated     map[string]time.Time
+	SigningKey        map[string][]byte
+	SigningKeyCreated map[string]time.Time
+	RefreshClient     map[string]string
+	RefreshUsername   map[string]string
+	RefreshScope      map[string]string
+	RefreshCreated    map[string]time.Time
+	BearerUsername    map[string]string // Maps grant ID to username
+	BearerAccess      map[string]string // Maps grant ID to access token hash
+	BearerRefresh     map[string]string // Maps grant ID to refresh token hash
+	BearerCreated     map[string]time.Time
+	BearerRefreshed   map[string]time.Time
+	TokenUsername     map[string]string // Maps personal access token ID to username
+	TokenName         map[string]string
+	TokenHash         map[string]string
+	TokenScopes       map[string][]string
+	TokenExpires      map[string]time.Time
+	TokenCreated      map[string]time.Time
+	TokenUsed         map[string]time.Time
 	SignupToken       map[string]bool
 	SignupCreated     map[string]time.Time
 	SignupEmail       map[string]string
 	SignupUsername    map[string]string
 	SignupReferrer    map[string]string
 	SignupChallenge   map[string]string
+	SignupPasskey     map[string]string
 	SignupError       map[string]string
 	SigninToken       map[string]bool
 	SigninCreated     map[string]time.Time
 	SigninUsername    map[string]string
 	SigninAuth        map[string]bool
+	SigninPending     map[string]bool
+	SigninPasskey     map[string]string
 	SigninError       map[string]string
+	SigninAddress     map[string]string
+	SigninAgent       map[string]string
+	SigninActive      map[string]time.Time
 	ResetToken        map[string]bool
 	ResetCreated      map[string]time.Time
 	ResetUsername     map[string]string
@@ -83,6 +189,24 @@ type InMemory struct {
 	RecoveryUsername  map[string]string
 	RecoveryChallenge map[string]string
 	RecoveryError     map[string]string
+	ChallengeSession  map[string]string
+	ChallengeUsername map[string]string
+	ChallengeCode     map[string]string
+	ChallengeCreated  map[string]time.Time
+	OIDCSession       map[string]string
+	OIDCProvider      map[string]string
+	OIDCNonce         map[string]string
+	OIDCVerifier      map[string]string
+	OIDCNext          map[string]string
+	OIDCCreated       map[string]time.Time
+	CodeClient        map[string]string
+	CodeUsername      map[string]string
+	CodeRedirect      map[string]string
+	CodeScope         map[string]string
+	CodeNonce         map[string]string
+	CodeChallenge     map[string]string
+	CodeCreated       map[string]time.Time
+	lastId            int64
 }
 
 func (db *InMemory) Close() error {
@@ -93,7 +217,7 @@ func (db *InMemory) Ping() error {
 	return nil
 }
 
-func (db *InMemory) CreateUser(email, username string, password []byte, created time.Time) (int64, error) {
+func (db *InMemory) CreateUser(ctx context.Context, email, username string, password []byte, created time.Time) (int64, error) {
 	db.Lock()
 	defer db.Unlock()
 	if _, ok := db.AccountUsername[email]; ok {
@@ -102,7 +226,8 @@ func (db *InMemory) CreateUser(email, username string, password []byte, created
 	if _, ok := db.AccountEmail[username]; ok {
 		return 0, authgo.ErrUsernameAlreadyRegistered
 	}
-	id := NextId()
+	db.lastId++
+	id := db.lastId
 	db.AccountId[username] = id
 	db.AccountEmail[username] = email
 	db.AccountUsername[email] = username
@@ -111,7 +236,9 @@ func (db *InMemory) CreateUser(email, username string, password []byte, created
 	return id, nil
 }
 
-func (db *InMemory) SelectUser(username string) (int64, string, []byte, time.Time, error) {
+func (db *InMemory) SelectUser(ctx context.Context, username string) (int64, string, []byte, time.Time, error) {
+	db.RLock()
+	defer db.RUnlock()
 	id, ok := db.AccountId[username]
 	if !ok {
 		return 0, "", nil, time.Time{}, authgo.ErrUsernameNotRegistered
@@ -125,7 +252,9 @@ func (db *InMemory) SelectUser(username string) (int64, string, []byte, time.Tim
 	return id, email, password, created, nil
 }
 
-func (db *InMemory) SelectUsernameByEmail(email string) (string, error) {
+func (db *InMemory) SelectUsernameByEmail(ctx context.Context, email string) (string, error) {
+	db.RLock()
+	defer db.RUnlock()
 	username, ok := db.AccountUsername[email]
 	if !ok {
 		return "", authgo.ErrEmailNotRegistered
@@ -136,7 +265,9 @@ func (db *InMemory) SelectUsernameByEmail(email string) (string, error) {
 	return username, nil
 }
 
-func (db *InMemory) ChangePassword(username string, password []byte) (int64, error) {
+func (db *InMemory) ChangePassword(ctx context.Context, username string, password []byte) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.AccountEmail[username]; !ok {
 		return 0, authgo.ErrUsernameNotRegistered
 	}
@@ -147,7 +278,635 @@ func (db *InMemory) ChangePassword(username string, password []byte) (int64, err
 	return 1, nil
 }
 
-func (db *InMemory) IsEmailVerified(email string) (bool, error) {
+func (db *InMemory) CreatePasswordHistory(ctx context.Context, username string, password []byte, created time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	db.lastId++
+	id := db.lastId
+	db.PasswordHistory[username] = append(db.PasswordHistory[username], password)
+	return id, nil
+}
+
+func (db *InMemory) SelectPasswordHistory(ctx context.Context, username string) ([][]byte, error) {
+	db.RLock()
+	defer db.RUnlock()
+	history := db.PasswordHistory[username]
+	// Newest first
+	passwords := make([][]byte, len(history))
+	for i, p := range history {
+		passwords[len(history)-1-i] = p
+	}
+	return passwords, nil
+}
+
+func (db *InMemory) PrunePasswordHistory(ctx context.Context, username string, keep int) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	history := db.PasswordHistory[username]
+	if len(history) <= keep {
+		return 0, nil
+	}
+	count := len(history) - keep
+	if keep <= 0 {
+		delete(db.PasswordHistory, username)
+	} else {
+		db.PasswordHistory[username] = append([][]byte(nil), history[count:]...)
+	}
+	return int64(count), nil
+}
+
+func (db *InMemory) SelectAuthenticationFailures(ctx context.Context, subject string) (int64, time.Time, error) {
+	db.RLock()
+	defer db.RUnlock()
+	return db.FailureCount[subject], db.FailureTime[subject], nil
+}
+
+func (db *InMemory) IncrementAuthenticationFailures(ctx context.Context, subject string, failed time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	db.FailureCount[subject]++
+	db.FailureTime[subject] = failed
+	return 1, nil
+}
+
+func (db *InMemory) DeleteAuthenticationFailures(ctx context.Context, subject string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.FailureCount[subject]; !ok {
+		return 0, nil
+	}
+	delete(db.FailureCount, subject)
+	delete(db.FailureTime, subject)
+	return 1, nil
+}
+
+func (db *InMemory) DeleteExpiredAuthenticationFailures(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for subject, failed := range db.FailureTime {
+		if failed.Before(before) {
+			delete(db.FailureCount, subject)
+			delete(db.FailureTime, subject)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *InMemory) SelectTOTP(ctx context.Context, username string) ([]byte, []byte, int64, error) {
+	db.RLock()
+	defer db.RUnlock()
+	return db.TOTPSecret[username], db.TOTPPending[username], db.TOTPCounter[username], nil
+}
+
+func (db *InMemory) UpdateTOTPPending(ctx context.Context, username string, pending []byte) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	db.TOTPPending[username] = pending
+	return 1, nil
+}
+
+func (db *InMemory) ConfirmTOTP(ctx context.Context, username string, counter int64) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	pending, ok := db.TOTPPending[username]
+	if !ok {
+		return 0, authgo.ErrTOTPNotEnrolled
+	}
+	db.TOTPSecret[username] = pending
+	db.TOTPCounter[username] = counter
+	delete(db.TOTPPending, username)
+	return 1, nil
+}
+
+func (db *InMemory) UpdateTOTPCounter(ctx context.Context, username string, counter int64) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.TOTPSecret[username]; !ok || db.TOTPCounter[username] >= counter {
+		return 0, authgo.ErrTOTPCodeIncorrect
+	}
+	db.TOTPCounter[username] = counter
+	return 1, nil
+}
+
+func (db *InMemory) DeleteTOTP(ctx context.Context, username string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	_, secret := db.TOTPSecret[username]
+	_, pending := db.TOTPPending[username]
+	if !secret && !pending {
+		return 0, nil
+	}
+	delete(db.TOTPSecret, username)
+	delete(db.TOTPPending, username)
+	delete(db.TOTPCounter, username)
+	return 1, nil
+}
+
+func (db *InMemory) CreatePasskey(ctx context.Context, username string, passkey *authgo.Passkey) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.PasskeyUsername[passkey.ID]; ok {
+		return 0, authgo.ErrPasskeyAlreadyRegistered
+	}
+	db.lastId++
+	id := db.lastId
+	db.PasskeyUsername[passkey.ID] = username
+	db.PasskeyName[passkey.ID] = passkey.Name
+	db.PasskeyPublicKey[passkey.ID] = passkey.PublicKey
+	db.PasskeySignCount[passkey.ID] = passkey.SignCount
+	db.PasskeyCreated[passkey.ID] = passkey.Created
+	if !passkey.Used.IsZero() {
+		db.PasskeyUsed[passkey.ID] = passkey.Used
+	}
+	return id, nil
+}
+
+func (db *InMemory) SelectPasskey(ctx context.Context, id string) (string, *authgo.Passkey, error) {
+	db.RLock()
+	defer db.RUnlock()
+	username, ok := db.PasskeyUsername[id]
+	if !ok {
+		return "", nil, authgo.ErrPasskeyNotRecognized
+	}
+	return username, db.passkey(id), nil
+}
+
+func (db *InMemory) SelectPasskeys(ctx context.Context, username string) ([]*authgo.Passkey, error) {
+	db.RLock()
+	defer db.RUnlock()
+	var passkeys []*authgo.Passkey
+	for id, u := range db.PasskeyUsername {
+		if u == username {
+			passkeys = append(passkeys, db.passkey(id))
+		}
+	}
+	// Oldest first
+	sort.Slice(passkeys, func(i, j int) bool {
+		return passkeys[i].Created.Before(passkeys[j].Created)
+	})
+	return passkeys, nil
+}
+
+// passkey returns the passkey with the given id, the caller must hold the lock.
+func (db *InMemory) passkey(id string) *authgo.Passkey {
+	return &authgo.Passkey{
+		ID:        id,
+		Name:      db.PasskeyName[id],
+		PublicKey: db.PasskeyPublicKey[id],
+		SignCount: db.PasskeySignCount[id],
+		Created:   db.PasskeyCreated[id],
+		Used:      db.PasskeyUsed[id],
+	}
+}
+
+func (db *InMemory) UpdatePasskeySignCount(ctx context.Context, id string, count int64, used time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.PasskeyUsername[id]; !ok {
+		return 0, authgo.ErrPasskeyNotRecognized
+	}
+	db.PasskeySignCount[id] = count
+	db.PasskeyUsed[id] = used
+	return 1, nil
+}
+
+func (db *InMemory) DeletePasskey(ctx context.Context, username, id string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if u, ok := db.PasskeyUsername[id]; !ok || u != username {
+		return 0, authgo.ErrPasskeyNotRecognized
+	}
+	delete(db.PasskeyUsername, id)
+	delete(db.PasskeyName, id)
+	delete(db.PasskeyPublicKey, id)
+	delete(db.PasskeySignCount, id)
+	delete(db.PasskeyCreated, id)
+	delete(db.PasskeyUsed, id)
+	return 1, nil
+}
+
+func (db *InMemory) CreateRecoveryCodes(ctx context.Context, username string, hashes [][]byte, created time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	used := make(map[string]time.Time)
+	for _, h := range hashes {
+		used[string(h)] = time.Time{}
+	}
+	db.RecoveryCodeTime[username] = created
+	db.RecoveryCodeUsed[username] = used
+	return int64(len(hashes)), nil
+}
+
+func (db *InMemory) SelectRecoveryCodes(ctx context.Context, username string) (time.Time, []time.Time, error) {
+	db.RLock()
+	defer db.RUnlock()
+	var used []time.Time
+	for _, u := range db.RecoveryCodeUsed[username] {
+		used = append(used, u)
+	}
+	return db.RecoveryCodeTime[username], used, nil
+}
+
+func (db *InMemory) UpdateRecoveryCodeUsed(ctx context.Context, username string, hash []byte, used time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	u, ok := db.RecoveryCodeUsed[username][string(hash)]
+	if !ok || !u.IsZero() {
+		return 0, authgo.ErrRecoveryCodeIncorrect
+	}
+	db.RecoveryCodeUsed[username][string(hash)] = used
+	return 1, nil
+}
+
+func (db *InMemory) CreateOIDCIdentity(ctx context.Context, username, provider, subject string, created time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	identities, ok := db.OIDCIdentity[provider]
+	if !ok {
+		identities = make(map[string]string)
+		db.OIDCIdentity[provider] = identities
+	}
+	if _, ok := identities[subject]; ok {
+		return 0, authgo.ErrOIDCIdentityAlreadyLinked
+	}
+	identities[subject] = username
+	db.lastId++
+	return db.lastId, nil
+}
+
+func (db *InMemory) SelectOIDCIdentity(ctx context.Context, provider, subject string) (string, error) {
+	db.RLock()
+	defer db.RUnlock()
+	username, ok := db.OIDCIdentity[provider][subject]
+	if !ok {
+		return "", authgo.ErrOIDCIdentityNotFound
+	}
+	return username, nil
+}
+
+func (db *InMemory) CreateOIDCClient(ctx context.Context, client *authgo.OIDCClient) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	id := client.ID
+	db.ClientName[id] = client.Name
+	db.ClientSecret[id] = client.Secret
+	db.ClientRedirects[id] = client.RedirectURIs
+	db.ClientCreated[id] = client.Created
+	db.lastId++
+	return db.lastId, nil
+}
+
+func (db *InMemory) SelectOIDCClient(ctx context.Context, id string) (*authgo.OIDCClient, error) {
+	db.RLock()
+	defer db.RUnlock()
+	created, ok := db.ClientCreated[id]
+	if !ok {
+		return nil, authgo.ErrOIDCClientNotFound
+	}
+	return &authgo.OIDCClient{
+		ID:           id,
+		Name:         db.ClientName[id],
+		Secret:       db.ClientSecret[id],
+		RedirectURIs: db.ClientRedirects[id],
+		Created:      created,
+	}, nil
+}
+
+func (db *InMemory) DeleteOIDCClient(ctx context.Context, id string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.ClientCreated[id]; !ok {
+		return 0, authgo.ErrOIDCClientNotFound
+	}
+	delete(db.ClientName, id)
+	delete(db.ClientSecret, id)
+	delete(db.ClientRedirects, id)
+	delete(db.ClientCreated, id)
+	return 1, nil
+}
+
+func (db *InMemory) CreateOIDCSigningKey(ctx context.Context, key *authgo.OIDCSigningKey) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	db.SigningKey[key.ID] = key.PrivateKey
+	db.SigningKeyCreated[key.ID] = key.Created
+	db.lastId++
+	return db.lastId, nil
+}
+
+func (db *InMemory) SelectOIDCSigningKeys(ctx context.Context) ([]*authgo.OIDCSigningKey, error) {
+	db.RLock()
+	defer db.RUnlock()
+	var keys []*authgo.OIDCSigningKey
+	for id, created := range db.SigningKeyCreated {
+		keys = append(keys, &authgo.OIDCSigningKey{
+			ID:         id,
+			PrivateKey: db.SigningKey[id],
+			Created:    created,
+		})
+	}
+	// Newest first
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Created.After(keys[j].Created)
+	})
+	return keys, nil
+}
+
+func (db *InMemory) DeleteExpiredOIDCSigningKeys(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for id, created := range db.SigningKeyCreated {
+		if created.Before(before) {
+			delete(db.SigningKey, id)
+			delete(db.SigningKeyCreated, id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *InMemory) CreateOIDCRefreshToken(ctx context.Context, token *authgo.OIDCRefreshToken) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	hash := token.Hash
+	db.RefreshClient[hash] = token.ClientID
+	db.RefreshUsername[hash] = token.Username
+	db.RefreshScope[hash] = token.Scope
+	db.RefreshCreated[hash] = token.Created
+	db.lastId++
+	return db.lastId, nil
+}
+
+func (db *InMemory) SelectOIDCRefreshToken(ctx context.Context, hash string) (*authgo.OIDCRefreshToken, error) {
+	db.RLock()
+	defer db.RUnlock()
+	created, ok := db.RefreshCreated[hash]
+	if !ok {
+		return nil, authgo.ErrOIDCGrantInvalid
+	}
+	return &authgo.OIDCRefreshToken{
+		Hash:     hash,
+		ClientID: db.RefreshClient[hash],
+		Username: db.RefreshUsername[hash],
+		Scope:    db.RefreshScope[hash],
+		Created:  created,
+	}, nil
+}
+
+func (db *InMemory) DeleteOIDCRefreshToken(ctx context.Context, hash string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.RefreshCreated[hash]; !ok {
+		return 0, authgo.ErrOIDCGrantInvalid
+	}
+	db.deleteOIDCRefreshToken(hash)
+	return 1, nil
+}
+
+func (db *InMemory) DeleteExpiredOIDCRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for hash, created := range db.RefreshCreated {
+		if created.Before(before) {
+			db.deleteOIDCRefreshToken(hash)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// deleteOIDCRefreshToken deletes the refresh token with the given hash, the caller must hold the lock.
+func (db *InMemory) deleteOIDCRefreshToken(hash string) {
+	delete(db.RefreshClient, hash)
+	delete(db.RefreshUsername, hash)
+	delete(db.RefreshScope, hash)
+	delete(db.RefreshCreated, hash)
+}
+
+func (db *InMemory) CreateBearerToken(ctx context.Context, token *authgo.BearerToken) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	id := token.ID
+	db.BearerUsername[id] = token.Username
+	db.BearerAccess[id] = token.AccessHash
+	db.BearerRefresh[id] = token.RefreshHash
+	db.BearerCreated[id] = token.Created
+	db.BearerRefreshed[id] = token.Refreshed
+	db.lastId++
+	return db.lastId, nil
+}
+
+func (db *InMemory) SelectBearerTokenByAccessHash(ctx context.Context, hash string) (*authgo.BearerToken, error) {
+	db.RLock()
+	defer db.RUnlock()
+	return db.selectBearerToken(db.BearerAccess, hash)
+}
+
+func (db *InMemory) SelectBearerTokenByRefreshHash(ctx context.Context, hash string) (*authgo.BearerToken, error) {
+	db.RLock()
+	defer db.RUnlock()
+	return db.selectBearerToken(db.BearerRefresh, hash)
+}
+
+// selectBearerToken returns the grant whose hash in the given map matches, the caller must hold the lock.
+func (db *InMemory) selectBearerToken(hashes map[string]string, hash string) (*authgo.BearerToken, error) {
+	for id, h := range hashes {
+		if h == hash {
+			return &authgo.BearerToken{
+				ID:          id,
+				Username:    db.BearerUsername[id],
+				AccessHash:  db.BearerAccess[id],
+				RefreshHash: db.BearerRefresh[id],
+				Created:     db.BearerCreated[id],
+				Refreshed:   db.BearerRefreshed[id],
+			}, nil
+		}
+	}
+	return nil, authgo.ErrBearerTokenInvalid
+}
+
+func (db *InMemory) UpdateBearerToken(ctx context.Context, refresh, nextAccess, nextRefresh string, refreshed time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	for id, h := range db.BearerRefresh {
+		if h == refresh {
+			db.BearerAccess[id] = nextAccess
+			db.BearerRefresh[id] = nextRefresh
+			db.BearerRefreshed[id] = refreshed
+			return 1, nil
+		}
+	}
+	return 0, authgo.ErrBearerTokenInvalid
+}
+
+func (db *InMemory) DeleteBearerToken(ctx context.Context, id string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.BearerUsername[id]; !ok {
+		return 0, authgo.ErrBearerTokenInvalid
+	}
+	db.deleteBearerToken(id)
+	return 1, nil
+}
+
+func (db *InMemory) DeleteBearerTokens(ctx context.Context, username string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for id, u := range db.BearerUsername {
+		if u == username {
+			db.deleteBearerToken(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *InMemory) DeleteExpiredBearerTokens(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for id, refreshed := range db.BearerRefreshed {
+		if refreshed.Before(before) {
+			db.deleteBearerToken(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// deleteBearerToken deletes the grant with the given ID, the caller must hold the lock.
+func (db *InMemory) deleteBearerToken(id string) {
+	delete(db.BearerUsername, id)
+	delete(db.BearerAccess, id)
+	delete(db.BearerRefresh, id)
+	delete(db.BearerCreated, id)
+	delete(db.BearerRefreshed, id)
+}
+
+func (db *InMemory) CreatePersonalAccessToken(ctx context.Context, username string, token *authgo.PersonalAccessToken) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	id := token.ID
+	db.TokenUsername[id] = username
+	db.TokenName[id] = token.Name
+	db.TokenHash[id] = token.Hash
+	db.TokenScopes[id] = token.Scopes
+	if !token.Expires.IsZero() {
+		db.TokenExpires[id] = token.Expires
+	}
+	db.TokenCreated[id] = token.Created
+	if !token.Used.IsZero() {
+		db.TokenUsed[id] = token.Used
+	}
+	db.lastId++
+	return db.lastId, nil
+}
+
+func (db *InMemory) SelectPersonalAccessToken(ctx context.Context, hash string) (string, *authgo.PersonalAccessToken, error) {
+	db.RLock()
+	defer db.RUnlock()
+	for id, h := range db.TokenHash {
+		if h == hash {
+			return db.TokenUsername[id], db.personalAccessToken(id), nil
+		}
+	}
+	return "", nil, authgo.ErrPersonalAccessTokenInvalid
+}
+
+func (db *InMemory) SelectPersonalAccessTokens(ctx context.Context, username string) ([]*authgo.PersonalAccessToken, error) {
+	db.RLock()
+	defer db.RUnlock()
+	var tokens []*authgo.PersonalAccessToken
+	for id, u := range db.TokenUsername {
+		if u == username {
+			tokens = append(tokens, db.personalAccessToken(id))
+		}
+	}
+	// Oldest first
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Created.Before(tokens[j].Created)
+	})
+	return tokens, nil
+}
+
+// personalAccessToken returns the token with the given id, the caller must hold the lock.
+func (db *InMemory) personalAccessToken(id string) *authgo.PersonalAccessToken {
+	return &authgo.PersonalAccessToken{
+		ID:      id,
+		Name:    db.TokenName[id],
+		Hash:    db.TokenHash[id],
+		Scopes:  db.TokenScopes[id],
+		Expires: db.TokenExpires[id],
+		Created: db.TokenCreated[id],
+		Used:    db.TokenUsed[id],
+	}
+}
+
+func (db *InMemory) UpdatePersonalAccessTokenUsed(ctx context.Context, id string, used time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.TokenUsername[id]; !ok {
+		return 0, authgo.ErrPersonalAccessTokenNotFound
+	}
+	db.TokenUsed[id] = used
+	return 1, nil
+}
+
+func (db *InMemory) DeletePersonalAccessToken(ctx context.Context, username, id string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if u, ok := db.TokenUsername[id]; !ok || u != username {
+		return 0, authgo.ErrPersonalAccessTokenNotFound
+	}
+	db.deletePersonalAccessToken(id)
+	return 1, nil
+}
+
+func (db *InMemory) DeletePersonalAccessTokens(ctx context.Context, username string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for id, u := range db.TokenUsername {
+		if u == username {
+			db.deletePersonalAccessToken(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *InMemory) DeleteExpiredPersonalAccessTokens(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for id, expires := range db.TokenExpires {
+		if expires.Before(before) {
+			db.deletePersonalAccessToken(id)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// deletePersonalAccessToken deletes the token with the given id, the caller must hold the lock.
+func (db *InMemory) deletePersonalAccessToken(id string) {
+	delete(db.TokenUsername, id)
+	delete(db.TokenName, id)
+	delete(db.TokenHash, id)
+	delete(db.TokenScopes, id)
+	delete(db.TokenExpires, id)
+	delete(db.TokenCreated, id)
+	delete(db.TokenUsed, id)
+}
+
+func (db *InMemory) IsEmailVerified(ctx context.Context, email string) (bool, error) {
+	db.RLock()
+	defer db.RUnlock()
 	username, ok := db.AccountUsername[email]
 	if !ok {
 		return false, authgo.ErrEmailNotRegistered
@@ -162,7 +921,9 @@ func (db *InMemory) IsEmailVerified(email string) (bool, error) {
 	return verified, nil
 }
 
-func (db *InMemory) SetEmailVerified(email string, verified bool) (int64, error) {
+func (db *InMemory) SetEmailVerified(ctx context.Context, email string, verified bool) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.AccountUsername[email]; !ok {
 		return 0, authgo.ErrEmailNotRegistered
 	}
@@ -170,41 +931,52 @@ func (db *InMemory) SetEmailVerified(email string, verified bool) (int64, error)
 	return 1, nil
 }
 
-func (db *InMemory) DeactivateUser(username string, deleted time.Time) (int64, error) {
+func (db *InMemory) DeactivateUser(ctx context.Context, username string, deleted time.Time) (int64, error) {
 	db.Lock()
 	defer db.Unlock()
 	if _, ok := db.AccountEmail[username]; !ok {
 		return 0, authgo.ErrUsernameNotRegistered
 	}
 	db.AccountDeleted[username] = deleted
-	for t := range db.SigninToken {
-		if db.SigninUsername[t] == username {
-			db.SigninAuth[t] = false
-		}
-	}
 	return 1, nil
 }
 
-func (db *InMemory) CreateSignUpSession(token string, created time.Time) (int64, error) {
+func (db *InMemory) CreateSignUpSession(ctx context.Context, session *authgo.SignUpSession) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	token := session.Token
 	db.SignupToken[token] = true
-	db.SignupCreated[token] = created
+	db.SignupEmail[token] = session.Email
+	db.SignupUsername[token] = session.Username
+	db.SignupReferrer[token] = session.Referrer
+	db.SignupChallenge[token] = session.Challenge
+	db.SignupPasskey[token] = session.PasskeyChallenge
+	db.SignupError[token] = session.Error
+	db.SignupCreated[token] = session.Created
 	return 1, nil
 }
 
-func (db *InMemory) SelectSignUpSession(token string) (string, string, string, string, string, time.Time, error) {
+func (db *InMemory) SelectSignUpSession(ctx context.Context, token string) (*authgo.SignUpSession, error) {
+	db.RLock()
+	defer db.RUnlock()
 	if _, ok := db.SignupToken[token]; !ok {
-		return "", "", "", "", "", time.Time{}, ErrNoSuchRecord
+		return nil, ErrNoSuchRecord
 	}
-	errmsg := db.SignupError[token]
-	email := db.SignupEmail[token]
-	username := db.SignupUsername[token]
-	referrer := db.SignupReferrer[token]
-	challenge := db.SignupChallenge[token]
-	created := db.SignupCreated[token]
-	return errmsg, email, username, referrer, challenge, created, nil
+	return &authgo.SignUpSession{
+		Token:            token,
+		Email:            db.SignupEmail[token],
+		Username:         db.SignupUsername[token],
+		Referrer:         db.SignupReferrer[token],
+		Challenge:        db.SignupChallenge[token],
+		PasskeyChallenge: db.SignupPasskey[token],
+		Error:            db.SignupError[token],
+		Created:          db.SignupCreated[token],
+	}, nil
 }
 
-func (db *InMemory) UpdateSignUpSessionError(token string, errmsg string) (int64, error) {
+func (db *InMemory) UpdateSignUpSessionError(ctx context.Context, token string, errmsg string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.SignupToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -212,7 +984,9 @@ func (db *InMemory) UpdateSignUpSessionError(token string, errmsg string) (int64
 	return 1, nil
 }
 
-func (db *InMemory) UpdateSignUpSessionIdentity(token, email, username string) (int64, error) {
+func (db *InMemory) UpdateSignUpSessionIdentity(ctx context.Context, token, email, username string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.SignupToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -221,7 +995,9 @@ func (db *InMemory) UpdateSignUpSessionIdentity(token, email, username string) (
 	return 1, nil
 }
 
-func (db *InMemory) UpdateSignUpSessionReferrer(token, referrer string) (int64, error) {
+func (db *InMemory) UpdateSignUpSessionReferrer(ctx context.Context, token, referrer string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.SignupToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -229,7 +1005,9 @@ func (db *InMemory) UpdateSignUpSessionReferrer(token, referrer string) (int64,
 	return 1, nil
 }
 
-func (db *InMemory) UpdateSignUpSessionChallenge(token, challenge string) (int64, error) {
+func (db *InMemory) UpdateSignUpSessionChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.SignupToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -237,26 +1015,103 @@ func (db *InMemory) UpdateSignUpSessionChallenge(token, challenge string) (int64
 	return 1, nil
 }
 
-func (db *InMemory) CreateSignInSession(token string, username string, authenticated bool, created time.Time) (int64, error) {
+func (db *InMemory) UpdateSignUpSessionPasskeyChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.SignupToken[token]; !ok {
+		return 0, ErrNoSuchRecord
+	}
+	db.SignupPasskey[token] = challenge
+	return 1, nil
+}
+
+func (db *InMemory) DeleteExpiredSignUpSessions(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for token, created := range db.SignupCreated {
+		if created.Before(before) {
+			delete(db.SignupToken, token)
+			delete(db.SignupCreated, token)
+			delete(db.SignupEmail, token)
+			delete(db.SignupUsername, token)
+			delete(db.SignupReferrer, token)
+			delete(db.SignupChallenge, token)
+			delete(db.SignupPasskey, token)
+			delete(db.SignupError, token)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *InMemory) CreateSignInSession(ctx context.Context, session *authgo.SignInSession) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	token := session.Token
 	db.SigninToken[token] = true
-	db.SigninUsername[token] = username
-	db.SigninAuth[token] = authenticated
-	db.SigninCreated[token] = created
+	db.SigninUsername[token] = session.Username
+	db.SigninAuth[token] = session.Authenticated
+	db.SigninPending[token] = session.PendingSecondFactor
+	db.SigninPasskey[token] = session.PasskeyChallenge
+	db.SigninError[token] = session.Error
+	db.SigninAddress[token] = session.Address
+	db.SigninAgent[token] = session.UserAgent
+	db.SigninCreated[token] = session.Created
+	db.SigninActive[token] = session.Active
 	return 1, nil
 }
 
-func (db *InMemory) SelectSignInSession(token string) (string, string, time.Time, bool, error) {
+func (db *InMemory) SelectSignInSession(ctx context.Context, token string) (*authgo.SignInSession, error) {
+	db.RLock()
+	defer db.RUnlock()
 	if _, ok := db.SigninToken[token]; !ok {
-		return "", "", time.Time{}, false, ErrNoSuchRecord
+		return nil, ErrNoSuchRecord
 	}
-	errmsg := db.SigninError[token]
-	username := db.SigninUsername[token]
-	created := db.SigninCreated[token]
-	authenticated := db.SigninAuth[token]
-	return errmsg, username, created, authenticated, nil
+	return &authgo.SignInSession{
+		Token:               token,
+		Username:            db.SigninUsername[token],
+		Authenticated:       db.SigninAuth[token],
+		PendingSecondFactor: db.SigninPending[token],
+		PasskeyChallenge:    db.SigninPasskey[token],
+		Error:               db.SigninError[token],
+		Address:             db.SigninAddress[token],
+		UserAgent:           db.SigninAgent[token],
+		Created:             db.SigninCreated[token],
+		Active:              db.SigninActive[token],
+	}, nil
 }
 
-func (db *InMemory) UpdateSignInSessionError(token, errmsg string) (int64, error) {
+func (db *InMemory) SelectSignInSessions(ctx context.Context, username string) ([]*authgo.SignInSession, error) {
+	db.RLock()
+	defer db.RUnlock()
+	var sessions []*authgo.SignInSession
+	for token := range db.SigninToken {
+		if db.SigninUsername[token] != username || !db.SigninAuth[token] {
+			continue
+		}
+		sessions = append(sessions, &authgo.SignInSession{
+			Token:               token,
+			Username:            username,
+			Authenticated:       true,
+			PendingSecondFactor: db.SigninPending[token],
+			PasskeyChallenge:    db.SigninPasskey[token],
+			Error:               db.SigninError[token],
+			Address:             db.SigninAddress[token],
+			UserAgent:           db.SigninAgent[token],
+			Created:             db.SigninCreated[token],
+			Active:              db.SigninActive[token],
+		})
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].Created.Before(sessions[j].Created)
+	})
+	return sessions, nil
+}
+
+func (db *InMemory) UpdateSignInSessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.SigninToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -264,7 +1119,9 @@ func (db *InMemory) UpdateSignInSessionError(token, errmsg string) (int64, error
 	return 1, nil
 }
 
-func (db *InMemory) UpdateSignInSessionUsername(token, username string) (int64, error) {
+func (db *InMemory) UpdateSignInSessionUsername(ctx context.Context, token, username string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.SigninToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -272,7 +1129,9 @@ func (db *InMemory) UpdateSignInSessionUsername(token, username string) (int64,
 	return 1, nil
 }
 
-func (db *InMemory) UpdateSignInSessionAuthenticated(token string, authenticated bool) (int64, error) {
+func (db *InMemory) UpdateSignInSessionAuthenticated(ctx context.Context, token string, authenticated bool) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.SigninToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -280,24 +1139,102 @@ func (db *InMemory) UpdateSignInSessionAuthenticated(token string, authenticated
 	return 1, nil
 }
 
-func (db *InMemory) CreateAccountPasswordSession(token string, username string, created time.Time) (int64, error) {
+func (db *InMemory) UpdateSignInSessionPendingSecondFactor(ctx context.Context, token string, pending bool) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.SigninToken[token]; !ok {
+		return 0, ErrNoSuchRecord
+	}
+	db.SigninPending[token] = pending
+	return 1, nil
+}
+
+func (db *InMemory) UpdateSignInSessionPasskeyChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.SigninToken[token]; !ok {
+		return 0, ErrNoSuchRecord
+	}
+	db.SigninPasskey[token] = challenge
+	return 1, nil
+}
+
+func (db *InMemory) UpdateSignInSessionActivity(ctx context.Context, token, address, agent string, active time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.SigninToken[token]; !ok {
+		return 0, ErrNoSuchRecord
+	}
+	db.SigninAddress[token] = address
+	db.SigninAgent[token] = agent
+	db.SigninActive[token] = active
+	return 1, nil
+}
+
+func (db *InMemory) DeleteExpiredSignInSessions(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for token, created := range db.SigninCreated {
+		if created.Before(before) {
+			delete(db.SigninToken, token)
+			delete(db.SigninCreated, token)
+			delete(db.SigninUsername, token)
+			delete(db.SigninAuth, token)
+			delete(db.SigninPending, token)
+			delete(db.SigninPasskey, token)
+			delete(db.SigninError, token)
+			delete(db.SigninAddress, token)
+			delete(db.SigninAgent, token)
+			delete(db.SigninActive, token)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *InMemory) DeauthenticateSignInSessions(ctx context.Context, username string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for t := range db.SigninToken {
+		if db.SigninUsername[t] == username && (db.SigninAuth[t] || db.SigninPending[t]) {
+			db.SigninAuth[t] = false
+			db.SigninPending[t] = false
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *InMemory) CreateAccountPasswordSession(ctx context.Context, session *authgo.AccountPasswordSession) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	token := session.Token
 	db.ResetToken[token] = true
-	db.ResetUsername[token] = username
-	db.ResetCreated[token] = created
+	db.ResetUsername[token] = session.Username
+	db.ResetError[token] = session.Error
+	db.ResetCreated[token] = session.Created
 	return 1, nil
 }
 
-func (db *InMemory) SelectAccountPasswordSession(token string) (string, string, time.Time, error) {
+func (db *InMemory) SelectAccountPasswordSession(ctx context.Context, token string) (*authgo.AccountPasswordSession, error) {
+	db.RLock()
+	defer db.RUnlock()
 	if _, ok := db.ResetToken[token]; !ok {
-		return "", "", time.Time{}, ErrNoSuchRecord
+		return nil, ErrNoSuchRecord
 	}
-	errmsg := db.ResetError[token]
-	username := db.ResetUsername[token]
-	created := db.ResetCreated[token]
-	return errmsg, username, created, nil
+	return &authgo.AccountPasswordSession{
+		Token:    token,
+		Username: db.ResetUsername[token],
+		Error:    db.ResetError[token],
+		Created:  db.ResetCreated[token],
+	}, nil
 }
 
-func (db *InMemory) UpdateAccountPasswordSessionError(token, errmsg string) (int64, error) {
+func (db *InMemory) UpdateAccountPasswordSessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.ResetToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -305,25 +1242,54 @@ func (db *InMemory) UpdateAccountPasswordSessionError(token, errmsg string) (int
 	return 1, nil
 }
 
-func (db *InMemory) CreateAccountRecoverySession(token string, created time.Time) (int64, error) {
+func (db *InMemory) DeleteExpiredAccountPasswordSessions(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for token, created := range db.ResetCreated {
+		if created.Before(before) {
+			delete(db.ResetToken, token)
+			delete(db.ResetCreated, token)
+			delete(db.ResetUsername, token)
+			delete(db.ResetError, token)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *InMemory) CreateAccountRecoverySession(ctx context.Context, session *authgo.AccountRecoverySession) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	token := session.Token
 	db.RecoveryToken[token] = true
-	db.RecoveryCreated[token] = created
+	db.RecoveryEmail[token] = session.Email
+	db.RecoveryUsername[token] = session.Username
+	db.RecoveryChallenge[token] = session.Challenge
+	db.RecoveryError[token] = session.Error
+	db.RecoveryCreated[token] = session.Created
 	return 1, nil
 }
 
-func (db *InMemory) SelectAccountRecoverySession(token string) (string, string, string, string, time.Time, error) {
+func (db *InMemory) SelectAccountRecoverySession(ctx context.Context, token string) (*authgo.AccountRecoverySession, error) {
+	db.RLock()
+	defer db.RUnlock()
 	if _, ok := db.RecoveryToken[token]; !ok {
-		return "", "", "", "", time.Time{}, ErrNoSuchRecord
+		return nil, ErrNoSuchRecord
 	}
-	errmsg := db.RecoveryError[token]
-	email := db.RecoveryEmail[token]
-	username := db.RecoveryUsername[token]
-	challenge := db.RecoveryChallenge[token]
-	created := db.RecoveryCreated[token]
-	return errmsg, email, username, challenge, created, nil
+	return &authgo.AccountRecoverySession{
+		Token:     token,
+		Email:     db.RecoveryEmail[token],
+		Username:  db.RecoveryUsername[token],
+		Challenge: db.RecoveryChallenge[token],
+		Error:     db.RecoveryError[token],
+		Created:   db.RecoveryCreated[token],
+	}, nil
 }
 
-func (db *InMemory) UpdateAccountRecoverySessionError(token, errmsg string) (int64, error) {
+func (db *InMemory) UpdateAccountRecoverySessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.RecoveryToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -331,7 +1297,9 @@ func (db *InMemory) UpdateAccountRecoverySessionError(token, errmsg string) (int
 	return 1, nil
 }
 
-func (db *InMemory) UpdateAccountRecoverySessionEmail(token, email string) (int64, error) {
+func (db *InMemory) UpdateAccountRecoverySessionEmail(ctx context.Context, token, email string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.RecoveryToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -339,7 +1307,9 @@ func (db *InMemory) UpdateAccountRecoverySessionEmail(token, email string) (int6
 	return 1, nil
 }
 
-func (db *InMemory) UpdateAccountRecoverySessionUsername(token, username string) (int64, error) {
+func (db *InMemory) UpdateAccountRecoverySessionUsername(ctx context.Context, token, username string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.RecoveryToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
@@ -347,10 +1317,239 @@ func (db *InMemory) UpdateAccountRecoverySessionUsername(token, username string)
 	return 1, nil
 }
 
-func (db *InMemory) UpdateAccountRecoverySessionChallenge(token, challenge string) (int64, error) {
+func (db *InMemory) UpdateAccountRecoverySessionChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
 	if _, ok := db.RecoveryToken[token]; !ok {
 		return 0, ErrNoSuchRecord
 	}
 	db.RecoveryChallenge[token] = challenge
 	return 1, nil
 }
+
+func (db *InMemory) DeleteExpiredAccountRecoverySessions(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for token, created := range db.RecoveryCreated {
+		if created.Before(before) {
+			delete(db.RecoveryToken, token)
+			delete(db.RecoveryCreated, token)
+			delete(db.RecoveryEmail, token)
+			delete(db.RecoveryUsername, token)
+			delete(db.RecoveryChallenge, token)
+			delete(db.RecoveryError, token)
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (db *InMemory) CreateSignInChallenge(ctx context.Context, challenge *authgo.SignInChallenge) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	// Replace any previous challenge of the session
+	for token, session := range db.ChallengeSession {
+		if session == challenge.Session {
+			db.deleteSignInChallenge(token)
+		}
+	}
+	token := challenge.Token
+	db.ChallengeSession[token] = challenge.Session
+	db.ChallengeUsername[token] = challenge.Username
+	db.ChallengeCode[token] = challenge.Code
+	db.ChallengeCreated[token] = challenge.Created
+	return 1, nil
+}
+
+func (db *InMemory) SelectSignInChallenge(ctx context.Context, token string) (*authgo.SignInChallenge, error) {
+	db.RLock()
+	defer db.RUnlock()
+	if _, ok := db.ChallengeSession[token]; !ok {
+		return nil, authgo.ErrSignInLinkInvalid
+	}
+	return db.signInChallenge(token), nil
+}
+
+func (db *InMemory) SelectSignInChallengeForSession(ctx context.Context, session string) (*authgo.SignInChallenge, error) {
+	db.RLock()
+	defer db.RUnlock()
+	for token, s := range db.ChallengeSession {
+		if s == session {
+			return db.signInChallenge(token), nil
+		}
+	}
+	return nil, authgo.ErrSignInChallengeExpired
+}
+
+// signInChallenge returns the challenge with the given token, the caller must hold the lock.
+func (db *InMemory) signInChallenge(token string) *authgo.SignInChallenge {
+	return &authgo.SignInChallenge{
+		Token:    token,
+		Session:  db.ChallengeSession[token],
+		Username: db.ChallengeUsername[token],
+		Code:     db.ChallengeCode[token],
+		Created:  db.ChallengeCreated[token],
+	}
+}
+
+func (db *InMemory) DeleteSignInChallenge(ctx context.Context, token string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.ChallengeSession[token]; !ok {
+		return 0, authgo.ErrSignInLinkInvalid
+	}
+	db.deleteSignInChallenge(token)
+	return 1, nil
+}
+
+func (db *InMemory) DeleteExpiredSignInChallenges(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for token, created := range db.ChallengeCreated {
+		if created.Before(before) {
+			db.deleteSignInChallenge(token)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// deleteSignInChallenge deletes the challenge with the given token, the caller must hold the lock.
+func (db *InMemory) deleteSignInChallenge(token string) {
+	delete(db.ChallengeSession, token)
+	delete(db.ChallengeUsername, token)
+	delete(db.ChallengeCode, token)
+	delete(db.ChallengeCreated, token)
+}
+
+func (db *InMemory) CreateOIDCAuthorization(ctx context.Context, authorization *authgo.OIDCAuthorization) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	state := authorization.State
+	db.OIDCSession[state] = authorization.Session
+	db.OIDCProvider[state] = authorization.Provider
+	db.OIDCNonce[state] = authorization.Nonce
+	db.OIDCVerifier[state] = authorization.Verifier
+	db.OIDCNext[state] = authorization.Next
+	db.OIDCCreated[state] = authorization.Created
+	return 1, nil
+}
+
+func (db *InMemory) SelectOIDCAuthorization(ctx context.Context, state string) (*authgo.OIDCAuthorization, error) {
+	db.RLock()
+	defer db.RUnlock()
+	if _, ok := db.OIDCSession[state]; !ok {
+		return nil, authgo.ErrOIDCAuthorizationExpired
+	}
+	return &authgo.OIDCAuthorization{
+		State:    state,
+		Session:  db.OIDCSession[state],
+		Provider: db.OIDCProvider[state],
+		Nonce:    db.OIDCNonce[state],
+		Verifier: db.OIDCVerifier[state],
+		Next:     db.OIDCNext[state],
+		Created:  db.OIDCCreated[state],
+	}, nil
+}
+
+func (db *InMemory) DeleteOIDCAuthorization(ctx context.Context, state string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.OIDCSession[state]; !ok {
+		return 0, authgo.ErrOIDCAuthorizationExpired
+	}
+	db.deleteOIDCAuthorization(state)
+	return 1, nil
+}
+
+func (db *InMemory) DeleteExpiredOIDCAuthorizations(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for state, created := range db.OIDCCreated {
+		if created.Before(before) {
+			db.deleteOIDCAuthorization(state)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// deleteOIDCAuthorization deletes the authorization with the given state, the caller must hold the lock.
+func (db *InMemory) deleteOIDCAuthorization(state string) {
+	delete(db.OIDCSession, state)
+	delete(db.OIDCProvider, state)
+	delete(db.OIDCNonce, state)
+	delete(db.OIDCVerifier, state)
+	delete(db.OIDCNext, state)
+	delete(db.OIDCCreated, state)
+}
+
+func (db *InMemory) CreateOIDCCode(ctx context.Context, code *authgo.OIDCCode) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	hash := code.Hash
+	db.CodeClient[hash] = code.ClientID
+	db.CodeUsername[hash] = code.Username
+	db.CodeRedirect[hash] = code.RedirectURI
+	db.CodeScope[hash] = code.Scope
+	db.CodeNonce[hash] = code.Nonce
+	db.CodeChallenge[hash] = code.Challenge
+	db.CodeCreated[hash] = code.Created
+	return 1, nil
+}
+
+func (db *InMemory) SelectOIDCCode(ctx context.Context, hash string) (*authgo.OIDCCode, error) {
+	db.RLock()
+	defer db.RUnlock()
+	created, ok := db.CodeCreated[hash]
+	if !ok {
+		return nil, authgo.ErrOIDCGrantInvalid
+	}
+	return &authgo.OIDCCode{
+		Hash:        hash,
+		ClientID:    db.CodeClient[hash],
+		Username:    db.CodeUsername[hash],
+		RedirectURI: db.CodeRedirect[hash],
+		Scope:       db.CodeScope[hash],
+		Nonce:       db.CodeNonce[hash],
+		Challenge:   db.CodeChallenge[hash],
+		Created:     created,
+	}, nil
+}
+
+func (db *InMemory) DeleteOIDCCode(ctx context.Context, hash string) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	if _, ok := db.CodeCreated[hash]; !ok {
+		return 0, authgo.ErrOIDCGrantInvalid
+	}
+	db.deleteOIDCCode(hash)
+	return 1, nil
+}
+
+func (db *InMemory) DeleteExpiredOIDCCodes(ctx context.Context, before time.Time) (int64, error) {
+	db.Lock()
+	defer db.Unlock()
+	var count int64
+	for hash, created := range db.CodeCreated {
+		if created.Before(before) {
+			db.deleteOIDCCode(hash)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// deleteOIDCCode deletes the code with the given hash, the caller must hold the lock.
+func (db *InMemory) deleteOIDCCode(hash string) {
+	delete(db.CodeClient, hash)
+	delete(db.CodeUsername, hash)
+	delete(db.CodeRedirect, hash)
+	delete(db.CodeScope, hash)
+	delete(db.CodeNonce, hash)
+	delete(db.CodeChallenge, hash)
+	delete(db.CodeCreated, hash)
+}
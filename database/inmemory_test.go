@@ -0,0 +1,81 @@
+package database_test
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/database"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestInMemory_DeleteExpiredSessions(t *testing.T) {
+	db := database.NewInMemory()
+	auth := authgo.NewAuthenticator(db, db, authtest.NewEmailVerifier())
+	authtest.NewTestAccount(t, auth)
+	auth.SetSignUpSessionTimeout(time.Nanosecond)
+	auth.SetSignInSessionTimeout(time.Nanosecond)
+	auth.SetAccountPasswordSessionTimeout(time.Nanosecond)
+	auth.SetAccountRecoverySessionTimeout(time.Nanosecond)
+	auth.SetSignInChallengeTimeout(time.Nanosecond)
+	auth.SetPasswordlessSignIn(true)
+	const n = 100
+	for i := 0; i < n; i++ {
+		_, err := auth.NewSignUpSession(context.Background())
+		assert.Nil(t, err)
+		session, err := auth.NewSignInSession(context.Background(), authtest.TEST_USERNAME, true)
+		assert.Nil(t, err)
+		_, err = auth.NewAccountPasswordSession(context.Background(), authtest.TEST_USERNAME)
+		assert.Nil(t, err)
+		_, err = auth.NewAccountRecoverySession(context.Background())
+		assert.Nil(t, err)
+		assert.Nil(t, auth.NewSignInChallenge(context.Background(), session, authtest.TEST_USERNAME))
+	}
+	assert.Equal(t, n, len(db.SignupToken))
+	assert.Equal(t, n, len(db.SigninToken))
+	assert.Equal(t, n, len(db.ResetToken))
+	assert.Equal(t, n, len(db.RecoveryToken))
+	assert.Equal(t, n, len(db.ChallengeSession))
+	time.Sleep(time.Millisecond) // Sleep to ensure expiry
+
+	auth.StartSessionJanitor(time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	auth.StopSessionJanitor()
+
+	for name, m := range map[string]int{
+		"SignupToken":       len(db.SignupToken),
+		"SignupCreated":     len(db.SignupCreated),
+		"SignupEmail":       len(db.SignupEmail),
+		"SignupUsername":    len(db.SignupUsername),
+		"SignupReferrer":    len(db.SignupReferrer),
+		"SignupChallenge":   len(db.SignupChallenge),
+		"SignupPasskey":     len(db.SignupPasskey),
+		"SignupError":       len(db.SignupError),
+		"SigninToken":       len(db.SigninToken),
+		"SigninCreated":     len(db.SigninCreated),
+		"SigninUsername":    len(db.SigninUsername),
+		"SigninAuth":        len(db.SigninAuth),
+		"SigninPending":     len(db.SigninPending),
+		"SigninPasskey":     len(db.SigninPasskey),
+		"SigninError":       len(db.SigninError),
+		"ResetToken":        len(db.ResetToken),
+		"ResetCreated":      len(db.ResetCreated),
+		"ResetUsername":     len(db.ResetUsername),
+		"ResetError":        len(db.ResetError),
+		"RecoveryToken":     len(db.RecoveryToken),
+		"RecoveryCreated":   len(db.RecoveryCreated),
+		"RecoveryEmail":     len(db.RecoveryEmail),
+		"RecoveryUsername":  len(db.RecoveryUsername),
+		"RecoveryChallenge": len(db.RecoveryChallenge),
+		"RecoveryError":     len(db.RecoveryError),
+		"ChallengeSession":  len(db.ChallengeSession),
+		"ChallengeUsername": len(db.ChallengeUsername),
+		"ChallengeCode":     len(db.ChallengeCode),
+		"ChallengeCreated":  len(db.ChallengeCreated),
+	} {
+		assert.Equal(t, 0, m, name)
+	}
+	// Accounts are not affected
+	assert.Equal(t, 1, len(db.AccountId))
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrSchemaTooNew     = errors.New("Schema Too New")
+	ErrInvalidMigration = errors.New("Invalid Migration")
+)
+
+//go:embed migrations/*.sql
+var migrationsFS embed.FS
+
+// Migration upgrades the schema of a SQL database from Version-1 to Version.
+type Migration struct {
+	Version    int
+	Name       string
+	Statements []string
+}
+
+// Migrations returns the embedded migrations sorted by version.
+func Migrations() ([]*Migration, error) {
+	return ParseMigrations(migrationsFS, "migrations")
+}
+
+// ParseMigrations reads the migrations in the given directory.
+// Each migration is a file named <version>_<name>.sql containing statements separated by semicolons.
+// Versions must start at 1 and increase without gaps.
+func ParseMigrations(fsys fs.FS, dir string) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	var migrations []*Migration
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || path.Ext(name) != ".sql" {
+			continue
+		}
+		parts := strings.SplitN(strings.TrimSuffix(name, ".sql"), "_", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, name)
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidMigration, name)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		var statements []string
+		for _, s := range strings.Split(string(data), ";") {
+			if s = strings.TrimSpace(s); s != "" {
+				statements = append(statements, s)
+			}
+		}
+		migrations = append(migrations, &Migration{
+			Version:    version,
+			Name:       parts[1],
+			Statements: statements,
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("%w: expected version %d, got %d", ErrInvalidMigration, i+1, m.Version)
+		}
+	}
+	return migrations, nil
+}
+
+// SchemaVersion returns the version of the most recently applied migration, or 0 if none have been applied.
+func (db *SQL) SchemaVersion(ctx context.Context) (int, error) {
+	if err := db.createMigrationsTable(ctx); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := db.queryRow(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		return 0, err
+	}
+	return int(version.Int64), nil
+}
+
+// Migrate applies all pending migrations.
+func (db *SQL) Migrate(ctx context.Context) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	return db.MigrateTo(ctx, len(migrations))
+}
+
+// MigrateTo applies the pending migrations up to and including the given version.
+// ErrSchemaTooNew is returned if the database has already been migrated beyond the latest known version.
+func (db *SQL) MigrateTo(ctx context.Context, version int) error {
+	migrations, err := Migrations()
+	if err != nil {
+		return err
+	}
+	if version > len(migrations) {
+		return fmt.Errorf("%w: no such version %d", ErrInvalidMigration, version)
+	}
+	current, err := db.SchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		return fmt.Errorf("%w: version %d, latest known version %d", ErrSchemaTooNew, current, len(migrations))
+	}
+	if version <= current {
+		return nil
+	}
+	for _, m := range migrations[current:version] {
+		if err := db.apply(ctx, m); err != nil {
+			return fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		log.Println("Applied Migration", m.Version, m.Name)
+	}
+	return nil
+}
+
+func (db *SQL) createMigrationsTable(ctx context.Context) error {
+	_, err := db.db.ExecContext(ctx, db.dialect.Expand(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		applied $TIMESTAMP NOT NULL
+	)`))
+	return err
+}
+
+func (db *SQL) apply(ctx context.Context, m *Migration) error {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, s := range m.Statements {
+		if _, err := tx.ExecContext(ctx, db.dialect.Expand(s)); err != nil {
+			return err
+		}
+	}
+	if _, err := tx.ExecContext(ctx, db.dialect.Rebind(`INSERT INTO schema_migrations (version, applied) VALUES (?, ?)`), m.Version, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
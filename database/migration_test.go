@@ -0,0 +1,149 @@
+package database_test
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/database"
+	"context"
+	"database/sql"
+	"errors"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func openSQL(t *testing.T, path string) *database.SQL {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	return database.NewSQL(conn, database.SQLite)
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := database.Migrations()
+	assert.Nil(t, err)
+	assert.True(t, len(migrations) > 1)
+	for i, m := range migrations {
+		assert.Equal(t, i+1, m.Version)
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.Statements)
+	}
+}
+
+func TestParseMigrations(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		migrations, err := database.ParseMigrations(fstest.MapFS{
+			"m/0002_second.sql": {Data: []byte("CREATE INDEX a ON b (c);\n\nCREATE INDEX d ON b (e);\n")},
+			"m/0001_first.sql":  {Data: []byte("CREATE TABLE b (c INTEGER, e INTEGER);")},
+			"m/README":          {Data: []byte("ignored")},
+		}, "m")
+		assert.Nil(t, err)
+		assert.Equal(t, 2, len(migrations))
+		assert.Equal(t, "first", migrations[0].Name)
+		assert.Equal(t, []string{"CREATE TABLE b (c INTEGER, e INTEGER)"}, migrations[0].Statements)
+		assert.Equal(t, "second", migrations[1].Name)
+		assert.Equal(t, []string{"CREATE INDEX a ON b (c)", "CREATE INDEX d ON b (e)"}, migrations[1].Statements)
+	})
+	t.Run("Gap", func(t *testing.T) {
+		_, err := database.ParseMigrations(fstest.MapFS{
+			"m/0001_first.sql": {Data: []byte("SELECT 1;")},
+			"m/0003_third.sql": {Data: []byte("SELECT 1;")},
+		}, "m")
+		assert.True(t, errors.Is(err, database.ErrInvalidMigration))
+	})
+	t.Run("Unnamed", func(t *testing.T) {
+		_, err := database.ParseMigrations(fstest.MapFS{
+			"m/0001.sql": {Data: []byte("SELECT 1;")},
+		}, "m")
+		assert.True(t, errors.Is(err, database.ErrInvalidMigration))
+	})
+}
+
+func TestSQL_Migrate(t *testing.T) {
+	migrations, err := database.Migrations()
+	assert.Nil(t, err)
+	head := len(migrations)
+
+	t.Run("Empty", func(t *testing.T) {
+		db := openSQL(t, filepath.Join(t.TempDir(), "auth.db"))
+		defer db.Close()
+		version, err := db.SchemaVersion(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 0, version)
+		assert.Nil(t, db.Migrate(context.Background()))
+		version, err = db.SchemaVersion(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, head, version)
+		// Migrating again should be a no-op
+		assert.Nil(t, db.Migrate(context.Background()))
+		version, err = db.SchemaVersion(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, head, version)
+	})
+	t.Run("Upgrade", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "auth.db")
+
+		// Create a version 1 database with an account and a session
+		db := openSQL(t, path)
+		assert.Nil(t, db.MigrateTo(context.Background(), 1))
+		version, err := db.SchemaVersion(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, 1, version)
+		hash, err := authgo.GeneratePasswordHash([]byte(authtest.TEST_PASSWORD))
+		assert.Nil(t, err)
+		_, err = db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, hash, time.Now())
+		assert.Nil(t, err)
+		assert.Nil(t, db.Close())
+
+		// Sessions are inserted directly as later migrations add columns
+		conn, err := sql.Open("sqlite3", path)
+		assert.Nil(t, err)
+		_, err = conn.Exec(`INSERT INTO sign_in_sessions (token, username, authenticated, error, created) VALUES (?, ?, ?, ?, ?)`, "token", authtest.TEST_USERNAME, true, "", time.Now())
+		assert.Nil(t, err)
+		assert.Nil(t, conn.Close())
+
+		// Reopen and upgrade to head
+		db = openSQL(t, path)
+		defer db.Close()
+		assert.Nil(t, db.Migrate(context.Background()))
+		version, err = db.SchemaVersion(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, head, version)
+
+		// Existing data survives the upgrade
+		auth := authgo.NewAuthenticator(db, db, authtest.NewEmailVerifier())
+		account, err := auth.AuthenticateAccount(context.Background(), authtest.TEST_USERNAME, []byte(authtest.TEST_PASSWORD))
+		assert.Nil(t, err)
+		assert.Equal(t, authtest.TEST_EMAIL, account.Email)
+		session := auth.LookupSignInSession(context.Background(), "token")
+		require.NotNil(t, session)
+		assert.True(t, session.Authenticated)
+		assert.Equal(t, authtest.TEST_USERNAME, session.Username)
+	})
+	t.Run("TooNew", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "auth.db")
+		db := openSQL(t, path)
+		defer db.Close()
+		assert.Nil(t, db.Migrate(context.Background()))
+
+		// Simulate a migration applied by a newer release
+		conn, err := sql.Open("sqlite3", path)
+		assert.Nil(t, err)
+		defer conn.Close()
+		_, err = conn.Exec(`INSERT INTO schema_migrations (version, applied) VALUES (?, ?)`, head+1, time.Now())
+		assert.Nil(t, err)
+
+		err = db.Migrate(context.Background())
+		assert.True(t, errors.Is(err, database.ErrSchemaTooNew))
+	})
+	t.Run("NoSuchVersion", func(t *testing.T) {
+		db := openSQL(t, filepath.Join(t.TempDir(), "auth.db"))
+		defer db.Close()
+		err := db.MigrateTo(context.Background(), head+1)
+		assert.True(t, errors.Is(err, database.ErrInvalidMigration))
+	})
+}
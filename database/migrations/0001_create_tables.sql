@@ -0,0 +1,47 @@
+CREATE TABLE IF NOT EXISTS users (
+	id $PRIMARY_KEY,
+	email VARCHAR(320) NOT NULL UNIQUE,
+	username VARCHAR(100) NOT NULL UNIQUE,
+	password $BINARY NOT NULL,
+	verified BOOLEAN NOT NULL DEFAULT FALSE,
+	created $TIMESTAMP NOT NULL,
+	deleted $TIMESTAMP NULL
+);
+
+CREATE TABLE IF NOT EXISTS sign_up_sessions (
+	id $PRIMARY_KEY,
+	token VARCHAR(64) NOT NULL UNIQUE,
+	email VARCHAR(320) NOT NULL DEFAULT '',
+	username VARCHAR(100) NOT NULL DEFAULT '',
+	referrer VARCHAR(100) NOT NULL DEFAULT '',
+	challenge VARCHAR(64) NOT NULL DEFAULT '',
+	error TEXT NOT NULL,
+	created $TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS sign_in_sessions (
+	id $PRIMARY_KEY,
+	token VARCHAR(64) NOT NULL UNIQUE,
+	username VARCHAR(100) NOT NULL DEFAULT '',
+	authenticated BOOLEAN NOT NULL DEFAULT FALSE,
+	error TEXT NOT NULL,
+	created $TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS account_password_sessions (
+	id $PRIMARY_KEY,
+	token VARCHAR(64) NOT NULL UNIQUE,
+	username VARCHAR(100) NOT NULL DEFAULT '',
+	error TEXT NOT NULL,
+	created $TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS account_recovery_sessions (
+	id $PRIMARY_KEY,
+	token VARCHAR(64) NOT NULL UNIQUE,
+	email VARCHAR(320) NOT NULL DEFAULT '',
+	username VARCHAR(100) NOT NULL DEFAULT '',
+	challenge VARCHAR(64) NOT NULL DEFAULT '',
+	error TEXT NOT NULL,
+	created $TIMESTAMP NOT NULL
+);
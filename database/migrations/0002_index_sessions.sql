@@ -0,0 +1,9 @@
+CREATE INDEX sign_in_sessions_username ON sign_in_sessions (username);
+
+CREATE INDEX sign_up_sessions_created ON sign_up_sessions (created);
+
+CREATE INDEX sign_in_sessions_created ON sign_in_sessions (created);
+
+CREATE INDEX account_password_sessions_created ON account_password_sessions (created);
+
+CREATE INDEX account_recovery_sessions_created ON account_recovery_sessions (created);
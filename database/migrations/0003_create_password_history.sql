@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS password_history (
+	id $PRIMARY_KEY,
+	username VARCHAR(100) NOT NULL,
+	password $BINARY NOT NULL,
+	created $TIMESTAMP NOT NULL
+);
+
+CREATE INDEX password_history_username ON password_history (username);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS authentication_failures (
+	id $PRIMARY_KEY,
+	subject VARCHAR(400) NOT NULL UNIQUE,
+	failures INTEGER NOT NULL,
+	failed $TIMESTAMP NOT NULL
+);
+
+CREATE INDEX authentication_failures_failed ON authentication_failures (failed);
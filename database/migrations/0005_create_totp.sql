@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS totp (
+	id $PRIMARY_KEY,
+	username VARCHAR(100) NOT NULL UNIQUE,
+	secret $BINARY NULL,
+	pending $BINARY NULL,
+	counter BIGINT NOT NULL
+);
+
+ALTER TABLE sign_in_sessions ADD COLUMN pending_second_factor BOOLEAN NOT NULL DEFAULT FALSE;
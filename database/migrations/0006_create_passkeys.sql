@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS passkeys (
+	id $PRIMARY_KEY,
+	credential VARCHAR(512) NOT NULL UNIQUE,
+	username VARCHAR(100) NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	public_key $BINARY NOT NULL,
+	sign_count BIGINT NOT NULL,
+	created $TIMESTAMP NOT NULL,
+	used $TIMESTAMP NULL
+);
+
+CREATE INDEX passkeys_username ON passkeys (username);
+
+ALTER TABLE sign_up_sessions ADD COLUMN passkey_challenge VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE sign_in_sessions ADD COLUMN passkey_challenge VARCHAR(64) NOT NULL DEFAULT '';
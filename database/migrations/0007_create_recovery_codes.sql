@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS recovery_codes (
+	id $PRIMARY_KEY,
+	username VARCHAR(100) NOT NULL,
+	hash $BINARY NOT NULL,
+	created $TIMESTAMP NOT NULL,
+	used $TIMESTAMP NULL
+);
+
+CREATE INDEX recovery_codes_username ON recovery_codes (username);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS sign_in_challenges (
+	id $PRIMARY_KEY,
+	token VARCHAR(64) NOT NULL UNIQUE,
+	session VARCHAR(64) NOT NULL,
+	username VARCHAR(100) NOT NULL,
+	code VARCHAR(64) NOT NULL DEFAULT '',
+	created $TIMESTAMP NOT NULL
+);
+
+CREATE INDEX sign_in_challenges_session ON sign_in_challenges (session);
@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS oidc_identities (
+	id $PRIMARY_KEY,
+	username VARCHAR(100) NOT NULL,
+	provider VARCHAR(64) NOT NULL,
+	subject VARCHAR(255) NOT NULL,
+	created $TIMESTAMP NOT NULL,
+	UNIQUE (provider, subject)
+);
+
+CREATE INDEX oidc_identities_username ON oidc_identities (username);
+
+CREATE TABLE IF NOT EXISTS oidc_authorizations (
+	id $PRIMARY_KEY,
+	state VARCHAR(64) NOT NULL UNIQUE,
+	session VARCHAR(64) NOT NULL,
+	provider VARCHAR(64) NOT NULL,
+	nonce VARCHAR(64) NOT NULL,
+	verifier VARCHAR(64) NOT NULL,
+	next VARCHAR(2048) NOT NULL DEFAULT '',
+	created $TIMESTAMP NOT NULL
+);
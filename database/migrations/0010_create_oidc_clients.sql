@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS oidc_clients (
+	id $PRIMARY_KEY,
+	client_id VARCHAR(64) NOT NULL UNIQUE,
+	name VARCHAR(100) NOT NULL,
+	secret $BINARY NULL,
+	redirect_uris TEXT NOT NULL,
+	created $TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS oidc_signing_keys (
+	id $PRIMARY_KEY,
+	key_id VARCHAR(64) NOT NULL UNIQUE,
+	private_key TEXT NOT NULL,
+	created $TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS oidc_refresh_tokens (
+	id $PRIMARY_KEY,
+	hash VARCHAR(64) NOT NULL UNIQUE,
+	client_id VARCHAR(64) NOT NULL,
+	username VARCHAR(100) NOT NULL,
+	scope VARCHAR(255) NOT NULL,
+	created $TIMESTAMP NOT NULL
+);
+
+CREATE INDEX oidc_refresh_tokens_created ON oidc_refresh_tokens (created);
+
+CREATE TABLE IF NOT EXISTS oidc_codes (
+	id $PRIMARY_KEY,
+	hash VARCHAR(64) NOT NULL UNIQUE,
+	client_id VARCHAR(64) NOT NULL,
+	username VARCHAR(100) NOT NULL,
+	redirect_uri VARCHAR(2048) NOT NULL,
+	scope VARCHAR(255) NOT NULL,
+	nonce VARCHAR(255) NOT NULL DEFAULT '',
+	challenge VARCHAR(128) NOT NULL,
+	created $TIMESTAMP NOT NULL
+);
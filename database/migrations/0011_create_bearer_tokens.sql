@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS bearer_tokens (
+	id $PRIMARY_KEY,
+	token_id VARCHAR(64) NOT NULL UNIQUE,
+	username VARCHAR(100) NOT NULL,
+	access_hash VARCHAR(64) NOT NULL UNIQUE,
+	refresh_hash VARCHAR(64) NOT NULL UNIQUE,
+	created $TIMESTAMP NOT NULL,
+	refreshed $TIMESTAMP NOT NULL
+);
+
+CREATE INDEX bearer_tokens_username ON bearer_tokens (username);
+
+CREATE INDEX bearer_tokens_refreshed ON bearer_tokens (refreshed);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS personal_access_tokens (
+	id $PRIMARY_KEY,
+	token_id VARCHAR(64) NOT NULL UNIQUE,
+	username VARCHAR(100) NOT NULL,
+	name VARCHAR(64) NOT NULL,
+	hash VARCHAR(64) NOT NULL UNIQUE,
+	scopes VARCHAR(1024) NOT NULL,
+	expires $TIMESTAMP NULL,
+	created $TIMESTAMP NOT NULL,
+	used $TIMESTAMP NULL
+);
+
+CREATE INDEX personal_access_tokens_username ON personal_access_tokens (username);
+
+CREATE INDEX personal_access_tokens_expires ON personal_access_tokens (expires);
@@ -0,0 +1,5 @@
+ALTER TABLE sign_in_sessions ADD COLUMN address VARCHAR(64) NOT NULL DEFAULT '';
+
+ALTER TABLE sign_in_sessions ADD COLUMN user_agent VARCHAR(512) NOT NULL DEFAULT '';
+
+ALTER TABLE sign_in_sessions ADD COLUMN active $TIMESTAMP NULL;
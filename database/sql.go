@@ -0,0 +1,854 @@
+package database
+
+import (
+	"aletheiaware.com/authgo"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+)
+
+func NewSQL(db *sql.DB, dialect *Dialect) *SQL {
+	return &SQL{
+		db:      db,
+		dialect: dialect,
+	}
+}
+
+// SQL implements authgo.Database on top of a database/sql connection.
+type SQL struct {
+	db      *sql.DB
+	dialect *Dialect
+}
+
+func (db *SQL) Close() error {
+	return db.db.Close()
+}
+
+func (db *SQL) Ping() error {
+	return db.db.Ping()
+}
+
+func (db *SQL) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return db.db.QueryRowContext(ctx, db.dialect.Rebind(query), args...)
+}
+
+func (db *SQL) insert(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	if db.dialect.Returning {
+		var id int64
+		if err := db.queryRow(ctx, query+" RETURNING id", args...).Scan(&id); err != nil {
+			return 0, err
+		}
+		return id, nil
+	}
+	result, err := db.db.ExecContext(ctx, db.dialect.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+// update executes the query and returns the number of affected rows, or missing if no rows were affected.
+func (db *SQL) update(ctx context.Context, missing error, query string, args ...interface{}) (int64, error) {
+	result, err := db.db.ExecContext(ctx, db.dialect.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	if count == 0 {
+		return 0, missing
+	}
+	return count, nil
+}
+
+// exec executes the query and returns the number of affected rows.
+func (db *SQL) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	result, err := db.db.ExecContext(ctx, db.dialect.Rebind(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// exists returns true if the query selects at least one row.
+func (db *SQL) exists(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	var id int64
+	err := db.queryRow(ctx, query, args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (db *SQL) CreateUser(ctx context.Context, email, username string, password []byte, created time.Time) (int64, error) {
+	// Deactivated accounts continue to reserve their email and username
+	if ok, err := db.exists(ctx, `SELECT id FROM users WHERE email=?`, email); err != nil {
+		return 0, err
+	} else if ok {
+		return 0, authgo.ErrEmailAlreadyRegistered
+	}
+	if ok, err := db.exists(ctx, `SELECT id FROM users WHERE username=?`, username); err != nil {
+		return 0, err
+	} else if ok {
+		return 0, authgo.ErrUsernameAlreadyRegistered
+	}
+	return db.insert(ctx, `INSERT INTO users (email, username, password, created) VALUES (?, ?, ?, ?)`, email, username, password, created)
+}
+
+func (db *SQL) SelectUser(ctx context.Context, username string) (int64, string, []byte, time.Time, error) {
+	var (
+		id       int64
+		email    string
+		password []byte
+		created  time.Time
+	)
+	err := db.queryRow(ctx, `SELECT id, email, password, created FROM users WHERE username=? AND deleted IS NULL`, username).Scan(&id, &email, &password, &created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", nil, time.Time{}, authgo.ErrUsernameNotRegistered
+	}
+	if err != nil {
+		return 0, "", nil, time.Time{}, err
+	}
+	return id, email, password, created, nil
+}
+
+func (db *SQL) SelectUsernameByEmail(ctx context.Context, email string) (string, error) {
+	var username string
+	err := db.queryRow(ctx, `SELECT username FROM users WHERE email=? AND deleted IS NULL`, email).Scan(&username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", authgo.ErrEmailNotRegistered
+	}
+	if err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+func (db *SQL) ChangePassword(ctx context.Context, username string, password []byte) (int64, error) {
+	return db.update(ctx, authgo.ErrUsernameNotRegistered, `UPDATE users SET password=? WHERE username=? AND deleted IS NULL`, password, username)
+}
+
+func (db *SQL) CreatePasswordHistory(ctx context.Context, username string, password []byte, created time.Time) (int64, error) {
+	return db.insert(ctx, `INSERT INTO password_history (username, password, created) VALUES (?, ?, ?)`, username, password, created)
+}
+
+func (db *SQL) SelectPasswordHistory(ctx context.Context, username string) ([][]byte, error) {
+	rows, err := db.db.QueryContext(ctx, db.dialect.Rebind(`SELECT password FROM password_history WHERE username=? ORDER BY id DESC`), username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var passwords [][]byte
+	for rows.Next() {
+		var password []byte
+		if err := rows.Scan(&password); err != nil {
+			return nil, err
+		}
+		passwords = append(passwords, password)
+	}
+	return passwords, rows.Err()
+}
+
+func (db *SQL) PrunePasswordHistory(ctx context.Context, username string, keep int) (int64, error) {
+	// The derived table allows MySQL to use LIMIT in the subquery
+	return db.exec(ctx, `DELETE FROM password_history WHERE username=? AND id NOT IN (SELECT id FROM (SELECT id FROM password_history WHERE username=? ORDER BY id DESC LIMIT ?) AS recent)`, username, username, keep)
+}
+
+func (db *SQL) SelectAuthenticationFailures(ctx context.Context, subject string) (int64, time.Time, error) {
+	var (
+		failures int64
+		failed   time.Time
+	)
+	err := db.queryRow(ctx, `SELECT failures, failed FROM authentication_failures WHERE subject=?`, subject).Scan(&failures, &failed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	return failures, failed, nil
+}
+
+func (db *SQL) IncrementAuthenticationFailures(ctx context.Context, subject string, failed time.Time) (int64, error) {
+	// Upsert so concurrent failures for a new subject are all counted
+	query := `INSERT INTO authentication_failures (subject, failures, failed) VALUES (?, 1, ?) ON CONFLICT (subject) DO UPDATE SET failures=authentication_failures.failures+1, failed=excluded.failed`
+	if db.dialect.DuplicateKey {
+		query = `INSERT INTO authentication_failures (subject, failures, failed) VALUES (?, 1, ?) ON DUPLICATE KEY UPDATE failures=failures+1, failed=VALUES(failed)`
+	}
+	if _, err := db.exec(ctx, query, subject, failed); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (db *SQL) DeleteAuthenticationFailures(ctx context.Context, subject string) (int64, error) {
+	return db.exec(ctx, `DELETE FROM authentication_failures WHERE subject=?`, subject)
+}
+
+func (db *SQL) DeleteExpiredAuthenticationFailures(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM authentication_failures WHERE failed<?`, before)
+}
+
+func (db *SQL) SelectTOTP(ctx context.Context, username string) ([]byte, []byte, int64, error) {
+	var (
+		secret, pending []byte
+		counter         int64
+	)
+	err := db.queryRow(ctx, `SELECT secret, pending, counter FROM totp WHERE username=?`, username).Scan(&secret, &pending, &counter)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil, 0, nil
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	return secret, pending, counter, nil
+}
+
+func (db *SQL) UpdateTOTPPending(ctx context.Context, username string, pending []byte) (int64, error) {
+	count, err := db.exec(ctx, `UPDATE totp SET pending=? WHERE username=?`, pending, username)
+	if err != nil || count > 0 {
+		return count, err
+	}
+	if _, err := db.insert(ctx, `INSERT INTO totp (username, pending, counter) VALUES (?, ?, ?)`, username, pending, 0); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+func (db *SQL) ConfirmTOTP(ctx context.Context, username string, counter int64) (int64, error) {
+	return db.update(ctx, authgo.ErrTOTPNotEnrolled, `UPDATE totp SET secret=pending, pending=NULL, counter=? WHERE username=? AND pending IS NOT NULL`, counter, username)
+}
+
+func (db *SQL) UpdateTOTPCounter(ctx context.Context, username string, counter int64) (int64, error) {
+	return db.update(ctx, authgo.ErrTOTPCodeIncorrect, `UPDATE totp SET counter=? WHERE username=? AND secret IS NOT NULL AND counter<?`, counter, username, counter)
+}
+
+func (db *SQL) DeleteTOTP(ctx context.Context, username string) (int64, error) {
+	return db.exec(ctx, `DELETE FROM totp WHERE username=?`, username)
+}
+
+func (db *SQL) CreatePasskey(ctx context.Context, username string, passkey *authgo.Passkey) (int64, error) {
+	if ok, err := db.exists(ctx, `SELECT id FROM passkeys WHERE credential=?`, passkey.ID); err != nil {
+		return 0, err
+	} else if ok {
+		return 0, authgo.ErrPasskeyAlreadyRegistered
+	}
+	var used interface{}
+	if !passkey.Used.IsZero() {
+		used = passkey.Used
+	}
+	return db.insert(ctx, `INSERT INTO passkeys (credential, username, name, public_key, sign_count, created, used) VALUES (?, ?, ?, ?, ?, ?, ?)`, passkey.ID, username, passkey.Name, passkey.PublicKey, passkey.SignCount, passkey.Created, used)
+}
+
+func (db *SQL) SelectPasskey(ctx context.Context, id string) (string, *authgo.Passkey, error) {
+	var (
+		username string
+		used     sql.NullTime
+	)
+	passkey := &authgo.Passkey{
+		ID: id,
+	}
+	err := db.queryRow(ctx, `SELECT username, name, public_key, sign_count, created, used FROM passkeys WHERE credential=?`, id).Scan(&username, &passkey.Name, &passkey.PublicKey, &passkey.SignCount, &passkey.Created, &used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, authgo.ErrPasskeyNotRecognized
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	passkey.Used = used.Time
+	return username, passkey, nil
+}
+
+func (db *SQL) SelectPasskeys(ctx context.Context, username string) ([]*authgo.Passkey, error) {
+	rows, err := db.db.QueryContext(ctx, db.dialect.Rebind(`SELECT credential, name, public_key, sign_count, created, used FROM passkeys WHERE username=? ORDER BY id`), username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var passkeys []*authgo.Passkey
+	for rows.Next() {
+		var used sql.NullTime
+		passkey := &authgo.Passkey{}
+		if err := rows.Scan(&passkey.ID, &passkey.Name, &passkey.PublicKey, &passkey.SignCount, &passkey.Created, &used); err != nil {
+			return nil, err
+		}
+		passkey.Used = used.Time
+		passkeys = append(passkeys, passkey)
+	}
+	return passkeys, rows.Err()
+}
+
+func (db *SQL) UpdatePasskeySignCount(ctx context.Context, id string, count int64, used time.Time) (int64, error) {
+	return db.update(ctx, authgo.ErrPasskeyNotRecognized, `UPDATE passkeys SET sign_count=?, used=? WHERE credential=?`, count, used, id)
+}
+
+func (db *SQL) DeletePasskey(ctx context.Context, username, id string) (int64, error) {
+	return db.update(ctx, authgo.ErrPasskeyNotRecognized, `DELETE FROM passkeys WHERE username=? AND credential=?`, username, id)
+}
+
+// CreateRecoveryCodes replaces the account's recovery codes in a transaction, so the previous codes remain if the new ones cannot be stored.
+func (db *SQL) CreateRecoveryCodes(ctx context.Context, username string, hashes [][]byte, created time.Time) (int64, error) {
+	tx, err := db.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, db.dialect.Rebind(`DELETE FROM recovery_codes WHERE username=?`), username); err != nil {
+		return 0, err
+	}
+	for _, h := range hashes {
+		if _, err := tx.ExecContext(ctx, db.dialect.Rebind(`INSERT INTO recovery_codes (username, hash, created) VALUES (?, ?, ?)`), username, h, created); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int64(len(hashes)), nil
+}
+
+func (db *SQL) SelectRecoveryCodes(ctx context.Context, username string) (time.Time, []time.Time, error) {
+	rows, err := db.db.QueryContext(ctx, db.dialect.Rebind(`SELECT created, used FROM recovery_codes WHERE username=?`), username)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+	defer rows.Close()
+	var (
+		created time.Time
+		used    []time.Time
+	)
+	for rows.Next() {
+		var u sql.NullTime
+		if err := rows.Scan(&created, &u); err != nil {
+			return time.Time{}, nil, err
+		}
+		used = append(used, u.Time)
+	}
+	return created, used, rows.Err()
+}
+
+func (db *SQL) UpdateRecoveryCodeUsed(ctx context.Context, username string, hash []byte, used time.Time) (int64, error) {
+	return db.update(ctx, authgo.ErrRecoveryCodeIncorrect, `UPDATE recovery_codes SET used=? WHERE username=? AND hash=? AND used IS NULL`, used, username, hash)
+}
+
+func (db *SQL) CreateOIDCIdentity(ctx context.Context, username, provider, subject string, created time.Time) (int64, error) {
+	if ok, err := db.exists(ctx, `SELECT id FROM oidc_identities WHERE provider=? AND subject=?`, provider, subject); err != nil {
+		return 0, err
+	} else if ok {
+		return 0, authgo.ErrOIDCIdentityAlreadyLinked
+	}
+	return db.insert(ctx, `INSERT INTO oidc_identities (username, provider, subject, created) VALUES (?, ?, ?, ?)`, username, provider, subject, created)
+}
+
+func (db *SQL) SelectOIDCIdentity(ctx context.Context, provider, subject string) (string, error) {
+	var username string
+	err := db.queryRow(ctx, `SELECT username FROM oidc_identities WHERE provider=? AND subject=?`, provider, subject).Scan(&username)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", authgo.ErrOIDCIdentityNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+func (db *SQL) CreateOIDCClient(ctx context.Context, client *authgo.OIDCClient) (int64, error) {
+	// Redirect URIs cannot contain spaces
+	return db.insert(ctx, `INSERT INTO oidc_clients (client_id, name, secret, redirect_uris, created) VALUES (?, ?, ?, ?, ?)`, client.ID, client.Name, client.Secret, strings.Join(client.RedirectURIs, " "), client.Created)
+}
+
+func (db *SQL) SelectOIDCClient(ctx context.Context, id string) (*authgo.OIDCClient, error) {
+	var redirects string
+	client := &authgo.OIDCClient{
+		ID: id,
+	}
+	err := db.queryRow(ctx, `SELECT name, secret, redirect_uris, created FROM oidc_clients WHERE client_id=?`, id).Scan(&client.Name, &client.Secret, &redirects, &client.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, authgo.ErrOIDCClientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	client.RedirectURIs = strings.Fields(redirects)
+	return client, nil
+}
+
+func (db *SQL) DeleteOIDCClient(ctx context.Context, id string) (int64, error) {
+	return db.update(ctx, authgo.ErrOIDCClientNotFound, `DELETE FROM oidc_clients WHERE client_id=?`, id)
+}
+
+func (db *SQL) CreateOIDCSigningKey(ctx context.Context, key *authgo.OIDCSigningKey) (int64, error) {
+	// Keys are encoded as text as they are too large for some binary column types
+	return db.insert(ctx, `INSERT INTO oidc_signing_keys (key_id, private_key, created) VALUES (?, ?, ?)`, key.ID, base64.StdEncoding.EncodeToString(key.PrivateKey), key.Created)
+}
+
+func (db *SQL) SelectOIDCSigningKeys(ctx context.Context) ([]*authgo.OIDCSigningKey, error) {
+	rows, err := db.db.QueryContext(ctx, db.dialect.Rebind(`SELECT key_id, private_key, created FROM oidc_signing_keys ORDER BY created DESC`))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var keys []*authgo.OIDCSigningKey
+	for rows.Next() {
+		var encoded string
+		key := &authgo.OIDCSigningKey{}
+		if err := rows.Scan(&key.ID, &encoded, &key.Created); err != nil {
+			return nil, err
+		}
+		if key.PrivateKey, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+func (db *SQL) DeleteExpiredOIDCSigningKeys(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM oidc_signing_keys WHERE created<?`, before)
+}
+
+func (db *SQL) CreateOIDCRefreshToken(ctx context.Context, token *authgo.OIDCRefreshToken) (int64, error) {
+	return db.insert(ctx, `INSERT INTO oidc_refresh_tokens (hash, client_id, username, scope, created) VALUES (?, ?, ?, ?, ?)`, token.Hash, token.ClientID, token.Username, token.Scope, token.Created)
+}
+
+func (db *SQL) SelectOIDCRefreshToken(ctx context.Context, hash string) (*authgo.OIDCRefreshToken, error) {
+	token := &authgo.OIDCRefreshToken{
+		Hash: hash,
+	}
+	err := db.queryRow(ctx, `SELECT client_id, username, scope, created FROM oidc_refresh_tokens WHERE hash=?`, hash).Scan(&token.ClientID, &token.Username, &token.Scope, &token.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, authgo.ErrOIDCGrantInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (db *SQL) DeleteOIDCRefreshToken(ctx context.Context, hash string) (int64, error) {
+	return db.update(ctx, authgo.ErrOIDCGrantInvalid, `DELETE FROM oidc_refresh_tokens WHERE hash=?`, hash)
+}
+
+func (db *SQL) DeleteExpiredOIDCRefreshTokens(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM oidc_refresh_tokens WHERE created<?`, before)
+}
+
+func (db *SQL) CreateBearerToken(ctx context.Context, token *authgo.BearerToken) (int64, error) {
+	return db.insert(ctx, `INSERT INTO bearer_tokens (token_id, username, access_hash, refresh_hash, created, refreshed) VALUES (?, ?, ?, ?, ?, ?)`, token.ID, token.Username, token.AccessHash, token.RefreshHash, token.Created, token.Refreshed)
+}
+
+func (db *SQL) SelectBearerTokenByAccessHash(ctx context.Context, hash string) (*authgo.BearerToken, error) {
+	return db.selectBearerToken(ctx, `SELECT token_id, username, access_hash, refresh_hash, created, refreshed FROM bearer_tokens WHERE access_hash=?`, hash)
+}
+
+func (db *SQL) SelectBearerTokenByRefreshHash(ctx context.Context, hash string) (*authgo.BearerToken, error) {
+	return db.selectBearerToken(ctx, `SELECT token_id, username, access_hash, refresh_hash, created, refreshed FROM bearer_tokens WHERE refresh_hash=?`, hash)
+}
+
+func (db *SQL) selectBearerToken(ctx context.Context, query, hash string) (*authgo.BearerToken, error) {
+	token := &authgo.BearerToken{}
+	err := db.queryRow(ctx, query, hash).Scan(&token.ID, &token.Username, &token.AccessHash, &token.RefreshHash, &token.Created, &token.Refreshed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, authgo.ErrBearerTokenInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (db *SQL) UpdateBearerToken(ctx context.Context, refresh, nextAccess, nextRefresh string, refreshed time.Time) (int64, error) {
+	return db.update(ctx, authgo.ErrBearerTokenInvalid, `UPDATE bearer_tokens SET access_hash=?, refresh_hash=?, refreshed=? WHERE refresh_hash=?`, nextAccess, nextRefresh, refreshed, refresh)
+}
+
+func (db *SQL) DeleteBearerToken(ctx context.Context, id string) (int64, error) {
+	return db.update(ctx, authgo.ErrBearerTokenInvalid, `DELETE FROM bearer_tokens WHERE token_id=?`, id)
+}
+
+func (db *SQL) DeleteBearerTokens(ctx context.Context, username string) (int64, error) {
+	return db.exec(ctx, `DELETE FROM bearer_tokens WHERE username=?`, username)
+}
+
+func (db *SQL) DeleteExpiredBearerTokens(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM bearer_tokens WHERE refreshed<?`, before)
+}
+
+func (db *SQL) CreatePersonalAccessToken(ctx context.Context, username string, token *authgo.PersonalAccessToken) (int64, error) {
+	var expires, used interface{}
+	if !token.Expires.IsZero() {
+		expires = token.Expires
+	}
+	if !token.Used.IsZero() {
+		used = token.Used
+	}
+	return db.insert(ctx, `INSERT INTO personal_access_tokens (token_id, username, name, hash, scopes, expires, created, used) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, token.ID, username, token.Name, token.Hash, strings.Join(token.Scopes, " "), expires, token.Created, used)
+}
+
+func (db *SQL) SelectPersonalAccessToken(ctx context.Context, hash string) (string, *authgo.PersonalAccessToken, error) {
+	var (
+		username, scopes string
+		expires, used    sql.NullTime
+	)
+	token := &authgo.PersonalAccessToken{
+		Hash: hash,
+	}
+	err := db.queryRow(ctx, `SELECT token_id, username, name, scopes, expires, created, used FROM personal_access_tokens WHERE hash=?`, hash).Scan(&token.ID, &username, &token.Name, &scopes, &expires, &token.Created, &used)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, authgo.ErrPersonalAccessTokenInvalid
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	token.Scopes = strings.Fields(scopes)
+	token.Expires = expires.Time
+	token.Used = used.Time
+	return username, token, nil
+}
+
+func (db *SQL) SelectPersonalAccessTokens(ctx context.Context, username string) ([]*authgo.PersonalAccessToken, error) {
+	rows, err := db.db.QueryContext(ctx, db.dialect.Rebind(`SELECT token_id, name, hash, scopes, expires, created, used FROM personal_access_tokens WHERE username=? ORDER BY id`), username)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var tokens []*authgo.PersonalAccessToken
+	for rows.Next() {
+		var (
+			scopes        string
+			expires, used sql.NullTime
+		)
+		token := &authgo.PersonalAccessToken{}
+		if err := rows.Scan(&token.ID, &token.Name, &token.Hash, &scopes, &expires, &token.Created, &used); err != nil {
+			return nil, err
+		}
+		token.Scopes = strings.Fields(scopes)
+		token.Expires = expires.Time
+		token.Used = used.Time
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+func (db *SQL) UpdatePersonalAccessTokenUsed(ctx context.Context, id string, used time.Time) (int64, error) {
+	return db.update(ctx, authgo.ErrPersonalAccessTokenNotFound, `UPDATE personal_access_tokens SET used=? WHERE token_id=?`, used, id)
+}
+
+func (db *SQL) DeletePersonalAccessToken(ctx context.Context, username, id string) (int64, error) {
+	return db.update(ctx, authgo.ErrPersonalAccessTokenNotFound, `DELETE FROM personal_access_tokens WHERE username=? AND token_id=?`, username, id)
+}
+
+func (db *SQL) DeletePersonalAccessTokens(ctx context.Context, username string) (int64, error) {
+	return db.exec(ctx, `DELETE FROM personal_access_tokens WHERE username=?`, username)
+}
+
+func (db *SQL) DeleteExpiredPersonalAccessTokens(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM personal_access_tokens WHERE expires<?`, before)
+}
+
+func (db *SQL) IsEmailVerified(ctx context.Context, email string) (bool, error) {
+	var verified bool
+	err := db.queryRow(ctx, `SELECT verified FROM users WHERE email=? AND deleted IS NULL`, email).Scan(&verified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, authgo.ErrEmailNotRegistered
+	}
+	if err != nil {
+		return false, err
+	}
+	return verified, nil
+}
+
+func (db *SQL) SetEmailVerified(ctx context.Context, email string, verified bool) (int64, error) {
+	return db.update(ctx, authgo.ErrEmailNotRegistered, `UPDATE users SET verified=? WHERE email=?`, verified, email)
+}
+
+func (db *SQL) DeactivateUser(ctx context.Context, username string, deleted time.Time) (int64, error) {
+	return db.update(ctx, authgo.ErrUsernameNotRegistered, `UPDATE users SET deleted=? WHERE username=?`, deleted, username)
+}
+
+func (db *SQL) CreateSignUpSession(ctx context.Context, session *authgo.SignUpSession) (int64, error) {
+	return db.insert(ctx, `INSERT INTO sign_up_sessions (token, email, username, referrer, challenge, passkey_challenge, error, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, session.Token, session.Email, session.Username, session.Referrer, session.Challenge, session.PasskeyChallenge, session.Error, session.Created)
+}
+
+func (db *SQL) SelectSignUpSession(ctx context.Context, token string) (*authgo.SignUpSession, error) {
+	session := &authgo.SignUpSession{
+		Token: token,
+	}
+	err := db.queryRow(ctx, `SELECT email, username, referrer, challenge, passkey_challenge, error, created FROM sign_up_sessions WHERE token=?`, token).Scan(&session.Email, &session.Username, &session.Referrer, &session.Challenge, &session.PasskeyChallenge, &session.Error, &session.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSuchRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (db *SQL) UpdateSignUpSessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_up_sessions SET error=? WHERE token=?`, errmsg, token)
+}
+
+func (db *SQL) UpdateSignUpSessionIdentity(ctx context.Context, token, email, username string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_up_sessions SET email=?, username=? WHERE token=?`, email, username, token)
+}
+
+func (db *SQL) UpdateSignUpSessionReferrer(ctx context.Context, token, referrer string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_up_sessions SET referrer=? WHERE token=?`, referrer, token)
+}
+
+func (db *SQL) UpdateSignUpSessionChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_up_sessions SET challenge=? WHERE token=?`, challenge, token)
+}
+
+func (db *SQL) UpdateSignUpSessionPasskeyChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_up_sessions SET passkey_challenge=? WHERE token=?`, challenge, token)
+}
+
+func (db *SQL) DeleteExpiredSignUpSessions(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM sign_up_sessions WHERE created<?`, before)
+}
+
+func (db *SQL) CreateSignInSession(ctx context.Context, session *authgo.SignInSession) (int64, error) {
+	var active interface{}
+	if !session.Active.IsZero() {
+		active = session.Active
+	}
+	return db.insert(ctx, `INSERT INTO sign_in_sessions (token, username, authenticated, pending_second_factor, passkey_challenge, error, address, user_agent, created, active) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`, session.Token, session.Username, session.Authenticated, session.PendingSecondFactor, session.PasskeyChallenge, session.Error, session.Address, session.UserAgent, session.Created, active)
+}
+
+func (db *SQL) SelectSignInSession(ctx context.Context, token string) (*authgo.SignInSession, error) {
+	session := &authgo.SignInSession{
+		Token: token,
+	}
+	var active sql.NullTime
+	err := db.queryRow(ctx, `SELECT username, authenticated, pending_second_factor, passkey_challenge, error, address, user_agent, created, active FROM sign_in_sessions WHERE token=?`, token).Scan(&session.Username, &session.Authenticated, &session.PendingSecondFactor, &session.PasskeyChallenge, &session.Error, &session.Address, &session.UserAgent, &session.Created, &active)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSuchRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	session.Active = active.Time
+	return session, nil
+}
+
+func (db *SQL) SelectSignInSessions(ctx context.Context, username string) ([]*authgo.SignInSession, error) {
+	rows, err := db.db.QueryContext(ctx, db.dialect.Rebind(`SELECT token, pending_second_factor, passkey_challenge, error, address, user_agent, created, active FROM sign_in_sessions WHERE username=? AND authenticated=? ORDER BY created, id`), username, true)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []*authgo.SignInSession
+	for rows.Next() {
+		var active sql.NullTime
+		session := &authgo.SignInSession{
+			Username:      username,
+			Authenticated: true,
+		}
+		if err := rows.Scan(&session.Token, &session.PendingSecondFactor, &session.PasskeyChallenge, &session.Error, &session.Address, &session.UserAgent, &session.Created, &active); err != nil {
+			return nil, err
+		}
+		session.Active = active.Time
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+func (db *SQL) UpdateSignInSessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_in_sessions SET error=? WHERE token=?`, errmsg, token)
+}
+
+func (db *SQL) UpdateSignInSessionUsername(ctx context.Context, token, username string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_in_sessions SET username=? WHERE token=?`, username, token)
+}
+
+func (db *SQL) UpdateSignInSessionAuthenticated(ctx context.Context, token string, authenticated bool) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_in_sessions SET authenticated=? WHERE token=?`, authenticated, token)
+}
+
+func (db *SQL) UpdateSignInSessionPendingSecondFactor(ctx context.Context, token string, pending bool) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_in_sessions SET pending_second_factor=? WHERE token=?`, pending, token)
+}
+
+func (db *SQL) UpdateSignInSessionPasskeyChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_in_sessions SET passkey_challenge=? WHERE token=?`, challenge, token)
+}
+
+func (db *SQL) UpdateSignInSessionActivity(ctx context.Context, token, address, agent string, active time.Time) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE sign_in_sessions SET address=?, user_agent=?, active=? WHERE token=?`, address, agent, active, token)
+}
+
+func (db *SQL) DeleteExpiredSignInSessions(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM sign_in_sessions WHERE created<?`, before)
+}
+
+func (db *SQL) DeauthenticateSignInSessions(ctx context.Context, username string) (int64, error) {
+	return db.exec(ctx, `UPDATE sign_in_sessions SET authenticated=?, pending_second_factor=? WHERE username=? AND (authenticated=? OR pending_second_factor=?)`, false, false, username, true, true)
+}
+
+func (db *SQL) CreateAccountPasswordSession(ctx context.Context, session *authgo.AccountPasswordSession) (int64, error) {
+	return db.insert(ctx, `INSERT INTO account_password_sessions (token, username, error, created) VALUES (?, ?, ?, ?)`, session.Token, session.Username, session.Error, session.Created)
+}
+
+func (db *SQL) SelectAccountPasswordSession(ctx context.Context, token string) (*authgo.AccountPasswordSession, error) {
+	session := &authgo.AccountPasswordSession{
+		Token: token,
+	}
+	err := db.queryRow(ctx, `SELECT username, error, created FROM account_password_sessions WHERE token=?`, token).Scan(&session.Username, &session.Error, &session.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSuchRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (db *SQL) UpdateAccountPasswordSessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE account_password_sessions SET error=? WHERE token=?`, errmsg, token)
+}
+
+func (db *SQL) DeleteExpiredAccountPasswordSessions(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM account_password_sessions WHERE created<?`, before)
+}
+
+func (db *SQL) CreateAccountRecoverySession(ctx context.Context, session *authgo.AccountRecoverySession) (int64, error) {
+	return db.insert(ctx, `INSERT INTO account_recovery_sessions (token, email, username, challenge, error, created) VALUES (?, ?, ?, ?, ?, ?)`, session.Token, session.Email, session.Username, session.Challenge, session.Error, session.Created)
+}
+
+func (db *SQL) SelectAccountRecoverySession(ctx context.Context, token string) (*authgo.AccountRecoverySession, error) {
+	session := &authgo.AccountRecoverySession{
+		Token: token,
+	}
+	err := db.queryRow(ctx, `SELECT email, username, challenge, error, created FROM account_recovery_sessions WHERE token=?`, token).Scan(&session.Email, &session.Username, &session.Challenge, &session.Error, &session.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSuchRecord
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (db *SQL) UpdateAccountRecoverySessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE account_recovery_sessions SET error=? WHERE token=?`, errmsg, token)
+}
+
+func (db *SQL) UpdateAccountRecoverySessionEmail(ctx context.Context, token, email string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE account_recovery_sessions SET email=? WHERE token=?`, email, token)
+}
+
+func (db *SQL) UpdateAccountRecoverySessionUsername(ctx context.Context, token, username string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE account_recovery_sessions SET username=? WHERE token=?`, username, token)
+}
+
+func (db *SQL) UpdateAccountRecoverySessionChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	return db.update(ctx, ErrNoSuchRecord, `UPDATE account_recovery_sessions SET challenge=? WHERE token=?`, challenge, token)
+}
+
+func (db *SQL) DeleteExpiredAccountRecoverySessions(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM account_recovery_sessions WHERE created<?`, before)
+}
+
+// CreateSignInChallenge replaces any previous challenge of the session.
+func (db *SQL) CreateSignInChallenge(ctx context.Context, challenge *authgo.SignInChallenge) (int64, error) {
+	if _, err := db.exec(ctx, `DELETE FROM sign_in_challenges WHERE session=?`, challenge.Session); err != nil {
+		return 0, err
+	}
+	return db.insert(ctx, `INSERT INTO sign_in_challenges (token, session, username, code, created) VALUES (?, ?, ?, ?, ?)`, challenge.Token, challenge.Session, challenge.Username, challenge.Code, challenge.Created)
+}
+
+func (db *SQL) SelectSignInChallenge(ctx context.Context, token string) (*authgo.SignInChallenge, error) {
+	challenge := &authgo.SignInChallenge{
+		Token: token,
+	}
+	err := db.queryRow(ctx, `SELECT session, username, code, created FROM sign_in_challenges WHERE token=?`, token).Scan(&challenge.Session, &challenge.Username, &challenge.Code, &challenge.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, authgo.ErrSignInLinkInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (db *SQL) SelectSignInChallengeForSession(ctx context.Context, session string) (*authgo.SignInChallenge, error) {
+	challenge := &authgo.SignInChallenge{
+		Session: session,
+	}
+	err := db.queryRow(ctx, `SELECT token, username, code, created FROM sign_in_challenges WHERE session=? ORDER BY id DESC LIMIT 1`, session).Scan(&challenge.Token, &challenge.Username, &challenge.Code, &challenge.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, authgo.ErrSignInChallengeExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+func (db *SQL) DeleteSignInChallenge(ctx context.Context, token string) (int64, error) {
+	return db.update(ctx, authgo.ErrSignInLinkInvalid, `DELETE FROM sign_in_challenges WHERE token=?`, token)
+}
+
+func (db *SQL) DeleteExpiredSignInChallenges(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM sign_in_challenges WHERE created<?`, before)
+}
+
+func (db *SQL) CreateOIDCAuthorization(ctx context.Context, authorization *authgo.OIDCAuthorization) (int64, error) {
+	return db.insert(ctx, `INSERT INTO oidc_authorizations (state, session, provider, nonce, verifier, next, created) VALUES (?, ?, ?, ?, ?, ?, ?)`, authorization.State, authorization.Session, authorization.Provider, authorization.Nonce, authorization.Verifier, authorization.Next, authorization.Created)
+}
+
+func (db *SQL) SelectOIDCAuthorization(ctx context.Context, state string) (*authgo.OIDCAuthorization, error) {
+	authorization := &authgo.OIDCAuthorization{
+		State: state,
+	}
+	err := db.queryRow(ctx, `SELECT session, provider, nonce, verifier, next, created FROM oidc_authorizations WHERE state=?`, state).Scan(&authorization.Session, &authorization.Provider, &authorization.Nonce, &authorization.Verifier, &authorization.Next, &authorization.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, authgo.ErrOIDCAuthorizationExpired
+	}
+	if err != nil {
+		return nil, err
+	}
+	return authorization, nil
+}
+
+func (db *SQL) DeleteOIDCAuthorization(ctx context.Context, state string) (int64, error) {
+	return db.update(ctx, authgo.ErrOIDCAuthorizationExpired, `DELETE FROM oidc_authorizations WHERE state=?`, state)
+}
+
+func (db *SQL) DeleteExpiredOIDCAuthorizations(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM oidc_authorizations WHERE created<?`, before)
+}
+
+func (db *SQL) CreateOIDCCode(ctx context.Context, code *authgo.OIDCCode) (int64, error) {
+	return db.insert(ctx, `INSERT INTO oidc_codes (hash, client_id, username, redirect_uri, scope, nonce, challenge, created) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`, code.Hash, code.ClientID, code.Username, code.RedirectURI, code.Scope, code.Nonce, code.Challenge, code.Created)
+}
+
+func (db *SQL) SelectOIDCCode(ctx context.Context, hash string) (*authgo.OIDCCode, error) {
+	code := &authgo.OIDCCode{
+		Hash: hash,
+	}
+	err := db.queryRow(ctx, `SELECT client_id, username, redirect_uri, scope, nonce, challenge, created FROM oidc_codes WHERE hash=?`, hash).Scan(&code.ClientID, &code.Username, &code.RedirectURI, &code.Scope, &code.Nonce, &code.Challenge, &code.Created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, authgo.ErrOIDCGrantInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+func (db *SQL) DeleteOIDCCode(ctx context.Context, hash string) (int64, error) {
+	return db.update(ctx, authgo.ErrOIDCGrantInvalid, `DELETE FROM oidc_codes WHERE hash=?`, hash)
+}
+
+func (db *SQL) DeleteExpiredOIDCCodes(ctx context.Context, before time.Time) (int64, error) {
+	return db.exec(ctx, `DELETE FROM oidc_codes WHERE created<?`, before)
+}
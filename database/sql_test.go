@@ -0,0 +1,133 @@
+package database_test
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/database"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newSQL(t *testing.T) *database.SQL {
+	t.Helper()
+	db := openSQL(t, filepath.Join(t.TempDir(), "auth.db"))
+	assert.Nil(t, db.Migrate(context.Background()))
+	t.Cleanup(func() {
+		db.Close()
+	})
+	return db
+}
+
+func newSQLAuthenticator(t *testing.T) authgo.Authenticator {
+	t.Helper()
+	db := newSQL(t)
+	a := authgo.NewAuthenticator(db, db, authtest.NewEmailVerifier())
+	a.SetPasswordHasher(authtest.NewPasswordHasher())
+	return a
+}
+
+func TestSQL_CreateUser(t *testing.T) {
+	db := newSQL(t)
+	id, err := db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), time.Now())
+	assert.Nil(t, err)
+	assert.True(t, id > 0)
+	_, err = db.CreateUser(context.Background(), authtest.TEST_EMAIL, "bob", []byte("hash"), time.Now())
+	assert.Equal(t, authgo.ErrEmailAlreadyRegistered, err)
+	_, err = db.CreateUser(context.Background(), "bob@example.com", authtest.TEST_USERNAME, []byte("hash"), time.Now())
+	assert.Equal(t, authgo.ErrUsernameAlreadyRegistered, err)
+}
+
+func TestSQL_DeactivateUser(t *testing.T) {
+	db := newSQL(t)
+	_, err := db.DeactivateUser(context.Background(), authtest.TEST_USERNAME, time.Now())
+	assert.Equal(t, authgo.ErrUsernameNotRegistered, err)
+	_, err = db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), time.Now())
+	assert.Nil(t, err)
+	_, err = db.DeactivateUser(context.Background(), authtest.TEST_USERNAME, time.Now())
+	assert.Nil(t, err)
+	_, _, _, _, err = db.SelectUser(context.Background(), authtest.TEST_USERNAME)
+	assert.Equal(t, authgo.ErrUsernameNotRegistered, err)
+	// Email and Username remain reserved
+	_, err = db.CreateUser(context.Background(), authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), time.Now())
+	assert.Equal(t, authgo.ErrEmailAlreadyRegistered, err)
+}
+
+func TestSQL_DeauthenticateSignInSessions(t *testing.T) {
+	db := newSQL(t)
+	for _, token := range []string{"token1", "token2"} {
+		_, err := db.CreateSignInSession(context.Background(), &authgo.SignInSession{
+			Token:         token,
+			Username:      authtest.TEST_USERNAME,
+			Authenticated: true,
+			Created:       time.Now(),
+		})
+		assert.Nil(t, err)
+	}
+	count, err := db.DeauthenticateSignInSessions(context.Background(), authtest.TEST_USERNAME)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), count)
+	session, err := db.SelectSignInSession(context.Background(), "token1")
+	assert.Nil(t, err)
+	assert.False(t, session.Authenticated)
+	// Already deauthenticated
+	count, err = db.DeauthenticateSignInSessions(context.Background(), authtest.TEST_USERNAME)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestSQL_NoSuchRecord(t *testing.T) {
+	db := newSQL(t)
+	_, err := db.SelectSignUpSession(context.Background(), "token")
+	assert.Equal(t, database.ErrNoSuchRecord, err)
+	_, err = db.UpdateSignInSessionError(context.Background(), "token", "ERR")
+	assert.Equal(t, database.ErrNoSuchRecord, err)
+	_, err = db.SelectAccountPasswordSession(context.Background(), "token")
+	assert.Equal(t, database.ErrNoSuchRecord, err)
+	_, err = db.UpdateAccountRecoverySessionChallenge(context.Background(), "token", "abcd1234")
+	assert.Equal(t, database.ErrNoSuchRecord, err)
+}
+
+func TestSQL_Authenticator(t *testing.T) {
+	testAuthenticator(t, newSQLAuthenticator)
+}
+
+// TestSQL_AccountStore keeps accounts in SQL and sessions in memory.
+func TestSQL_AccountStore(t *testing.T) {
+	testAuthenticator(t, func(t *testing.T) authgo.Authenticator {
+		a := authgo.NewAuthenticator(newSQL(t), database.NewInMemory(), authtest.NewEmailVerifier())
+		a.SetPasswordHasher(authtest.NewPasswordHasher())
+		return a
+	})
+}
+
+func TestSQL_Context(t *testing.T) {
+	db := newSQL(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := db.CreateUser(ctx, authtest.TEST_EMAIL, authtest.TEST_USERNAME, []byte("hash"), time.Now())
+	assert.True(t, errors.Is(err, context.Canceled))
+	_, _, _, _, err = db.SelectUser(ctx, authtest.TEST_USERNAME)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestSQL_IncrementAuthenticationFailures(t *testing.T) {
+	db := newSQL(t)
+	first := time.Now().Add(-time.Minute)
+	last := time.Now()
+	for _, failed := range []time.Time{first, last} {
+		_, err := db.IncrementAuthenticationFailures(context.Background(), "account:"+authtest.TEST_USERNAME, failed)
+		assert.Nil(t, err)
+	}
+	failures, failed, err := db.SelectAuthenticationFailures(context.Background(), "account:"+authtest.TEST_USERNAME)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), failures)
+	assert.True(t, last.Equal(failed), failed)
+	// Other subjects are unaffected
+	failures, _, err = db.SelectAuthenticationFailures(context.Background(), "account:bob")
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), failures)
+}
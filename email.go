@@ -32,3 +32,8 @@ func ValidateEmail(email string) error {
 type EmailVerifier interface {
 	Verify(email, username string) (string, error)
 }
+
+// EmailNotifier tells users about security events on their account, such as a recovery code being used.
+type EmailNotifier interface {
+	Notify(email, username, message string) error
+}
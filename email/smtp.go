@@ -78,3 +78,62 @@ func (v SmtpEmailVerifier) Verify(email, username string) (string, error) {
 	}
 	return code, nil
 }
+
+type SmtpEmailNotifier struct {
+	Server   string
+	Identity string
+	Sender   string
+	Template *template.Template
+}
+
+func NewSmtpEmailNotifier(server, identity, sender string, template *template.Template) *SmtpEmailNotifier {
+	return &SmtpEmailNotifier{
+		Server:   server,
+		Identity: identity,
+		Sender:   sender,
+		Template: template,
+	}
+}
+
+func (n SmtpEmailNotifier) Notify(email, username, message string) error {
+	data := struct {
+		From     string
+		To       string
+		Username string
+		Message  string
+	}{
+		From:     n.Sender,
+		To:       email,
+		Username: username,
+		Message:  message,
+	}
+	return SendEmail(n.Server, n.Identity, n.Sender, email, n.Template, data)
+}
+
+// SmtpSignInLinkVerifier is an SmtpEmailVerifier which also sends passwordless sign in links.
+type SmtpSignInLinkVerifier struct {
+	SmtpEmailVerifier
+	LinkTemplate *template.Template
+}
+
+func NewSmtpSignInLinkVerifier(server, identity, sender string, template, linkTemplate *template.Template) *SmtpSignInLinkVerifier {
+	return &SmtpSignInLinkVerifier{
+		SmtpEmailVerifier: *NewSmtpEmailVerifier(server, identity, sender, template),
+		LinkTemplate:      linkTemplate,
+	}
+}
+
+func (v SmtpSignInLinkVerifier) SendSignInLink(email, username, link string) error {
+	data := struct {
+		From     string
+		To       string
+		Username string
+		Link     string
+	}{
+		From:     v.Sender,
+		To:       email,
+		Username: username,
+		Link:     link,
+	}
+	return SendEmail(v.Server, v.Identity, v.Sender, email, v.LinkTemplate, data)
+}
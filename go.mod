@@ -5,6 +5,7 @@ go 1.16
 require (
 	aletheiaware.com/cryptogo v1.2.2
 	aletheiaware.com/netgo v1.3.2
+	github.com/mattn/go-sqlite3 v1.14.16
 	github.com/stretchr/testify v1.7.0
 	golang.org/x/crypto v0.0.0-20210817164053-32db794688a5
 )
@@ -11,7 +11,7 @@ import (
 )
 
 func AttachAccountHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
-	m.Handle("/account", handler.Log(handler.Compress(Account(a, ts))))
+	m.Handle("/account", handler.Log(handler.Compress(CSRF(a, Account(a, ts)))))
 }
 
 func Account(a authgo.Authenticator, ts *template.Template) http.Handler {
@@ -22,11 +22,15 @@ func Account(a authgo.Authenticator, ts *template.Template) http.Handler {
 			return
 		}
 		data := struct {
-			Live    bool
-			Account *authgo.Account
+			Live      bool
+			CSRF      string
+			Account   *authgo.Account
+			Providers []*authgo.OIDCProvider
 		}{
-			Live:    netgo.IsLive(),
-			Account: account,
+			Live:      netgo.IsLive(),
+			CSRF:      a.CSRFToken(w, r),
+			Account:   account,
+			Providers: a.OIDCProviders(),
 		}
 		if err := ts.ExecuteTemplate(w, "account.go.html", data); err != nil {
 			log.Println(err)
@@ -11,11 +11,12 @@ import (
 )
 
 func AttachAccountDeactivateHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
-	m.Handle("/account-deactivate", handler.Log(handler.Compress(AccountDeactivate(a, ts))))
+	m.Handle("/account-deactivate", handler.Log(handler.Compress(CSRF(a, AccountDeactivate(a, ts)))))
 }
 
 func AccountDeactivate(a authgo.Authenticator, ts *template.Template) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		account := a.CurrentAccount(w, r)
 		if account == nil {
 			redirect.SignIn(w, r, r.URL.String())
@@ -23,18 +24,20 @@ func AccountDeactivate(a authgo.Authenticator, ts *template.Template) http.Handl
 		}
 		data := &AccountDeactivateData{
 			Live:    netgo.IsLive(),
+			CSRF:    a.CSRFToken(w, r),
 			Account: account,
 		}
 		switch r.Method {
 		case "GET":
 			executeAccountDeactiveTemplate(w, ts, data)
 		case "POST":
-			if err := a.DeactivateAccount(account); err != nil {
+			if err := a.DeactivateAccount(ctx, account); err != nil {
 				log.Println(err)
 				data.Error = err.Error()
 				executeAccountDeactiveTemplate(w, ts, data)
 				return
 			}
+			http.SetCookie(w, a.CookiePolicy().ExpiredCookie(authgo.COOKIE_SIGN_IN))
 
 			redirect.Index(w, r)
 		}
@@ -49,6 +52,7 @@ func executeAccountDeactiveTemplate(w http.ResponseWriter, ts *template.Template
 
 type AccountDeactivateData struct {
 	Live    bool
+	CSRF    string
 	Account *authgo.Account
 	Error   string
 }
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func AttachAccountPasskeysHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
+	m.Handle("/account-passkeys", handler.Log(handler.Compress(CSRF(a, AccountPasskeys(a, ts)))))
+	m.Handle("/account-passkeys-options", handler.Log(CSRF(a, AccountPasskeysOptions(a))))
+}
+
+// AccountPasskeysOptions starts registering another passkey for the signed in account.
+func AccountPasskeysOptions(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := r.Context()
+		account := a.CurrentAccount(w, r)
+		session := a.CurrentSignInSession(r)
+		if account == nil || session == nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		challenge, err := authgo.NewPasskeyChallenge()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		options, err := a.NewPasskeyCreationOptions(ctx, account.Email, account.Username, challenge)
+		if err != nil {
+			log.Println(err)
+			writePasskeyError(w, err)
+			return
+		}
+		if err := a.SetSignInSessionPasskeyChallenge(ctx, session.Token, challenge); err != nil {
+			log.Println(err)
+			writePasskeyError(w, err)
+			return
+		}
+		writePasskeyOptions(w, options)
+	})
+}
+
+// AccountPasskeys lists the signed in account's passkeys, and registers or deletes one on POST.
+// Passkeys sign in without the password, so registering one requires the password, and authentication code if two-factor authentication is enabled.
+func AccountPasskeys(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		account := a.CurrentAccount(w, r)
+		if account == nil {
+			redirect.SignIn(w, r, r.URL.String())
+			return
+		}
+		switch r.Method {
+		case "GET":
+			executeAccountPasskeysTemplate(w, ts, newAccountPasskeysData(w, r, a, account))
+		case "POST":
+			var err error
+			switch r.FormValue("action") {
+			case "register":
+				password := []byte(strings.TrimSpace(r.FormValue("password")))
+				code := strings.TrimSpace(r.FormValue("code"))
+				if err = reauthenticate(ctx, a, account.Username, password, code); err == nil {
+					err = registerPasskey(r, a, account, []byte(r.FormValue("credential")), strings.TrimSpace(r.FormValue("name")))
+				}
+			case "delete":
+				err = a.DeletePasskey(ctx, account.Username, r.FormValue("id"))
+			default:
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Println(err)
+				data := newAccountPasskeysData(w, r, a, account)
+				data.Error = err.Error()
+				executeAccountPasskeysTemplate(w, ts, data)
+				return
+			}
+
+			redirect.AccountPasskeys(w, r)
+		}
+	})
+}
+
+func registerPasskey(r *http.Request, a authgo.Authenticator, account *authgo.Account, credential []byte, name string) error {
+	ctx := r.Context()
+	session := a.CurrentSignInSession(r)
+	if session == nil {
+		return authgo.ErrPasskeyExpired
+	}
+	challenge := session.PasskeyChallenge
+
+	// Each challenge can only be used once
+	if err := a.SetSignInSessionPasskeyChallenge(ctx, session.Token, ""); err != nil {
+		return err
+	}
+	if challenge == "" {
+		return authgo.ErrPasskeyExpired
+	}
+
+	passkey, err := a.VerifyPasskeyCreation(challenge, credential)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		passkey.Name = name
+	}
+	return a.AddPasskey(ctx, account.Username, passkey)
+}
+
+// reauthenticate returns nil if the password, and authentication code if two-factor authentication is enabled, are those of the account.
+func reauthenticate(ctx context.Context, a authgo.Authenticator, username string, password []byte, code string) error {
+	if _, err := a.AuthenticateAccount(ctx, username, password); err != nil {
+		return err
+	}
+	if a.IsTOTPEnabled(ctx, username) {
+		return a.VerifyTOTP(ctx, username, code)
+	}
+	return nil
+}
+
+func newAccountPasskeysData(w http.ResponseWriter, r *http.Request, a authgo.Authenticator, account *authgo.Account) *AccountPasskeysData {
+	passkeys, err := a.LookupPasskeys(r.Context(), account.Username)
+	if err != nil {
+		log.Println(err)
+	}
+	return &AccountPasskeysData{
+		Live:     netgo.IsLive(),
+		CSRF:     a.CSRFToken(w, r),
+		Account:  account,
+		Passkeys: passkeys,
+		TOTP:     a.IsTOTPEnabled(r.Context(), account.Username),
+	}
+}
+
+func executeAccountPasskeysTemplate(w http.ResponseWriter, ts *template.Template, data *AccountPasskeysData) {
+	if err := ts.ExecuteTemplate(w, "account-passkeys.go.html", data); err != nil {
+		log.Println(err)
+	}
+}
+
+type AccountPasskeysData struct {
+	Live     bool
+	CSRF     string
+	Account  *authgo.Account
+	Passkeys []*authgo.Passkey
+	// TOTP is true when an authentication code is required to register a passkey.
+	TOTP  bool
+	Error string
+}
@@ -5,6 +5,7 @@ import (
 	"aletheiaware.com/authgo/redirect"
 	"aletheiaware.com/netgo"
 	"aletheiaware.com/netgo/handler"
+	"context"
 	"html/template"
 	"log"
 	"net/http"
@@ -13,18 +14,23 @@ import (
 )
 
 func AttachAccountPasswordHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
-	m.Handle("/account-password", handler.Log(handler.Compress(AccountPassword(a, ts))))
+	m.Handle("/account-password", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(AccountPassword(a, ts))))))
 }
 
 func AccountPassword(a authgo.Authenticator, ts *template.Template) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		account := a.CurrentAccount(w, r)
 		if account == nil {
 			redirect.SignIn(w, r, r.URL.String())
 			return
 		}
-		token, username, errmsg := a.CurrentAccountPasswordSession(r)
-		// log.Println("CurrentAccountPasswordSession", token, username, errmsg)
+		session := a.CurrentAccountPasswordSession(r)
+		// log.Println("CurrentAccountPasswordSession", session)
+		var token, username, errmsg string
+		if session != nil {
+			token, username, errmsg = session.Token, session.Username, session.Error
+		}
 		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
 		if err != nil {
 			log.Println(err)
@@ -35,10 +41,12 @@ func AccountPassword(a authgo.Authenticator, ts *template.Template) http.Handler
 		case "GET":
 			data := struct {
 				Live  bool
+				CSRF  string
 				Error string
 				Next  string
 			}{
 				Live:  netgo.IsLive(),
+				CSRF:  a.CSRFToken(w, r),
 				Error: errmsg,
 				Next:  next,
 			}
@@ -49,7 +57,7 @@ func AccountPassword(a authgo.Authenticator, ts *template.Template) http.Handler
 		case "POST":
 			if token == "" {
 				username = account.Username
-				t, err := a.NewAccountPasswordSession(username)
+				t, err := a.NewAccountPasswordSession(ctx, username)
 				// log.Println("NewAccountPasswordSession", t, err)
 				if err != nil {
 					log.Println(err)
@@ -63,33 +71,34 @@ func AccountPassword(a authgo.Authenticator, ts *template.Template) http.Handler
 			password := []byte(strings.TrimSpace(r.FormValue("password")))
 			confirmation := []byte(strings.TrimSpace(r.FormValue("confirmation")))
 
-			if err := accountPassword(a, username, password, confirmation); err != nil {
+			if err := accountPassword(ctx, a, username, password, confirmation); err != nil {
 				log.Println(err)
-				a.SetAccountPasswordSessionError(token, err.Error())
+				a.SetAccountPasswordSessionError(ctx, token, err.Error())
 				redirect.AccountPassword(w, r, next)
 				return
 			}
-			a.SetAccountPasswordSessionError(token, "")
+			a.SetAccountPasswordSessionError(ctx, token, "")
 
-			if next == "" {
-				redirect.Account(w, r)
-			} else {
-				http.Redirect(w, r, next, http.StatusFound)
-			}
+			redirect.Next(w, r, next)
 		}
 	})
 }
 
-func accountPassword(a authgo.Authenticator, username string, password, confirmation []byte) error {
-	// Check valid password and matching confirm
-	if err := authgo.ValidatePassword(password); err != nil {
+func accountPassword(ctx context.Context, a authgo.Authenticator, username string, password, confirmation []byte) error {
+	acc, err := a.LookupAccount(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	// Check acceptable password and matching confirm
+	if err := a.PasswordPolicy().Check(password, acc.Email, acc.Username); err != nil {
 		return err
 	}
 	if err := authgo.MatchPasswords(password, confirmation); err != nil {
 		return err
 	}
 
-	if err := a.ChangePassword(username, password); err != nil {
+	if err := a.ChangePassword(ctx, username, password); err != nil {
 		return err
 	}
 
@@ -5,6 +5,7 @@ import (
 	"aletheiaware.com/authgo/redirect"
 	"aletheiaware.com/netgo"
 	"aletheiaware.com/netgo/handler"
+	"context"
 	"html/template"
 	"log"
 	"net/http"
@@ -13,19 +14,25 @@ import (
 )
 
 func AttachAccountRecoveryHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
-	m.Handle("/account-recovery", handler.Log(handler.Compress(AccountRecovery(a, ts))))
-	m.Handle("/account-recovery-verification", handler.Log(handler.Compress(AccountRecoveryVerification(a, ts))))
+	m.Handle("/account-recovery", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(AccountRecovery(a, ts))))))
+	m.Handle("/account-recovery-verification", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(AccountRecoveryVerification(a, ts))))))
+	m.Handle("/account-recovery-code", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(AccountRecoveryCode(a, ts))))))
 }
 
 func AccountRecovery(a authgo.Authenticator, ts *template.Template) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		if a := a.CurrentAccount(w, r); a != nil {
 			// Already signed in
 			redirect.Account(w, r)
 			return
 		}
-		token, email, _, _, errmsg := a.CurrentAccountRecoverySession(r)
-		// log.Println("CurrentAccountRecoverySession", token, email, username, challenge, errmsg)
+		session := a.CurrentAccountRecoverySession(r)
+		// log.Println("CurrentAccountRecoverySession", session)
+		var token, email, errmsg string
+		if session != nil {
+			token, email, errmsg = session.Token, session.Email, session.Error
+		}
 		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
 		if err != nil {
 			log.Println(err)
@@ -36,11 +43,13 @@ func AccountRecovery(a authgo.Authenticator, ts *template.Template) http.Handler
 		case "GET":
 			data := struct {
 				Live  bool
+				CSRF  string
 				Email string
 				Error string
 				Next  string
 			}{
 				Live:  netgo.IsLive(),
+				CSRF:  a.CSRFToken(w, r),
 				Email: email,
 				Error: errmsg,
 				Next:  next,
@@ -51,7 +60,7 @@ func AccountRecovery(a authgo.Authenticator, ts *template.Template) http.Handler
 			}
 		case "POST":
 			if token == "" {
-				t, err := a.NewAccountRecoverySession()
+				t, err := a.NewAccountRecoverySession(ctx)
 				// log.Println("NewAccountRecoverySession", t, err)
 				if err != nil {
 					log.Println(err)
@@ -64,22 +73,22 @@ func AccountRecovery(a authgo.Authenticator, ts *template.Template) http.Handler
 
 			email := strings.TrimSpace(r.FormValue("email"))
 
-			if err := accountRecovery(a, token, email); err != nil {
+			if err := accountRecovery(ctx, a, token, email); err != nil {
 				log.Println(err)
-				a.SetAccountRecoverySessionError(token, err.Error())
+				a.SetAccountRecoverySessionError(ctx, token, err.Error())
 				redirect.AccountRecovery(w, r, next)
 				return
 			}
 
-			a.SetAccountRecoverySessionError(token, "")
+			a.SetAccountRecoverySessionError(ctx, token, "")
 
 			redirect.AccountRecoveryVerification(w, r, next)
 		}
 	})
 }
 
-func accountRecovery(a authgo.Authenticator, token, email string) error {
-	if err := a.SetAccountRecoverySessionEmail(token, email); err != nil {
+func accountRecovery(ctx context.Context, a authgo.Authenticator, token, email string) error {
+	if err := a.SetAccountRecoverySessionEmail(ctx, token, email); err != nil {
 		return err
 	}
 
@@ -89,12 +98,12 @@ func accountRecovery(a authgo.Authenticator, token, email string) error {
 	}
 
 	// Get username associated with email
-	username, err := a.LookupUsernameForEmail(email)
+	username, err := a.LookupUsernameForEmail(ctx, email)
 	if err != nil {
 		return err
 	}
 
-	if err := a.SetAccountRecoverySessionUsername(token, username); err != nil {
+	if err := a.SetAccountRecoverySessionUsername(ctx, token, username); err != nil {
 		return err
 	}
 
@@ -103,7 +112,7 @@ func accountRecovery(a authgo.Authenticator, token, email string) error {
 	if err != nil {
 		return err
 	}
-	if err := a.SetAccountRecoverySessionChallenge(token, code); err != nil {
+	if err := a.SetAccountRecoverySessionChallenge(ctx, token, code); err != nil {
 		return err
 	}
 	return nil
@@ -111,27 +120,31 @@ func accountRecovery(a authgo.Authenticator, token, email string) error {
 
 func AccountRecoveryVerification(a authgo.Authenticator, ts *template.Template) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, _, username, challenge, errmsg := a.CurrentAccountRecoverySession(r)
-		// log.Println("CurrentAccountRecoverySession", token, email, username, challenge, errmsg)
+		ctx := r.Context()
+		session := a.CurrentAccountRecoverySession(r)
+		// log.Println("CurrentAccountRecoverySession", session)
 		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
 		if err != nil {
 			log.Println(err)
 			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 			return
 		}
-		if token == "" {
+		if session == nil {
 			redirect.AccountRecovery(w, r, next)
 			return
 		}
+		token, username, challenge, errmsg := session.Token, session.Username, session.Challenge, session.Error
 		switch r.Method {
 		case "GET":
 			data := struct {
 				Live     bool
+				CSRF     string
 				Username string
 				Error    string
 				Next     string
 			}{
 				Live:     netgo.IsLive(),
+				CSRF:     a.CSRFToken(w, r),
 				Username: username,
 				Error:    errmsg,
 				Next:     next,
@@ -141,17 +154,26 @@ func AccountRecoveryVerification(a authgo.Authenticator, ts *template.Template)
 				return
 			}
 		case "POST":
+			if challenge == "" {
+				// No code has been sent, so there is nothing to verify
+				redirect.AccountRecovery(w, r, next)
+				return
+			}
+
 			verification := strings.TrimSpace(r.FormValue("verification"))
 
 			if err := accountRecoveryVerification(challenge, verification); err != nil {
-				a.SetAccountRecoverySessionError(token, err.Error())
+				a.SetAccountRecoverySessionError(ctx, token, err.Error())
 				redirect.AccountRecoveryVerification(w, r, next)
 				return
 			}
 
-			a.SetAccountRecoverySessionError(token, "")
+			a.SetAccountRecoverySessionError(ctx, token, "")
+
+			// A code sent by email does not replace the second factor
+			totp := a.IsTOTPEnabled(ctx, username)
 
-			token, err := a.NewSignInSession(username, true)
+			token, err := a.NewSignInSession(authgo.WithClient(r), username, !totp)
 			// log.Println("NewSignInSession", token, err)
 			if err != nil {
 				log.Println(err)
@@ -161,14 +183,135 @@ func AccountRecoveryVerification(a authgo.Authenticator, ts *template.Template)
 
 			http.SetCookie(w, a.NewSignInSessionCookie(token))
 
+			if totp {
+				if err := a.SetSignInSessionPendingSecondFactor(ctx, token, true); err != nil {
+					log.Println(err)
+					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+					return
+				}
+				redirect.SignInTOTP(w, r, accountPasswordNext(next))
+				return
+			}
+
 			redirect.AccountPassword(w, r, next)
 		}
 	})
 }
 
+// accountPasswordNext returns where to go to change the password, and then on to next.
+func accountPasswordNext(next string) string {
+	if redirect.IsAllowed(next) {
+		return "/account-password?next=" + url.QueryEscape(next)
+	}
+	return "/account-password"
+}
+
 func accountRecoveryVerification(challenge, verification string) error {
 	if verification != challenge {
 		return authgo.ErrEmailVerificationIncorrect
 	}
 	return nil
 }
+
+// AccountRecoveryCode recovers an account with one of its recovery codes instead of a code sent by email, then leads to /account-password as for email recovery.
+// Recovery codes stand in for the authenticator app, so unlike email recovery no authentication code is asked for.
+func AccountRecoveryCode(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		if a := a.CurrentAccount(w, r); a != nil {
+			// Already signed in
+			redirect.Account(w, r)
+			return
+		}
+		session := a.CurrentAccountRecoverySession(r)
+		// log.Println("CurrentAccountRecoverySession", session)
+		var token, username, errmsg string
+		if session != nil {
+			token, username, errmsg = session.Token, session.Username, session.Error
+		}
+		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			data := struct {
+				Live     bool
+				CSRF     string
+				Username string
+				Error    string
+				Next     string
+			}{
+				Live:     netgo.IsLive(),
+				CSRF:     a.CSRFToken(w, r),
+				Username: username,
+				Error:    errmsg,
+				Next:     next,
+			}
+			if err := ts.ExecuteTemplate(w, "account-recovery-code.go.html", data); err != nil {
+				log.Println(err)
+				return
+			}
+		case "POST":
+			if token == "" {
+				t, err := a.NewAccountRecoverySession(ctx)
+				// log.Println("NewAccountRecoverySession", t, err)
+				if err != nil {
+					log.Println(err)
+					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+					return
+				}
+				token = t
+				http.SetCookie(w, a.NewAccountRecoverySessionCookie(token))
+			}
+
+			username := strings.TrimSpace(r.FormValue("username"))
+			code := strings.TrimSpace(r.FormValue("code"))
+
+			if err := accountRecoveryCode(ctx, a, token, username, code); err != nil {
+				log.Println(err)
+				a.SetAccountRecoverySessionError(ctx, token, err.Error())
+				redirect.AccountRecoveryCode(w, r, next)
+				return
+			}
+
+			a.SetAccountRecoverySessionError(ctx, token, "")
+
+			token, err := a.NewSignInSession(ctx, username, true)
+			// log.Println("NewSignInSession", token, err)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+
+			http.SetCookie(w, a.NewSignInSessionCookie(token))
+
+			redirect.AccountPassword(w, r, next)
+		}
+	})
+}
+
+func accountRecoveryCode(ctx context.Context, a authgo.Authenticator, token, username, code string) error {
+	// Check valid username
+	if err := authgo.ValidateUsername(username); err != nil {
+		return err
+	}
+
+	if err := a.VerifyRecoveryCode(ctx, username, code); err != nil {
+		return err
+	}
+
+	// Deactivated accounts cannot be recovered
+	if _, err := a.LookupAccount(ctx, username); err != nil {
+		return err
+	}
+
+	// Only recorded once verified, otherwise the session could be used to recover the account by email verification
+	if err := a.SetAccountRecoverySessionUsername(ctx, token, username); err != nil {
+		return err
+	}
+	return nil
+}
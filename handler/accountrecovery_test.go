@@ -13,3 +13,7 @@ func TestAccountRecovery(t *testing.T) {
 func TestAccountRecoveryVerification(t *testing.T) {
 	handler.AccountRecoveryVerification(t, authtest.NewAuthenticator)
 }
+
+func TestAccountRecoveryCode(t *testing.T) {
+	handler.AccountRecoveryCode(t, authtest.NewAuthenticator)
+}
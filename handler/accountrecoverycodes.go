@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+func AttachAccountRecoveryCodesHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
+	m.Handle("/account-recovery-codes", handler.Log(handler.Compress(CSRF(a, AccountRecoveryCodes(a, ts)))))
+}
+
+// AccountRecoveryCodes shows how many recovery codes remain, and generates a new set on POST.
+// The new codes are shown in the response rather than after a redirect, as this is the only time they are available.
+func AccountRecoveryCodes(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		account := a.CurrentAccount(w, r)
+		if account == nil {
+			redirect.SignIn(w, r, r.URL.String())
+			return
+		}
+		switch r.Method {
+		case "GET":
+			executeAccountRecoveryCodesTemplate(w, ts, newAccountRecoveryCodesData(w, r, a, account))
+		case "POST":
+			codes, err := a.NewRecoveryCodes(ctx, account.Username)
+			data := newAccountRecoveryCodesData(w, r, a, account)
+			if err != nil {
+				log.Println(err)
+				data.Error = err.Error()
+			} else {
+				data.Codes = codes
+				w.Header().Set("Cache-Control", "no-store")
+			}
+			executeAccountRecoveryCodesTemplate(w, ts, data)
+		}
+	})
+}
+
+func newAccountRecoveryCodesData(w http.ResponseWriter, r *http.Request, a authgo.Authenticator, account *authgo.Account) *AccountRecoveryCodesData {
+	return &AccountRecoveryCodesData{
+		Live:          netgo.IsLive(),
+		CSRF:          a.CSRFToken(w, r),
+		Account:       account,
+		RecoveryCodes: a.LookupRecoveryCodes(r.Context(), account.Username),
+	}
+}
+
+func executeAccountRecoveryCodesTemplate(w http.ResponseWriter, ts *template.Template, data *AccountRecoveryCodesData) {
+	if err := ts.ExecuteTemplate(w, "account-recovery-codes.go.html", data); err != nil {
+		log.Println(err)
+	}
+}
+
+type AccountRecoveryCodesData struct {
+	Live          bool
+	CSRF          string
+	Account       *authgo.Account
+	RecoveryCodes *authgo.RecoveryCodes
+	// Codes holds newly generated codes, which are only shown once.
+	Codes []string
+	Error string
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+func AttachAccountSessionsHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
+	m.Handle("/account-sessions", handler.Log(handler.Compress(CSRF(a, AccountSessions(a, ts)))))
+}
+
+// AccountSessions lists where the signed in account is signed in, and revokes one or all other sessions on POST.
+func AccountSessions(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		account := a.CurrentAccount(w, r)
+		if account == nil {
+			redirect.SignIn(w, r, r.URL.String())
+			return
+		}
+		current := a.CurrentSignInSession(r)
+		if current == nil || !current.Authenticated {
+			// The session was refreshed, so reload with the new cookie
+			redirect.AccountSessions(w, r)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			executeAccountSessionsTemplate(w, ts, newAccountSessionsData(w, r, a, account, current))
+		case "POST":
+			var err error
+			switch r.FormValue("action") {
+			case "revoke":
+				err = a.RevokeSignInSession(ctx, account.Username, r.FormValue("id"))
+			case "revoke-others":
+				err = a.RevokeOtherSignInSessions(ctx, account.Username, current.Token)
+			default:
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Println(err)
+				data := newAccountSessionsData(w, r, a, account, current)
+				data.Error = err.Error()
+				executeAccountSessionsTemplate(w, ts, data)
+				return
+			}
+			redirect.AccountSessions(w, r)
+		}
+	})
+}
+
+func newAccountSessionsData(w http.ResponseWriter, r *http.Request, a authgo.Authenticator, account *authgo.Account, current *authgo.SignInSession) *AccountSessionsData {
+	sessions, err := a.LookupSignInSessions(r.Context(), account.Username)
+	if err != nil {
+		log.Println(err)
+	}
+	return &AccountSessionsData{
+		Live:     netgo.IsLive(),
+		CSRF:     a.CSRFToken(w, r),
+		Account:  account,
+		Sessions: sessions,
+		Current:  current.ID(),
+	}
+}
+
+func executeAccountSessionsTemplate(w http.ResponseWriter, ts *template.Template, data *AccountSessionsData) {
+	if err := ts.ExecuteTemplate(w, "account-sessions.go.html", data); err != nil {
+		log.Println(err)
+	}
+}
+
+type AccountSessionsData struct {
+	Live     bool
+	CSRF     string
+	Account  *authgo.Account
+	Sessions []*authgo.SignInSession
+	// Current is the ID of the session making the request.
+	Current string
+	Error   string
+}
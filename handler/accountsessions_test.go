@@ -0,0 +1,11 @@
+package handler_test
+
+import (
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/authtest/handler"
+	"testing"
+)
+
+func TestAccountSessions(t *testing.T) {
+	handler.AccountSessions(t, authtest.NewAuthenticator)
+}
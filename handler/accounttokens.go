@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func AttachAccountTokensHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
+	m.Handle("/account-tokens", handler.Log(handler.Compress(CSRF(a, AccountTokens(a, ts)))))
+}
+
+// AccountTokens lists the personal access tokens of the signed in account, and creates or deletes them on POST.
+// A new token is shown in the response rather than after a redirect, as this is the only time it is available.
+func AccountTokens(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		account := a.CurrentAccount(w, r)
+		if account == nil {
+			redirect.SignIn(w, r, r.URL.String())
+			return
+		}
+		switch r.Method {
+		case "GET":
+			executeAccountTokensTemplate(w, ts, newAccountTokensData(w, r, a, account))
+		case "POST":
+			switch r.FormValue("action") {
+			case "create":
+				token, err := createPersonalAccessToken(r, a, account)
+				data := newAccountTokensData(w, r, a, account)
+				if err != nil {
+					log.Println(err)
+					data.Error = err.Error()
+				} else {
+					data.NewToken = token
+					w.Header().Set("Cache-Control", "no-store")
+				}
+				executeAccountTokensTemplate(w, ts, data)
+			case "delete":
+				if err := a.DeletePersonalAccessToken(ctx, account.Username, r.FormValue("id")); err != nil {
+					log.Println(err)
+					data := newAccountTokensData(w, r, a, account)
+					data.Error = err.Error()
+					executeAccountTokensTemplate(w, ts, data)
+					return
+				}
+				redirect.AccountTokens(w, r)
+			default:
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			}
+		}
+	})
+}
+
+// createPersonalAccessToken creates a token with the name, scopes, and number of days until expiry submitted in the form, which never expires if the number of days is empty.
+func createPersonalAccessToken(r *http.Request, a authgo.Authenticator, account *authgo.Account) (string, error) {
+	if err := r.ParseForm(); err != nil {
+		return "", err
+	}
+	var expires time.Time
+	if days := strings.TrimSpace(r.FormValue("expires")); days != "" {
+		d, err := strconv.Atoi(days)
+		if err != nil || d <= 0 {
+			return "", authgo.ErrPersonalAccessTokenExpiryInvalid
+		}
+		expires = time.Now().AddDate(0, 0, d)
+	}
+	return a.NewPersonalAccessToken(r.Context(), account.Username, strings.TrimSpace(r.FormValue("name")), r.Form["scope"], expires)
+}
+
+func newAccountTokensData(w http.ResponseWriter, r *http.Request, a authgo.Authenticator, account *authgo.Account) *AccountTokensData {
+	tokens, err := a.LookupPersonalAccessTokens(r.Context(), account.Username)
+	if err != nil {
+		log.Println(err)
+	}
+	return &AccountTokensData{
+		Live:    netgo.IsLive(),
+		CSRF:    a.CSRFToken(w, r),
+		Account: account,
+		Tokens:  tokens,
+		Scopes:  a.PersonalAccessTokenScopes(),
+	}
+}
+
+func executeAccountTokensTemplate(w http.ResponseWriter, ts *template.Template, data *AccountTokensData) {
+	if err := ts.ExecuteTemplate(w, "account-tokens.go.html", data); err != nil {
+		log.Println(err)
+	}
+}
+
+type AccountTokensData struct {
+	Live    bool
+	CSRF    string
+	Account *authgo.Account
+	Tokens  []*authgo.PersonalAccessToken
+	// Scopes are the scopes a token can be created with.
+	Scopes []string
+	// NewToken holds a newly created token, which is only shown once.
+	NewToken string
+	Error    string
+}
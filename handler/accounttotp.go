@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"strings"
+)
+
+func AttachAccountTOTPHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
+	m.Handle("/account-totp", handler.Log(handler.Compress(CSRF(a, AccountTOTP(a, ts)))))
+}
+
+func AccountTOTP(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		account := a.CurrentAccount(w, r)
+		if account == nil {
+			redirect.SignIn(w, r, r.URL.String())
+			return
+		}
+		switch r.Method {
+		case "GET":
+			executeAccountTOTPTemplate(w, ts, newAccountTOTPData(w, r, a, account))
+		case "POST":
+			code := strings.TrimSpace(r.FormValue("code"))
+			var err error
+			switch r.FormValue("action") {
+			case "enroll":
+				_, err = a.NewTOTP(ctx, account.Username)
+			case "confirm":
+				// Require the current app, or a recovery code, so a stolen session cannot replace the second factor
+				if a.IsTOTPEnabled(ctx, account.Username) {
+					err = verifyCurrentSecondFactor(ctx, a, account.Username, strings.TrimSpace(r.FormValue("current")))
+				}
+				if err == nil {
+					err = a.ConfirmTOTP(ctx, account.Username, code)
+				}
+			case "disable":
+				// Require a current code so a stolen session cannot remove the second factor
+				if err = a.VerifyTOTP(ctx, account.Username, code); err == nil {
+					err = a.DisableTOTP(ctx, account.Username)
+				}
+			default:
+				http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+				return
+			}
+			if err != nil {
+				log.Println(err)
+				data := newAccountTOTPData(w, r, a, account)
+				data.Error = err.Error()
+				executeAccountTOTPTemplate(w, ts, data)
+				return
+			}
+
+			redirect.AccountTOTP(w, r)
+		}
+	})
+}
+
+// verifyCurrentSecondFactor returns nil if the code is from the account's authenticator app, or is one of its recovery codes.
+func verifyCurrentSecondFactor(ctx context.Context, a authgo.Authenticator, username, code string) error {
+	if len(code) > authgo.TOTP_DIGITS {
+		return a.VerifyRecoveryCode(ctx, username, code)
+	}
+	return a.VerifyTOTP(ctx, username, code)
+}
+
+func newAccountTOTPData(w http.ResponseWriter, r *http.Request, a authgo.Authenticator, account *authgo.Account) *AccountTOTPData {
+	ctx := r.Context()
+	return &AccountTOTPData{
+		Live:       netgo.IsLive(),
+		CSRF:       a.CSRFToken(w, r),
+		Account:    account,
+		Enabled:    a.IsTOTPEnabled(ctx, account.Username),
+		Enrollment: a.LookupTOTPEnrollment(ctx, account.Username),
+	}
+}
+
+func executeAccountTOTPTemplate(w http.ResponseWriter, ts *template.Template, data *AccountTOTPData) {
+	if err := ts.ExecuteTemplate(w, "account-totp.go.html", data); err != nil {
+		log.Println(err)
+	}
+}
+
+type AccountTOTPData struct {
+	Live       bool
+	CSRF       string
+	Account    *authgo.Account
+	Enabled    bool
+	Enrollment *authgo.TOTPEnrollment
+	Error      string
+}
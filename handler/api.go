@@ -0,0 +1,635 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/netgo/handler"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// MAXIMUM_API_REQUEST_SIZE limits the size of JSON API request bodies.
+const MAXIMUM_API_REQUEST_SIZE = 1 << 16
+
+var (
+	errAPIRequestInvalid       = errors.New("Invalid Request")
+	errAPIMethodNotAllowed     = errors.New("Method Not Allowed")
+	errAPIMediaTypeUnsupported = errors.New("Content Type Must Be application/json")
+	errAPISignInRequired       = errors.New("Sign In Required")
+	errAPIAlreadySignedIn      = errors.New("Already Signed In")
+	errAPISessionNotFound      = errors.New("Session Not Found Or Expired")
+	errAPITOTPRequired         = errors.New("Authentication Code Required")
+	errAPIEmailNotVerified     = errors.New("Email Not Verified")
+	errAPIInternal             = errors.New("Internal Server Error")
+)
+
+// apiErrors maps errors to the status and code the JSON API responds with, any other error is an internal_error.
+var apiErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{errAPIRequestInvalid, http.StatusBadRequest, "invalid_request"},
+	{errAPIMethodNotAllowed, http.StatusMethodNotAllowed, "method_not_allowed"},
+	{errAPIMediaTypeUnsupported, http.StatusUnsupportedMediaType, "unsupported_media_type"},
+	{errAPISignInRequired, http.StatusUnauthorized, "sign_in_required"},
+	{errAPIAlreadySignedIn, http.StatusConflict, "already_signed_in"},
+	{errAPISessionNotFound, http.StatusBadRequest, "session_not_found"},
+	{errAPITOTPRequired, http.StatusUnauthorized, "totp_required"},
+	{errAPIEmailNotVerified, http.StatusForbidden, "email_not_verified"},
+	{authgo.ErrCredentialsIncorrect, http.StatusUnauthorized, "credentials_incorrect"},
+	{authgo.ErrAccountLocked, http.StatusTooManyRequests, "account_locked"},
+	{authgo.ErrTOTPCodeIncorrect, http.StatusUnauthorized, "totp_code_incorrect"},
+	{authgo.ErrEmailTooLong, http.StatusBadRequest, "email_too_long"},
+	{authgo.ErrEmailInvalid, http.StatusBadRequest, "email_invalid"},
+	{authgo.ErrEmailVerificationIncorrect, http.StatusBadRequest, "verification_incorrect"},
+	{authgo.ErrEmailAlreadyRegistered, http.StatusConflict, "email_already_registered"},
+	{authgo.ErrUsernameAlreadyRegistered, http.StatusConflict, "username_already_registered"},
+	{authgo.ErrEmailNotRegistered, http.StatusNotFound, "email_not_registered"},
+	{authgo.ErrUsernameNotRegistered, http.StatusNotFound, "username_not_registered"},
+	{authgo.ErrInvalidReferrer, http.StatusBadRequest, "referrer_invalid"},
+	{authgo.ErrUsernameTooShort, http.StatusBadRequest, "username_too_short"},
+	{authgo.ErrUsernameTooLong, http.StatusBadRequest, "username_too_long"},
+	{authgo.ErrUsernameInvalid, http.StatusBadRequest, "username_invalid"},
+	{authgo.ErrPasswordTooShort, http.StatusBadRequest, "password_too_short"},
+	{authgo.ErrPasswordTooLong, http.StatusBadRequest, "password_too_long"},
+	{authgo.ErrPasswordsDoNotMatch, http.StatusBadRequest, "passwords_do_not_match"},
+	{authgo.ErrPasswordPreviouslyUsed, http.StatusBadRequest, "password_previously_used"},
+	{authgo.ErrPasswordTooWeak, http.StatusBadRequest, "password_too_weak"},
+	{authgo.ErrPasswordContainsUsername, http.StatusBadRequest, "password_contains_username"},
+	{authgo.ErrPasswordContainsEmail, http.StatusBadRequest, "password_contains_email"},
+	{authgo.ErrPasswordCompromised, http.StatusBadRequest, "password_compromised"},
+	{errAPIInternal, http.StatusInternalServerError, "internal_error"},
+}
+
+// APIOperation describes an endpoint of the JSON API, from which both its handler is attached and its part of the OpenAPI document is generated.
+type APIOperation struct {
+	ID       string
+	Method   string
+	Path     string
+	Summary  string
+	Request  []*APIField
+	Status   int
+	Response []*APIField
+	// Errors lists the errors the operation responds with, in addition to those of every operation.
+	Errors  []error
+	Handler func(authgo.Authenticator) http.Handler
+}
+
+// APIField describes a property of a JSON request or response body.
+type APIField struct {
+	Name        string
+	Type        string
+	Format      string
+	Enum        []string
+	Required    bool
+	Description string
+}
+
+// APIAccount is the JSON representation of an account.
+type APIAccount struct {
+	Username string    `json:"username"`
+	Email    string    `json:"email"`
+	Created  time.Time `json:"created"`
+}
+
+// APIError is the JSON representation of an error, where Code is stable for clients to act on and Message is for showing to users.
+type APIError struct {
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+var apiAccountFields = []*APIField{
+	{Name: "username", Type: "string", Required: true},
+	{Name: "email", Type: "string", Format: "email", Required: true},
+	{Name: "created", Type: "string", Format: "date-time", Required: true},
+}
+
+// APIOperations returns the operations of the JSON API, which uses the same cookie sessions as the HTML handlers.
+func APIOperations() []*APIOperation {
+	return []*APIOperation{
+		{
+			ID:      "signUp",
+			Method:  http.MethodPost,
+			Path:    "/api/sign-up",
+			Summary: "Registers a new account and sends a verification code to its email address.",
+			Request: []*APIField{
+				{Name: "email", Type: "string", Format: "email", Required: true},
+				{Name: "username", Type: "string", Required: true},
+				{Name: "password", Type: "string", Format: "password", Required: true},
+				{Name: "confirmation", Type: "string", Format: "password", Required: true},
+				{Name: "referrer", Type: "string"},
+			},
+			Status: http.StatusAccepted,
+			Errors: []error{
+				errAPIAlreadySignedIn,
+				authgo.ErrEmailTooLong,
+				authgo.ErrEmailInvalid,
+				authgo.ErrEmailAlreadyRegistered,
+				authgo.ErrUsernameTooShort,
+				authgo.ErrUsernameTooLong,
+				authgo.ErrUsernameInvalid,
+				authgo.ErrUsernameAlreadyRegistered,
+				authgo.ErrInvalidReferrer,
+				authgo.ErrPasswordTooShort,
+				authgo.ErrPasswordTooLong,
+				authgo.ErrPasswordsDoNotMatch,
+				authgo.ErrPasswordTooWeak,
+				authgo.ErrPasswordContainsUsername,
+				authgo.ErrPasswordContainsEmail,
+				authgo.ErrPasswordCompromised,
+			},
+			Handler: APISignUp,
+		},
+		{
+			ID:      "signUpVerification",
+			Method:  http.MethodPost,
+			Path:    "/api/sign-up-verification",
+			Summary: "Verifies the email address with the code sent to it, and signs in.",
+			Request: []*APIField{
+				{Name: "verification", Type: "string", Required: true},
+			},
+			Status:   http.StatusOK,
+			Response: apiAccountFields,
+			Errors: []error{
+				errAPISessionNotFound,
+				authgo.ErrEmailVerificationIncorrect,
+			},
+			Handler: APISignUpVerification,
+		},
+		{
+			ID:      "signIn",
+			Method:  http.MethodPost,
+			Path:    "/api/sign-in",
+			Summary: "Signs in with a username and password, and authentication code if two-factor authentication is enabled.",
+			Request: []*APIField{
+				{Name: "username", Type: "string", Required: true},
+				{Name: "password", Type: "string", Format: "password", Required: true},
+				{Name: "code", Type: "string", Description: "Required if two-factor authentication is enabled."},
+			},
+			Status:   http.StatusOK,
+			Response: apiAccountFields,
+			Errors: []error{
+				authgo.ErrCredentialsIncorrect,
+				authgo.ErrAccountLocked,
+				errAPITOTPRequired,
+				authgo.ErrTOTPCodeIncorrect,
+				errAPIEmailNotVerified,
+			},
+			Handler: APISignIn,
+		},
+		{
+			ID:      "signOut",
+			Method:  http.MethodPost,
+			Path:    "/api/sign-out",
+			Summary: "Signs out.",
+			Status:  http.StatusNoContent,
+			Errors: []error{
+				errAPISignInRequired,
+			},
+			Handler: APISignOut,
+		},
+		{
+			ID:       "whoAmI",
+			Method:   http.MethodGet,
+			Path:     "/api/whoami",
+			Summary:  "Returns the signed in account.",
+			Status:   http.StatusOK,
+			Response: apiAccountFields,
+			Errors: []error{
+				errAPISignInRequired,
+			},
+			Handler: APIWhoAmI,
+		},
+		{
+			ID:      "accountPassword",
+			Method:  http.MethodPost,
+			Path:    "/api/account-password",
+			Summary: "Changes the signed in account's password.",
+			Request: []*APIField{
+				{Name: "password", Type: "string", Format: "password", Required: true},
+				{Name: "confirmation", Type: "string", Format: "password", Required: true},
+			},
+			Status: http.StatusNoContent,
+			Errors: []error{
+				errAPISignInRequired,
+				authgo.ErrPasswordTooShort,
+				authgo.ErrPasswordTooLong,
+				authgo.ErrPasswordsDoNotMatch,
+				authgo.ErrPasswordPreviouslyUsed,
+				authgo.ErrPasswordTooWeak,
+				authgo.ErrPasswordContainsUsername,
+				authgo.ErrPasswordContainsEmail,
+				authgo.ErrPasswordCompromised,
+			},
+			Handler: APIAccountPassword,
+		},
+		{
+			ID:      "accountRecovery",
+			Method:  http.MethodPost,
+			Path:    "/api/account-recovery",
+			Summary: "Sends a verification code to the email address of the account to recover.",
+			Request: []*APIField{
+				{Name: "email", Type: "string", Format: "email", Required: true},
+			},
+			Status: http.StatusAccepted,
+			Errors: []error{
+				errAPIAlreadySignedIn,
+				authgo.ErrEmailTooLong,
+				authgo.ErrEmailInvalid,
+				authgo.ErrEmailNotRegistered,
+			},
+			Handler: APIAccountRecovery,
+		},
+		{
+			ID:      "accountRecoveryVerification",
+			Method:  http.MethodPost,
+			Path:    "/api/account-recovery-verification",
+			Summary: "Verifies the code sent to the email address of the account to recover, and authentication code if two-factor authentication is enabled, and signs in so a new password can be chosen.",
+			Request: []*APIField{
+				{Name: "verification", Type: "string", Required: true},
+				{Name: "code", Type: "string", Description: "Required if two-factor authentication is enabled."},
+			},
+			Status:   http.StatusOK,
+			Response: apiAccountFields,
+			Errors: []error{
+				errAPISessionNotFound,
+				authgo.ErrEmailVerificationIncorrect,
+				authgo.ErrAccountLocked,
+				errAPITOTPRequired,
+				authgo.ErrTOTPCodeIncorrect,
+			},
+			Handler: APIAccountRecoveryVerification,
+		},
+		{
+			ID:      "accountDeactivate",
+			Method:  http.MethodPost,
+			Path:    "/api/account-deactivate",
+			Summary: "Deactivates the signed in account.",
+			Status:  http.StatusNoContent,
+			Errors: []error{
+				errAPISignInRequired,
+			},
+			Handler: APIAccountDeactivate,
+		},
+	}
+}
+
+// AttachAPIHandlers serves the JSON API and its OpenAPI document at /api/openapi.json.
+// Origins in the CORS configuration, which may be nil, can call the API from other websites.
+func AttachAPIHandlers(m *http.ServeMux, a authgo.Authenticator, c *CORS) {
+	operations := APIOperations()
+	for _, o := range operations {
+		m.Handle(o.Path, handler.Log(c.Handler(apiMethod(o.Method, a.SealedSessionHandler(o.Handler(a))))))
+	}
+	m.Handle("/api/openapi.json", handler.Log(c.Handler(apiMethod(http.MethodGet, OpenAPI(operations)))))
+}
+
+func APISignUp(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var request struct {
+			Email        string `json:"email"`
+			Username     string `json:"username"`
+			Password     string `json:"password"`
+			Confirmation string `json:"confirmation"`
+			Referrer     string `json:"referrer"`
+		}
+		if err := decodeAPIRequest(w, r, &request); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if account := a.CurrentAccount(w, r); account != nil {
+			writeAPIError(w, errAPIAlreadySignedIn)
+			return
+		}
+		token, err := a.NewSignUpSession(ctx)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		email := strings.TrimSpace(request.Email)
+		username := strings.TrimSpace(request.Username)
+		password := []byte(strings.TrimSpace(request.Password))
+		confirmation := []byte(strings.TrimSpace(request.Confirmation))
+		referrer := strings.TrimSpace(request.Referrer)
+		if err := signUp(ctx, a, token, email, username, password, confirmation, referrer); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		http.SetCookie(w, a.NewSignUpSessionCookie(token))
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func APISignUpVerification(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var request struct {
+			Verification string `json:"verification"`
+		}
+		if err := decodeAPIRequest(w, r, &request); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		session := a.CurrentSignUpSession(r)
+		if session == nil || session.Challenge == "" {
+			writeAPIError(w, errAPISessionNotFound)
+			return
+		}
+		if err := signUpVerification(session.Challenge, strings.TrimSpace(request.Verification)); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if err := a.SetEmailVerified(ctx, session.Email, true); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		apiSignIn(w, r, a, session.Username)
+	})
+}
+
+// APISignIn signs in with the username and password, and authentication code if two-factor authentication is enabled.
+// If the account's email address is not verified, a code is sent to it and the client must use /api/sign-up-verification to complete signing in.
+func APISignIn(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		var request struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		if err := decodeAPIRequest(w, r, &request); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		username := strings.TrimSpace(request.Username)
+		password := []byte(strings.TrimSpace(request.Password))
+		account, err := a.AuthenticateAccount(ctx, username, password)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if err := apiSecondFactor(ctx, a, account.Username, strings.TrimSpace(request.Code)); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if !a.IsEmailVerified(ctx, account.Email) {
+			token, err := a.NewSignUpSession(ctx)
+			if err != nil {
+				writeAPIError(w, err)
+				return
+			}
+			if err := a.SetSignUpSessionIdentity(ctx, token, account.Email, account.Username); err != nil {
+				writeAPIError(w, err)
+				return
+			}
+			code, err := a.EmailVerifier().Verify(account.Email, account.Username)
+			if err != nil {
+				writeAPIError(w, err)
+				return
+			}
+			if err := a.SetSignUpSessionChallenge(ctx, token, code); err != nil {
+				writeAPIError(w, err)
+				return
+			}
+			http.SetCookie(w, a.NewSignUpSessionCookie(token))
+			writeAPIError(w, errAPIEmailNotVerified)
+			return
+		}
+		apiSignIn(w, r, a, account.Username)
+	})
+}
+
+func APISignOut(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := decodeAPIRequest(w, r, nil); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		session := a.CurrentSignInSession(r)
+		if session == nil || session.Username == "" || !session.Authenticated {
+			writeAPIError(w, errAPISignInRequired)
+			return
+		}
+		if err := a.SetSignInSessionAuthenticated(r.Context(), session.Token, false); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		http.SetCookie(w, a.CookiePolicy().ExpiredCookie(authgo.COOKIE_SIGN_IN))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func APIWhoAmI(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		account := a.CurrentAccount(w, r)
+		if account == nil {
+			writeAPIError(w, errAPISignInRequired)
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, apiAccount(account))
+	})
+}
+
+func APIAccountPassword(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			Password     string `json:"password"`
+			Confirmation string `json:"confirmation"`
+		}
+		if err := decodeAPIRequest(w, r, &request); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		account := a.CurrentAccount(w, r)
+		if account == nil {
+			writeAPIError(w, errAPISignInRequired)
+			return
+		}
+		password := []byte(strings.TrimSpace(request.Password))
+		confirmation := []byte(strings.TrimSpace(request.Confirmation))
+		if err := accountPassword(r.Context(), a, account.Username, password, confirmation); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func APIAccountRecovery(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		var request struct {
+			Email string `json:"email"`
+		}
+		if err := decodeAPIRequest(w, r, &request); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if account := a.CurrentAccount(w, r); account != nil {
+			writeAPIError(w, errAPIAlreadySignedIn)
+			return
+		}
+		token, err := a.NewAccountRecoverySession(ctx)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		if err := accountRecovery(ctx, a, token, strings.TrimSpace(request.Email)); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		http.SetCookie(w, a.NewAccountRecoverySessionCookie(token))
+		w.WriteHeader(http.StatusAccepted)
+	})
+}
+
+func APIAccountRecoveryVerification(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		var request struct {
+			Verification string `json:"verification"`
+			Code         string `json:"code"`
+		}
+		if err := decodeAPIRequest(w, r, &request); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		session := a.CurrentAccountRecoverySession(r)
+		if session == nil || session.Challenge == "" {
+			writeAPIError(w, errAPISessionNotFound)
+			return
+		}
+		if err := accountRecoveryVerification(session.Challenge, strings.TrimSpace(request.Verification)); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		// A code sent by email does not replace the second factor
+		if err := apiSecondFactor(ctx, a, session.Username, strings.TrimSpace(request.Code)); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		apiSignIn(w, r, a, session.Username)
+	})
+}
+
+func APIAccountDeactivate(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := decodeAPIRequest(w, r, nil); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		account := a.CurrentAccount(w, r)
+		if account == nil {
+			writeAPIError(w, errAPISignInRequired)
+			return
+		}
+		if err := a.DeactivateAccount(r.Context(), account); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		http.SetCookie(w, a.CookiePolicy().ExpiredCookie(authgo.COOKIE_SIGN_IN))
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// apiSecondFactor verifies the authentication code if two-factor authentication is enabled for the account.
+func apiSecondFactor(ctx context.Context, a authgo.Authenticator, username, code string) error {
+	if !a.IsTOTPEnabled(ctx, username) {
+		return nil
+	}
+	if code == "" {
+		return errAPITOTPRequired
+	}
+	return a.VerifyTOTP(ctx, username, code)
+}
+
+// apiSignIn replaces any current sign in session with a new authenticated one, and responds with the account.
+func apiSignIn(w http.ResponseWriter, r *http.Request, a authgo.Authenticator, username string) {
+	ctx := r.Context()
+	account, err := a.LookupAccount(ctx, username)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	if session := a.CurrentSignInSession(r); session != nil {
+		if err := a.SetSignInSessionAuthenticated(ctx, session.Token, false); err != nil {
+			log.Println(err)
+		}
+	}
+	token, err := a.NewSignInSession(authgo.WithClient(r), account.Username, true)
+	if err != nil {
+		writeAPIError(w, err)
+		return
+	}
+	http.SetCookie(w, a.NewSignInSessionCookie(token))
+	writeAPIJSON(w, http.StatusOK, apiAccount(account))
+}
+
+func apiAccount(account *authgo.Account) *APIAccount {
+	return &APIAccount{
+		Username: account.Username,
+		Email:    account.Email,
+		Created:  account.Created,
+	}
+}
+
+// apiMethod only calls the handler for requests with the method.
+func apiMethod(method string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			writeAPIError(w, errAPIMethodNotAllowed)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// decodeAPIRequest decodes the JSON request body into v, which is nil for operations without a request body.
+// Requiring the application/json content type means browsers only send requests from other websites once CORS allows them.
+func decodeAPIRequest(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return errAPIMediaTypeUnsupported
+	}
+	if v == nil {
+		return nil
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, MAXIMUM_API_REQUEST_SIZE)).Decode(v); err != nil && err != io.EOF {
+		log.Println(err)
+		return errAPIRequestInvalid
+	}
+	return nil
+}
+
+// writeAPIError writes the error as JSON with the status and code it maps to, internal errors are logged but not revealed.
+func writeAPIError(w http.ResponseWriter, err error) {
+	for _, e := range apiErrors {
+		if errors.Is(err, e.err) {
+			writeAPIJSON(w, e.status, &APIError{
+				Code:    e.code,
+				Message: e.err.Error(),
+			})
+			return
+		}
+	}
+	log.Println(err)
+	writeAPIError(w, errAPIInternal)
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+func AttachAuthorizeHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
+	m.Handle("/authorize", handler.Log(handler.Compress(CSRF(a, Authorize(a, ts)))))
+}
+
+// Authorize asks the signed in user whether a client may sign them in, and sends them back to the client with an authorization code if they allow it.
+func Authorize(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if err := r.ParseForm(); err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+			return
+		}
+		request := authgo.ParseOIDCAuthorizationRequest(r.Form)
+		client, err := a.VerifyOIDCAuthorizationRequest(ctx, request)
+		switch {
+		case errors.Is(err, authgo.ErrOIDCIssuerNotConfigured):
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		case errors.Is(err, authgo.ErrOIDCClientNotFound),
+			errors.Is(err, authgo.ErrOIDCRedirectURIInvalid):
+			// The redirect URI cannot be trusted, so the error is shown to the user instead
+			log.Println(err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Println(err)
+			redirectAuthorizeError(w, r, request, err)
+			return
+		}
+		account := a.CurrentAccount(w, r)
+		if account == nil {
+			redirect.SignIn(w, r, "/authorize?"+request.Values().Encode())
+			return
+		}
+		switch r.Method {
+		case "GET":
+			data := &AuthorizeData{
+				Live:    netgo.IsLive(),
+				CSRF:    a.CSRFToken(w, r),
+				Account: account,
+				Client:  client,
+				Scopes:  request.Scopes(),
+				Request: request,
+			}
+			if err := ts.ExecuteTemplate(w, "authorize.go.html", data); err != nil {
+				log.Println(err)
+				return
+			}
+		case "POST":
+			if r.FormValue("consent") != "allow" {
+				redirectAuthorizeError(w, r, request, authgo.ErrOIDCAuthorizationDenied)
+				return
+			}
+			code, err := a.NewOIDCCode(ctx, account.Username, request)
+			if err != nil {
+				log.Println(err)
+				redirectAuthorizeError(w, r, request, err)
+				return
+			}
+			http.Redirect(w, r, request.Response(url.Values{
+				"code": {code},
+			}), http.StatusFound)
+		}
+	})
+}
+
+// redirectAuthorizeError sends the user back to the client with the error.
+func redirectAuthorizeError(w http.ResponseWriter, r *http.Request, request *authgo.OIDCAuthorizationRequest, err error) {
+	values := url.Values{
+		"error": {authgo.OIDCErrorCode(err)},
+	}
+	if values.Get("error") != "server_error" {
+		// Internal errors are not shared with clients
+		values.Set("error_description", err.Error())
+	}
+	http.Redirect(w, r, request.Response(values), http.StatusFound)
+}
+
+type AuthorizeData struct {
+	Live    bool
+	CSRF    string
+	Account *authgo.Account
+	Client  *authgo.OIDCClient
+	// Scopes are the scopes the client is asking for.
+	Scopes []string
+	// Request is the client's request, which the form must submit again.
+	Request *authgo.OIDCAuthorizationRequest
+}
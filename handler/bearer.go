@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/netgo/handler"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// AttachBearerTokenHandler serves the endpoints API clients use to get, refresh, and revoke bearer tokens.
+func AttachBearerTokenHandler(m *http.ServeMux, a authgo.Authenticator) {
+	m.Handle("/bearer-token", handler.Log(BearerToken(a)))
+	m.Handle("/bearer-token-revoke", handler.Log(BearerTokenRevoke(a)))
+}
+
+// BearerToken issues tokens in exchange for a username and password (and authentication code if two-factor authentication is enabled), or a refresh token.
+func BearerToken(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		if r.Method != "POST" {
+			writeBearerError(w, http.StatusMethodNotAllowed, "invalid_request", errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+			return
+		}
+		var (
+			tokens *authgo.BearerTokens
+			err    error
+		)
+		switch r.PostFormValue("grant_type") {
+		case "password":
+			username := strings.TrimSpace(r.PostFormValue("username"))
+			password := []byte(r.PostFormValue("password"))
+			var account *authgo.Account
+			account, err = a.AuthenticateAccount(ctx, username, password)
+			if err == nil && a.IsTOTPEnabled(ctx, account.Username) {
+				err = a.VerifyTOTP(ctx, account.Username, strings.TrimSpace(r.PostFormValue("code")))
+			}
+			if err == nil {
+				tokens, err = a.NewBearerTokens(ctx, account.Username)
+			}
+		case "refresh_token":
+			tokens, err = a.RefreshBearerTokens(ctx, r.PostFormValue("refresh_token"))
+		default:
+			writeBearerError(w, http.StatusBadRequest, "unsupported_grant_type", errors.New("Grant Type Not Supported"))
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			writeBearerError(w, http.StatusBadRequest, "invalid_grant", err)
+			return
+		}
+		writeBearerJSON(w, http.StatusOK, tokens)
+	})
+}
+
+// BearerTokenRevoke revokes the access token or refresh token, and the other token issued with it, or a personal access token.
+// Unknown tokens are ignored, see https://www.rfc-editor.org/rfc/rfc7009#section-2.2.
+func BearerTokenRevoke(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			writeBearerError(w, http.StatusMethodNotAllowed, "invalid_request", errors.New(http.StatusText(http.StatusMethodNotAllowed)))
+			return
+		}
+		if err := a.RevokeBearerToken(r.Context(), r.PostFormValue("token")); err != nil && !errors.Is(err, authgo.ErrBearerTokenInvalid) {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// RequireBearerAccount only calls the handler for requests with a valid access token or personal access token, whose account can be found with authgo.BearerAccount.
+func RequireBearerAccount(a authgo.Authenticator, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		token := authgo.BearerTokenFromRequest(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		var (
+			account *authgo.Account
+			pat     *authgo.PersonalAccessToken
+			err     error
+		)
+		if authgo.IsPersonalAccessToken(token) {
+			account, pat, err = a.AuthenticatePersonalAccessToken(ctx, token)
+		} else {
+			account, err = a.LookupBearerAccount(ctx, token)
+		}
+		if err != nil {
+			log.Println(err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		ctx = authgo.WithBearerAccount(ctx, account)
+		if pat != nil {
+			ctx = authgo.WithPersonalAccessToken(ctx, pat)
+		}
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// RequireBearerScope is like RequireBearerAccount, but personal access tokens must also have been created with the scope.
+func RequireBearerScope(a authgo.Authenticator, scope string, h http.Handler) http.Handler {
+	return RequireBearerAccount(a, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authgo.HasBearerScope(r.Context(), scope) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="insufficient_scope", scope="`+scope+`"`)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	}))
+}
+
+func writeBearerError(w http.ResponseWriter, status int, code string, err error) {
+	writeBearerJSON(w, status, struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}{
+		Error:            code,
+		ErrorDescription: err.Error(),
+	})
+}
+
+func writeBearerJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,11 @@
+package handler_test
+
+import (
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/authtest/handler"
+	"testing"
+)
+
+func TestBearerToken(t *testing.T) {
+	handler.BearerToken(t, authtest.NewAuthenticator)
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// CORS configures which other websites may call the JSON API from their scripts, with the user's cookies.
+type CORS struct {
+	// Origins lists the allowed origins, such as "https://app.example.com".
+	Origins []string
+	// MaxAge is how long browsers may cache the result of a preflight request.
+	MaxAge time.Duration
+}
+
+// IsAllowed returns true if the origin is allowed.
+func (c *CORS) IsAllowed(origin string) bool {
+	if c == nil || origin == "" {
+		return false
+	}
+	for _, o := range c.Origins {
+		if o == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// Handler adds the CORS headers to responses for allowed origins, and answers their preflight requests.
+// Requests from other origins are still passed to the handler, but browsers do not let their scripts read the response.
+func (c *CORS) Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+		origin := r.Header.Get("Origin")
+		allowed := c.IsAllowed(origin)
+		if allowed {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if !allowed {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			if c.MaxAge > 0 {
+				w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
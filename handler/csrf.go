@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"log"
+	"net/http"
+)
+
+// CSRF only calls the handler for POST requests from the website's own pages, which carry the token from authgo.Authenticator.CSRFToken.
+func CSRF(a authgo.Authenticator, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			if err := a.VerifyCSRF(r); err != nil {
+				log.Println(err)
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,11 @@
+package handler_test
+
+import (
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/authtest/handler"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	handler.CSRF(t, authtest.NewAuthenticator)
+}
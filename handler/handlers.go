@@ -11,7 +11,18 @@ func AttachAuthenticationHandlers(m *http.ServeMux, a authgo.Authenticator, ts *
 	AttachAccountPasswordHandler(m, a, ts)
 	AttachAccountRecoveryHandler(m, a, ts)
 	AttachAccountDeactivateHandler(m, a, ts)
+	AttachAccountTOTPHandler(m, a, ts)
+	AttachAccountRecoveryCodesHandler(m, a, ts)
+	AttachAccountPasskeysHandler(m, a, ts)
+	AttachAccountTokensHandler(m, a, ts)
+	AttachAccountSessionsHandler(m, a, ts)
 	AttachSignInHandler(m, a, ts)
+	AttachSignInPasskeyHandler(m, a, ts)
+	AttachSignInOIDCHandler(m, a)
 	AttachSignOutHandler(m, a, ts)
 	AttachSignUpHandler(m, a, ts)
+	AttachSignUpPasskeyHandler(m, a, ts)
+	AttachAuthorizeHandler(m, a, ts)
+	AttachOpenIDHandler(m, a)
+	AttachBearerTokenHandler(m, a)
 }
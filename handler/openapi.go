@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const OPENAPI_VERSION = "3.0.3"
+
+// OpenAPI serves the OpenAPI document describing the operations.
+func OpenAPI(operations []*APIOperation) http.Handler {
+	document := OpenAPIDocument(operations)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeAPIJSON(w, http.StatusOK, document)
+	})
+}
+
+// OpenAPIDocument generates an OpenAPI document from the operations, for encoding as JSON.
+func OpenAPIDocument(operations []*APIOperation) map[string]interface{} {
+	var codes []string
+	for _, e := range apiErrors {
+		codes = append(codes, e.code)
+	}
+	errorSchema := openAPISchema([]*APIField{
+		{Name: "error", Type: "string", Enum: codes, Required: true, Description: "A code for clients to act on."},
+		{Name: "message", Type: "string", Required: true, Description: "A message for showing to users."},
+	})
+	paths := make(map[string]interface{})
+	for _, o := range operations {
+		operation := map[string]interface{}{
+			"operationId": o.ID,
+			"summary":     o.Summary,
+			"responses":   openAPIResponses(o),
+		}
+		if o.Request != nil {
+			operation["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  openAPIContent(openAPISchema(o.Request)),
+			}
+		}
+		path, ok := paths[o.Path].(map[string]interface{})
+		if !ok {
+			path = make(map[string]interface{})
+			paths[o.Path] = path
+		}
+		path[strings.ToLower(o.Method)] = operation
+	}
+	return map[string]interface{}{
+		"openapi": OPENAPI_VERSION,
+		"info": map[string]interface{}{
+			"title":   "Authentication API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": map[string]interface{}{
+				"Error": errorSchema,
+			},
+		},
+	}
+}
+
+func openAPIResponses(o *APIOperation) map[string]interface{} {
+	success := map[string]interface{}{
+		"description": http.StatusText(o.Status),
+	}
+	if o.Response != nil {
+		success["content"] = openAPIContent(openAPISchema(o.Response))
+	}
+	responses := map[string]interface{}{
+		strconv.Itoa(o.Status): success,
+	}
+	errs := []error{errAPIMethodNotAllowed}
+	if o.Method == http.MethodPost {
+		errs = append(errs, errAPIMediaTypeUnsupported)
+		if o.Request != nil {
+			errs = append(errs, errAPIRequestInvalid)
+		}
+	}
+	errs = append(errs, o.Errors...)
+	errs = append(errs, errAPIInternal)
+	codes := make(map[int][]string)
+	for _, err := range errs {
+		for _, e := range apiErrors {
+			if e.err == err {
+				codes[e.status] = append(codes[e.status], e.code)
+				break
+			}
+		}
+	}
+	for status, cs := range codes {
+		sort.Strings(cs)
+		responses[strconv.Itoa(status)] = map[string]interface{}{
+			"description": http.StatusText(status) + ": " + strings.Join(cs, ", "),
+			"content": openAPIContent(map[string]interface{}{
+				"$ref": "#/components/schemas/Error",
+			}),
+		}
+	}
+	return responses
+}
+
+func openAPIContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": schema,
+		},
+	}
+}
+
+func openAPISchema(fields []*APIField) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	for _, f := range fields {
+		property := map[string]interface{}{
+			"type": f.Type,
+		}
+		if f.Format != "" {
+			property["format"] = f.Format
+		}
+		if f.Enum != nil {
+			property["enum"] = f.Enum
+		}
+		if f.Description != "" {
+			property["description"] = f.Description
+		}
+		properties[f.Name] = property
+		if f.Required {
+			required = append(required, f.Name)
+		}
+	}
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,166 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/netgo/handler"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// AttachOpenIDHandler serves the endpoints clients use to sign users in with this service, once the authenticator has an OIDC issuer.
+func AttachOpenIDHandler(m *http.ServeMux, a authgo.Authenticator) {
+	m.Handle("/.well-known/openid-configuration", handler.Log(OpenIDConfiguration(a)))
+	m.Handle("/jwks", handler.Log(JSONWebKeySet(a)))
+	m.Handle("/token", handler.Log(Token(a)))
+	m.Handle("/userinfo", handler.Log(UserInfo(a)))
+}
+
+func OpenIDConfiguration(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := a.OIDCConfiguration()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		// Clients running in browsers discover the configuration from other origins
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		writeOIDCJSON(w, http.StatusOK, c)
+	})
+}
+
+func JSONWebKeySet(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.OIDCIssuer() == "" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		keys, err := a.OIDCJSONWebKeySet(r.Context())
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		writeOIDCJSON(w, http.StatusOK, keys)
+	})
+}
+
+// Token exchanges an authorization code or a refresh token for new tokens, see https://openid.net/specs/openid-connect-core-1_0.html#TokenEndpoint.
+func Token(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if a.OIDCIssuer() == "" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		if r.Method != "POST" {
+			writeOIDCError(w, authgo.ErrOIDCRequestInvalid)
+			return
+		}
+		id, secret, basic := r.BasicAuth()
+		if basic {
+			// Credentials are form encoded before being put in the header, see https://www.rfc-editor.org/rfc/rfc6749#section-2.3.1
+			var err error
+			if id, err = url.QueryUnescape(id); err != nil {
+				writeOIDCError(w, authgo.ErrOIDCClientUnauthorized)
+				return
+			}
+			if secret, err = url.QueryUnescape(secret); err != nil {
+				writeOIDCError(w, authgo.ErrOIDCClientUnauthorized)
+				return
+			}
+		} else {
+			id = r.PostFormValue("client_id")
+			secret = r.PostFormValue("client_secret")
+		}
+		client, err := a.AuthenticateOIDCClient(ctx, id, secret)
+		if err != nil {
+			log.Println(err)
+			if basic {
+				w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+			}
+			writeOIDCError(w, err)
+			return
+		}
+		var tokens *authgo.OIDCTokens
+		switch r.PostFormValue("grant_type") {
+		case "authorization_code":
+			tokens, err = a.RedeemOIDCCode(ctx, client, r.PostFormValue("code"), r.PostFormValue("redirect_uri"), r.PostFormValue("code_verifier"))
+		case "refresh_token":
+			tokens, err = a.RefreshOIDCTokens(ctx, client, r.PostFormValue("refresh_token"), r.PostFormValue("scope"))
+		default:
+			err = authgo.ErrOIDCGrantUnsupported
+		}
+		if err != nil {
+			log.Println(err)
+			writeOIDCError(w, err)
+			return
+		}
+		writeOIDCJSON(w, http.StatusOK, tokens)
+	})
+}
+
+// UserInfo returns the claims about the user which the bearer's access token allows, see https://openid.net/specs/openid-connect-core-1_0.html#UserInfo.
+func UserInfo(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.OIDCIssuer() == "" {
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		token := authgo.BearerTokenFromRequest(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		info, err := a.LookupOIDCUserInfo(r.Context(), token)
+		if errors.Is(err, authgo.ErrOIDCAccessTokenInvalid) {
+			log.Println(err)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="userinfo", error="invalid_token"`)
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		} else if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		writeOIDCJSON(w, http.StatusOK, info)
+	})
+}
+
+// writeOIDCError writes the error as JSON in the form clients expect, see https://www.rfc-editor.org/rfc/rfc6749#section-5.2.
+func writeOIDCError(w http.ResponseWriter, err error) {
+	code := authgo.OIDCErrorCode(err)
+	status := http.StatusBadRequest
+	description := err.Error()
+	switch code {
+	case "invalid_client":
+		status = http.StatusUnauthorized
+	case "server_error":
+		// Internal errors are not shared with clients
+		status = http.StatusInternalServerError
+		description = http.StatusText(status)
+	}
+	writeOIDCJSON(w, status, struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}{
+		Error:            code,
+		ErrorDescription: description,
+	})
+}
+
+func writeOIDCJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,11 @@
+package handler_test
+
+import (
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/authtest/handler"
+	"testing"
+)
+
+func TestOpenID(t *testing.T) {
+	handler.OpenID(t, authtest.NewAuthenticator)
+}
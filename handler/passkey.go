@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// writePasskeyOptions writes the options for a passkey ceremony as JSON, for the page's script to pass to the browser.
+func writePasskeyOptions(w http.ResponseWriter, options interface{}) {
+	writePasskeyJSON(w, http.StatusOK, options)
+}
+
+// writePasskeyError writes the error as JSON, for the page's script to show to the user.
+func writePasskeyError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, authgo.ErrRelyingPartyNotConfigured) {
+		status = http.StatusNotFound
+	}
+	writePasskeyJSON(w, status, struct {
+		Error string `json:"error"`
+	}{
+		Error: err.Error(),
+	})
+}
+
+func writePasskeyJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
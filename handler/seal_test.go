@@ -0,0 +1,31 @@
+package handler_test
+
+import (
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/authtest/handler"
+	"testing"
+)
+
+func TestSealed_SignUp(t *testing.T) {
+	handler.SignUp(t, authtest.NewSealedAuthenticator)
+}
+
+func TestSealed_SignIn(t *testing.T) {
+	handler.SignIn(t, authtest.NewSealedAuthenticator)
+}
+
+func TestSealed_AccountPassword(t *testing.T) {
+	handler.AccountPassword(t, authtest.NewSealedAuthenticator)
+}
+
+func TestSealed_AccountRecovery(t *testing.T) {
+	handler.AccountRecovery(t, authtest.NewSealedAuthenticator)
+}
+
+func TestSealed_API(t *testing.T) {
+	handler.API(t, authtest.NewSealedAuthenticator)
+}
+
+func TestSealed_SignUpSignOutSignInAccount(t *testing.T) {
+	handler.SignUpSignOutSignInAccount(t, authtest.NewSealedAuthenticator)
+}
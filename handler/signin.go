@@ -13,13 +13,24 @@ import (
 )
 
 func AttachSignInHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
-	m.Handle("/sign-in", handler.Log(handler.Compress(SignIn(a, ts))))
+	m.Handle("/sign-in", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(SignIn(a, ts))))))
+	m.Handle("/sign-in-totp", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(SignInTOTP(a, ts))))))
+	m.Handle("/sign-in-code", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(SignInCode(a, ts))))))
+	m.Handle("/sign-in-link", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(SignInLink(a, ts))))))
 }
 
 func SignIn(a authgo.Authenticator, ts *template.Template) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, username, authenticated, _, errmsg := a.CurrentSignInSession(r)
-		// log.Println("CurrentSignInSession", token, username, authenticated, created, errmsg)
+		ctx := authgo.WithClient(r)
+		session := a.CurrentSignInSession(r)
+		// log.Println("CurrentSignInSession", session)
+		var (
+			token, username, errmsg            string
+			authenticated, pendingSecondFactor bool
+		)
+		if session != nil {
+			token, username, authenticated, pendingSecondFactor, errmsg = session.Token, session.Username, session.Authenticated, session.PendingSecondFactor, session.Error
+		}
 		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
 		if err != nil {
 			log.Println(err)
@@ -34,15 +45,21 @@ func SignIn(a authgo.Authenticator, ts *template.Template) http.Handler {
 				return
 			}
 			data := struct {
-				Live     bool
-				Username string
-				Error    string
-				Next     string
+				Live         bool
+				CSRF         string
+				Passwordless bool
+				Providers    []*authgo.OIDCProvider
+				Username     string
+				Error        string
+				Next         string
 			}{
-				Live:     netgo.IsLive(),
-				Username: username,
-				Error:    errmsg,
-				Next:     next,
+				Live:         netgo.IsLive(),
+				CSRF:         a.CSRFToken(w, r),
+				Passwordless: a.PasswordlessSignIn(),
+				Providers:    a.OIDCProviders(),
+				Username:     username,
+				Error:        errmsg,
+				Next:         next,
 			}
 			if err := ts.ExecuteTemplate(w, "sign-in.go.html", data); err != nil {
 				log.Println(err)
@@ -53,7 +70,7 @@ func SignIn(a authgo.Authenticator, ts *template.Template) http.Handler {
 			password := []byte(strings.TrimSpace(r.FormValue("password")))
 
 			if token == "" {
-				t, err := a.NewSignInSession(username, false)
+				t, err := a.NewSignInSession(ctx, username, false)
 				// log.Println("NewSignInSession", t, err)
 				if err != nil {
 					log.Println(err)
@@ -63,69 +80,173 @@ func SignIn(a authgo.Authenticator, ts *template.Template) http.Handler {
 				token = t
 				http.SetCookie(w, a.NewSignInSessionCookie(token))
 			} else {
-				if err := a.SetSignInSessionUsername(token, username); err != nil {
+				if authenticated || pendingSecondFactor {
+					// Forget the previous sign in before the username changes
+					if err := a.SetSignInSessionAuthenticated(ctx, token, false); err != nil {
+						log.Println(err)
+					}
+					if err := a.SetSignInSessionPendingSecondFactor(ctx, token, false); err != nil {
+						log.Println(err)
+					}
+				}
+				if err := a.SetSignInSessionUsername(ctx, token, username); err != nil {
 					log.Println(err)
-					a.SetSignInSessionError(token, err.Error())
+					a.SetSignInSessionError(ctx, token, err.Error())
 					redirect.SignIn(w, r, next)
 					return
 				}
-				a.SetSignInSessionError(token, "")
+				a.SetSignInSessionError(ctx, token, "")
 			}
 
-			account, err := a.AuthenticateAccount(username, password)
+			if len(password) == 0 && a.PasswordlessSignIn() {
+				// Username may also be an email address
+				if err := a.NewSignInChallenge(ctx, token, username); err != nil {
+					log.Println(err)
+					a.SetSignInSessionError(ctx, token, err.Error())
+					redirect.SignIn(w, r, next)
+					return
+				}
+				redirect.SignInCode(w, r, next)
+				return
+			}
+
+			account, err := a.AuthenticateAccount(ctx, username, password)
 			// log.Println("AuthenticateAccount", account, err)
 			if err != nil {
 				log.Println(err)
-				a.SetSignInSessionError(token, err.Error())
+				a.SetSignInSessionError(ctx, token, err.Error())
 				redirect.SignIn(w, r, next)
 				return
 			}
 
-			if err := a.SetSignInSessionAuthenticated(token, true); err != nil {
+			signInOrSecondFactor(w, r, a, token, account, next)
+		}
+	})
+}
+
+func SignInTOTP(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		session := a.CurrentSignInSession(r)
+		// log.Println("CurrentSignInSession", session)
+		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if session == nil || session.Username == "" || !session.PendingSecondFactor {
+			redirect.SignIn(w, r, next)
+			return
+		}
+		token, username, errmsg := session.Token, session.Username, session.Error
+		switch r.Method {
+		case "GET":
+			data := struct {
+				Live  bool
+				CSRF  string
+				Error string
+				Next  string
+			}{
+				Live:  netgo.IsLive(),
+				CSRF:  a.CSRFToken(w, r),
+				Error: errmsg,
+				Next:  next,
+			}
+			if err := ts.ExecuteTemplate(w, "sign-in-totp.go.html", data); err != nil {
 				log.Println(err)
-				a.SetSignInSessionError(token, err.Error())
-				redirect.SignIn(w, r, next)
 				return
 			}
+		case "POST":
+			code := strings.TrimSpace(r.FormValue("code"))
 
-			if !a.IsEmailVerified(account.Email) {
-				token, err := a.NewSignUpSession()
-				// log.Println("NewSignUpSession", token, err)
-				if err != nil {
-					log.Println(err)
-					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-					return
-				}
-				if err := a.SetSignUpSessionIdentity(token, account.Email, account.Username); err != nil {
-					log.Println(err)
-					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
-					return
-				}
-
-				http.SetCookie(w, a.NewSignUpSessionCookie(token))
+			if err := a.VerifyTOTP(ctx, username, code); err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignInTOTP(w, r, next)
+				return
+			}
+			a.SetSignInSessionError(ctx, token, "")
 
-				code, err := a.EmailVerifier().Verify(account.Email, account.Username)
-				// log.Println("Verify", account.Email, account.Username, code, err)
-				if err != nil {
-					log.Println(err)
-					a.SetSignUpSessionError(token, err.Error())
-					redirect.SignIn(w, r, next)
-					return
-				}
-				if err := a.SetSignUpSessionChallenge(token, code); err != nil {
-					log.Println(err)
-					a.SetSignUpSessionError(token, err.Error())
-					redirect.SignIn(w, r, next)
-					return
-				}
-				redirect.SignUpVerification(w, r, next)
+			account, err := a.LookupAccount(ctx, username)
+			if err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignIn(w, r, next)
 				return
 			}
-			if next == "" {
-				redirect.Account(w, r)
-			} else {
-				http.Redirect(w, r, next, http.StatusFound)
+
+			if err := a.SetSignInSessionPendingSecondFactor(ctx, token, false); err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignIn(w, r, next)
+				return
 			}
+
+			signIn(w, r, a, token, account, next)
 		}
 	})
 }
+
+// signInOrSecondFactor signs in to the account, unless two-factor authentication is enabled in which case the user is sent to enter their code.
+func signInOrSecondFactor(w http.ResponseWriter, r *http.Request, a authgo.Authenticator, token string, account *authgo.Account, next string) {
+	ctx := r.Context()
+	if a.IsTOTPEnabled(ctx, account.Username) {
+		if err := a.SetSignInSessionPendingSecondFactor(ctx, token, true); err != nil {
+			log.Println(err)
+			a.SetSignInSessionError(ctx, token, err.Error())
+			redirect.SignIn(w, r, next)
+			return
+		}
+		redirect.SignInTOTP(w, r, next)
+		return
+	}
+
+	signIn(w, r, a, token, account, next)
+}
+
+// signIn authenticates the session and, once the account's email is verified, redirects to next.
+func signIn(w http.ResponseWriter, r *http.Request, a authgo.Authenticator, token string, account *authgo.Account, next string) {
+	ctx := r.Context()
+	if err := a.SetSignInSessionAuthenticated(ctx, token, true); err != nil {
+		log.Println(err)
+		a.SetSignInSessionError(ctx, token, err.Error())
+		redirect.SignIn(w, r, next)
+		return
+	}
+
+	if !a.IsEmailVerified(ctx, account.Email) {
+		token, err := a.NewSignUpSession(ctx)
+		// log.Println("NewSignUpSession", token, err)
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if err := a.SetSignUpSessionIdentity(ctx, token, account.Email, account.Username); err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+
+		http.SetCookie(w, a.NewSignUpSessionCookie(token))
+
+		code, err := a.EmailVerifier().Verify(account.Email, account.Username)
+		// log.Println("Verify", account.Email, account.Username, code, err)
+		if err != nil {
+			log.Println(err)
+			a.SetSignUpSessionError(ctx, token, err.Error())
+			redirect.SignIn(w, r, next)
+			return
+		}
+		if err := a.SetSignUpSessionChallenge(ctx, token, code); err != nil {
+			log.Println(err)
+			a.SetSignUpSessionError(ctx, token, err.Error())
+			redirect.SignIn(w, r, next)
+			return
+		}
+		redirect.SignUpVerification(w, r, next)
+		return
+	}
+	redirect.Next(w, r, next)
+}
@@ -9,3 +9,23 @@ import (
 func TestSignIn(t *testing.T) {
 	handler.SignIn(t, authtest.NewAuthenticator)
 }
+
+func TestSignInTOTP(t *testing.T) {
+	handler.SignInTOTP(t, authtest.NewAuthenticator)
+}
+
+func TestSignInPasskey(t *testing.T) {
+	handler.SignInPasskey(t, authtest.NewAuthenticator)
+}
+
+func TestSignInCode(t *testing.T) {
+	handler.SignInCode(t, authtest.NewAuthenticator)
+}
+
+func TestSignInLink(t *testing.T) {
+	handler.SignInLink(t, authtest.NewAuthenticator)
+}
+
+func TestSignInOIDC(t *testing.T) {
+	handler.SignInOIDC(t, authtest.NewAuthenticator)
+}
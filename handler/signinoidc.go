@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo/handler"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func AttachSignInOIDCHandler(m *http.ServeMux, a authgo.Authenticator) {
+	m.Handle("/sign-in-oidc", handler.Log(CSRF(a, SignInOIDC(a))))
+	m.Handle("/sign-in-oidc-callback", handler.Log(a.SealedSessionHandler(SignInOIDCCallback(a))))
+}
+
+// SignInOIDC sends the user to sign in with the identity provider chosen in the form.
+// When already signed in, the identity is linked to the current account.
+func SignInOIDC(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if r.Method != "POST" {
+			redirect.SignIn(w, r, next)
+			return
+		}
+		var token string
+		if session := a.CurrentSignInSession(r); session != nil {
+			token = session.Token
+		}
+		if token == "" {
+			t, err := a.NewSignInSession(authgo.WithClient(r), "", false)
+			// log.Println("NewSignInSession", t, err)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			token = t
+			http.SetCookie(w, a.NewSignInSessionCookie(token))
+		}
+		provider := strings.TrimSpace(r.FormValue("provider"))
+		u, err := a.NewOIDCAuthorization(ctx, token, provider, next)
+		if err != nil {
+			log.Println(err)
+			a.SetSignInSessionError(ctx, token, err.Error())
+			redirect.SignIn(w, r, next)
+			return
+		}
+		http.Redirect(w, r, u, http.StatusFound)
+	})
+}
+
+// SignInOIDCCallback completes signing in when the identity provider redirects the user back.
+func SignInOIDCCallback(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		session := a.CurrentSignInSession(r)
+		// log.Println("CurrentSignInSession", session)
+		if session == nil {
+			redirect.SignIn(w, r, "")
+			return
+		}
+		token := session.Token
+		query := r.URL.Query()
+		if e := query.Get("error"); e != "" {
+			log.Println("Identity Provider Error:", e, query.Get("error_description"))
+			a.SetSignInSessionError(ctx, token, authgo.ErrOIDCAuthorizationDenied.Error())
+			redirect.SignIn(w, r, "")
+			return
+		}
+
+		account, next, err := a.AuthenticateOIDC(ctx, token, query.Get("state"), query.Get("code"))
+		if err != nil {
+			log.Println(err)
+			a.SetSignInSessionError(ctx, token, err.Error())
+			redirect.SignIn(w, r, next)
+			return
+		}
+		a.SetSignInSessionError(ctx, token, "")
+
+		if session.Authenticated {
+			// The identity was linked to the current account
+			redirect.Next(w, r, next)
+			return
+		}
+
+		if session.PendingSecondFactor {
+			// Forget the previous sign in before the username changes
+			if err := a.SetSignInSessionPendingSecondFactor(ctx, token, false); err != nil {
+				log.Println(err)
+			}
+		}
+		if err := a.SetSignInSessionUsername(ctx, token, account.Username); err != nil {
+			log.Println(err)
+			a.SetSignInSessionError(ctx, token, err.Error())
+			redirect.SignIn(w, r, next)
+			return
+		}
+
+		signInOrSecondFactor(w, r, a, token, account, next)
+	})
+}
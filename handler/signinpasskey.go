@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func AttachSignInPasskeyHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
+	m.Handle("/sign-in-passkey", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(SignInPasskey(a, ts))))))
+	m.Handle("/sign-in-passkey-options", handler.Log(CSRF(a, SignInPasskeyOptions(a))))
+}
+
+// SignInPasskeyOptions starts a passkey sign in by issuing a challenge for the browser to pass to the authenticator.
+func SignInPasskeyOptions(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := r.Context()
+		var token string
+		if session := a.CurrentSignInSession(r); session != nil {
+			token = session.Token
+		}
+		if token == "" {
+			t, err := a.NewSignInSession(authgo.WithClient(r), "", false)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			token = t
+			http.SetCookie(w, a.NewSignInSessionCookie(token))
+		}
+		challenge, err := authgo.NewPasskeyChallenge()
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+		options, err := a.NewPasskeyRequestOptions(challenge)
+		if err != nil {
+			log.Println(err)
+			writePasskeyError(w, err)
+			return
+		}
+		if err := a.SetSignInSessionPasskeyChallenge(ctx, token, challenge); err != nil {
+			log.Println(err)
+			writePasskeyError(w, err)
+			return
+		}
+		writePasskeyOptions(w, options)
+	})
+}
+
+// SignInPasskey signs in with the passkey credential posted by the page's script.
+// Passkeys verify the user, so no second factor is required.
+func SignInPasskey(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		session := a.CurrentSignInSession(r)
+		// log.Println("CurrentSignInSession", session)
+		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			var errmsg string
+			if session != nil {
+				if session.Authenticated {
+					// Already signed in
+					redirect.Account(w, r)
+					return
+				}
+				errmsg = session.Error
+			}
+			data := struct {
+				Live  bool
+				CSRF  string
+				Error string
+				Next  string
+			}{
+				Live:  netgo.IsLive(),
+				CSRF:  a.CSRFToken(w, r),
+				Error: errmsg,
+				Next:  next,
+			}
+			if err := ts.ExecuteTemplate(w, "sign-in-passkey.go.html", data); err != nil {
+				log.Println(err)
+				return
+			}
+		case "POST":
+			if session == nil {
+				redirect.SignInPasskey(w, r, next)
+				return
+			}
+			token, challenge := session.Token, session.PasskeyChallenge
+			credential := []byte(r.FormValue("credential"))
+
+			// Each challenge can only be used once
+			if err := a.SetSignInSessionPasskeyChallenge(ctx, token, ""); err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignInPasskey(w, r, next)
+				return
+			}
+			if challenge == "" {
+				a.SetSignInSessionError(ctx, token, authgo.ErrPasskeyExpired.Error())
+				redirect.SignInPasskey(w, r, next)
+				return
+			}
+
+			account, err := a.AuthenticatePasskey(ctx, challenge, credential)
+			// log.Println("AuthenticatePasskey", account, err)
+			if err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignInPasskey(w, r, next)
+				return
+			}
+
+			if session.Authenticated || session.PendingSecondFactor {
+				// Forget the previous sign in before the username changes
+				if err := a.SetSignInSessionAuthenticated(ctx, token, false); err != nil {
+					log.Println(err)
+				}
+				if err := a.SetSignInSessionPendingSecondFactor(ctx, token, false); err != nil {
+					log.Println(err)
+				}
+			}
+			if err := a.SetSignInSessionUsername(ctx, token, account.Username); err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignInPasskey(w, r, next)
+				return
+			}
+			a.SetSignInSessionError(ctx, token, "")
+
+			signIn(w, r, a, token, account, next)
+		}
+	})
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SignInCode completes a passwordless sign in with the code emailed to the user.
+// When links are sent instead, the page asks the user to check their email.
+func SignInCode(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		session := a.CurrentSignInSession(r)
+		// log.Println("CurrentSignInSession", session)
+		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if session == nil || !a.PasswordlessSignIn() {
+			redirect.SignIn(w, r, next)
+			return
+		}
+		token, errmsg := session.Token, session.Error
+		switch r.Method {
+		case "GET":
+			data := struct {
+				Live  bool
+				CSRF  string
+				Link  bool
+				Error string
+				Next  string
+			}{
+				Live:  netgo.IsLive(),
+				CSRF:  a.CSRFToken(w, r),
+				Link:  a.SendsSignInLinks(),
+				Error: errmsg,
+				Next:  next,
+			}
+			if err := ts.ExecuteTemplate(w, "sign-in-code.go.html", data); err != nil {
+				log.Println(err)
+				return
+			}
+		case "POST":
+			code := strings.TrimSpace(r.FormValue("code"))
+
+			account, err := a.VerifySignInCode(ctx, token, code)
+			if err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignInCode(w, r, next)
+				return
+			}
+			a.SetSignInSessionError(ctx, token, "")
+
+			// The session may have been started with an email address
+			if err := a.SetSignInSessionUsername(ctx, token, account.Username); err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignIn(w, r, next)
+				return
+			}
+
+			signInOrSecondFactor(w, r, a, token, account, next)
+		}
+	})
+}
+
+// SignInLink completes a passwordless sign in with the link emailed to the user.
+// Following the link only shows a confirmation, so that email clients which prefetch links do not use it up.
+// The link signs in whichever browser submits the confirmation, so it can be opened on a different device to the one which requested it.
+func SignInLink(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := authgo.WithClient(r)
+		session := a.CurrentSignInSession(r)
+		// log.Println("CurrentSignInSession", session)
+		var (
+			token, errmsg                      string
+			authenticated, pendingSecondFactor bool
+		)
+		if session != nil {
+			token, authenticated, pendingSecondFactor, errmsg = session.Token, session.Authenticated, session.PendingSecondFactor, session.Error
+		}
+		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		if !a.PasswordlessSignIn() {
+			redirect.SignIn(w, r, next)
+			return
+		}
+		link := strings.TrimSpace(r.FormValue("token"))
+		switch r.Method {
+		case "GET":
+			data := struct {
+				Live  bool
+				CSRF  string
+				Token string
+				Error string
+				Next  string
+			}{
+				Live:  netgo.IsLive(),
+				CSRF:  a.CSRFToken(w, r),
+				Token: link,
+				Error: errmsg,
+				Next:  next,
+			}
+			if err := ts.ExecuteTemplate(w, "sign-in-link.go.html", data); err != nil {
+				log.Println(err)
+				return
+			}
+		case "POST":
+			if token == "" {
+				t, err := a.NewSignInSession(ctx, "", false)
+				// log.Println("NewSignInSession", t, err)
+				if err != nil {
+					log.Println(err)
+					http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+					return
+				}
+				token = t
+				http.SetCookie(w, a.NewSignInSessionCookie(token))
+			} else if authenticated || pendingSecondFactor {
+				// Forget the previous sign in before the username changes
+				if err := a.SetSignInSessionAuthenticated(ctx, token, false); err != nil {
+					log.Println(err)
+				}
+				if err := a.SetSignInSessionPendingSecondFactor(ctx, token, false); err != nil {
+					log.Println(err)
+				}
+			}
+
+			account, err := a.VerifySignInLink(ctx, link)
+			if err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignIn(w, r, next)
+				return
+			}
+			a.SetSignInSessionError(ctx, token, "")
+
+			if err := a.SetSignInSessionUsername(ctx, token, account.Username); err != nil {
+				log.Println(err)
+				a.SetSignInSessionError(ctx, token, err.Error())
+				redirect.SignIn(w, r, next)
+				return
+			}
+
+			signInOrSecondFactor(w, r, a, token, account, next)
+		}
+	})
+}
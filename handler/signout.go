@@ -11,29 +11,33 @@ import (
 )
 
 func AttachSignOutHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
-	m.Handle("/sign-out", handler.Log(handler.Compress(SignOut(a, ts))))
+	m.Handle("/sign-out", handler.Log(handler.Compress(CSRF(a, SignOut(a, ts)))))
 }
 
 func SignOut(a authgo.Authenticator, ts *template.Template) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, username, authenticated, _, errmsg := a.CurrentSignInSession(r)
-		// log.Println("CurrentSignInSession", token, username, authenticated, created, errmsg)
-		if token == "" || username == "" || !authenticated {
+		ctx := r.Context()
+		session := a.CurrentSignInSession(r)
+		// log.Println("CurrentSignInSession", session)
+		if session == nil || session.Username == "" || !session.Authenticated {
 			// Not signed in
 			redirect.Index(w, r)
 			return
 		}
+		token, username, errmsg := session.Token, session.Username, session.Error
 		switch r.Method {
 		case "GET":
 			data := struct {
 				Live    bool
+				CSRF    string
 				Account *authgo.Account
 				Error   string
 			}{
 				Live:  netgo.IsLive(),
+				CSRF:  a.CSRFToken(w, r),
 				Error: errmsg,
 			}
-			account, err := a.LookupAccount(username)
+			account, err := a.LookupAccount(ctx, username)
 			if err == nil {
 				data.Account = account
 			}
@@ -42,10 +46,11 @@ func SignOut(a authgo.Authenticator, ts *template.Template) http.Handler {
 				return
 			}
 		case "POST":
-			a.SetSignInSessionError(token, "")
-			if err := a.SetSignInSessionAuthenticated(token, false); err != nil {
+			a.SetSignInSessionError(ctx, token, "")
+			if err := a.SetSignInSessionAuthenticated(ctx, token, false); err != nil {
 				log.Println(err)
 			}
+			http.SetCookie(w, a.CookiePolicy().ExpiredCookie(authgo.COOKIE_SIGN_IN))
 			redirect.Index(w, r)
 		}
 	})
@@ -5,6 +5,7 @@ import (
 	"aletheiaware.com/authgo/redirect"
 	"aletheiaware.com/netgo"
 	"aletheiaware.com/netgo/handler"
+	"context"
 	"html/template"
 	"log"
 	"net/http"
@@ -13,19 +14,24 @@ import (
 )
 
 func AttachSignUpHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
-	m.Handle("/sign-up", handler.Log(handler.Compress(SignUp(a, ts))))
-	m.Handle("/sign-up-verification", handler.Log(handler.Compress(SignUpVerification(a, ts))))
+	m.Handle("/sign-up", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(SignUp(a, ts))))))
+	m.Handle("/sign-up-verification", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(SignUpVerification(a, ts))))))
 }
 
 func SignUp(a authgo.Authenticator, ts *template.Template) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
 		if a := a.CurrentAccount(w, r); a != nil {
 			// Already signed in
 			redirect.Account(w, r)
 			return
 		}
-		token, email, username, referrer, _, errmsg := a.CurrentSignUpSession(r)
-		// log.Println("CurrentSignUpSession", token, email, username, referrer, challenge, errmsg)
+		session := a.CurrentSignUpSession(r)
+		// log.Println("CurrentSignUpSession", session)
+		var token, email, username, referrer, errmsg string
+		if session != nil {
+			token, email, username, referrer, errmsg = session.Token, session.Email, session.Username, session.Referrer, session.Error
+		}
 		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
 		if err != nil {
 			log.Println(err)
@@ -39,6 +45,7 @@ func SignUp(a authgo.Authenticator, ts *template.Template) http.Handler {
 			}
 			data := struct {
 				Live     bool
+				CSRF     string
 				Email    string
 				Username string
 				Referrer string
@@ -46,6 +53,7 @@ func SignUp(a authgo.Authenticator, ts *template.Template) http.Handler {
 				Next     string
 			}{
 				Live:     netgo.IsLive(),
+				CSRF:     a.CSRFToken(w, r),
 				Email:    email,
 				Username: username,
 				Referrer: referrer,
@@ -58,7 +66,7 @@ func SignUp(a authgo.Authenticator, ts *template.Template) http.Handler {
 			}
 		case "POST":
 			if token == "" {
-				t, err := a.NewSignUpSession()
+				t, err := a.NewSignUpSession(ctx)
 				// log.Println("NewSignUpSession", t, err)
 				if err != nil {
 					log.Println(err)
@@ -75,21 +83,21 @@ func SignUp(a authgo.Authenticator, ts *template.Template) http.Handler {
 			confirmation := []byte(strings.TrimSpace(r.FormValue("confirmation")))
 			referrer := strings.TrimSpace(r.FormValue("referrer"))
 
-			if err := signUp(a, token, email, username, password, confirmation, referrer); err != nil {
+			if err := signUp(ctx, a, token, email, username, password, confirmation, referrer); err != nil {
 				log.Println(err)
-				a.SetSignUpSessionError(token, err.Error())
+				a.SetSignUpSessionError(ctx, token, err.Error())
 				redirect.SignUp(w, r, next)
 				return
 			}
 
-			a.SetSignUpSessionError(token, "")
+			a.SetSignUpSessionError(ctx, token, "")
 
 			redirect.SignUpVerification(w, r, next)
 		}
 	})
 }
 
-func signUp(a authgo.Authenticator, token, email, username string, password, confirmation []byte, referrer string) error {
+func signUp(ctx context.Context, a authgo.Authenticator, token, email, username string, password, confirmation []byte, referrer string) error {
 	// Check valid email
 	if err := authgo.ValidateEmail(email); err != nil {
 		return err
@@ -100,23 +108,23 @@ func signUp(a authgo.Authenticator, token, email, username string, password, con
 		return err
 	}
 
-	if err := a.SetSignUpSessionIdentity(token, email, username); err != nil {
+	if err := a.SetSignUpSessionIdentity(ctx, token, email, username); err != nil {
 		return err
 	}
 
-	if err := a.SetSignUpSessionReferrer(token, referrer); err != nil {
+	if err := a.SetSignUpSessionReferrer(ctx, token, referrer); err != nil {
 		return err
 	}
 
-	// Check valid password and matching confirm
-	if err := authgo.ValidatePassword(password); err != nil {
+	// Check acceptable password and matching confirm
+	if err := a.PasswordPolicy().Check(password, email, username); err != nil {
 		return err
 	}
 	if err := authgo.MatchPasswords(password, confirmation); err != nil {
 		return err
 	}
 
-	_, err := a.NewAccount(email, username, password)
+	_, err := a.NewAccount(ctx, email, username, password)
 	// log.Println("NewAccount", acc, err)
 	if err != nil {
 		return err
@@ -127,7 +135,7 @@ func signUp(a authgo.Authenticator, token, email, username string, password, con
 	if err != nil {
 		return err
 	}
-	if err := a.SetSignUpSessionChallenge(token, code); err != nil {
+	if err := a.SetSignUpSessionChallenge(ctx, token, code); err != nil {
 		return err
 	}
 	return nil
@@ -135,26 +143,30 @@ func signUp(a authgo.Authenticator, token, email, username string, password, con
 
 func SignUpVerification(a authgo.Authenticator, ts *template.Template) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token, email, username, _, challenge, errmsg := a.CurrentSignUpSession(r)
-		// log.Println("CurrentSignUpSession", token, email, username, referrer, challenge, errmsg)
+		ctx := r.Context()
+		session := a.CurrentSignUpSession(r)
+		// log.Println("CurrentSignUpSession", session)
 		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
 		if err != nil {
 			log.Println(err)
 			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 			return
 		}
-		if token == "" {
+		if session == nil {
 			redirect.SignUp(w, r, next)
 			return
 		}
+		token, email, username, challenge, errmsg := session.Token, session.Email, session.Username, session.Challenge, session.Error
 		switch r.Method {
 		case "GET":
 			data := struct {
 				Live  bool
+				CSRF  string
 				Error string
 				Next  string
 			}{
 				Live:  netgo.IsLive(),
+				CSRF:  a.CSRFToken(w, r),
 				Error: errmsg,
 				Next:  next,
 			}
@@ -166,20 +178,20 @@ func SignUpVerification(a authgo.Authenticator, ts *template.Template) http.Hand
 			verification := strings.TrimSpace(r.FormValue("verification"))
 
 			if err := signUpVerification(challenge, verification); err != nil {
-				a.SetSignUpSessionError(token, err.Error())
+				a.SetSignUpSessionError(ctx, token, err.Error())
 				redirect.SignUpVerification(w, r, next)
 				return
 			}
 
-			a.SetSignUpSessionError(token, "")
+			a.SetSignUpSessionError(ctx, token, "")
 
-			if err := a.SetEmailVerified(email, true); err != nil {
+			if err := a.SetEmailVerified(ctx, email, true); err != nil {
 				log.Println(err)
 				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
 				return
 			}
 
-			token, err := a.NewSignInSession(username, true)
+			token, err := a.NewSignInSession(authgo.WithClient(r), username, true)
 			// log.Println("NewSignInSession", token, err)
 			if err != nil {
 				log.Println(err)
@@ -189,11 +201,7 @@ func SignUpVerification(a authgo.Authenticator, ts *template.Template) http.Hand
 
 			http.SetCookie(w, a.NewSignInSessionCookie(token))
 
-			if next == "" {
-				redirect.Account(w, r)
-			} else {
-				http.Redirect(w, r, next, http.StatusFound)
-			}
+			redirect.Next(w, r, next)
 		}
 	})
 }
@@ -13,3 +13,7 @@ func TestSignUp(t *testing.T) {
 func TestSignUpVerification(t *testing.T) {
 	handler.SignUpVerification(t, authtest.NewAuthenticator)
 }
+
+func TestSignUpPasskey(t *testing.T) {
+	handler.SignUpPasskey(t, authtest.NewAuthenticator)
+}
@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/redirect"
+	"aletheiaware.com/netgo"
+	"aletheiaware.com/netgo/handler"
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+func AttachSignUpPasskeyHandler(m *http.ServeMux, a authgo.Authenticator, ts *template.Template) {
+	m.Handle("/sign-up-passkey", handler.Log(handler.Compress(CSRF(a, a.SealedSessionHandler(SignUpPasskey(a, ts))))))
+	m.Handle("/sign-up-passkey-options", handler.Log(CSRF(a, a.SealedSessionHandler(SignUpPasskeyOptions(a)))))
+}
+
+// SignUpPasskeyOptions starts a passkey sign up by checking the chosen email and username, and issuing a challenge for the browser to pass to the authenticator.
+func SignUpPasskeyOptions(a authgo.Authenticator) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+		ctx := r.Context()
+		var token string
+		if session := a.CurrentSignUpSession(r); session != nil {
+			token = session.Token
+		}
+		if token == "" {
+			t, err := a.NewSignUpSession(ctx)
+			if err != nil {
+				log.Println(err)
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			token = t
+			http.SetCookie(w, a.NewSignUpSessionCookie(token))
+		}
+
+		email := strings.TrimSpace(r.FormValue("email"))
+		username := strings.TrimSpace(r.FormValue("username"))
+		referrer := strings.TrimSpace(r.FormValue("referrer"))
+
+		options, err := signUpPasskeyOptions(ctx, a, token, email, username, referrer)
+		if err != nil {
+			log.Println(err)
+			a.SetSignUpSessionError(ctx, token, err.Error())
+			writePasskeyError(w, err)
+			return
+		}
+		a.SetSignUpSessionError(ctx, token, "")
+		writePasskeyOptions(w, options)
+	})
+}
+
+func signUpPasskeyOptions(ctx context.Context, a authgo.Authenticator, token, email, username, referrer string) (*authgo.PasskeyCreationOptions, error) {
+	// Check valid email
+	if err := authgo.ValidateEmail(email); err != nil {
+		return nil, err
+	}
+
+	// Check valid username
+	if err := authgo.ValidateUsername(username); err != nil {
+		return nil, err
+	}
+
+	if err := a.SetSignUpSessionIdentity(ctx, token, email, username); err != nil {
+		return nil, err
+	}
+
+	if err := a.SetSignUpSessionReferrer(ctx, token, referrer); err != nil {
+		return nil, err
+	}
+
+	// Check availability before the user is asked to create a passkey
+	if _, err := a.LookupUsernameForEmail(ctx, email); err == nil {
+		return nil, authgo.ErrEmailAlreadyRegistered
+	}
+	if _, err := a.LookupAccount(ctx, username); err == nil {
+		return nil, authgo.ErrUsernameAlreadyRegistered
+	}
+
+	challenge, err := authgo.NewPasskeyChallenge()
+	if err != nil {
+		return nil, err
+	}
+	options, err := a.NewPasskeyCreationOptions(ctx, email, username, challenge)
+	if err != nil {
+		return nil, err
+	}
+	if err := a.SetSignUpSessionPasskeyChallenge(ctx, token, challenge); err != nil {
+		return nil, err
+	}
+	return options, nil
+}
+
+// SignUpPasskey creates an account with the passkey credential posted by the page's script, then verifies the email as for /sign-up.
+func SignUpPasskey(a authgo.Authenticator, ts *template.Template) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if a := a.CurrentAccount(w, r); a != nil {
+			// Already signed in
+			redirect.Account(w, r)
+			return
+		}
+		session := a.CurrentSignUpSession(r)
+		// log.Println("CurrentSignUpSession", session)
+		next, err := url.QueryUnescape(strings.TrimSpace(r.FormValue("next")))
+		if err != nil {
+			log.Println(err)
+			http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			var email, username, referrer, errmsg string
+			if session != nil {
+				email, username, referrer, errmsg = session.Email, session.Username, session.Referrer, session.Error
+			}
+			if referrer == "" {
+				referrer = strings.TrimSpace(r.FormValue("referrer"))
+			}
+			data := struct {
+				Live     bool
+				CSRF     string
+				Email    string
+				Username string
+				Referrer string
+				Error    string
+				Next     string
+			}{
+				Live:     netgo.IsLive(),
+				CSRF:     a.CSRFToken(w, r),
+				Email:    email,
+				Username: username,
+				Referrer: referrer,
+				Error:    errmsg,
+				Next:     next,
+			}
+			if err := ts.ExecuteTemplate(w, "sign-up-passkey.go.html", data); err != nil {
+				log.Println(err)
+				return
+			}
+		case "POST":
+			if session == nil {
+				redirect.SignUpPasskey(w, r, next)
+				return
+			}
+			credential := []byte(r.FormValue("credential"))
+			name := strings.TrimSpace(r.FormValue("name"))
+
+			if err := signUpPasskey(ctx, a, session, credential, name); err != nil {
+				log.Println(err)
+				a.SetSignUpSessionError(ctx, session.Token, err.Error())
+				redirect.SignUpPasskey(w, r, next)
+				return
+			}
+
+			a.SetSignUpSessionError(ctx, session.Token, "")
+
+			redirect.SignUpVerification(w, r, next)
+		}
+	})
+}
+
+func signUpPasskey(ctx context.Context, a authgo.Authenticator, session *authgo.SignUpSession, credential []byte, name string) error {
+	token, email, username, challenge := session.Token, session.Email, session.Username, session.PasskeyChallenge
+
+	// Each challenge can only be used once
+	if err := a.SetSignUpSessionPasskeyChallenge(ctx, token, ""); err != nil {
+		return err
+	}
+	if challenge == "" || email == "" || username == "" {
+		return authgo.ErrPasskeyExpired
+	}
+
+	passkey, err := a.VerifyPasskeyCreation(challenge, credential)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		passkey.Name = name
+	}
+
+	_, err = a.NewPasskeyAccount(ctx, email, username, passkey)
+	// log.Println("NewPasskeyAccount", acc, err)
+	if err != nil {
+		return err
+	}
+
+	code, err := a.EmailVerifier().Verify(email, username)
+	// log.Println("VerifyEmail", email, username, code, err)
+	if err != nil {
+		return err
+	}
+	if err := a.SetSignUpSessionChallenge(ctx, token, code); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package authgo
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	DEFAULT_BCRYPT_COST = 14
+
+	PASSWORD_SALT_LENGTH = 16
+	PASSWORD_KEY_LENGTH  = 32
+
+	PREFIX_SCRYPT   = "$scrypt$"
+	PREFIX_ARGON2ID = "$argon2id$"
+)
+
+var ErrInvalidPasswordHash = errors.New("Invalid Password Hash")
+
+// PasswordHasher generates self-describing password hashes which encode the algorithm and parameters used.
+type PasswordHasher interface {
+	// Hash generates a new hash of the password.
+	Hash([]byte) ([]byte, error)
+	// Check returns true if the password matches the hash.
+	Check(hash, password []byte) bool
+	// NeedsRehash returns true if the hash was not generated with this hasher's algorithm and parameters.
+	NeedsRehash([]byte) bool
+}
+
+func GeneratePasswordHash(password []byte) ([]byte, error) {
+	return NewBcryptHasher(DEFAULT_BCRYPT_COST).Hash(password)
+}
+
+// CheckPasswordHash returns true if the password matches the hash, which may have been generated by any of the bcrypt, scrypt, or argon2id hashers.
+func CheckPasswordHash(hash, password []byte) bool {
+	switch {
+	case bytes.HasPrefix(hash, []byte(PREFIX_SCRYPT)):
+		s, salt, key, err := decodeScryptHash(hash)
+		if err != nil {
+			return false
+		}
+		k, err := scrypt.Key(password, salt, 1<<s.LogN, s.R, s.P, len(key))
+		if err != nil {
+			return false
+		}
+		return subtle.ConstantTimeCompare(k, key) == 1
+	case bytes.HasPrefix(hash, []byte(PREFIX_ARGON2ID)):
+		a, salt, key, err := decodeArgon2idHash(hash)
+		if err != nil {
+			return false
+		}
+		k := argon2.IDKey(password, salt, a.Time, a.Memory, a.Threads, uint32(len(key)))
+		return subtle.ConstantTimeCompare(k, key) == 1
+	default:
+		return bcrypt.CompareHashAndPassword(hash, password) == nil
+	}
+}
+
+func NewBcryptHasher(cost int) *BcryptHasher {
+	return &BcryptHasher{
+		Cost: cost,
+	}
+}
+
+// BcryptHasher generates bcrypt hashes in the Modular Crypt Format ($2a$cost$...).
+type BcryptHasher struct {
+	Cost int
+}
+
+func (h *BcryptHasher) Hash(password []byte) ([]byte, error) {
+	return bcrypt.GenerateFromPassword(password, h.Cost)
+}
+
+func (h *BcryptHasher) Check(hash, password []byte) bool {
+	return CheckPasswordHash(hash, password)
+}
+
+func (h *BcryptHasher) NeedsRehash(hash []byte) bool {
+	cost, err := bcrypt.Cost(hash)
+	return err != nil || cost != h.Cost
+}
+
+func NewScryptHasher(logN uint8, r, p int) *ScryptHasher {
+	return &ScryptHasher{
+		LogN: logN,
+		R:    r,
+		P:    p,
+	}
+}
+
+// ScryptHasher generates scrypt hashes encoded as $scrypt$ln=<logN>,r=<r>,p=<p>$<salt>$<key>.
+type ScryptHasher struct {
+	LogN uint8
+	R, P int
+}
+
+func (h *ScryptHasher) Hash(password []byte) ([]byte, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(password, salt, 1<<h.LogN, h.R, h.P, PASSWORD_KEY_LENGTH)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(fmt.Sprintf("%sln=%d,r=%d,p=%d$%s$%s", PREFIX_SCRYPT, h.LogN, h.R, h.P, encodeBase64(salt), encodeBase64(key))), nil
+}
+
+func (h *ScryptHasher) Check(hash, password []byte) bool {
+	return CheckPasswordHash(hash, password)
+}
+
+func (h *ScryptHasher) NeedsRehash(hash []byte) bool {
+	s, _, _, err := decodeScryptHash(hash)
+	return err != nil || *s != *h
+}
+
+func decodeScryptHash(hash []byte) (*ScryptHasher, []byte, []byte, error) {
+	// "", "scrypt", params, salt, key
+	parts := bytes.Split(hash, []byte("$"))
+	if len(parts) != 5 || !bytes.HasPrefix(hash, []byte(PREFIX_SCRYPT)) {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	s := &ScryptHasher{}
+	if _, err := fmt.Sscanf(string(parts[2]), "ln=%d,r=%d,p=%d", &s.LogN, &s.R, &s.P); err != nil {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	salt, key, err := decodeSaltAndKey(parts[3], parts[4])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return s, salt, key, nil
+}
+
+func NewArgon2idHasher(time, memory uint32, threads uint8) *Argon2idHasher {
+	return &Argon2idHasher{
+		Time:    time,
+		Memory:  memory,
+		Threads: threads,
+	}
+}
+
+// Argon2idHasher generates argon2id hashes in the PHC string format $argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<key>.
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+}
+
+func (h *Argon2idHasher) Hash(password []byte) ([]byte, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+	key := argon2.IDKey(password, salt, h.Time, h.Memory, h.Threads, PASSWORD_KEY_LENGTH)
+	return []byte(fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", PREFIX_ARGON2ID, argon2.Version, h.Memory, h.Time, h.Threads, encodeBase64(salt), encodeBase64(key))), nil
+}
+
+func (h *Argon2idHasher) Check(hash, password []byte) bool {
+	return CheckPasswordHash(hash, password)
+}
+
+func (h *Argon2idHasher) NeedsRehash(hash []byte) bool {
+	a, _, _, err := decodeArgon2idHash(hash)
+	return err != nil || *a != *h
+}
+
+func decodeArgon2idHash(hash []byte) (*Argon2idHasher, []byte, []byte, error) {
+	// "", "argon2id", version, params, salt, key
+	parts := bytes.Split(hash, []byte("$"))
+	if len(parts) != 6 || !bytes.HasPrefix(hash, []byte(PREFIX_ARGON2ID)) {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	var version int
+	if _, err := fmt.Sscanf(string(parts[2]), "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	a := &Argon2idHasher{}
+	if _, err := fmt.Sscanf(string(parts[3]), "m=%d,t=%d,p=%d", &a.Memory, &a.Time, &a.Threads); err != nil || a.Time == 0 || a.Threads == 0 {
+		return nil, nil, nil, ErrInvalidPasswordHash
+	}
+	salt, key, err := decodeSaltAndKey(parts[4], parts[5])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return a, salt, key, nil
+}
+
+func newSalt() ([]byte, error) {
+	salt := make([]byte, PASSWORD_SALT_LENGTH)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return salt, nil
+}
+
+func encodeBase64(data []byte) string {
+	return base64.RawStdEncoding.EncodeToString(data)
+}
+
+func decodeSaltAndKey(s, k []byte) ([]byte, []byte, error) {
+	salt, err := base64.RawStdEncoding.DecodeString(string(s))
+	if err != nil {
+		return nil, nil, ErrInvalidPasswordHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(string(k))
+	if err != nil || len(key) == 0 {
+		return nil, nil, ErrInvalidPasswordHash
+	}
+	return salt, key, nil
+}
@@ -0,0 +1,90 @@
+package authgo_test
+
+import (
+	"aletheiaware.com/authgo"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_PasswordHasher(t *testing.T) {
+	for name, tt := range map[string]struct {
+		hasher authgo.PasswordHasher
+		other  authgo.PasswordHasher
+		prefix string
+	}{
+		"Bcrypt": {
+			hasher: authgo.NewBcryptHasher(4),
+			other:  authgo.NewBcryptHasher(5),
+			prefix: "$2a$04$",
+		},
+		"Scrypt": {
+			hasher: authgo.NewScryptHasher(10, 8, 1),
+			other:  authgo.NewScryptHasher(11, 8, 1),
+			prefix: "$scrypt$ln=10,r=8,p=1$",
+		},
+		"Argon2id": {
+			hasher: authgo.NewArgon2idHasher(1, 1024, 1),
+			other:  authgo.NewArgon2idHasher(2, 1024, 1),
+			prefix: "$argon2id$v=19$m=1024,t=1,p=1$",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			hash, err := tt.hasher.Hash(password)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(string(hash), tt.prefix), string(hash))
+			t.Run("Matching", func(t *testing.T) {
+				assert.True(t, tt.hasher.Check(hash, password))
+				assert.True(t, authgo.CheckPasswordHash(hash, password))
+			})
+			t.Run("NotMatching", func(t *testing.T) {
+				assert.False(t, tt.hasher.Check(hash, []byte("1234password")))
+				assert.False(t, authgo.CheckPasswordHash(hash, []byte("1234password")))
+			})
+			t.Run("Salted", func(t *testing.T) {
+				again, err := tt.hasher.Hash(password)
+				assert.NoError(t, err)
+				assert.NotEqual(t, hash, again)
+			})
+			t.Run("NeedsRehash", func(t *testing.T) {
+				assert.False(t, tt.hasher.NeedsRehash(hash))
+				assert.True(t, tt.other.NeedsRehash(hash))
+			})
+			t.Run("OtherHasher", func(t *testing.T) {
+				// Hashes from other hashers are still checked, but need rehashing
+				for _, h := range []authgo.PasswordHasher{
+					authgo.NewBcryptHasher(4),
+					authgo.NewScryptHasher(10, 8, 1),
+					authgo.NewArgon2idHasher(1, 1024, 1),
+				} {
+					assert.True(t, h.Check(hash, password))
+				}
+				assert.True(t, tt.other.Check(hash, password))
+			})
+		})
+	}
+	t.Run("AcrossAlgorithms", func(t *testing.T) {
+		hash, err := authgo.NewScryptHasher(10, 8, 1).Hash(password)
+		assert.NoError(t, err)
+		assert.True(t, authgo.NewBcryptHasher(4).NeedsRehash(hash))
+		assert.True(t, authgo.NewArgon2idHasher(1, 1024, 1).NeedsRehash(hash))
+	})
+	t.Run("Invalid", func(t *testing.T) {
+		for _, hash := range []string{
+			"",
+			"password1234",
+			"$scrypt$",
+			"$scrypt$ln=10,r=8,p=1$c2FsdA$",
+			"$scrypt$ln=x,r=8,p=1$c2FsdA$a2V5",
+			"$scrypt$ln=10,r=8,p=1$!!!$a2V5",
+			"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+			"$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5",
+			"$argon2id$v=19$m=1024,t=0,p=1$c2FsdA$a2V5",
+			"$argon2id$v=19$m=1024,t=1,p=0$c2FsdA$a2V5",
+		} {
+			assert.False(t, authgo.CheckPasswordHash([]byte(hash), password), hash)
+			assert.True(t, authgo.NewArgon2idHasher(1, 1024, 1).NeedsRehash([]byte(hash)), hash)
+			assert.True(t, authgo.NewScryptHasher(10, 8, 1).NeedsRehash([]byte(hash)), hash)
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package authgo
+
+import (
+	"context"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SESSION_JANITOR_INTERVAL is used instead of an interval which is not positive.
+const SESSION_JANITOR_INTERVAL = time.Hour
+
+// janitor periodically deletes expired sessions, and forgotten authentication failures, so that storage can be reclaimed.
+type janitor struct {
+	sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func (a *authenticator) DeleteExpiredSessions(ctx context.Context) (int64, error) {
+	now := time.Now()
+	var (
+		total int64
+		errs  janitorErrors
+	)
+	for _, d := range []struct {
+		delete  func(context.Context, time.Time) (int64, error)
+		timeout time.Duration
+	}{
+		{a.sessions.DeleteExpiredSignUpSessions, a.signUpSessionTimeout},
+		{a.sessions.DeleteExpiredSignInSessions, a.signInSessionTimeout},
+		{a.sessions.DeleteExpiredAccountPasswordSessions, a.accountPasswordSessionTimeout},
+		{a.sessions.DeleteExpiredAccountRecoverySessions, a.accountRecoverySessionTimeout},
+		{a.sessions.DeleteExpiredSignInChallenges, a.signInChallengeTimeout},
+		{a.sessions.DeleteExpiredOIDCAuthorizations, OIDC_AUTHORIZATION_TIMEOUT},
+		{a.sessions.DeleteExpiredOIDCCodes, OIDC_CODE_TIMEOUT},
+		{a.accounts.DeleteExpiredOIDCRefreshTokens, OIDC_REFRESH_TOKEN_TIMEOUT},
+		{a.accounts.DeleteExpiredOIDCSigningKeys, 2 * a.keyRotation},
+		{a.accounts.DeleteExpiredBearerTokens, a.bearerRefreshTimeout},
+		{a.accounts.DeleteExpiredPersonalAccessTokens, 0},
+		{a.accounts.DeleteExpiredAuthenticationFailures, a.lockout.MaximumDuration},
+	} {
+		// Keep going so that one failing store does not stop the others being cleaned up
+		count, err := d.delete(ctx, now.Add(-d.timeout))
+		total += count
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch len(errs) {
+	case 0:
+		return total, nil
+	case 1:
+		return total, errs[0]
+	default:
+		return total, errs
+	}
+}
+
+// janitorErrors combines the errors of each store which failed to delete.
+type janitorErrors []error
+
+func (e janitorErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// StartSessionJanitor deletes expired sessions every interval until StopSessionJanitor is called.
+// Starting the janitor again replaces the previous one.
+func (a *authenticator) StartSessionJanitor(interval time.Duration) {
+	if interval <= 0 {
+		log.Println("Invalid Session Janitor Interval", interval, "using", SESSION_JANITOR_INTERVAL)
+		interval = SESSION_JANITOR_INTERVAL
+	}
+	j := a.janitor
+	j.Lock()
+	defer j.Unlock()
+	j.stop()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	j.cancel = cancel
+	j.done = done
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				count, err := a.DeleteExpiredSessions(ctx)
+				if err != nil && ctx.Err() == nil {
+					log.Println(err)
+				}
+				if count > 0 {
+					log.Println("Deleted", count, "Expired Sessions")
+				}
+			}
+		}
+	}()
+}
+
+// StopSessionJanitor stops the janitor and waits for it to finish.
+func (a *authenticator) StopSessionJanitor() {
+	j := a.janitor
+	j.Lock()
+	defer j.Unlock()
+	j.stop()
+}
+
+func (j *janitor) stop() {
+	if j.cancel == nil {
+		return
+	}
+	j.cancel()
+	<-j.done
+	j.cancel = nil
+	j.done = nil
+}
@@ -0,0 +1,53 @@
+package authgo_test
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"aletheiaware.com/authgo/database"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// failingSessionStore fails to delete expired sign up sessions and OIDC codes.
+type failingSessionStore struct {
+	*database.InMemory
+}
+
+func (s *failingSessionStore) DeleteExpiredSignUpSessions(context.Context, time.Time) (int64, error) {
+	return 0, errors.New("Sign Up Failed")
+}
+
+func (s *failingSessionStore) DeleteExpiredOIDCCodes(context.Context, time.Time) (int64, error) {
+	return 0, errors.New("OIDC Codes Failed")
+}
+
+func TestDeleteExpiredSessions_ContinuesAfterError(t *testing.T) {
+	db := database.NewInMemory()
+	auth := authgo.NewAuthenticator(db, &failingSessionStore{db}, authtest.NewEmailVerifier())
+	auth.SetSignInSessionTimeout(time.Nanosecond)
+	auth.SetLockoutPolicy(&authgo.LockoutPolicy{
+		AccountThreshold: 1,
+		Duration:         time.Nanosecond,
+		MaximumDuration:  time.Nanosecond,
+	})
+	ctx := context.Background()
+	token, err := auth.NewSignInSession(ctx, authtest.TEST_USERNAME, true)
+	require.Nil(t, err)
+	_, err = db.IncrementAuthenticationFailures(ctx, "account:"+authtest.TEST_USERNAME, time.Now())
+	require.Nil(t, err)
+	time.Sleep(time.Millisecond) // Sleep to ensure expiry
+
+	count, err := auth.DeleteExpiredSessions(ctx)
+	require.NotNil(t, err)
+	assert.Equal(t, "Sign Up Failed; OIDC Codes Failed", err.Error())
+	// Stores after the failures are still cleaned up
+	assert.Equal(t, int64(2), count)
+	assert.Nil(t, auth.LookupSignInSession(ctx, token))
+	failures, _, err := db.SelectAuthenticationFailures(ctx, "account:"+authtest.TEST_USERNAME)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), failures)
+}
@@ -0,0 +1,244 @@
+package authgo
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// JSON Web Signature algorithms of the supported keys.
+const (
+	JWT_ALGORITHM_RS256 = "RS256"
+	JWT_ALGORITHM_ES256 = "ES256"
+)
+
+var (
+	ErrJWTInvalid     = errors.New("Invalid Token")
+	ErrJWKUnsupported = errors.New("Key Not Supported")
+)
+
+var jwtEncoding = base64.RawURLEncoding
+
+// JSONWebKey is the JSON encoding of a public key, see https://www.rfc-editor.org/rfc/rfc7517.
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Use string `json:"use,omitempty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	// N and E are the modulus and exponent of an RSA key.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Crv, X and Y are the curve and coordinates of an elliptic curve key.
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+type JSONWebKeySet struct {
+	Keys []*JSONWebKey `json:"keys"`
+}
+
+// NewJSONWebKey encodes the public key, which must be an RSA key or an elliptic curve key on P-256, for verifying signatures.
+func NewJSONWebKey(id string, key crypto.PublicKey) (*JSONWebKey, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return &JSONWebKey{
+			Kty: "RSA",
+			Use: "sig",
+			Kid: id,
+			Alg: JWT_ALGORITHM_RS256,
+			N:   jwtEncoding.EncodeToString(k.N.Bytes()),
+			E:   jwtEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("%w: curve %s", ErrJWKUnsupported, k.Curve.Params().Name)
+		}
+		return &JSONWebKey{
+			Kty: "EC",
+			Use: "sig",
+			Kid: id,
+			Alg: JWT_ALGORITHM_ES256,
+			Crv: "P-256",
+			X:   jwtEncoding.EncodeToString(k.X.FillBytes(make([]byte, 32))),
+			Y:   jwtEncoding.EncodeToString(k.Y.FillBytes(make([]byte, 32))),
+		}, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrJWKUnsupported, key)
+	}
+}
+
+// PublicKey decodes the key, which must be an RSA key or an elliptic curve key on P-256.
+func (k *JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := jwtEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("%w: modulus", ErrJWKUnsupported)
+		}
+		e, err := jwtEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("%w: exponent", ErrJWKUnsupported)
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("%w: curve %s", ErrJWKUnsupported, k.Crv)
+		}
+		x, err := jwtEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("%w: x", ErrJWKUnsupported)
+		}
+		y, err := jwtEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("%w: y", ErrJWKUnsupported)
+		}
+		key := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("%w: point not on curve", ErrJWKUnsupported)
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("%w: type %s", ErrJWKUnsupported, k.Kty)
+	}
+}
+
+// Supports returns true if the key can verify signatures made with the algorithm.
+func (k *JSONWebKey) Supports(alg string) bool {
+	if k.Use != "" && k.Use != "sig" {
+		return false
+	}
+	if k.Alg != "" && k.Alg != alg {
+		return false
+	}
+	switch alg {
+	case JWT_ALGORITHM_RS256:
+		return k.Kty == "RSA"
+	case JWT_ALGORITHM_ES256:
+		return k.Kty == "EC" && k.Crv == "P-256"
+	}
+	return false
+}
+
+// JWTHeader is the header of a signed JSON Web Token.
+type JWTHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// ParseJWT splits a compact serialized token into its header, the JSON encoded claims, the signed input and the signature.
+// The signature is not verified.
+func ParseJWT(token string) (*JWTHeader, []byte, []byte, []byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, nil, nil, ErrJWTInvalid
+	}
+	h, err := jwtEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("%w: header", ErrJWTInvalid)
+	}
+	header := &JWTHeader{}
+	if err := json.Unmarshal(h, header); err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("%w: header", ErrJWTInvalid)
+	}
+	claims, err := jwtEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("%w: claims", ErrJWTInvalid)
+	}
+	signature, err := jwtEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, nil, nil, fmt.Errorf("%w: signature", ErrJWTInvalid)
+	}
+	return header, claims, []byte(parts[0] + "." + parts[1]), signature, nil
+}
+
+// VerifyJWTSignature returns nil if the signature of the input was made by the key with the algorithm.
+func VerifyJWTSignature(alg string, key crypto.PublicKey, input, signature []byte) error {
+	hash := sha256.Sum256(input)
+	switch alg {
+	case JWT_ALGORITHM_RS256:
+		k, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key type", ErrJWTInvalid)
+		}
+		if err := rsa.VerifyPKCS1v15(k, crypto.SHA256, hash[:], signature); err != nil {
+			return fmt.Errorf("%w: signature", ErrJWTInvalid)
+		}
+		return nil
+	case JWT_ALGORITHM_ES256:
+		k, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("%w: key type", ErrJWTInvalid)
+		}
+		// Signatures are the concatenated r and s values, not ASN.1 as in WebAuthn
+		if len(signature) != 64 {
+			return fmt.Errorf("%w: signature", ErrJWTInvalid)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(k, hash[:], r, s) {
+			return fmt.Errorf("%w: signature", ErrJWTInvalid)
+		}
+		return nil
+	default:
+		return fmt.Errorf("%w: algorithm %s", ErrJWTInvalid, alg)
+	}
+}
+
+// SignJWT returns the compact serialization of the claims signed with the key, which must be an RSA key or an elliptic curve key on P-256.
+// The algorithm in the header is set to match the key.
+func SignJWT(header *JWTHeader, claims interface{}, key crypto.Signer) (string, error) {
+	switch k := key.Public().(type) {
+	case *rsa.PublicKey:
+		header.Alg = JWT_ALGORITHM_RS256
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return "", fmt.Errorf("%w: curve %s", ErrJWKUnsupported, k.Curve.Params().Name)
+		}
+		header.Alg = JWT_ALGORITHM_ES256
+	default:
+		return "", fmt.Errorf("%w: %T", ErrJWKUnsupported, k)
+	}
+	h, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	c, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := jwtEncoding.EncodeToString(h) + "." + jwtEncoding.EncodeToString(c)
+	hash := sha256.Sum256([]byte(input))
+	var signature []byte
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, hash[:])
+		if err != nil {
+			return "", err
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	default:
+		signature, err = key.Sign(rand.Reader, hash[:], crypto.SHA256)
+		if err != nil {
+			return "", err
+		}
+	}
+	return input + "." + jwtEncoding.EncodeToString(signature), nil
+}
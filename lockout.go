@@ -0,0 +1,167 @@
+package authgo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+const (
+	DEFAULT_ACCOUNT_LOCKOUT_THRESHOLD = 5
+	DEFAULT_CLIENT_LOCKOUT_THRESHOLD  = 20
+	DEFAULT_LOCKOUT_DURATION          = time.Minute
+	DEFAULT_MAXIMUM_LOCKOUT_DURATION  = 24 * time.Hour
+)
+
+// ErrAccountLocked is returned when too many attempts to authenticate have failed, either for the account or from the client.
+var ErrAccountLocked = errors.New("Account Locked")
+
+// LockoutPolicy limits the rate at which passwords can be guessed.
+//
+// Once the number of consecutive failures for an account, or from a client, reaches the threshold it is locked for the duration,
+// which doubles with each further failure up to the maximum. Failures are forgotten once the maximum has elapsed since the last.
+type LockoutPolicy struct {
+	AccountThreshold int // 0 disables locking of accounts
+	ClientThreshold  int // 0 disables locking of clients
+	Duration         time.Duration
+	MaximumDuration  time.Duration
+}
+
+func NewLockoutPolicy() *LockoutPolicy {
+	return &LockoutPolicy{
+		AccountThreshold: DEFAULT_ACCOUNT_LOCKOUT_THRESHOLD,
+		ClientThreshold:  DEFAULT_CLIENT_LOCKOUT_THRESHOLD,
+		Duration:         DEFAULT_LOCKOUT_DURATION,
+		MaximumDuration:  DEFAULT_MAXIMUM_LOCKOUT_DURATION,
+	}
+}
+
+// LockedUntil returns the time until which a subject is locked after the given number of failures, the last of which occurred at last.
+// The zero time is returned if the subject is not locked.
+func (p *LockoutPolicy) LockedUntil(threshold int, failures int64, last time.Time) time.Time {
+	if threshold <= 0 || failures < int64(threshold) {
+		return time.Time{}
+	}
+	d := p.Duration
+	for i := int64(threshold); i < failures && d < p.MaximumDuration; i++ {
+		d *= 2
+	}
+	if d > p.MaximumDuration {
+		d = p.MaximumDuration
+	}
+	return last.Add(d)
+}
+
+// Locked reports whether a subject is locked at now after the given number of failures, the last of which occurred at last.
+// Failures are forgotten once the maximum duration has elapsed since the last.
+func (p *LockoutPolicy) Locked(threshold int, failures int64, last, now time.Time) bool {
+	if now.Sub(last) > p.MaximumDuration {
+		return false
+	}
+	return now.Before(p.LockedUntil(threshold, failures, last))
+}
+
+func accountSubject(username string) string {
+	return "account:" + username
+}
+
+// secondFactorSubject counts failed codes separately, so a correct password does not reset them.
+func secondFactorSubject(username string) string {
+	return "second-factor:" + username
+}
+
+func clientSubject(address string) string {
+	return "client:" + address
+}
+
+type lockoutSubject struct {
+	subject   string
+	threshold int
+}
+
+// lockoutSubjects returns the subjects whose failures are counted when authenticating the username.
+func (a *authenticator) lockoutSubjects(ctx context.Context, username string) []lockoutSubject {
+	return append([]lockoutSubject{
+		{accountSubject(username), a.lockout.AccountThreshold},
+	}, a.clientLockoutSubjects(ctx)...)
+}
+
+// secondFactorLockoutSubjects returns the subjects whose failures are counted when verifying a second factor for the username.
+func (a *authenticator) secondFactorLockoutSubjects(ctx context.Context, username string) []lockoutSubject {
+	return append([]lockoutSubject{
+		{secondFactorSubject(username), a.lockout.AccountThreshold},
+	}, a.clientLockoutSubjects(ctx)...)
+}
+
+// clientLockoutSubjects returns the subjects whose failures are counted when the account is not yet known.
+func (a *authenticator) clientLockoutSubjects(ctx context.Context) []lockoutSubject {
+	if address := ClientAddress(ctx); address != "" {
+		return []lockoutSubject{
+			{clientSubject(address), a.lockout.ClientThreshold},
+		}
+	}
+	return nil
+}
+
+// checkLockout returns ErrAccountLocked if any of the subjects are locked.
+func (a *authenticator) checkLockout(ctx context.Context, subjects []lockoutSubject, now time.Time) error {
+	for _, s := range subjects {
+		if s.threshold <= 0 {
+			continue
+		}
+		failures, last, err := a.accounts.SelectAuthenticationFailures(ctx, s.subject)
+		if err != nil {
+			return err
+		}
+		if a.lockout.Locked(s.threshold, failures, last, now) {
+			return ErrAccountLocked
+		}
+	}
+	return nil
+}
+
+// recordFailure counts a failed attempt to authenticate against each of the subjects.
+func (a *authenticator) recordFailure(ctx context.Context, subjects []lockoutSubject, now time.Time) {
+	for _, s := range subjects {
+		if s.threshold <= 0 {
+			continue
+		}
+		_, last, err := a.accounts.SelectAuthenticationFailures(ctx, s.subject)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if !last.IsZero() && now.Sub(last) > a.lockout.MaximumDuration {
+			// Forget stale failures
+			if _, err := a.accounts.DeleteAuthenticationFailures(ctx, s.subject); err != nil {
+				log.Println(err)
+				continue
+			}
+		}
+		if _, err := a.accounts.IncrementAuthenticationFailures(ctx, s.subject, now); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+func (a *authenticator) LockoutPolicy() *LockoutPolicy {
+	return a.lockout
+}
+
+func (a *authenticator) SetLockoutPolicy(policy *LockoutPolicy) {
+	a.lockout = policy
+}
+
+func (a *authenticator) UnlockAccount(ctx context.Context, username string) error {
+	if _, err := a.accounts.DeleteAuthenticationFailures(ctx, accountSubject(username)); err != nil {
+		return err
+	}
+	_, err := a.accounts.DeleteAuthenticationFailures(ctx, secondFactorSubject(username))
+	return err
+}
+
+func (a *authenticator) UnlockClient(ctx context.Context, address string) error {
+	_, err := a.accounts.DeleteAuthenticationFailures(ctx, clientSubject(address))
+	return err
+}
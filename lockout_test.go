@@ -0,0 +1,63 @@
+package authgo_test
+
+import (
+	"aletheiaware.com/authgo"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_LockoutPolicy(t *testing.T) {
+	policy := &authgo.LockoutPolicy{
+		AccountThreshold: 3,
+		Duration:         time.Minute,
+		MaximumDuration:  time.Hour,
+	}
+	last := time.Now()
+	for failures, duration := range map[int64]time.Duration{
+		0:  0,
+		2:  0,
+		3:  time.Minute,
+		4:  2 * time.Minute,
+		5:  4 * time.Minute,
+		8:  32 * time.Minute,
+		9:  time.Hour,
+		50: time.Hour,
+	} {
+		until := policy.LockedUntil(policy.AccountThreshold, failures, last)
+		if duration == 0 {
+			assert.True(t, until.IsZero(), failures)
+		} else {
+			assert.Equal(t, last.Add(duration), until, failures)
+		}
+	}
+	t.Run("Disabled", func(t *testing.T) {
+		assert.True(t, policy.LockedUntil(0, 100, last).IsZero())
+	})
+}
+
+func Test_LockoutPolicy_Locked(t *testing.T) {
+	policy := &authgo.LockoutPolicy{
+		Duration:        time.Minute,
+		MaximumDuration: time.Hour,
+	}
+	last := time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for name, tt := range map[string]struct {
+		failures int64
+		elapsed  time.Duration
+		locked   bool
+	}{
+		"Below Threshold":    {1, 0, false},
+		"Locked":             {2, 0, true},
+		"Locked Until":       {2, time.Minute - time.Nanosecond, true},
+		"Expired":            {2, time.Minute, false},
+		"Doubled":            {3, time.Minute, true},
+		"Doubled Expired":    {3, 2 * time.Minute, false},
+		"Maximum":            {20, time.Hour - time.Nanosecond, true},
+		"Failures Forgotten": {20, time.Hour + time.Nanosecond, false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.locked, policy.Locked(2, tt.failures, last, last.Add(tt.elapsed)))
+		})
+	}
+}
@@ -0,0 +1,542 @@
+package authgo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	// OIDC_STATE_LENGTH is the number of random bytes in each state, nonce and PKCE code verifier.
+	OIDC_STATE_LENGTH = 32
+	// OIDC_AUTHORIZATION_TIMEOUT is how long the user has to sign in with the identity provider.
+	OIDC_AUTHORIZATION_TIMEOUT = 10 * time.Minute
+	// OIDC_CLOCK_SKEW is the leeway given to the provider's clock when checking the times in ID tokens.
+	OIDC_CLOCK_SKEW = time.Minute
+	// MAXIMUM_OIDC_RESPONSE_SIZE limits how much of each response from the provider is read, in bytes.
+	MAXIMUM_OIDC_RESPONSE_SIZE = 1 << 20
+)
+
+var (
+	ErrOIDCProviderNotFound      = errors.New("Identity Provider Not Found")
+	ErrOIDCProviderFailed        = errors.New("Identity Provider Request Failed")
+	ErrOIDCAuthorizationExpired  = errors.New("Identity Provider Sign In Expired")
+	ErrOIDCAuthorizationDenied   = errors.New("Identity Provider Sign In Denied")
+	ErrOIDCTokenInvalid          = errors.New("Invalid ID Token")
+	ErrOIDCEmailNotVerified      = errors.New("Identity Provider Email Not Verified")
+	ErrOIDCIdentityNotFound      = errors.New("Identity Not Linked")
+	ErrOIDCIdentityAlreadyLinked = errors.New("Identity Already Linked To Another Account")
+)
+
+// OIDCProvider is an OpenID Connect identity provider which users can sign in with.
+type OIDCProvider struct {
+	// ID identifies the provider in forms and linked identities, such as "google".
+	ID string
+	// Name is shown to the user, such as "Google".
+	Name string
+	// Issuer is the URL of the provider, from which its configuration is discovered.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the absolute URL of the callback registered with the provider, such as "https://example.com/sign-in-oidc-callback".
+	RedirectURL string
+	// Scopes are requested in addition to "openid", and default to "email" and "profile".
+	Scopes []string
+	// Client makes requests to the provider, or http.DefaultClient if nil.
+	Client *http.Client
+
+	mutex         sync.Mutex
+	configuration *OIDCConfiguration
+	keys          *JSONWebKeySet
+}
+
+// OIDCConfiguration is the part of a provider's discovery document used to sign in, see https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata.
+type OIDCConfiguration struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ScopesSupported                   []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported            []string `json:"response_types_supported,omitempty"`
+	GrantTypesSupported               []string `json:"grant_types_supported,omitempty"`
+	SubjectTypesSupported             []string `json:"subject_types_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported,omitempty"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported,omitempty"`
+	ClaimsSupported                   []string `json:"claims_supported,omitempty"`
+}
+
+// OIDCClaims are the claims of an ID token used to identify the user.
+type OIDCClaims struct {
+	Issuer            string       `json:"iss"`
+	Subject           string       `json:"sub"`
+	Audience          OIDCAudience `json:"aud"`
+	AuthorizedParty   string       `json:"azp"`
+	Expiry            int64        `json:"exp"`
+	IssuedAt          int64        `json:"iat"`
+	Nonce             string       `json:"nonce"`
+	Email             string       `json:"email"`
+	EmailVerified     bool         `json:"email_verified"`
+	Name              string       `json:"name"`
+	PreferredUsername string       `json:"preferred_username"`
+}
+
+// OIDCAudience is either a single audience or a list of audiences.
+type OIDCAudience []string
+
+func (a *OIDCAudience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = OIDCAudience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(data, &multiple); err != nil {
+		return err
+	}
+	*a = OIDCAudience(multiple)
+	return nil
+}
+
+func (a OIDCAudience) Contains(audience string) bool {
+	for _, s := range a {
+		if s == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// OIDCAuthorization is a sign in with an identity provider which is waiting for the user to be redirected back.
+type OIDCAuthorization struct {
+	// State identifies the authorization in the redirect back from the provider.
+	State string
+	// Session is the token of the sign in session which began the authorization, and which alone can complete it.
+	Session  string
+	Provider string
+	Nonce    string
+	// Verifier is the PKCE code verifier.
+	Verifier string
+	// Next is where to go once signed in.
+	Next    string
+	Created time.Time
+}
+
+func NewOIDCState() (string, error) {
+	state := make([]byte, OIDC_STATE_LENGTH)
+	if _, err := rand.Read(state); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(state), nil
+}
+
+// OIDCCodeChallenge returns the S256 PKCE code challenge of the verifier.
+func OIDCCodeChallenge(verifier string) string {
+	hash := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func (p *OIDCProvider) client() *http.Client {
+	if p.Client == nil {
+		return http.DefaultClient
+	}
+	return p.Client
+}
+
+func (p *OIDCProvider) getJSON(ctx context.Context, u string, v interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+	response, err := p.client().Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %s returned %d", ErrOIDCProviderFailed, u, response.StatusCode)
+	}
+	return json.NewDecoder(io.LimitReader(response.Body, MAXIMUM_OIDC_RESPONSE_SIZE)).Decode(v)
+}
+
+// Configuration returns the provider's configuration, which is discovered on first use.
+func (p *OIDCProvider) Configuration(ctx context.Context) (*OIDCConfiguration, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.configuration != nil {
+		return p.configuration, nil
+	}
+	c := &OIDCConfiguration{}
+	if err := p.getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", c); err != nil {
+		return nil, err
+	}
+	if c.Issuer != p.Issuer {
+		return nil, fmt.Errorf("%w: issuer %s does not match %s", ErrOIDCProviderFailed, c.Issuer, p.Issuer)
+	}
+	if c.AuthorizationEndpoint == "" || c.TokenEndpoint == "" || c.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete configuration", ErrOIDCProviderFailed)
+	}
+	p.configuration = c
+	return c, nil
+}
+
+// keySet returns the provider's signing keys, fetching them if they have not been fetched yet, or refresh is true.
+func (p *OIDCProvider) keySet(ctx context.Context, c *OIDCConfiguration, refresh bool) (*JSONWebKeySet, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.keys != nil && !refresh {
+		return p.keys, nil
+	}
+	keys := &JSONWebKeySet{}
+	if err := p.getJSON(ctx, c.JWKSURI, keys); err != nil {
+		return nil, err
+	}
+	p.keys = keys
+	return keys, nil
+}
+
+// AuthorizationURL returns the URL the user is sent to in order to sign in with the provider.
+func (p *OIDCProvider) AuthorizationURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	c, err := p.Configuration(ctx)
+	if err != nil {
+		return "", err
+	}
+	scopes := p.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"email", "profile"}
+	}
+	values := url.Values{}
+	values.Set("response_type", "code")
+	values.Set("client_id", p.ClientID)
+	values.Set("redirect_uri", p.RedirectURL)
+	values.Set("scope", strings.Join(append([]string{"openid"}, scopes...), " "))
+	values.Set("state", state)
+	values.Set("nonce", nonce)
+	values.Set("code_challenge", OIDCCodeChallenge(verifier))
+	values.Set("code_challenge_method", "S256")
+	separator := "?"
+	if strings.Contains(c.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return c.AuthorizationEndpoint + separator + values.Encode(), nil
+}
+
+// Exchange redeems the authorization code at the provider's token endpoint, returning the ID token.
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier string) (string, error) {
+	c, err := p.Configuration(ctx)
+	if err != nil {
+		return "", err
+	}
+	values := url.Values{}
+	values.Set("grant_type", "authorization_code")
+	values.Set("code", code)
+	values.Set("redirect_uri", p.RedirectURL)
+	values.Set("code_verifier", verifier)
+	values.Set("client_id", p.ClientID)
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenEndpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return "", err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.ClientSecret != "" {
+		request.SetBasicAuth(url.QueryEscape(p.ClientID), url.QueryEscape(p.ClientSecret))
+	}
+	response, err := p.client().Do(request)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+	var result struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(response.Body, MAXIMUM_OIDC_RESPONSE_SIZE)).Decode(&result); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrOIDCProviderFailed, err)
+	}
+	if response.StatusCode != http.StatusOK || result.Error != "" {
+		return "", fmt.Errorf("%w: %d %s %s", ErrOIDCProviderFailed, response.StatusCode, result.Error, result.ErrorDescription)
+	}
+	if result.IDToken == "" {
+		return "", fmt.Errorf("%w: missing ID token", ErrOIDCProviderFailed)
+	}
+	return result.IDToken, nil
+}
+
+// VerifyIDToken checks the signature, issuer, audience, times and nonce of the ID token, returning its claims.
+func (p *OIDCProvider) VerifyIDToken(ctx context.Context, token, nonce string, now time.Time) (*OIDCClaims, error) {
+	c, err := p.Configuration(ctx)
+	if err != nil {
+		return nil, err
+	}
+	header, payload, input, signature, err := ParseJWT(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCTokenInvalid, err)
+	}
+	if err := p.verifySignature(ctx, c, header, input, signature); err != nil {
+		return nil, err
+	}
+	claims := &OIDCClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, fmt.Errorf("%w: claims", ErrOIDCTokenInvalid)
+	}
+	if claims.Issuer != c.Issuer {
+		return nil, fmt.Errorf("%w: issuer", ErrOIDCTokenInvalid)
+	}
+	if !claims.Audience.Contains(p.ClientID) {
+		return nil, fmt.Errorf("%w: audience", ErrOIDCTokenInvalid)
+	}
+	if (len(claims.Audience) > 1 || claims.AuthorizedParty != "") && claims.AuthorizedParty != p.ClientID {
+		return nil, fmt.Errorf("%w: authorized party", ErrOIDCTokenInvalid)
+	}
+	if !now.Before(time.Unix(claims.Expiry, 0).Add(OIDC_CLOCK_SKEW)) {
+		return nil, fmt.Errorf("%w: expired", ErrOIDCTokenInvalid)
+	}
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(OIDC_CLOCK_SKEW)) {
+		return nil, fmt.Errorf("%w: issued in the future", ErrOIDCTokenInvalid)
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.Nonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce", ErrOIDCTokenInvalid)
+	}
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: subject", ErrOIDCTokenInvalid)
+	}
+	return claims, nil
+}
+
+// verifySignature verifies the signature with the provider's keys, fetching them again once if none match in case they have been rotated.
+func (p *OIDCProvider) verifySignature(ctx context.Context, c *OIDCConfiguration, header *JWTHeader, input, signature []byte) error {
+	if header.Alg != JWT_ALGORITHM_RS256 && header.Alg != JWT_ALGORITHM_ES256 {
+		return fmt.Errorf("%w: algorithm %s", ErrOIDCTokenInvalid, header.Alg)
+	}
+	for _, refresh := range []bool{false, true} {
+		keys, err := p.keySet(ctx, c, refresh)
+		if err != nil {
+			return err
+		}
+		for _, k := range keys.Keys {
+			if (header.Kid != "" && k.Kid != header.Kid) || !k.Supports(header.Alg) {
+				continue
+			}
+			key, err := k.PublicKey()
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			if err := VerifyJWTSignature(header.Alg, key, input, signature); err == nil {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: signature", ErrOIDCTokenInvalid)
+}
+
+func (a *authenticator) OIDCProviders() []*OIDCProvider {
+	return a.providers
+}
+
+func (a *authenticator) SetOIDCProviders(providers []*OIDCProvider) {
+	a.providers = providers
+}
+
+func (a *authenticator) oidcProvider(id string) (*OIDCProvider, error) {
+	for _, p := range a.providers {
+		if p.ID == id {
+			return p, nil
+		}
+	}
+	return nil, ErrOIDCProviderNotFound
+}
+
+// NewOIDCAuthorization begins signing in the session with the identity provider, returning the URL to send the user to.
+func (a *authenticator) NewOIDCAuthorization(ctx context.Context, session, provider, next string) (string, error) {
+	p, err := a.oidcProvider(provider)
+	if err != nil {
+		return "", err
+	}
+	var values [3]string
+	for i := range values {
+		v, err := NewOIDCState()
+		if err != nil {
+			return "", err
+		}
+		values[i] = v
+	}
+	authorization := &OIDCAuthorization{
+		State:    values[0],
+		Session:  session,
+		Provider: p.ID,
+		Nonce:    values[1],
+		Verifier: values[2],
+		Next:     next,
+		Created:  time.Now(),
+	}
+	u, err := p.AuthorizationURL(ctx, authorization.State, authorization.Nonce, authorization.Verifier)
+	if err != nil {
+		return "", err
+	}
+	if _, err := a.sessions.CreateOIDCAuthorization(ctx, authorization); err != nil {
+		return "", err
+	}
+	return u, nil
+}
+
+// AuthenticateOIDC completes the authorization with the code the provider redirected back with, returning the account and where to go next.
+//
+// The account is the one already linked to the identity, otherwise the identity is linked to the account the session is signed in to,
+// or the account with the same verified email address, or a new account if there is none.
+func (a *authenticator) AuthenticateOIDC(ctx context.Context, session, state, code string) (*Account, string, error) {
+	now := time.Now()
+	if err := a.checkLockout(ctx, a.clientLockoutSubjects(ctx), now); err != nil {
+		return nil, "", err
+	}
+	authorization, err := a.sessions.SelectOIDCAuthorization(ctx, state)
+	if err != nil || authorization.Created.Add(OIDC_AUTHORIZATION_TIMEOUT).Before(now) || subtle.ConstantTimeCompare([]byte(authorization.Session), []byte(session)) != 1 {
+		// The state must have been issued to this session, otherwise another site could sign the user in to an account of its choosing
+		return nil, "", ErrOIDCAuthorizationExpired
+	}
+	// Fails if the authorization was completed concurrently
+	if _, err := a.sessions.DeleteOIDCAuthorization(ctx, state); err != nil {
+		return nil, "", ErrOIDCAuthorizationExpired
+	}
+	next := authorization.Next
+	p, err := a.oidcProvider(authorization.Provider)
+	if err != nil {
+		return nil, next, err
+	}
+	token, err := p.Exchange(ctx, code, authorization.Verifier)
+	if err != nil {
+		return nil, next, err
+	}
+	claims, err := p.VerifyIDToken(ctx, token, authorization.Nonce, now)
+	if err != nil {
+		return nil, next, err
+	}
+	account, err := a.oidcAccount(ctx, session, p, claims)
+	if err != nil {
+		return nil, next, err
+	}
+	if err := a.checkLockout(ctx, a.lockoutSubjects(ctx, account.Username), now); err != nil {
+		return nil, next, err
+	}
+	return account, next, nil
+}
+
+// oidcAccount returns the account for the identity, linking or creating one as necessary.
+func (a *authenticator) oidcAccount(ctx context.Context, session string, p *OIDCProvider, claims *OIDCClaims) (*Account, error) {
+	var current string
+	if s, err := a.sessions.SelectSignInSession(ctx, session); err == nil && s.Authenticated {
+		current = s.Username
+	}
+	username, err := a.accounts.SelectOIDCIdentity(ctx, p.ID, claims.Subject)
+	switch {
+	case err == nil:
+		if current != "" && current != username {
+			return nil, ErrOIDCIdentityAlreadyLinked
+		}
+		return a.LookupAccount(ctx, username)
+	case !errors.Is(err, ErrOIDCIdentityNotFound):
+		return nil, err
+	case current != "":
+		return a.linkOIDCIdentity(ctx, current, p, claims)
+	}
+	if !claims.EmailVerified || claims.Email == "" {
+		return nil, ErrOIDCEmailNotVerified
+	}
+	if username, err := a.accounts.SelectUsernameByEmail(ctx, claims.Email); err == nil {
+		if !a.IsEmailVerified(ctx, claims.Email) {
+			// The address may have been registered by someone else, who has yet to prove they own it
+			return nil, ErrEmailAlreadyRegistered
+		}
+		return a.linkOIDCIdentity(ctx, username, p, claims)
+	}
+	account, err := a.newOIDCAccount(ctx, claims)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.accounts.CreateOIDCIdentity(ctx, account.Username, p.ID, claims.Subject, time.Now()); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+func (a *authenticator) linkOIDCIdentity(ctx context.Context, username string, p *OIDCProvider, claims *OIDCClaims) (*Account, error) {
+	if _, err := a.accounts.CreateOIDCIdentity(ctx, username, p.ID, claims.Subject, time.Now()); err != nil {
+		return nil, err
+	}
+	log.Println("Linked", p.ID, "identity to", username)
+	a.notify(ctx, username, fmt.Sprintf("Your account can now be signed in to with %s. If this was not you, contact support.", p.Name))
+	return a.LookupAccount(ctx, username)
+}
+
+// newOIDCAccount creates an account with a username derived from the claims, and a random password which can later be replaced through account recovery.
+func (a *authenticator) newOIDCAccount(ctx context.Context, claims *OIDCClaims) (*Account, error) {
+	base := oidcUsername(claims)
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+	for i := 1; i <= 100; i++ {
+		username := base
+		if i > 1 {
+			username += strconv.Itoa(i)
+		}
+		account, err := a.NewAccount(ctx, claims.Email, username, []byte(base64.RawURLEncoding.EncodeToString(password)))
+		if errors.Is(err, ErrUsernameAlreadyRegistered) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := a.SetEmailVerified(ctx, claims.Email, true); err != nil {
+			return nil, err
+		}
+		return account, nil
+	}
+	return nil, ErrUsernameAlreadyRegistered
+}
+
+// oidcUsername returns a valid username based on the preferred username or email address in the claims.
+func oidcUsername(claims *OIDCClaims) string {
+	filter := func(s string) string {
+		return strings.Map(func(c rune) rune {
+			if unicode.IsLetter(c) || unicode.IsNumber(c) {
+				return c
+			}
+			return -1
+		}, s)
+	}
+	username := filter(claims.PreferredUsername)
+	if len(username) < MINIMUM_USERNAME_LENGTH {
+		username = filter(strings.Split(claims.Email, "@")[0])
+	}
+	if len(username) < MINIMUM_USERNAME_LENGTH {
+		username = "user"
+	}
+	// Leave room for a number to be appended
+	if limit := MAXIMUM_USERNAME_LENGTH - 3; len(username) > limit {
+		runes := []rune(username)
+		for len(string(runes)) > limit {
+			runes = runes[:len(runes)-1]
+		}
+		username = string(runes)
+	}
+	return username
+}
@@ -0,0 +1,709 @@
+package authgo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// OIDC_CODE_TIMEOUT is how long a client has to redeem an authorization code.
+	OIDC_CODE_TIMEOUT = time.Minute
+	// OIDC_ACCESS_TOKEN_TIMEOUT is how long access tokens and ID tokens issued to clients are valid for.
+	OIDC_ACCESS_TOKEN_TIMEOUT = time.Hour
+	// OIDC_REFRESH_TOKEN_TIMEOUT is how long a client can use a refresh token, which is replaced each time it is used.
+	OIDC_REFRESH_TOKEN_TIMEOUT = 30 * 24 * time.Hour
+	// DEFAULT_OIDC_KEY_ROTATION is how long a signing key is used before it is replaced.
+	DEFAULT_OIDC_KEY_ROTATION = 30 * 24 * time.Hour
+	// OIDC_SIGNING_KEY_SIZE is the size of the RSA signing keys, in bits.
+	OIDC_SIGNING_KEY_SIZE = 2048
+	// OIDC_ACCESS_TOKEN_TYPE is the type of access tokens, see https://www.rfc-editor.org/rfc/rfc9068.
+	OIDC_ACCESS_TOKEN_TYPE = "at+jwt"
+)
+
+// OIDC_SCOPES are the scopes a client can request.
+var OIDC_SCOPES = []string{"openid", "email", "profile"}
+
+var (
+	ErrOIDCIssuerNotConfigured     = errors.New("OpenID Connect Issuer Not Configured")
+	ErrOIDCClientNotFound          = errors.New("Client Not Found")
+	ErrOIDCClientUnauthorized      = errors.New("Client Authentication Failed")
+	ErrOIDCRedirectURIInvalid      = errors.New("Redirect URI Not Registered")
+	ErrOIDCRequestInvalid          = errors.New("Invalid Authorization Request")
+	ErrOIDCResponseTypeUnsupported = errors.New("Response Type Not Supported")
+	ErrOIDCScopeInvalid            = errors.New("Invalid Scope")
+	ErrOIDCGrantInvalid            = errors.New("Invalid Or Expired Grant")
+	ErrOIDCGrantUnsupported        = errors.New("Grant Type Not Supported")
+	ErrOIDCAccessTokenInvalid      = errors.New("Invalid Access Token")
+)
+
+// OIDCClient is an application registered to sign its users in with this service.
+type OIDCClient struct {
+	ID   string
+	Name string
+	// Secret is the SHA-256 hash of the client secret, or nil for a public client, such as a mobile app, which cannot keep a secret.
+	Secret []byte
+	// RedirectURIs are the only URIs an authorization can be returned to.
+	RedirectURIs []string
+	Created      time.Time
+}
+
+// Confidential returns true if the client must authenticate with its secret.
+func (c *OIDCClient) Confidential() bool {
+	return len(c.Secret) > 0
+}
+
+func (c *OIDCClient) hasRedirectURI(uri string) bool {
+	for _, u := range c.RedirectURIs {
+		if u == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// OIDCSigningKey is a key which tokens issued to clients are signed with.
+type OIDCSigningKey struct {
+	ID string
+	// PrivateKey is the PKCS #8 encoding of the RSA private key.
+	PrivateKey []byte
+	Created    time.Time
+}
+
+// OIDCCode is an authorization code granted to a client, waiting to be exchanged for tokens.
+type OIDCCode struct {
+	// Hash is the SHA-256 hash of the code, so stored codes cannot be redeemed.
+	Hash        string
+	ClientID    string
+	Username    string
+	RedirectURI string
+	Scope       string
+	Nonce       string
+	// Challenge is the S256 PKCE code challenge the client must prove it knows the verifier of.
+	Challenge string
+	Created   time.Time
+}
+
+// OIDCRefreshToken lets a client get new tokens without the user signing in again.
+type OIDCRefreshToken struct {
+	// Hash is the SHA-256 hash of the token, so stored tokens cannot be used.
+	Hash     string
+	ClientID string
+	Username string
+	Scope    string
+	Created  time.Time
+}
+
+// OIDCTokens are the tokens issued to a client, as returned from the token endpoint.
+type OIDCTokens struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// OIDCAuthorizationRequest is a client's request for the user to sign in, see https://openid.net/specs/openid-connect-core-1_0.html#AuthRequest.
+type OIDCAuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+}
+
+func ParseOIDCAuthorizationRequest(values url.Values) *OIDCAuthorizationRequest {
+	return &OIDCAuthorizationRequest{
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		ResponseType:        values.Get("response_type"),
+		Scope:               values.Get("scope"),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+	}
+}
+
+// Values returns the request encoded as URL parameters, the reverse of ParseOIDCAuthorizationRequest.
+func (r *OIDCAuthorizationRequest) Values() url.Values {
+	values := url.Values{}
+	for k, v := range map[string]string{
+		"client_id":             r.ClientID,
+		"redirect_uri":          r.RedirectURI,
+		"response_type":         r.ResponseType,
+		"scope":                 r.Scope,
+		"state":                 r.State,
+		"nonce":                 r.Nonce,
+		"code_challenge":        r.CodeChallenge,
+		"code_challenge_method": r.CodeChallengeMethod,
+	} {
+		if v != "" {
+			values.Set(k, v)
+		}
+	}
+	return values
+}
+
+func (r *OIDCAuthorizationRequest) Scopes() []string {
+	return strings.Fields(r.Scope)
+}
+
+// Response returns the redirect URI with the parameters, and the state, added to the query.
+func (r *OIDCAuthorizationRequest) Response(values url.Values) string {
+	if r.State != "" {
+		values.Set("state", r.State)
+	}
+	separator := "?"
+	if strings.Contains(r.RedirectURI, "?") {
+		separator = "&"
+	}
+	return r.RedirectURI + separator + values.Encode()
+}
+
+// OIDCErrorCode returns the OAuth 2.0 error code a client is sent for the error, see https://www.rfc-editor.org/rfc/rfc6749#section-5.2.
+func OIDCErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrOIDCClientNotFound),
+		errors.Is(err, ErrOIDCClientUnauthorized):
+		return "invalid_client"
+	case errors.Is(err, ErrOIDCRedirectURIInvalid),
+		errors.Is(err, ErrOIDCRequestInvalid):
+		return "invalid_request"
+	case errors.Is(err, ErrOIDCResponseTypeUnsupported):
+		return "unsupported_response_type"
+	case errors.Is(err, ErrOIDCScopeInvalid):
+		return "invalid_scope"
+	case errors.Is(err, ErrOIDCGrantInvalid):
+		return "invalid_grant"
+	case errors.Is(err, ErrOIDCGrantUnsupported):
+		return "unsupported_grant_type"
+	case errors.Is(err, ErrOIDCAccessTokenInvalid):
+		return "invalid_token"
+	case errors.Is(err, ErrOIDCAuthorizationDenied):
+		return "access_denied"
+	}
+	return "server_error"
+}
+
+// oidcHash returns the encoded SHA-256 hash of the secret, under which it is stored.
+func oidcHash(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return base64.RawURLEncoding.EncodeToString(hash[:])
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *authenticator) OIDCIssuer() string {
+	return a.issuer
+}
+
+// SetOIDCIssuer sets the absolute URL this service is known to clients as, such as "https://example.com", which enables the identity provider.
+func (a *authenticator) SetOIDCIssuer(issuer string) {
+	a.issuer = strings.TrimSuffix(issuer, "/")
+}
+
+func (a *authenticator) OIDCKeyRotation() time.Duration {
+	return a.keyRotation
+}
+
+// SetOIDCKeyRotation sets how long each signing key is used, falling back to DEFAULT_OIDC_KEY_ROTATION if not positive.
+func (a *authenticator) SetOIDCKeyRotation(rotation time.Duration) {
+	if rotation <= 0 {
+		log.Println("Invalid OIDC Key Rotation", rotation, "using", DEFAULT_OIDC_KEY_ROTATION)
+		rotation = DEFAULT_OIDC_KEY_ROTATION
+	}
+	a.keyRotation = rotation
+}
+
+// OIDCConfiguration returns the discovery document clients use to find the endpoints and features of this service.
+func (a *authenticator) OIDCConfiguration() (*OIDCConfiguration, error) {
+	if a.issuer == "" {
+		return nil, ErrOIDCIssuerNotConfigured
+	}
+	return &OIDCConfiguration{
+		Issuer:                            a.issuer,
+		AuthorizationEndpoint:             a.issuer + "/authorize",
+		TokenEndpoint:                     a.issuer + "/token",
+		UserInfoEndpoint:                  a.issuer + "/userinfo",
+		JWKSURI:                           a.issuer + "/jwks",
+		ScopesSupported:                   OIDC_SCOPES,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{"authorization_code", "refresh_token"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{JWT_ALGORITHM_RS256},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{"S256"},
+		ClaimsSupported:                   []string{"sub", "email", "email_verified", "preferred_username"},
+	}, nil
+}
+
+// RotateOIDCSigningKey replaces the signing key immediately, such as when it may have been compromised.
+// The previous key continues to be published until tokens signed with it have expired.
+func (a *authenticator) RotateOIDCSigningKey(ctx context.Context) error {
+	_, _, err := a.newOIDCSigningKey(ctx)
+	return err
+}
+
+// OIDCJSONWebKeySet returns the public keys clients verify tokens with.
+func (a *authenticator) OIDCJSONWebKeySet(ctx context.Context) (*JSONWebKeySet, error) {
+	now := time.Now()
+	// Ensures there is a current key to publish
+	if _, _, err := a.oidcSigningKey(ctx, now); err != nil {
+		return nil, err
+	}
+	keys, err := a.oidcPublishedKeys(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	set := &JSONWebKeySet{
+		Keys: []*JSONWebKey{},
+	}
+	for id, k := range keys {
+		key, err := NewJSONWebKey(id, k.Public())
+		if err != nil {
+			return nil, err
+		}
+		set.Keys = append(set.Keys, key)
+	}
+	return set, nil
+}
+
+// oidcPublishedKeys returns the keys which may have signed a token that is still valid, by ID.
+// Each key is used for one rotation period, so keys older than two periods can only have signed expired tokens.
+func (a *authenticator) oidcPublishedKeys(ctx context.Context, now time.Time) (map[string]*rsa.PrivateKey, error) {
+	keys, err := a.accounts.SelectOIDCSigningKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	published := make(map[string]*rsa.PrivateKey)
+	for _, k := range keys {
+		if k.Created.Add(2 * a.keyRotation).Before(now) {
+			continue
+		}
+		key, err := parseOIDCSigningKey(k)
+		if err != nil {
+			return nil, err
+		}
+		published[k.ID] = key
+	}
+	return published, nil
+}
+
+// oidcSigningKey returns the newest key, replacing it if it is due to be rotated.
+func (a *authenticator) oidcSigningKey(ctx context.Context, now time.Time) (string, *rsa.PrivateKey, error) {
+	keys, err := a.accounts.SelectOIDCSigningKeys(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(keys) > 0 && keys[0].Created.Add(a.keyRotation).After(now) {
+		key, err := parseOIDCSigningKey(keys[0])
+		if err != nil {
+			return "", nil, err
+		}
+		return keys[0].ID, key, nil
+	}
+	return a.newOIDCSigningKey(ctx)
+}
+
+func (a *authenticator) newOIDCSigningKey(ctx context.Context) (string, *rsa.PrivateKey, error) {
+	key, err := rsa.GenerateKey(rand.Reader, OIDC_SIGNING_KEY_SIZE)
+	if err != nil {
+		return "", nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return "", nil, err
+	}
+	id, err := NewOIDCState()
+	if err != nil {
+		return "", nil, err
+	}
+	id = id[:16]
+	if _, err := a.accounts.CreateOIDCSigningKey(ctx, &OIDCSigningKey{
+		ID:         id,
+		PrivateKey: der,
+		Created:    time.Now(),
+	}); err != nil {
+		return "", nil, err
+	}
+	log.Println("Created OIDC Signing Key", id)
+	return id, key, nil
+}
+
+func parseOIDCSigningKey(k *OIDCSigningKey) (*rsa.PrivateKey, error) {
+	key, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: %T", ErrJWKUnsupported, key)
+	}
+	return rsaKey, nil
+}
+
+// NewOIDCClient registers a client, returning it and its secret, which is only stored hashed and so must be given to the client now.
+// Public clients, which cannot keep a secret, are given none.
+func (a *authenticator) NewOIDCClient(ctx context.Context, name string, redirectURIs []string, confidential bool) (*OIDCClient, string, error) {
+	if len(redirectURIs) == 0 {
+		return nil, "", ErrOIDCRedirectURIInvalid
+	}
+	for _, uri := range redirectURIs {
+		u, err := url.Parse(uri)
+		if err != nil || !u.IsAbs() || u.Fragment != "" || strings.ContainsAny(uri, " \t\r\n") {
+			return nil, "", fmt.Errorf("%w: %s", ErrOIDCRedirectURIInvalid, uri)
+		}
+	}
+	id, err := NewOIDCState()
+	if err != nil {
+		return nil, "", err
+	}
+	client := &OIDCClient{
+		ID:           id[:22],
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Created:      time.Now(),
+	}
+	var secret string
+	if confidential {
+		secret, err = NewOIDCState()
+		if err != nil {
+			return nil, "", err
+		}
+		hash := sha256.Sum256([]byte(secret))
+		client.Secret = hash[:]
+	}
+	if _, err := a.accounts.CreateOIDCClient(ctx, client); err != nil {
+		return nil, "", err
+	}
+	log.Println("Registered OIDC Client", client.ID, "for", name)
+	return client, secret, nil
+}
+
+func (a *authenticator) LookupOIDCClient(ctx context.Context, id string) (*OIDCClient, error) {
+	return a.accounts.SelectOIDCClient(ctx, id)
+}
+
+// DeleteOIDCClient unregisters the client, after which its tokens are no longer accepted.
+func (a *authenticator) DeleteOIDCClient(ctx context.Context, id string) error {
+	_, err := a.accounts.DeleteOIDCClient(ctx, id)
+	return err
+}
+
+// AuthenticateOIDCClient returns the client if the secret is correct, or empty for a public client.
+func (a *authenticator) AuthenticateOIDCClient(ctx context.Context, id, secret string) (*OIDCClient, error) {
+	client, err := a.accounts.SelectOIDCClient(ctx, id)
+	if err != nil {
+		log.Println(err)
+		return nil, ErrOIDCClientUnauthorized
+	}
+	if !client.Confidential() {
+		if secret != "" {
+			return nil, ErrOIDCClientUnauthorized
+		}
+		return client, nil
+	}
+	hash := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(hash[:], client.Secret) != 1 {
+		return nil, ErrOIDCClientUnauthorized
+	}
+	return client, nil
+}
+
+// VerifyOIDCAuthorizationRequest returns the client making the request if it is valid.
+//
+// If the client is not found or the redirect URI is not registered the user must not be redirected, for any other error
+// the user can be sent back to the client with the error.
+func (a *authenticator) VerifyOIDCAuthorizationRequest(ctx context.Context, request *OIDCAuthorizationRequest) (*OIDCClient, error) {
+	if a.issuer == "" {
+		return nil, ErrOIDCIssuerNotConfigured
+	}
+	client, err := a.accounts.SelectOIDCClient(ctx, request.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if !client.hasRedirectURI(request.RedirectURI) {
+		return nil, ErrOIDCRedirectURIInvalid
+	}
+	if request.ResponseType != "code" {
+		return client, ErrOIDCResponseTypeUnsupported
+	}
+	scopes := request.Scopes()
+	if !containsScope(scopes, "openid") {
+		return client, fmt.Errorf("%w: openid is required", ErrOIDCScopeInvalid)
+	}
+	for _, s := range scopes {
+		if !containsScope(OIDC_SCOPES, s) {
+			return client, fmt.Errorf("%w: %s", ErrOIDCScopeInvalid, s)
+		}
+	}
+	// PKCE is required of all clients, see https://www.rfc-editor.org/rfc/rfc9700#section-2.1.1
+	if request.CodeChallenge == "" || request.CodeChallengeMethod != "S256" {
+		return client, fmt.Errorf("%w: S256 code challenge is required", ErrOIDCRequestInvalid)
+	}
+	return client, nil
+}
+
+// NewOIDCCode grants the authorization request, which must have been verified, to the user, returning the code the client is sent.
+func (a *authenticator) NewOIDCCode(ctx context.Context, username string, request *OIDCAuthorizationRequest) (string, error) {
+	code, err := NewOIDCState()
+	if err != nil {
+		return "", err
+	}
+	if _, err := a.sessions.CreateOIDCCode(ctx, &OIDCCode{
+		Hash:        oidcHash(code),
+		ClientID:    request.ClientID,
+		Username:    username,
+		RedirectURI: request.RedirectURI,
+		Scope:       request.Scope,
+		Nonce:       request.Nonce,
+		Challenge:   request.CodeChallenge,
+		Created:     time.Now(),
+	}); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// RedeemOIDCCode exchanges the code granted to the client for tokens, if the verifier matches the code challenge.
+func (a *authenticator) RedeemOIDCCode(ctx context.Context, client *OIDCClient, code, redirectURI, verifier string) (*OIDCTokens, error) {
+	now := time.Now()
+	hash := oidcHash(code)
+	c, err := a.sessions.SelectOIDCCode(ctx, hash)
+	if err != nil {
+		log.Println(err)
+		return nil, ErrOIDCGrantInvalid
+	}
+	// Fails if the code was redeemed concurrently
+	if _, err := a.sessions.DeleteOIDCCode(ctx, hash); err != nil {
+		log.Println(err)
+		return nil, ErrOIDCGrantInvalid
+	}
+	if c.Created.Add(OIDC_CODE_TIMEOUT).Before(now) {
+		return nil, fmt.Errorf("%w: expired", ErrOIDCGrantInvalid)
+	}
+	if c.ClientID != client.ID || c.RedirectURI != redirectURI {
+		return nil, fmt.Errorf("%w: client", ErrOIDCGrantInvalid)
+	}
+	if subtle.ConstantTimeCompare([]byte(OIDCCodeChallenge(verifier)), []byte(c.Challenge)) != 1 {
+		return nil, fmt.Errorf("%w: code verifier", ErrOIDCGrantInvalid)
+	}
+	account, err := a.LookupAccount(ctx, c.Username)
+	if err != nil {
+		log.Println(err)
+		return nil, ErrOIDCGrantInvalid
+	}
+	return a.newOIDCTokens(ctx, client, account, c.Scope, c.Nonce, now)
+}
+
+// RefreshOIDCTokens exchanges the refresh token issued to the client for new tokens, optionally with fewer scopes.
+// The refresh token is replaced, and can no longer be used.
+func (a *authenticator) RefreshOIDCTokens(ctx context.Context, client *OIDCClient, token, scope string) (*OIDCTokens, error) {
+	now := time.Now()
+	hash := oidcHash(token)
+	t, err := a.accounts.SelectOIDCRefreshToken(ctx, hash)
+	if err != nil {
+		log.Println(err)
+		return nil, ErrOIDCGrantInvalid
+	}
+	if t.ClientID != client.ID {
+		return nil, fmt.Errorf("%w: client", ErrOIDCGrantInvalid)
+	}
+	// Fails if the token was used concurrently
+	if _, err := a.accounts.DeleteOIDCRefreshToken(ctx, hash); err != nil {
+		log.Println(err)
+		return nil, ErrOIDCGrantInvalid
+	}
+	if t.Created.Add(OIDC_REFRESH_TOKEN_TIMEOUT).Before(now) {
+		return nil, fmt.Errorf("%w: expired", ErrOIDCGrantInvalid)
+	}
+	if scope == "" {
+		scope = t.Scope
+	} else {
+		granted := strings.Fields(t.Scope)
+		for _, s := range strings.Fields(scope) {
+			if !containsScope(granted, s) {
+				return nil, fmt.Errorf("%w: %s was not granted", ErrOIDCScopeInvalid, s)
+			}
+		}
+	}
+	account, err := a.LookupAccount(ctx, t.Username)
+	if err != nil {
+		log.Println(err)
+		return nil, ErrOIDCGrantInvalid
+	}
+	return a.newOIDCTokens(ctx, client, account, scope, "", now)
+}
+
+// oidcAccessTokenClaims are the claims of access tokens issued to clients.
+type oidcAccessTokenClaims struct {
+	Issuer   string `json:"iss"`
+	Subject  string `json:"sub"`
+	Audience string `json:"aud"`
+	ClientID string `json:"client_id"`
+	Expiry   int64  `json:"exp"`
+	IssuedAt int64  `json:"iat"`
+	ID       string `json:"jti"`
+	Scope    string `json:"scope"`
+}
+
+func (a *authenticator) newOIDCTokens(ctx context.Context, client *OIDCClient, account *Account, scope, nonce string, now time.Time) (*OIDCTokens, error) {
+	if a.issuer == "" {
+		return nil, ErrOIDCIssuerNotConfigured
+	}
+	kid, key, err := a.oidcSigningKey(ctx, now)
+	if err != nil {
+		return nil, err
+	}
+	jti, err := NewOIDCState()
+	if err != nil {
+		return nil, err
+	}
+	expiry := now.Add(OIDC_ACCESS_TOKEN_TIMEOUT)
+	access, err := SignJWT(&JWTHeader{
+		Kid: kid,
+		Typ: OIDC_ACCESS_TOKEN_TYPE,
+	}, &oidcAccessTokenClaims{
+		Issuer:   a.issuer,
+		Subject:  account.Username,
+		Audience: client.ID,
+		ClientID: client.ID,
+		Expiry:   expiry.Unix(),
+		IssuedAt: now.Unix(),
+		ID:       jti,
+		Scope:    scope,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := NewOIDCState()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.accounts.CreateOIDCRefreshToken(ctx, &OIDCRefreshToken{
+		Hash:     oidcHash(refresh),
+		ClientID: client.ID,
+		Username: account.Username,
+		Scope:    scope,
+		Created:  now,
+	}); err != nil {
+		return nil, err
+	}
+	tokens := &OIDCTokens{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(OIDC_ACCESS_TOKEN_TIMEOUT / time.Second),
+		RefreshToken: refresh,
+		Scope:        scope,
+	}
+	scopes := strings.Fields(scope)
+	if containsScope(scopes, "openid") {
+		claims := a.oidcUserInfo(ctx, account, scopes)
+		claims["iss"] = a.issuer
+		claims["aud"] = client.ID
+		claims["exp"] = expiry.Unix()
+		claims["iat"] = now.Unix()
+		if nonce != "" {
+			claims["nonce"] = nonce
+		}
+		tokens.IDToken, err = SignJWT(&JWTHeader{
+			Kid: kid,
+			Typ: "JWT",
+		}, claims, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return tokens, nil
+}
+
+// oidcUserInfo returns the claims about the account which the scopes allow the client to know.
+func (a *authenticator) oidcUserInfo(ctx context.Context, account *Account, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{
+		"sub": account.Username,
+	}
+	if containsScope(scopes, "email") {
+		claims["email"] = account.Email
+		claims["email_verified"] = a.IsEmailVerified(ctx, account.Email)
+	}
+	if containsScope(scopes, "profile") {
+		claims["preferred_username"] = account.Username
+	}
+	return claims
+}
+
+// VerifyOIDCAccessToken returns the account and scopes of an access token issued by this service.
+func (a *authenticator) VerifyOIDCAccessToken(ctx context.Context, token string) (*Account, []string, error) {
+	now := time.Now()
+	header, payload, input, signature, err := ParseJWT(token)
+	if err != nil {
+		return nil, nil, ErrOIDCAccessTokenInvalid
+	}
+	if header.Typ != OIDC_ACCESS_TOKEN_TYPE || header.Alg != JWT_ALGORITHM_RS256 {
+		return nil, nil, fmt.Errorf("%w: type", ErrOIDCAccessTokenInvalid)
+	}
+	keys, err := a.oidcPublishedKeys(ctx, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, ok := keys[header.Kid]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: key", ErrOIDCAccessTokenInvalid)
+	}
+	if err := VerifyJWTSignature(header.Alg, key.Public(), input, signature); err != nil {
+		return nil, nil, fmt.Errorf("%w: signature", ErrOIDCAccessTokenInvalid)
+	}
+	claims := &oidcAccessTokenClaims{}
+	if err := json.Unmarshal(payload, claims); err != nil {
+		return nil, nil, fmt.Errorf("%w: claims", ErrOIDCAccessTokenInvalid)
+	}
+	if a.issuer == "" || claims.Issuer != a.issuer {
+		return nil, nil, fmt.Errorf("%w: issuer", ErrOIDCAccessTokenInvalid)
+	}
+	if !now.Before(time.Unix(claims.Expiry, 0)) {
+		return nil, nil, fmt.Errorf("%w: expired", ErrOIDCAccessTokenInvalid)
+	}
+	if _, err := a.accounts.SelectOIDCClient(ctx, claims.ClientID); err != nil {
+		return nil, nil, fmt.Errorf("%w: client", ErrOIDCAccessTokenInvalid)
+	}
+	account, err := a.LookupAccount(ctx, claims.Subject)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: subject", ErrOIDCAccessTokenInvalid)
+	}
+	return account, strings.Fields(claims.Scope), nil
+}
+
+// LookupOIDCUserInfo returns the claims about the user which the access token allows the client to know.
+func (a *authenticator) LookupOIDCUserInfo(ctx context.Context, token string) (map[string]interface{}, error) {
+	account, scopes, err := a.VerifyOIDCAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if !containsScope(scopes, "openid") {
+		return nil, fmt.Errorf("%w: openid scope was not granted", ErrOIDCAccessTokenInvalid)
+	}
+	return a.oidcUserInfo(ctx, account, scopes), nil
+}
@@ -3,18 +3,21 @@ package authgo
 import (
 	"bytes"
 	"errors"
-	"golang.org/x/crypto/bcrypt"
 )
 
 const (
 	MINIMUM_PASSWORD_LENGTH = 12
 	MAXIMUM_PASSWORD_LENGTH = 50
+
+	// DEFAULT_PASSWORD_HISTORY_LIMIT is the number of previous passwords, in addition to the current one, which cannot be reused.
+	DEFAULT_PASSWORD_HISTORY_LIMIT = 5
 )
 
 var (
-	ErrPasswordTooShort    = errors.New("Password Too Short")
-	ErrPasswordTooLong     = errors.New("Password Too Long")
-	ErrPasswordsDoNotMatch = errors.New("Passwords Do Not Match")
+	ErrPasswordTooShort       = errors.New("Password Too Short")
+	ErrPasswordTooLong        = errors.New("Password Too Long")
+	ErrPasswordsDoNotMatch    = errors.New("Passwords Do Not Match")
+	ErrPasswordPreviouslyUsed = errors.New("Password Previously Used")
 )
 
 func ValidatePassword(password []byte) error {
@@ -34,11 +37,3 @@ func MatchPasswords(password, confirmation []byte) error {
 	}
 	return nil
 }
-
-func GeneratePasswordHash(password []byte) ([]byte, error) {
-	return bcrypt.GenerateFromPassword(password, 14)
-}
-
-func CheckPasswordHash(hash, password []byte) bool {
-	return bcrypt.CompareHashAndPassword(hash, password) == nil
-}
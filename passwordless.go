@@ -0,0 +1,195 @@
+package authgo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const SIGN_IN_CHALLENGE_TOKEN_LENGTH = 32
+
+var (
+	ErrPasswordlessDisabled   = errors.New("Passwordless Sign In Not Enabled")
+	ErrSignInChallengeExpired = errors.New("Sign In Request Expired")
+	ErrSignInCodeIncorrect    = errors.New("Incorrect Sign In Code")
+	ErrSignInLinkInvalid      = errors.New("Invalid Sign In Link")
+)
+
+// SignInLinkVerifier is optionally implemented by an EmailVerifier to send a link which signs the user in, instead of a code.
+type SignInLinkVerifier interface {
+	SendSignInLink(email, username, link string) error
+}
+
+// SignInChallenge is an outstanding passwordless sign in, redeemed either by entering the code in the session which requested it,
+// or by following the link from any device.
+type SignInChallenge struct {
+	// Token identifies the challenge in the link.
+	Token string
+	// Session is the token of the sign in session which requested the challenge.
+	Session  string
+	Username string
+	// Code is the code sent by email, or empty if a link was sent instead.
+	Code    string
+	Created time.Time
+}
+
+func NewSignInChallengeToken() (string, error) {
+	token := make([]byte, SIGN_IN_CHALLENGE_TOKEN_LENGTH)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func (a *authenticator) PasswordlessSignIn() bool {
+	return a.passwordless
+}
+
+// SetPasswordlessSignIn enables signing in with just an email or username, after which a code or link is sent by email.
+func (a *authenticator) SetPasswordlessSignIn(enabled bool) {
+	a.passwordless = enabled
+}
+
+func (a *authenticator) SignInLinkURL() string {
+	return a.signInLink
+}
+
+// SetSignInLinkURL sets the absolute URL of the page which redeems sign in links, such as "https://example.com/sign-in-link".
+// Links are only sent when this is set and the EmailVerifier implements SignInLinkVerifier, otherwise codes are sent.
+func (a *authenticator) SetSignInLinkURL(u string) {
+	a.signInLink = u
+}
+
+func (a *authenticator) SignInChallengeTimeout() time.Duration {
+	return a.signInChallengeTimeout
+}
+
+func (a *authenticator) SetSignInChallengeTimeout(timeout time.Duration) {
+	a.signInChallengeTimeout = timeout
+}
+
+// signInLinkVerifier returns the verifier which sends sign in links, if links are enabled.
+func (a *authenticator) signInLinkVerifier() (SignInLinkVerifier, bool) {
+	if a.signInLink == "" {
+		return nil, false
+	}
+	v, ok := a.verifier.(SignInLinkVerifier)
+	return v, ok
+}
+
+// SendsSignInLinks returns true if passwordless sign ins are completed with a link rather than a code.
+func (a *authenticator) SendsSignInLinks() bool {
+	_, ok := a.signInLinkVerifier()
+	return ok
+}
+
+// NewSignInChallenge emails a code or link to the account with the given email or username, replacing any previous challenge of the session.
+// To avoid revealing which accounts exist, nothing is sent and no error is returned if there is no such account, or it is locked.
+func (a *authenticator) NewSignInChallenge(ctx context.Context, session, identity string) error {
+	if !a.passwordless {
+		return ErrPasswordlessDisabled
+	}
+	username := identity
+	if strings.Contains(identity, "@") {
+		u, err := a.accounts.SelectUsernameByEmail(ctx, identity)
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+		username = u
+	}
+	_, email, _, _, err := a.accounts.SelectUser(ctx, username)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if err := a.checkLockout(ctx, a.lockoutSubjects(ctx, username), time.Now()); err != nil {
+		log.Println(err)
+		return nil
+	}
+	token, err := NewSignInChallengeToken()
+	if err != nil {
+		return err
+	}
+	challenge := &SignInChallenge{
+		Token:    token,
+		Session:  session,
+		Username: username,
+		Created:  time.Now(),
+	}
+	if v, ok := a.signInLinkVerifier(); ok {
+		if err := v.SendSignInLink(email, username, a.signInLink+"?token="+url.QueryEscape(token)); err != nil {
+			return err
+		}
+	} else {
+		code, err := a.verifier.Verify(email, username)
+		if err != nil {
+			return err
+		}
+		challenge.Code = code
+	}
+	_, err = a.sessions.CreateSignInChallenge(ctx, challenge)
+	return err
+}
+
+// VerifySignInCode returns the account if the code matches the challenge sent for the session, which is then used up.
+// Failures count towards the lockout policy.
+func (a *authenticator) VerifySignInCode(ctx context.Context, session, code string) (*Account, error) {
+	now := time.Now()
+	if err := a.checkLockout(ctx, a.clientLockoutSubjects(ctx), now); err != nil {
+		return nil, err
+	}
+	challenge, err := a.sessions.SelectSignInChallengeForSession(ctx, session)
+	if err != nil || challenge.Code == "" || challenge.Created.Add(a.signInChallengeTimeout).Before(now) {
+		return nil, ErrSignInChallengeExpired
+	}
+	subjects := a.lockoutSubjects(ctx, challenge.Username)
+	if err := a.checkLockout(ctx, subjects, now); err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(challenge.Code), []byte(code)) != 1 {
+		a.recordFailure(ctx, subjects, now)
+		return nil, ErrSignInCodeIncorrect
+	}
+	return a.redeemSignInChallenge(ctx, challenge)
+}
+
+// VerifySignInLink returns the account if the token is that of an outstanding sign in link, which is then used up.
+// The link can be followed from any device, not just the one which requested it.
+func (a *authenticator) VerifySignInLink(ctx context.Context, token string) (*Account, error) {
+	now := time.Now()
+	subjects := a.clientLockoutSubjects(ctx)
+	if err := a.checkLockout(ctx, subjects, now); err != nil {
+		return nil, err
+	}
+	challenge, err := a.sessions.SelectSignInChallenge(ctx, token)
+	if err != nil || challenge.Code != "" {
+		// Challenges sent as codes can only be redeemed by the session which requested them
+		a.recordFailure(ctx, subjects, now)
+		return nil, ErrSignInLinkInvalid
+	}
+	if challenge.Created.Add(a.signInChallengeTimeout).Before(now) {
+		return nil, ErrSignInChallengeExpired
+	}
+	if err := a.checkLockout(ctx, a.lockoutSubjects(ctx, challenge.Username), now); err != nil {
+		return nil, err
+	}
+	return a.redeemSignInChallenge(ctx, challenge)
+}
+
+func (a *authenticator) redeemSignInChallenge(ctx context.Context, challenge *SignInChallenge) (*Account, error) {
+	// Fails if the challenge was redeemed concurrently
+	if _, err := a.sessions.DeleteSignInChallenge(ctx, challenge.Token); err != nil {
+		return nil, err
+	}
+	if _, err := a.accounts.DeleteAuthenticationFailures(ctx, accountSubject(challenge.Username)); err != nil {
+		log.Println(err)
+	}
+	return a.LookupAccount(ctx, challenge.Username)
+}
@@ -0,0 +1,223 @@
+package authgo
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// MINIMUM_PASSWORD_ENTROPY is the minimum estimated entropy, in bits, of an acceptable password.
+const MINIMUM_PASSWORD_ENTROPY = 50
+
+var (
+	ErrPasswordTooWeak          = errors.New("Password Too Weak")
+	ErrPasswordContainsUsername = errors.New("Password Contains Username")
+	ErrPasswordContainsEmail    = errors.New("Password Contains Email")
+	ErrPasswordCompromised      = errors.New("Password Compromised")
+)
+
+// PasswordPolicy decides whether a password is acceptable for the account with the given email and username.
+type PasswordPolicy interface {
+	Check(password []byte, email, username string) error
+}
+
+// NewPasswordPolicy returns a PasswordPolicy which requires a valid length, an estimated entropy of at least the given number of bits, no username or email, and, if corpus is not nil, no appearance in the corpus of compromised passwords.
+func NewPasswordPolicy(entropy float64, corpus PasswordCorpus) PasswordPolicy {
+	return &passwordPolicy{
+		entropy: entropy,
+		corpus:  corpus,
+	}
+}
+
+type passwordPolicy struct {
+	entropy float64
+	corpus  PasswordCorpus
+}
+
+func (p *passwordPolicy) Check(password []byte, email, username string) error {
+	if err := ValidatePassword(password); err != nil {
+		return err
+	}
+	lower := bytes.ToLower(password)
+	if u := strings.ToLower(username); len(u) >= MINIMUM_USERNAME_LENGTH && bytes.Contains(lower, []byte(u)) {
+		return ErrPasswordContainsUsername
+	}
+	if e := strings.ToLower(email); e != "" {
+		if bytes.Contains(lower, []byte(e)) {
+			return ErrPasswordContainsEmail
+		}
+		if i := strings.LastIndex(e, "@"); i >= MINIMUM_USERNAME_LENGTH && bytes.Contains(lower, []byte(e[:i])) {
+			return ErrPasswordContainsEmail
+		}
+	}
+	if PasswordEntropy(password) < p.entropy {
+		return ErrPasswordTooWeak
+	}
+	if p.corpus != nil {
+		compromised, err := p.corpus.Contains(password)
+		if err != nil {
+			return err
+		}
+		if compromised {
+			return ErrPasswordCompromised
+		}
+	}
+	return nil
+}
+
+// PasswordEntropy estimates the number of bits of entropy in the password.
+//
+// The estimate is the cheapest way of describing the password as a series of
+// characters drawn from the character classes used, common words and passwords,
+// sequences such as "abcd" or "4321", keyboard runs such as "qwerty", and
+// repetitions of the preceding characters.
+func PasswordEntropy(password []byte) float64 {
+	runes := []rune(strings.ToLower(string(password)))
+	n := len(runes)
+	if n == 0 {
+		return 0
+	}
+	perCharacter := math.Log2(float64(characterPoolSize(string(password))))
+	// cost[i] is the minimum number of bits to describe runes[:i]
+	cost := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		cost[i] = math.Inf(1)
+	}
+	for i := 0; i < n; i++ {
+		relax := func(j int, bits float64) {
+			if c := cost[i] + bits; c < cost[j] {
+				cost[j] = c
+			}
+		}
+		// Single character
+		relax(i+1, perCharacter)
+		// Common word
+		for j := i + 3; j <= n; j++ {
+			if _, ok := commonWords[string(runes[i:j])]; ok {
+				relax(j, math.Log2(float64(len(commonWords)))+1)
+			}
+		}
+		// Sequence or keyboard run
+		if j := sequenceEnd(runes, i); j-i >= 3 {
+			for k := i + 3; k <= j; k++ {
+				relax(k, perCharacter+math.Log2(float64(k-i))+1)
+			}
+		}
+		// Repetition of the preceding characters
+		for l := 1; l <= i && i+l <= n; l++ {
+			if string(runes[i:i+l]) == string(runes[i-l:i]) {
+				relax(i+l, math.Log2(float64(l))+1)
+			}
+		}
+	}
+	return cost[n]
+}
+
+// characterPoolSize returns the number of characters in the classes used by the password.
+func characterPoolSize(password string) int {
+	var lower, upper, digit, symbol, other bool
+	for _, c := range password {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c < unicode.MaxASCII && unicode.IsPrint(c):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	return size
+}
+
+var keyboardRows = []string{
+	"1234567890",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+}
+
+// sequenceEnd returns the end of the longest sequence or keyboard run starting at i.
+func sequenceEnd(runes []rune, i int) int {
+	end := i + 1
+	// Alphabetical or numerical, ascending or descending
+	for _, step := range []rune{1, -1} {
+		j := i + 1
+		for j < len(runes) && runes[j]-runes[j-1] == step {
+			j++
+		}
+		if j > end {
+			end = j
+		}
+	}
+	// Keyboard rows, forwards or backwards
+	for _, row := range keyboardRows {
+		for _, r := range []string{row, reverse(row)} {
+			k := strings.IndexRune(r, runes[i])
+			if k < 0 {
+				continue
+			}
+			j := i + 1
+			for j < len(runes) && k+j-i < len(r) && rune(r[k+j-i]) == runes[j] {
+				j++
+			}
+			if j > end {
+				end = j
+			}
+		}
+	}
+	return end
+}
+
+func reverse(s string) string {
+	r := []rune(s)
+	for i, j := 0, len(r)-1; i < j; i, j = i+1, j-1 {
+		r[i], r[j] = r[j], r[i]
+	}
+	return string(r)
+}
+
+// commonWords are among the most frequently used words in passwords.
+var commonWords = func() map[string]struct{} {
+	words := make(map[string]struct{})
+	for _, w := range strings.Fields(`
+		password passw0rd p4ssword pass word secret letmein welcome admin
+		administrator login root user guest default changeme qwerty azerty
+		iloveyou love lovely monkey dragon master shadow sunshine princess
+		football baseball soccer hockey basketball superman batman trustno
+		hello freedom whatever starwars pokemon cheese computer internet
+		summer winter spring autumn monday friday january december
+		michael jennifer jordan thomas charlie michelle daniel jessica
+		ashley robert andrew joshua matthew hunter ranger buster tigger
+		cookie ginger pepper maggie killer secure access flower orange
+		banana apple purple silver golden diamond mustang harley corvette
+		ferrari porsche london paris berlin america canada google facebook
+		twitter yahoo microsoft apple samsung nintendo minecraft fortnite
+		abc abcd test testing demo sample temp qazwsx zaq1 asdf zxcv
+		foo bar baz foobar hunter2 blink182 matrix thunder phoenix
+	`) {
+		words[w] = struct{}{}
+	}
+	return words
+}()
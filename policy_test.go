@@ -0,0 +1,89 @@
+package authgo_test
+
+import (
+	"aletheiaware.com/authgo"
+	"aletheiaware.com/authgo/authtest"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func Test_PasswordEntropy(t *testing.T) {
+	t.Run("Weak", func(t *testing.T) {
+		for _, p := range []string{
+			"",
+			"password1234",
+			"1234password",
+			"Password1234!",
+			"aaaaaaaaaaaaaaaa",
+			"abcdefghijkl",
+			"qwertyuiop1234",
+			"foobarfoobar",
+			"letmeinletmein",
+		} {
+			assert.Less(t, authgo.PasswordEntropy([]byte(p)), float64(authgo.MINIMUM_PASSWORD_ENTROPY), p)
+		}
+	})
+	t.Run("Strong", func(t *testing.T) {
+		for _, p := range []string{
+			authtest.TEST_PASSWORD,
+			"correct horse battery staple",
+			"g7#Lp2vQ!x9Rz",
+			"violet-marble-stapler-97",
+		} {
+			assert.GreaterOrEqual(t, authgo.PasswordEntropy([]byte(p)), float64(authgo.MINIMUM_PASSWORD_ENTROPY), p)
+		}
+	})
+}
+
+func Test_PasswordPolicy(t *testing.T) {
+	corpus := newPasswordCorpus(t, "violet-marble-stapler-97")
+	policy := authgo.NewPasswordPolicy(authgo.MINIMUM_PASSWORD_ENTROPY, corpus)
+	for name, tt := range map[string]struct {
+		password string
+		err      error
+	}{
+		"Valid": {
+			password: authtest.TEST_PASSWORD,
+		},
+		"Too Short": {
+			password: "x7#Lp2",
+			err:      authgo.ErrPasswordTooShort,
+		},
+		"Too Long": {
+			password: strings.Repeat("x7#Lp2", 10),
+			err:      authgo.ErrPasswordTooLong,
+		},
+		"Too Weak": {
+			password: "password1234",
+			err:      authgo.ErrPasswordTooWeak,
+		},
+		"Contains Username": {
+			password: "olive-ALICE-kettle-42",
+			err:      authgo.ErrPasswordContainsUsername,
+		},
+		"Contains Email": {
+			password: "olive-alice@example.com",
+			err:      authgo.ErrPasswordContainsUsername,
+		},
+		"Contains Email Domain": {
+			password: "olive-example.com-42",
+		},
+		"Compromised": {
+			password: "violet-marble-stapler-97",
+			err:      authgo.ErrPasswordCompromised,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tt.err, policy.Check([]byte(tt.password), authtest.TEST_EMAIL, authtest.TEST_USERNAME))
+		})
+	}
+	t.Run("Email", func(t *testing.T) {
+		assert.Equal(t, authgo.ErrPasswordContainsEmail, policy.Check([]byte("olive-bobby@example.com"), "bobby@example.com", "robert"))
+		assert.Equal(t, authgo.ErrPasswordContainsEmail, policy.Check([]byte("olive-BOBBY-kettle-42"), "bobby@example.com", "robert"))
+	})
+	t.Run("Without Corpus", func(t *testing.T) {
+		policy := authgo.NewPasswordPolicy(authgo.MINIMUM_PASSWORD_ENTROPY, nil)
+		assert.NoError(t, policy.Check([]byte("violet-marble-stapler-97"), authtest.TEST_EMAIL, authtest.TEST_USERNAME))
+	})
+}
@@ -0,0 +1,127 @@
+package authgo
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	RECOVERY_CODE_COUNT = 10
+	// RECOVERY_CODE_LENGTH is the number of random bytes in each code, which is shown as 16 base32 characters.
+	RECOVERY_CODE_LENGTH = 10
+)
+
+var (
+	ErrRecoveryCodeIncorrect = errors.New("Incorrect Recovery Code")
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// RecoveryCodes describes the recovery codes of an account, without revealing the codes themselves.
+type RecoveryCodes struct {
+	Created   time.Time
+	Remaining int
+	// Used holds the time each used code was used, oldest first.
+	Used []time.Time
+}
+
+// GenerateRecoveryCode returns a random code formatted in groups of four characters, such as "abcd-efgh-ijkl-mnop".
+func GenerateRecoveryCode() (string, error) {
+	code := make([]byte, RECOVERY_CODE_LENGTH)
+	if _, err := rand.Read(code); err != nil {
+		return "", err
+	}
+	encoded := strings.ToLower(recoveryCodeEncoding.EncodeToString(code))
+	var groups []string
+	for i := 0; i < len(encoded); i += 4 {
+		groups = append(groups, encoded[i:i+4])
+	}
+	return strings.Join(groups, "-"), nil
+}
+
+// HashRecoveryCode returns the hash stored in place of the code.
+// Codes are random rather than chosen by users, so a fast hash is enough to make them unrecoverable from the database.
+func HashRecoveryCode(code string) []byte {
+	code = strings.ToLower(code)
+	code = strings.NewReplacer("-", "", " ", "").Replace(code)
+	hash := sha256.Sum256([]byte(code))
+	return hash[:]
+}
+
+// NewRecoveryCodes replaces the account's recovery codes with a new set, returning the codes so they can be shown to the user once.
+func (a *authenticator) NewRecoveryCodes(ctx context.Context, username string) ([]string, error) {
+	codes := make([]string, RECOVERY_CODE_COUNT)
+	hashes := make([][]byte, RECOVERY_CODE_COUNT)
+	for i := range codes {
+		code, err := GenerateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+		hashes[i] = HashRecoveryCode(code)
+	}
+	if _, err := a.accounts.CreateRecoveryCodes(ctx, username, hashes, time.Now()); err != nil {
+		return nil, err
+	}
+	a.notify(ctx, username, "New recovery codes were generated for your account, and any previous codes no longer work.")
+	return codes, nil
+}
+
+// LookupRecoveryCodes returns the state of the account's recovery codes, or nil if none have been generated.
+func (a *authenticator) LookupRecoveryCodes(ctx context.Context, username string) *RecoveryCodes {
+	created, used, err := a.accounts.SelectRecoveryCodes(ctx, username)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if len(used) == 0 {
+		return nil
+	}
+	codes := &RecoveryCodes{
+		Created: created,
+	}
+	for _, u := range used {
+		if u.IsZero() {
+			codes.Remaining++
+		} else {
+			codes.Used = append(codes.Used, u)
+		}
+	}
+	sort.Slice(codes.Used, func(i, j int) bool {
+		return codes.Used[i].Before(codes.Used[j])
+	})
+	return codes
+}
+
+// VerifyRecoveryCode returns nil if the code is one of the account's unused recovery codes, which is then used up.
+// Failures count towards the lockout policy, and each use is notified by email.
+func (a *authenticator) VerifyRecoveryCode(ctx context.Context, username, code string) error {
+	now := time.Now()
+	subjects := a.secondFactorLockoutSubjects(ctx, username)
+	if err := a.checkLockout(ctx, subjects, now); err != nil {
+		return err
+	}
+	if _, err := a.accounts.UpdateRecoveryCodeUsed(ctx, username, HashRecoveryCode(code), now); err != nil {
+		if errors.Is(err, ErrRecoveryCodeIncorrect) {
+			a.recordFailure(ctx, subjects, now)
+		}
+		return err
+	}
+	if _, err := a.accounts.DeleteAuthenticationFailures(ctx, secondFactorSubject(username)); err != nil {
+		log.Println(err)
+	}
+	remaining := 0
+	if codes := a.LookupRecoveryCodes(ctx, username); codes != nil {
+		remaining = codes.Remaining
+	}
+	a.notify(ctx, username, fmt.Sprintf("A recovery code was used to access your account, %d recovery codes remain. If this was not you, change your password and generate new recovery codes.", remaining))
+	return nil
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func AccountPassword(w http.ResponseWriter, r *http.Request, n string) {
-	if n != "" {
+	if IsAllowed(n) {
 		http.Redirect(w, r, "/account-password?next="+url.QueryEscape(n), http.StatusFound)
 	} else {
 		http.Redirect(w, r, "/account-password", http.StatusFound)
@@ -6,7 +6,7 @@ import (
 )
 
 func AccountRecovery(w http.ResponseWriter, r *http.Request, n string) {
-	if n != "" {
+	if IsAllowed(n) {
 		http.Redirect(w, r, "/account-recovery?next="+url.QueryEscape(n), http.StatusFound)
 	} else {
 		http.Redirect(w, r, "/account-recovery", http.StatusFound)
@@ -14,9 +14,17 @@ func AccountRecovery(w http.ResponseWriter, r *http.Request, n string) {
 }
 
 func AccountRecoveryVerification(w http.ResponseWriter, r *http.Request, n string) {
-	if n != "" {
+	if IsAllowed(n) {
 		http.Redirect(w, r, "/account-recovery-verification?next="+url.QueryEscape(n), http.StatusFound)
 	} else {
 		http.Redirect(w, r, "/account-recovery-verification", http.StatusFound)
 	}
 }
+
+func AccountRecoveryCode(w http.ResponseWriter, r *http.Request, n string) {
+	if IsAllowed(n) {
+		http.Redirect(w, r, "/account-recovery-code?next="+url.QueryEscape(n), http.StatusFound)
+	} else {
+		http.Redirect(w, r, "/account-recovery-code", http.StatusFound)
+	}
+}
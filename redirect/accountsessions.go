@@ -0,0 +1,9 @@
+package redirect
+
+import (
+	"net/http"
+)
+
+func AccountSessions(w http.ResponseWriter, r *http.Request) {
+	http.Redirect(w, r, "/account-sessions", http.StatusFound)
+}
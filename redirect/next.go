@@ -0,0 +1,80 @@
+package redirect
+
+import (
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// allowlist holds the prefixes which next may redirect to.
+var allowlist = []string{"/"}
+
+// Allowlist returns the prefixes which next may redirect to.
+func Allowlist() []string {
+	return allowlist
+}
+
+// SetAllowlist sets the prefixes which next may redirect to, and should be called before serving requests.
+// Paths, such as "/products", allow relative paths on the website, and URLs, such as "https://app.example.com", allow other hosts.
+// By default every relative path on the website is allowed.
+func SetAllowlist(prefixes []string) {
+	allowlist = prefixes
+}
+
+// IsAllowed returns true if next matches a prefix in the allowlist.
+func IsAllowed(next string) bool {
+	// Browsers treat backslashes as slashes, so "/\example.com" would leave the website
+	if next == "" || strings.Contains(next, "\\") {
+		return false
+	}
+	u, err := url.Parse(next)
+	if err != nil || u.Opaque != "" || u.User != nil {
+		return false
+	}
+	if u.Scheme == "" && u.Host == "" {
+		if !strings.HasPrefix(next, "/") {
+			return false
+		}
+	} else if (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+		return false
+	}
+	p := cleanPath(u.Path)
+	for _, prefix := range allowlist {
+		a, err := url.Parse(prefix)
+		if err != nil {
+			continue
+		}
+		if !strings.EqualFold(a.Scheme, u.Scheme) || !strings.EqualFold(a.Host, u.Host) {
+			continue
+		}
+		if hasPathPrefix(p, cleanPath(a.Path)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Next redirects to next if it is allowed, otherwise to the account page.
+func Next(w http.ResponseWriter, r *http.Request, next string) {
+	if IsAllowed(next) {
+		http.Redirect(w, r, next, http.StatusFound)
+	} else {
+		Account(w, r)
+	}
+}
+
+// cleanPath resolves any dot segments, as the browser would when following the redirect.
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	return path.Clean("/" + p)
+}
+
+func hasPathPrefix(p, prefix string) bool {
+	if prefix == "/" || p == prefix {
+		return true
+	}
+	return strings.HasPrefix(p, prefix+"/")
+}
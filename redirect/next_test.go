@@ -0,0 +1,109 @@
+package redirect_test
+
+import (
+	"aletheiaware.com/authgo/redirect"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsAllowed(t *testing.T) {
+	for name, tt := range map[string]struct {
+		allowlist []string
+		next      string
+		allowed   bool
+	}{
+		"Empty": {
+			next: "",
+		},
+		"Relative Path": {
+			next:    "/products?page=2",
+			allowed: true,
+		},
+		"Relative Path Without Slash": {
+			next: "products",
+		},
+		"Absolute URL": {
+			next: "https://evil.example.com/account",
+		},
+		"Protocol Relative URL": {
+			next: "//evil.example.com",
+		},
+		"Backslash": {
+			next: "/\\evil.example.com",
+		},
+		"Control Character": {
+			next: "/\t/evil.example.com",
+		},
+		"JavaScript": {
+			next: "javascript:alert(1)",
+		},
+		"Allowed Path": {
+			allowlist: []string{"/products"},
+			next:      "/products/1",
+			allowed:   true,
+		},
+		"Disallowed Path": {
+			allowlist: []string{"/products"},
+			next:      "/productsandmore",
+		},
+		"Dot Segments": {
+			allowlist: []string{"/products"},
+			next:      "/products/../account",
+		},
+		"Encoded Dot Segments": {
+			allowlist: []string{"/products"},
+			next:      "/products/%2e%2e/account",
+		},
+		"Allowed Host": {
+			allowlist: []string{"/", "https://app.example.com"},
+			next:      "https://APP.example.com/welcome",
+			allowed:   true,
+		},
+		"Allowed Host Wrong Scheme": {
+			allowlist: []string{"https://app.example.com"},
+			next:      "http://app.example.com/welcome",
+		},
+		"Allowed Host With User": {
+			allowlist: []string{"https://app.example.com"},
+			next:      "https://evil@app.example.com/welcome",
+		},
+		"Disallowed Host": {
+			allowlist: []string{"https://app.example.com"},
+			next:      "https://app.example.com.evil.example.com/welcome",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if tt.allowlist != nil {
+				defer redirect.SetAllowlist(redirect.Allowlist())
+				redirect.SetAllowlist(tt.allowlist)
+			}
+			assert.Equal(t, tt.allowed, redirect.IsAllowed(tt.next))
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	for next, location := range map[string]string{
+		"":                          "/account",
+		"/products":                 "/products",
+		"https://evil.example.com/": "/account",
+	} {
+		response := httptest.NewRecorder()
+		redirect.Next(response, httptest.NewRequest(http.MethodPost, "/sign-in", nil), next)
+		assert.Equal(t, http.StatusFound, response.Code)
+		assert.Equal(t, location, response.Header().Get("Location"), next)
+	}
+}
+
+func TestSignIn(t *testing.T) {
+	for next, location := range map[string]string{
+		"/products":                 "/sign-in?next=%2Fproducts",
+		"https://evil.example.com/": "/sign-in",
+	} {
+		response := httptest.NewRecorder()
+		redirect.SignIn(response, httptest.NewRequest(http.MethodGet, "/", nil), next)
+		assert.Equal(t, location, response.Header().Get("Location"), next)
+	}
+}
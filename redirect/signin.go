@@ -6,9 +6,33 @@ import (
 )
 
 func SignIn(w http.ResponseWriter, r *http.Request, n string) {
-	if n != "" {
+	if IsAllowed(n) {
 		http.Redirect(w, r, "/sign-in?next="+url.QueryEscape(n), http.StatusFound)
 	} else {
 		http.Redirect(w, r, "/sign-in", http.StatusFound)
 	}
 }
+
+func SignInTOTP(w http.ResponseWriter, r *http.Request, n string) {
+	if IsAllowed(n) {
+		http.Redirect(w, r, "/sign-in-totp?next="+url.QueryEscape(n), http.StatusFound)
+	} else {
+		http.Redirect(w, r, "/sign-in-totp", http.StatusFound)
+	}
+}
+
+func SignInPasskey(w http.ResponseWriter, r *http.Request, n string) {
+	if IsAllowed(n) {
+		http.Redirect(w, r, "/sign-in-passkey?next="+url.QueryEscape(n), http.StatusFound)
+	} else {
+		http.Redirect(w, r, "/sign-in-passkey", http.StatusFound)
+	}
+}
+
+func SignInCode(w http.ResponseWriter, r *http.Request, n string) {
+	if IsAllowed(n) {
+		http.Redirect(w, r, "/sign-in-code?next="+url.QueryEscape(n), http.StatusFound)
+	} else {
+		http.Redirect(w, r, "/sign-in-code", http.StatusFound)
+	}
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func SignUp(w http.ResponseWriter, r *http.Request, n string) {
-	if n != "" {
+	if IsAllowed(n) {
 		http.Redirect(w, r, "/sign-up?next="+url.QueryEscape(n), http.StatusFound)
 	} else {
 		http.Redirect(w, r, "/sign-up", http.StatusFound)
@@ -14,9 +14,17 @@ func SignUp(w http.ResponseWriter, r *http.Request, n string) {
 }
 
 func SignUpVerification(w http.ResponseWriter, r *http.Request, n string) {
-	if n != "" {
+	if IsAllowed(n) {
 		http.Redirect(w, r, "/sign-up-verification?next="+url.QueryEscape(n), http.StatusFound)
 	} else {
 		http.Redirect(w, r, "/sign-up-verification", http.StatusFound)
 	}
 }
+
+func SignUpPasskey(w http.ResponseWriter, r *http.Request, n string) {
+	if IsAllowed(n) {
+		http.Redirect(w, r, "/sign-up-passkey?next="+url.QueryEscape(n), http.StatusFound)
+	} else {
+		http.Redirect(w, r, "/sign-up-passkey", http.StatusFound)
+	}
+}
@@ -0,0 +1,229 @@
+package authgo
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+var (
+	ErrSealedSessionInvalid     = errors.New("Invalid Sealed Session")
+	ErrSealedSessionNotWritable = errors.New("Sealed Session Not Writable")
+	ErrSessionSealerKeyMissing  = errors.New("Session Sealer Key Missing")
+)
+
+// SessionSealer seals sessions into encrypted cookies instead of the SessionStore, so anonymous requests do not write to the database.
+//
+// Sessions are sealed with AES-GCM using the first key, and unsealed with any of the keys, so a new key can be added before old keys are removed.
+// Sealed sessions can only be changed by handlers wrapped with SealedSessionHandler, which sets the updated cookies on the response.
+type SessionSealer struct {
+	Keys     [][]byte // AES keys of 16, 24, or 32 bytes
+	Sessions []string // Cookie names of the sessions to seal; COOKIE_SIGN_UP, COOKIE_ACCOUNT_PASSWORD, or COOKIE_ACCOUNT_RECOVERY
+}
+
+// NewSessionSealer returns a SessionSealer which seals sign up, account password, and account recovery sessions with the keys.
+func NewSessionSealer(keys ...[]byte) *SessionSealer {
+	return &SessionSealer{
+		Keys: keys,
+		Sessions: []string{
+			COOKIE_SIGN_UP,
+			COOKIE_ACCOUNT_PASSWORD,
+			COOKIE_ACCOUNT_RECOVERY,
+		},
+	}
+}
+
+// Seals returns true if sessions with the given cookie name are sealed.
+func (s *SessionSealer) Seals(name string) bool {
+	if s == nil {
+		return false
+	}
+	for _, n := range s.Sessions {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Seal encrypts the session, binding it to the given cookie name so it cannot be used as another kind of session.
+func (s *SessionSealer) Seal(name string, session interface{}) (string, error) {
+	if len(s.Keys) == 0 {
+		return "", ErrSessionSealerKeyMissing
+	}
+	plaintext, err := json.Marshal(session)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newSealerAEAD(s.Keys[0])
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, plaintext, []byte(name))), nil
+}
+
+// Unseal decrypts the value into the session, trying each key in turn.
+func (s *SessionSealer) Unseal(name, value string, session interface{}) error {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return ErrSealedSessionInvalid
+	}
+	for _, k := range s.Keys {
+		aead, err := newSealerAEAD(k)
+		if err != nil {
+			return err
+		}
+		if len(sealed) < aead.NonceSize() {
+			return ErrSealedSessionInvalid
+		}
+		plaintext, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+		if err != nil {
+			continue
+		}
+		return json.Unmarshal(plaintext, session)
+	}
+	return ErrSealedSessionInvalid
+}
+
+func newSealerAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (a *authenticator) SessionSealer() *SessionSealer {
+	return a.sealer
+}
+
+func (a *authenticator) SetSessionSealer(sealer *SessionSealer) {
+	a.sealer = sealer
+}
+
+// SealedSessionHandler lets the handler change sealed sessions, setting their cookies on the response before the header is written.
+func (a *authenticator) SealedSessionHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.sealer == nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		sw := &sealedResponseWriter{
+			ResponseWriter: w,
+			authenticator:  a,
+			sessions: &sealedSessions{
+				sessions: make(map[string]*sealedSession),
+			},
+		}
+		h.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), sealedSessionsKey{}, sw.sessions)))
+		sw.writeCookies()
+	})
+}
+
+// sessionStore returns the store for sign up, account password, and account recovery sessions.
+func (a *authenticator) sessionStore() SessionStore {
+	if a.sealer == nil {
+		return a.sessions
+	}
+	return &sealedSessionStore{
+		SessionStore: a.sessions,
+		sealer:       a.sealer,
+	}
+}
+
+type sealedSessionsKey struct{}
+
+// sealedSessions holds the sessions unsealed or created during a request, by token.
+type sealedSessions struct {
+	sessions map[string]*sealedSession
+}
+
+type sealedSession struct {
+	name     string
+	session  interface{}
+	created  bool
+	modified bool
+}
+
+func currentSealedSessions(ctx context.Context) *sealedSessions {
+	s, _ := ctx.Value(sealedSessionsKey{}).(*sealedSessions)
+	return s
+}
+
+type sealedResponseWriter struct {
+	http.ResponseWriter
+	authenticator *authenticator
+	sessions      *sealedSessions
+	wroteCookies  bool
+}
+
+func (w *sealedResponseWriter) WriteHeader(status int) {
+	w.writeCookies()
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *sealedResponseWriter) Write(b []byte) (int, error) {
+	w.writeCookies()
+	return w.ResponseWriter.Write(b)
+}
+
+// writeCookies seals the sessions which changed during the request.
+// Cookies the handler set for a session are given the sealed value, and sessions which arrived in the request are given a new cookie.
+func (w *sealedResponseWriter) writeCookies() {
+	if w.wroteCookies {
+		return
+	}
+	w.wroteCookies = true
+	header := w.Header()
+	for token, s := range w.sessions.sessions {
+		if !s.created && !s.modified {
+			continue
+		}
+		value, err := w.authenticator.sealer.Seal(s.name, s.session)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		name := w.authenticator.cookies.Name(s.name)
+		found := false
+		for i, line := range header["Set-Cookie"] {
+			response := &http.Response{
+				Header: http.Header{"Set-Cookie": {line}},
+			}
+			for _, c := range response.Cookies() {
+				if c.Name == name && c.Value == token {
+					c.Value = value
+					header["Set-Cookie"][i] = c.String()
+					found = true
+				}
+			}
+		}
+		if !found && !s.created {
+			if c := w.authenticator.newSessionCookie(s.name, value); c != nil {
+				http.SetCookie(w.ResponseWriter, c)
+			}
+		}
+	}
+}
+
+func (a *authenticator) newSessionCookie(name, value string) *http.Cookie {
+	switch name {
+	case COOKIE_SIGN_UP:
+		return a.NewSignUpSessionCookie(value)
+	case COOKIE_ACCOUNT_PASSWORD:
+		return a.NewAccountPasswordSessionCookie(value)
+	case COOKIE_ACCOUNT_RECOVERY:
+		return a.NewAccountRecoverySessionCookie(value)
+	}
+	return nil
+}
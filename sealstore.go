@@ -0,0 +1,230 @@
+package authgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// sealedSessionStore keeps the sessions selected by the sealer in the request, and all others in the SessionStore.
+// Sessions sealed before the sealer was set, or whose kind is no longer sealed, continue to be found in the SessionStore.
+type sealedSessionStore struct {
+	SessionStore
+	sealer *SessionSealer
+}
+
+func (s *sealedSessionStore) CreateSignUpSession(ctx context.Context, session *SignUpSession) (int64, error) {
+	if !s.sealer.Seals(COOKIE_SIGN_UP) {
+		return s.SessionStore.CreateSignUpSession(ctx, session)
+	}
+	return s.create(ctx, COOKIE_SIGN_UP, session.Token, session)
+}
+
+func (s *sealedSessionStore) SelectSignUpSession(ctx context.Context, token string) (*SignUpSession, error) {
+	if sealed := s.lookup(ctx, COOKIE_SIGN_UP, token, &SignUpSession{}); sealed != nil {
+		session := *sealed.session.(*SignUpSession)
+		return &session, nil
+	}
+	return s.SessionStore.SelectSignUpSession(ctx, token)
+}
+
+func (s *sealedSessionStore) UpdateSignUpSessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_SIGN_UP, token, &SignUpSession{}, func(session interface{}) {
+		session.(*SignUpSession).Error = errmsg
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateSignUpSessionError(ctx, token, errmsg)
+}
+
+func (s *sealedSessionStore) UpdateSignUpSessionIdentity(ctx context.Context, token, email, username string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_SIGN_UP, token, &SignUpSession{}, func(session interface{}) {
+		session.(*SignUpSession).Email = email
+		session.(*SignUpSession).Username = username
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateSignUpSessionIdentity(ctx, token, email, username)
+}
+
+func (s *sealedSessionStore) UpdateSignUpSessionReferrer(ctx context.Context, token, referrer string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_SIGN_UP, token, &SignUpSession{}, func(session interface{}) {
+		session.(*SignUpSession).Referrer = referrer
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateSignUpSessionReferrer(ctx, token, referrer)
+}
+
+func (s *sealedSessionStore) UpdateSignUpSessionChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_SIGN_UP, token, &SignUpSession{}, func(session interface{}) {
+		session.(*SignUpSession).Challenge = challenge
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateSignUpSessionChallenge(ctx, token, challenge)
+}
+
+func (s *sealedSessionStore) UpdateSignUpSessionPasskeyChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_SIGN_UP, token, &SignUpSession{}, func(session interface{}) {
+		session.(*SignUpSession).PasskeyChallenge = challenge
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateSignUpSessionPasskeyChallenge(ctx, token, challenge)
+}
+
+func (s *sealedSessionStore) CreateAccountPasswordSession(ctx context.Context, session *AccountPasswordSession) (int64, error) {
+	if !s.sealer.Seals(COOKIE_ACCOUNT_PASSWORD) {
+		return s.SessionStore.CreateAccountPasswordSession(ctx, session)
+	}
+	return s.create(ctx, COOKIE_ACCOUNT_PASSWORD, session.Token, session)
+}
+
+func (s *sealedSessionStore) SelectAccountPasswordSession(ctx context.Context, token string) (*AccountPasswordSession, error) {
+	if sealed := s.lookup(ctx, COOKIE_ACCOUNT_PASSWORD, token, &AccountPasswordSession{}); sealed != nil {
+		session := *sealed.session.(*AccountPasswordSession)
+		return &session, nil
+	}
+	return s.SessionStore.SelectAccountPasswordSession(ctx, token)
+}
+
+func (s *sealedSessionStore) UpdateAccountPasswordSessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_ACCOUNT_PASSWORD, token, &AccountPasswordSession{}, func(session interface{}) {
+		session.(*AccountPasswordSession).Error = errmsg
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateAccountPasswordSessionError(ctx, token, errmsg)
+}
+
+func (s *sealedSessionStore) CreateAccountRecoverySession(ctx context.Context, session *AccountRecoverySession) (int64, error) {
+	if !s.sealer.Seals(COOKIE_ACCOUNT_RECOVERY) {
+		return s.SessionStore.CreateAccountRecoverySession(ctx, session)
+	}
+	return s.create(ctx, COOKIE_ACCOUNT_RECOVERY, session.Token, session)
+}
+
+func (s *sealedSessionStore) SelectAccountRecoverySession(ctx context.Context, token string) (*AccountRecoverySession, error) {
+	if sealed := s.lookup(ctx, COOKIE_ACCOUNT_RECOVERY, token, &AccountRecoverySession{}); sealed != nil {
+		session := *sealed.session.(*AccountRecoverySession)
+		return &session, nil
+	}
+	return s.SessionStore.SelectAccountRecoverySession(ctx, token)
+}
+
+func (s *sealedSessionStore) UpdateAccountRecoverySessionError(ctx context.Context, token, errmsg string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_ACCOUNT_RECOVERY, token, &AccountRecoverySession{}, func(session interface{}) {
+		session.(*AccountRecoverySession).Error = errmsg
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateAccountRecoverySessionError(ctx, token, errmsg)
+}
+
+func (s *sealedSessionStore) UpdateAccountRecoverySessionEmail(ctx context.Context, token, email string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_ACCOUNT_RECOVERY, token, &AccountRecoverySession{}, func(session interface{}) {
+		session.(*AccountRecoverySession).Email = email
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateAccountRecoverySessionEmail(ctx, token, email)
+}
+
+func (s *sealedSessionStore) UpdateAccountRecoverySessionUsername(ctx context.Context, token, username string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_ACCOUNT_RECOVERY, token, &AccountRecoverySession{}, func(session interface{}) {
+		session.(*AccountRecoverySession).Username = username
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateAccountRecoverySessionUsername(ctx, token, username)
+}
+
+func (s *sealedSessionStore) UpdateAccountRecoverySessionChallenge(ctx context.Context, token, challenge string) (int64, error) {
+	if ok, err := s.update(ctx, COOKIE_ACCOUNT_RECOVERY, token, &AccountRecoverySession{}, func(session interface{}) {
+		session.(*AccountRecoverySession).Challenge = challenge
+	}); ok {
+		return 1, err
+	}
+	return s.SessionStore.UpdateAccountRecoverySessionChallenge(ctx, token, challenge)
+}
+
+// create adds the session to the request, to be sealed into the cookie the handler sets for the token.
+func (s *sealedSessionStore) create(ctx context.Context, name, token string, session interface{}) (int64, error) {
+	sessions := currentSealedSessions(ctx)
+	if sessions == nil {
+		return 0, ErrSealedSessionNotWritable
+	}
+	sessions.sessions[token] = &sealedSession{
+		name:    name,
+		session: session,
+		created: true,
+	}
+	return int64(len(sessions.sessions)), nil
+}
+
+// lookup returns the session created or unsealed earlier in the request with the token, or else unseals the token into session.
+// Nil is returned if the token is not a sealed session.
+func (s *sealedSessionStore) lookup(ctx context.Context, name, token string, session interface{}) *sealedSession {
+	if !s.sealer.Seals(name) {
+		return nil
+	}
+	sessions := currentSealedSessions(ctx)
+	if sessions != nil {
+		if sealed, ok := sessions.sessions[token]; ok && sealed.name == name {
+			return sealed
+		}
+	}
+	if err := s.sealer.Unseal(name, token, session); err != nil {
+		return nil
+	}
+	sealed := &sealedSession{
+		name:    name,
+		session: session,
+	}
+	if sessions != nil {
+		id := sealedSessionToken(session)
+		if existing, ok := sessions.sessions[id]; ok && existing.name == name {
+			return existing
+		}
+		sessions.sessions[id] = sealed
+	}
+	return sealed
+}
+
+// update applies the change to the sealed session with the token, returning false if the token is not a sealed session.
+// Changes which leave the session as it was do not cause its cookie to be set again.
+func (s *sealedSessionStore) update(ctx context.Context, name, token string, session interface{}, change func(interface{})) (bool, error) {
+	sealed := s.lookup(ctx, name, token, session)
+	if sealed == nil {
+		return false, nil
+	}
+	if currentSealedSessions(ctx) == nil {
+		return true, ErrSealedSessionNotWritable
+	}
+	before, err := json.Marshal(sealed.session)
+	if err != nil {
+		return true, err
+	}
+	change(sealed.session)
+	after, err := json.Marshal(sealed.session)
+	if err != nil {
+		return true, err
+	}
+	if !bytes.Equal(before, after) {
+		sealed.modified = true
+	}
+	return true, nil
+}
+
+func sealedSessionToken(session interface{}) string {
+	switch s := session.(type) {
+	case *SignUpSession:
+		return s.Token
+	case *AccountPasswordSession:
+		return s.Token
+	case *AccountRecoverySession:
+		return s.Token
+	}
+	return ""
+}
@@ -1,9 +1,65 @@
 package authgo
 
-import "aletheiaware.com/cryptogo"
+import (
+	"aletheiaware.com/cryptogo"
+	"time"
+)
 
-const SESSION_TOKEN_LENGTH = 16
+const (
+	SESSION_TOKEN_LENGTH = 16
+	SESSION_ID_LENGTH    = 16
+)
 
 func NewSessionToken() (string, error) {
 	return cryptogo.RandomString(SESSION_TOKEN_LENGTH)
 }
+
+type SignUpSession struct {
+	Token     string
+	Email     string
+	Username  string
+	Referrer  string
+	Challenge string
+	// PasskeyChallenge is the challenge of the passkey registration in progress.
+	PasskeyChallenge string
+	Error            string
+	Created          time.Time
+}
+
+type SignInSession struct {
+	Token         string
+	Username      string
+	Authenticated bool
+	// PendingSecondFactor is true when the password has been verified but the second factor has not.
+	PendingSecondFactor bool
+	// PasskeyChallenge is the challenge of the passkey ceremony in progress.
+	PasskeyChallenge string
+	Error            string
+	// Address and UserAgent are of the client which last used the session.
+	Address   string
+	UserAgent string
+	Created   time.Time
+	// Active is when the session was last used.
+	Active time.Time
+}
+
+// ID identifies the session without revealing its token, so it can be shown to the user.
+func (s *SignInSession) ID() string {
+	return HashBearerToken(s.Token)[:SESSION_ID_LENGTH]
+}
+
+type AccountPasswordSession struct {
+	Token    string
+	Username string
+	Error    string
+	Created  time.Time
+}
+
+type AccountRecoverySession struct {
+	Token     string
+	Email     string
+	Username  string
+	Challenge string
+	Error     string
+	Created   time.Time
+}
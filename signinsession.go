@@ -0,0 +1,88 @@
+package authgo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// SIGN_IN_SESSION_ACTIVE_INTERVAL limits how often the time a sign in session was last used is stored, so that frequent requests do not each cause a write.
+const SIGN_IN_SESSION_ACTIVE_INTERVAL = time.Minute
+
+var ErrSignInSessionNotFound = errors.New("Session Not Found")
+
+// LookupSignInSessions returns the account's signed in sessions which have not expired, oldest first.
+func (a *authenticator) LookupSignInSessions(ctx context.Context, username string) ([]*SignInSession, error) {
+	sessions, err := a.sessions.SelectSignInSessions(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	expired := time.Now().Add(-a.signInSessionTimeout)
+	var active []*SignInSession
+	for _, s := range sessions {
+		if s.Created.Before(expired) {
+			continue
+		}
+		active = append(active, s)
+	}
+	return active, nil
+}
+
+// RevokeSignInSession signs the account out of the session with the given ID.
+func (a *authenticator) RevokeSignInSession(ctx context.Context, username, id string) error {
+	sessions, err := a.LookupSignInSessions(ctx, username)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if s.ID() == id {
+			return a.revokeSignInSession(ctx, username, s)
+		}
+	}
+	return ErrSignInSessionNotFound
+}
+
+// RevokeOtherSignInSessions signs the account out of every session except the one with the given token.
+func (a *authenticator) RevokeOtherSignInSessions(ctx context.Context, username, token string) error {
+	sessions, err := a.LookupSignInSessions(ctx, username)
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		if s.Token == token {
+			continue
+		}
+		if err := a.revokeSignInSession(ctx, username, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *authenticator) revokeSignInSession(ctx context.Context, username string, session *SignInSession) error {
+	if _, err := a.sessions.UpdateSignInSessionAuthenticated(ctx, session.Token, false); err != nil {
+		return err
+	}
+	log.Println("Revoked Sign In", session.ID(), "for", username)
+	return nil
+}
+
+// updateSignInSessionActivity records that the session was used by the client carried by the context.
+func (a *authenticator) updateSignInSessionActivity(ctx context.Context, session *SignInSession) {
+	now := time.Now()
+	address, agent := signInSessionClient(ctx)
+	if session.Active.Add(SIGN_IN_SESSION_ACTIVE_INTERVAL).After(now) && session.Address == address && session.UserAgent == agent {
+		return
+	}
+	if _, err := a.sessions.UpdateSignInSessionActivity(ctx, session.Token, address, agent, now); err != nil {
+		log.Println(err)
+		return
+	}
+	session.Address, session.UserAgent, session.Active = address, agent, now
+}
+
+// signInSessionClient returns the address and user agent of the client carried by the context, truncated to fit the session.
+func signInSessionClient(ctx context.Context) (string, string) {
+	return truncate(ClientAddress(ctx), MAXIMUM_CLIENT_ADDRESS_LENGTH), truncate(ClientUserAgent(ctx), MAXIMUM_CLIENT_USER_AGENT_LENGTH)
+}
@@ -0,0 +1,220 @@
+package authgo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	TOTP_SECRET_LENGTH = 20
+	TOTP_DIGITS        = 6
+	TOTP_PERIOD        = 30 * time.Second
+	// TOTP_SKEW is the number of periods either side of the current one in which codes are accepted, allowing for clock drift.
+	TOTP_SKEW = 1
+)
+
+var (
+	ErrTOTPNotEnabled    = errors.New("Two-Factor Authentication Not Enabled")
+	ErrTOTPNotEnrolled   = errors.New("Two-Factor Authentication Not Enrolled")
+	ErrTOTPCodeIncorrect = errors.New("Incorrect Authentication Code")
+)
+
+var totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrollment holds the secret of a newly enrolled authenticator app.
+type TOTPEnrollment struct {
+	// Secret is the base32 encoded secret, for entering into the app manually.
+	Secret string
+	// URI is the otpauth:// key URI, which is also the payload of the QR code scanned by the app.
+	URI string
+}
+
+func GenerateTOTPSecret() ([]byte, error) {
+	secret := make([]byte, TOTP_SECRET_LENGTH)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// TOTPCounter returns the number of periods since the Unix epoch.
+func TOTPCounter(t time.Time) int64 {
+	return t.Unix() / int64(TOTP_PERIOD/time.Second)
+}
+
+// TOTPCode returns the RFC 6238 code for the secret at the given time.
+func TOTPCode(secret []byte, t time.Time) string {
+	return hotp(secret, TOTPCounter(t))
+}
+
+// hotp returns the RFC 4226 code for the secret and counter.
+func hotp(secret []byte, counter int64) string {
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	modulus := uint32(1)
+	for i := 0; i < TOTP_DIGITS; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", TOTP_DIGITS, value%modulus)
+}
+
+// matchTOTP returns the counter at which the code is valid for the secret, or -1 if it is not valid at any counter after last.
+func matchTOTP(secret []byte, code string, t time.Time, last int64) int64 {
+	code = strings.ReplaceAll(code, " ", "")
+	current := TOTPCounter(t)
+	for c := current - TOTP_SKEW; c <= current+TOTP_SKEW; c++ {
+		if c <= last {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(hotp(secret, c)), []byte(code)) == 1 {
+			return c
+		}
+	}
+	return -1
+}
+
+// TOTPURI returns the otpauth:// key URI understood by authenticator apps.
+func TOTPURI(issuer, username string, secret []byte) string {
+	label := username
+	values := url.Values{}
+	values.Set("secret", totpEncoding.EncodeToString(secret))
+	if issuer != "" {
+		label = issuer + ":" + username
+		values.Set("issuer", issuer)
+	}
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", fmt.Sprint(TOTP_DIGITS))
+	values.Set("period", fmt.Sprint(int(TOTP_PERIOD/time.Second)))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: values.Encode(),
+	}
+	return u.String()
+}
+
+func (a *authenticator) TOTPIssuer() string {
+	return a.totpIssuer
+}
+
+func (a *authenticator) SetTOTPIssuer(issuer string) {
+	a.totpIssuer = issuer
+}
+
+func (a *authenticator) IsTOTPEnabled(ctx context.Context, username string) bool {
+	secret, _, _, err := a.accounts.SelectTOTP(ctx, username)
+	if err != nil {
+		log.Println(err)
+		return false
+	}
+	return len(secret) > 0
+}
+
+// NewTOTP enrolls a new authenticator app for the account, which is not enabled until confirmed.
+// Any existing app continues to work until then.
+func (a *authenticator) NewTOTP(ctx context.Context, username string) (*TOTPEnrollment, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := a.accounts.UpdateTOTPPending(ctx, username, secret); err != nil {
+		return nil, err
+	}
+	return a.newTOTPEnrollment(username, secret), nil
+}
+
+// LookupTOTPEnrollment returns the enrollment awaiting confirmation, or nil if there is none.
+func (a *authenticator) LookupTOTPEnrollment(ctx context.Context, username string) *TOTPEnrollment {
+	_, pending, _, err := a.accounts.SelectTOTP(ctx, username)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return a.newTOTPEnrollment(username, pending)
+}
+
+func (a *authenticator) newTOTPEnrollment(username string, secret []byte) *TOTPEnrollment {
+	return &TOTPEnrollment{
+		Secret: totpEncoding.EncodeToString(secret),
+		URI:    TOTPURI(a.totpIssuer, username, secret),
+	}
+}
+
+// ConfirmTOTP enables the enrolled authenticator app if the code is valid, replacing any existing app.
+// Callers should first verify a code from any existing app, or a recovery code, so a stolen session cannot replace it.
+func (a *authenticator) ConfirmTOTP(ctx context.Context, username, code string) error {
+	_, pending, _, err := a.accounts.SelectTOTP(ctx, username)
+	if err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return ErrTOTPNotEnrolled
+	}
+	counter := matchTOTP(pending, code, time.Now(), -1)
+	if counter < 0 {
+		return ErrTOTPCodeIncorrect
+	}
+	if _, err := a.accounts.ConfirmTOTP(ctx, username, counter); err != nil {
+		return err
+	}
+	log.Println("Enabled Two-Factor Authentication for", username)
+	return nil
+}
+
+// VerifyTOTP returns nil if the code is valid for the account's authenticator app.
+// Each code can only be used once, and failures count towards the lockout policy.
+func (a *authenticator) VerifyTOTP(ctx context.Context, username, code string) error {
+	now := time.Now()
+	subjects := a.secondFactorLockoutSubjects(ctx, username)
+	if err := a.checkLockout(ctx, subjects, now); err != nil {
+		return err
+	}
+	secret, _, last, err := a.accounts.SelectTOTP(ctx, username)
+	if err != nil {
+		return err
+	}
+	if len(secret) == 0 {
+		return ErrTOTPNotEnabled
+	}
+	counter := matchTOTP(secret, code, now, last)
+	if counter < 0 {
+		a.recordFailure(ctx, subjects, now)
+		return ErrTOTPCodeIncorrect
+	}
+	// Fails if the code was used concurrently
+	if _, err := a.accounts.UpdateTOTPCounter(ctx, username, counter); err != nil {
+		return err
+	}
+	if _, err := a.accounts.DeleteAuthenticationFailures(ctx, secondFactorSubject(username)); err != nil {
+		log.Println(err)
+	}
+	return nil
+}
+
+func (a *authenticator) DisableTOTP(ctx context.Context, username string) error {
+	if _, err := a.accounts.DeleteTOTP(ctx, username); err != nil {
+		return err
+	}
+	log.Println("Disabled Two-Factor Authentication for", username)
+	return nil
+}
@@ -0,0 +1,48 @@
+package authgo_test
+
+import (
+	"aletheiaware.com/authgo"
+	"github.com/stretchr/testify/assert"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func Test_TOTPCode(t *testing.T) {
+	// RFC 6238 Appendix B test vectors for SHA1, truncated to 6 digits
+	secret := []byte("12345678901234567890")
+	for seconds, code := range map[int64]string{
+		59:          "287082",
+		1111111109:  "081804",
+		1111111111:  "050471",
+		1234567890:  "005924",
+		2000000000:  "279037",
+		20000000000: "353130",
+	} {
+		assert.Equal(t, code, authgo.TOTPCode(secret, time.Unix(seconds, 0)), seconds)
+	}
+}
+
+func Test_TOTPURI(t *testing.T) {
+	secret := []byte("12345678901234567890")
+	t.Run("Issuer", func(t *testing.T) {
+		u, err := url.Parse(authgo.TOTPURI("Example Co", "alice", secret))
+		assert.NoError(t, err)
+		assert.Equal(t, "otpauth", u.Scheme)
+		assert.Equal(t, "totp", u.Host)
+		assert.Equal(t, "/Example Co:alice", u.Path)
+		query := u.Query()
+		assert.Equal(t, "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ", query.Get("secret"))
+		assert.Equal(t, "Example Co", query.Get("issuer"))
+		assert.Equal(t, "SHA1", query.Get("algorithm"))
+		assert.Equal(t, "6", query.Get("digits"))
+		assert.Equal(t, "30", query.Get("period"))
+	})
+	t.Run("NoIssuer", func(t *testing.T) {
+		u, err := url.Parse(authgo.TOTPURI("", "alice", secret))
+		assert.NoError(t, err)
+		assert.Equal(t, "/alice", u.Path)
+		_, ok := u.Query()["issuer"]
+		assert.False(t, ok)
+	})
+}
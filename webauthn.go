@@ -0,0 +1,628 @@
+package authgo
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const (
+	PASSKEY_CHALLENGE_LENGTH = 32
+	// PASSKEY_TIMEOUT is how long the user has to complete a ceremony with their authenticator.
+	PASSKEY_TIMEOUT = 5 * time.Minute
+	// MAXIMUM_PASSKEY_ID_LENGTH is the longest credential ID accepted, in bytes.
+	MAXIMUM_PASSKEY_ID_LENGTH   = 384
+	MAXIMUM_PASSKEY_NAME_LENGTH = 64
+	DEFAULT_PASSKEY_NAME        = "Passkey"
+
+	// COSE algorithm identifiers of the supported public keys.
+	COSE_ALGORITHM_ES256 = -7
+	COSE_ALGORITHM_EDDSA = -8
+)
+
+var (
+	ErrRelyingPartyNotConfigured = errors.New("Passkeys Not Configured")
+	ErrPasskeyInvalid            = errors.New("Invalid Passkey")
+	ErrPasskeyExpired            = errors.New("Passkey Request Expired")
+	ErrPasskeyNotRecognized      = errors.New("Passkey Not Recognized")
+	ErrPasskeyUnsupported        = errors.New("Passkey Not Supported")
+	ErrPasskeyCloned             = errors.New("Passkey May Have Been Cloned")
+	ErrPasskeyNameTooLong        = errors.New("Passkey Name Too Long")
+	ErrPasskeyAlreadyRegistered  = errors.New("Passkey Already Registered")
+)
+
+// Authenticator data flags, see https://www.w3.org/TR/webauthn-2/#sctn-authenticator-data
+const (
+	authenticatorDataUserPresent  = 0x01
+	authenticatorDataUserVerified = 0x04
+	authenticatorDataAttested     = 0x40
+	authenticatorDataExtensions   = 0x80
+)
+
+var passkeyEncoding = base64.RawURLEncoding
+
+// RelyingParty identifies the website to passkey authenticators.
+type RelyingParty struct {
+	// ID is the domain passkeys are scoped to, such as example.com.
+	ID string
+	// Name is shown to the user by their authenticator.
+	Name string
+	// Origins are the origins ceremonies may be performed from, such as https://example.com.
+	Origins []string
+}
+
+// Passkey is a WebAuthn credential registered to an account.
+type Passkey struct {
+	// ID is the base64url encoded credential ID.
+	ID   string
+	Name string
+	// PublicKey is the COSE encoded public key.
+	PublicKey []byte
+	// SignCount is the signature counter last reported by the authenticator, or zero if it does not keep one.
+	SignCount int64
+	Created   time.Time
+	Used      time.Time
+}
+
+// PasskeyCreationOptions are passed to navigator.credentials.create() to register a passkey.
+type PasskeyCreationOptions struct {
+	Challenge              string                   `json:"challenge"`
+	RP                     PasskeyRelyingParty      `json:"rp"`
+	User                   PasskeyUser              `json:"user"`
+	PubKeyCredParams       []PasskeyParameters      `json:"pubKeyCredParams"`
+	Timeout                int64                    `json:"timeout"`
+	ExcludeCredentials     []PasskeyDescriptor      `json:"excludeCredentials"`
+	AuthenticatorSelection PasskeySelectionCriteria `json:"authenticatorSelection"`
+	Attestation            string                   `json:"attestation"`
+}
+
+// PasskeyRequestOptions are passed to navigator.credentials.get() to sign in with a passkey.
+type PasskeyRequestOptions struct {
+	Challenge        string              `json:"challenge"`
+	RPID             string              `json:"rpId"`
+	Timeout          int64               `json:"timeout"`
+	AllowCredentials []PasskeyDescriptor `json:"allowCredentials"`
+	UserVerification string              `json:"userVerification"`
+}
+
+type PasskeyRelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type PasskeyUser struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+type PasskeyParameters struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+type PasskeyDescriptor struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+type PasskeySelectionCriteria struct {
+	ResidentKey        string `json:"residentKey"`
+	RequireResidentKey bool   `json:"requireResidentKey"`
+	UserVerification   string `json:"userVerification"`
+}
+
+// PasskeyCredential is the JSON encoding of a PublicKeyCredential, as returned by its toJSON() method.
+type PasskeyCredential struct {
+	ID       string                    `json:"id"`
+	RawID    string                    `json:"rawId"`
+	Type     string                    `json:"type"`
+	Response PasskeyCredentialResponse `json:"response"`
+}
+
+// PasskeyCredentialResponse holds the fields of either an AuthenticatorAttestationResponse or an AuthenticatorAssertionResponse.
+type PasskeyCredentialResponse struct {
+	ClientDataJSON    string `json:"clientDataJSON"`
+	AttestationObject string `json:"attestationObject,omitempty"`
+	AuthenticatorData string `json:"authenticatorData,omitempty"`
+	Signature         string `json:"signature,omitempty"`
+	UserHandle        string `json:"userHandle,omitempty"`
+}
+
+// NewPasskeyChallenge returns a new base64url encoded challenge, which must be stored by the caller and used for exactly one ceremony.
+// The challenge embeds its creation time so it expires after PASSKEY_TIMEOUT.
+func NewPasskeyChallenge() (string, error) {
+	challenge := make([]byte, PASSKEY_CHALLENGE_LENGTH)
+	binary.BigEndian.PutUint64(challenge, uint64(time.Now().Unix()))
+	if _, err := rand.Read(challenge[8:]); err != nil {
+		return "", err
+	}
+	return passkeyEncoding.EncodeToString(challenge), nil
+}
+
+// checkPasskeyChallenge returns nil if the challenge signed by the client matches the one issued and has not expired.
+func checkPasskeyChallenge(issued, signed string, now time.Time) error {
+	challenge, err := decodePasskeyBase64(issued)
+	if err != nil || len(challenge) != PASSKEY_CHALLENGE_LENGTH {
+		return ErrPasskeyExpired
+	}
+	created := time.Unix(int64(binary.BigEndian.Uint64(challenge)), 0)
+	if created.Add(PASSKEY_TIMEOUT).Before(now) {
+		return ErrPasskeyExpired
+	}
+	if subtle.ConstantTimeCompare([]byte(issued), []byte(strings.TrimRight(signed, "="))) != 1 {
+		return fmt.Errorf("%w: challenge", ErrPasskeyInvalid)
+	}
+	return nil
+}
+
+// decodePasskeyBase64 decodes base64url with or without padding.
+func decodePasskeyBase64(s string) ([]byte, error) {
+	return passkeyEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// passkeyUserHandle returns the opaque user handle under which the account's passkeys are stored by authenticators.
+func passkeyUserHandle(username string) []byte {
+	handle := sha256.Sum256([]byte(username))
+	return handle[:]
+}
+
+// clientData is the subset of CollectedClientData checked by the relying party.
+type clientData struct {
+	Type        string `json:"type"`
+	Challenge   string `json:"challenge"`
+	Origin      string `json:"origin"`
+	CrossOrigin bool   `json:"crossOrigin"`
+}
+
+func (rp *RelyingParty) checkClientData(data []byte, ceremony, challenge string, now time.Time) error {
+	var cd clientData
+	if err := json.Unmarshal(data, &cd); err != nil {
+		return fmt.Errorf("%w: %s", ErrPasskeyInvalid, err)
+	}
+	if cd.Type != ceremony {
+		return fmt.Errorf("%w: type %s", ErrPasskeyInvalid, cd.Type)
+	}
+	if err := checkPasskeyChallenge(challenge, cd.Challenge, now); err != nil {
+		return err
+	}
+	if cd.CrossOrigin {
+		return fmt.Errorf("%w: cross origin", ErrPasskeyInvalid)
+	}
+	for _, o := range rp.Origins {
+		if cd.Origin == o {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: origin %s", ErrPasskeyInvalid, cd.Origin)
+}
+
+type authenticatorData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+func parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrPasskeyInvalid)
+	}
+	ad := &authenticatorData{
+		RPIDHash:  data[:32],
+		Flags:     data[32],
+		SignCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rest := data[37:]
+	if ad.Flags&authenticatorDataAttested != 0 {
+		// AAGUID, credential ID length, credential ID, and public key
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrPasskeyInvalid)
+		}
+		length := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if length > len(rest) {
+			return nil, fmt.Errorf("%w: credential ID too short", ErrPasskeyInvalid)
+		}
+		ad.CredentialID = rest[:length]
+		rest = rest[length:]
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: public key: %s", ErrPasskeyInvalid, err)
+		}
+		ad.PublicKey = rest[:n]
+		rest = rest[n:]
+	}
+	if ad.Flags&authenticatorDataExtensions != 0 {
+		_, n, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: extensions: %s", ErrPasskeyInvalid, err)
+		}
+		rest = rest[n:]
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrPasskeyInvalid)
+	}
+	return ad, nil
+}
+
+func (rp *RelyingParty) checkAuthenticatorData(ad *authenticatorData) error {
+	hash := sha256.Sum256([]byte(rp.ID))
+	if !bytes.Equal(ad.RPIDHash, hash[:]) {
+		return fmt.Errorf("%w: relying party", ErrPasskeyInvalid)
+	}
+	if ad.Flags&authenticatorDataUserPresent == 0 {
+		return fmt.Errorf("%w: user not present", ErrPasskeyInvalid)
+	}
+	// Passkeys replace both factors, so the authenticator must verify the user with a biometric or PIN
+	if ad.Flags&authenticatorDataUserVerified == 0 {
+		return fmt.Errorf("%w: user not verified", ErrPasskeyInvalid)
+	}
+	return nil
+}
+
+// parsePasskeyPublicKey parses a COSE encoded ES256 or EdDSA public key.
+func parsePasskeyPublicKey(key []byte) (crypto.PublicKey, error) {
+	item, _, err := decodeCBOR(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: public key: %s", ErrPasskeyInvalid, err)
+	}
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: public key", ErrPasskeyInvalid)
+	}
+	alg, _ := m[int64(3)].(int64)
+	crv, _ := m[int64(-1)].(int64)
+	x, _ := m[int64(-2)].([]byte)
+	switch alg {
+	case COSE_ALGORITHM_ES256:
+		y, _ := m[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("%w: public key", ErrPasskeyInvalid)
+		}
+		pub := &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return nil, fmt.Errorf("%w: public key", ErrPasskeyInvalid)
+		}
+		return pub, nil
+	case COSE_ALGORITHM_EDDSA:
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("%w: public key", ErrPasskeyInvalid)
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("%w: algorithm %d", ErrPasskeyUnsupported, alg)
+	}
+}
+
+// verifyPasskeySignature verifies the signature of the data with the COSE encoded public key.
+func verifyPasskeySignature(key, data, signature []byte) error {
+	pub, err := parsePasskeyPublicKey(key)
+	if err != nil {
+		return err
+	}
+	var valid bool
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		valid = ecdsa.VerifyASN1(pub, digest[:], signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(pub, data, signature)
+	}
+	if !valid {
+		return fmt.Errorf("%w: signature", ErrPasskeyInvalid)
+	}
+	return nil
+}
+
+func parsePasskeyCredential(response []byte) (*PasskeyCredential, []byte, []byte, error) {
+	var credential PasskeyCredential
+	if err := json.Unmarshal(response, &credential); err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %s", ErrPasskeyInvalid, err)
+	}
+	if credential.Type != "public-key" {
+		return nil, nil, nil, fmt.Errorf("%w: type %s", ErrPasskeyInvalid, credential.Type)
+	}
+	id, err := decodePasskeyBase64(credential.RawID)
+	if err != nil || len(id) == 0 || len(id) > MAXIMUM_PASSKEY_ID_LENGTH {
+		return nil, nil, nil, fmt.Errorf("%w: credential ID", ErrPasskeyInvalid)
+	}
+	cd, err := decodePasskeyBase64(credential.Response.ClientDataJSON)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: client data", ErrPasskeyInvalid)
+	}
+	return &credential, id, cd, nil
+}
+
+func ValidatePasskeyName(name string) error {
+	if len(name) > MAXIMUM_PASSKEY_NAME_LENGTH {
+		return ErrPasskeyNameTooLong
+	}
+	return nil
+}
+
+func (a *authenticator) RelyingParty() *RelyingParty {
+	return a.relyingParty
+}
+
+func (a *authenticator) SetRelyingParty(rp *RelyingParty) {
+	a.relyingParty = rp
+}
+
+// NewPasskeyCreationOptions returns the options for registering a passkey for the account with the given challenge.
+// The account's existing passkeys are excluded so an authenticator cannot register twice.
+func (a *authenticator) NewPasskeyCreationOptions(ctx context.Context, email, username, challenge string) (*PasskeyCreationOptions, error) {
+	rp := a.relyingParty
+	if rp == nil {
+		return nil, ErrRelyingPartyNotConfigured
+	}
+	passkeys, err := a.accounts.SelectPasskeys(ctx, username)
+	if err != nil {
+		return nil, err
+	}
+	exclude := make([]PasskeyDescriptor, len(passkeys))
+	for i, p := range passkeys {
+		exclude[i] = PasskeyDescriptor{
+			Type: "public-key",
+			ID:   p.ID,
+		}
+	}
+	return &PasskeyCreationOptions{
+		Challenge: challenge,
+		RP: PasskeyRelyingParty{
+			ID:   rp.ID,
+			Name: rp.Name,
+		},
+		User: PasskeyUser{
+			ID:          passkeyEncoding.EncodeToString(passkeyUserHandle(username)),
+			Name:        username,
+			DisplayName: email,
+		},
+		PubKeyCredParams: []PasskeyParameters{
+			{Type: "public-key", Alg: COSE_ALGORITHM_ES256},
+			{Type: "public-key", Alg: COSE_ALGORITHM_EDDSA},
+		},
+		Timeout:            PASSKEY_TIMEOUT.Milliseconds(),
+		ExcludeCredentials: exclude,
+		AuthenticatorSelection: PasskeySelectionCriteria{
+			ResidentKey:        "required",
+			RequireResidentKey: true,
+			UserVerification:   "required",
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// VerifyPasskeyCreation verifies the registration response to the challenge, and returns the new passkey without storing it.
+// Only the "none" attestation format is accepted, as the authenticator's make and model are not checked.
+func (a *authenticator) VerifyPasskeyCreation(challenge string, response []byte) (*Passkey, error) {
+	rp := a.relyingParty
+	if rp == nil {
+		return nil, ErrRelyingPartyNotConfigured
+	}
+	now := time.Now()
+	credential, id, cd, err := parsePasskeyCredential(response)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.checkClientData(cd, "webauthn.create", challenge, now); err != nil {
+		return nil, err
+	}
+	attestation, err := decodePasskeyBase64(credential.Response.AttestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation", ErrPasskeyInvalid)
+	}
+	item, _, err := decodeCBOR(attestation)
+	if err != nil {
+		return nil, fmt.Errorf("%w: attestation: %s", ErrPasskeyInvalid, err)
+	}
+	object, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: attestation", ErrPasskeyInvalid)
+	}
+	if format, _ := object["fmt"].(string); format != "none" {
+		return nil, fmt.Errorf("%w: attestation format %s", ErrPasskeyUnsupported, format)
+	}
+	if statement, ok := object["attStmt"].(map[interface{}]interface{}); !ok || len(statement) != 0 {
+		return nil, fmt.Errorf("%w: attestation statement", ErrPasskeyInvalid)
+	}
+	data, ok := object["authData"].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("%w: authenticator data", ErrPasskeyInvalid)
+	}
+	ad, err := parseAuthenticatorData(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := rp.checkAuthenticatorData(ad); err != nil {
+		return nil, err
+	}
+	if ad.PublicKey == nil || !bytes.Equal(ad.CredentialID, id) {
+		return nil, fmt.Errorf("%w: credential ID", ErrPasskeyInvalid)
+	}
+	// Check the key is usable now rather than at every sign in
+	if _, err := parsePasskeyPublicKey(ad.PublicKey); err != nil {
+		return nil, err
+	}
+	return &Passkey{
+		ID:        passkeyEncoding.EncodeToString(id),
+		Name:      DEFAULT_PASSKEY_NAME,
+		PublicKey: ad.PublicKey,
+		SignCount: int64(ad.SignCount),
+		Created:   now,
+	}, nil
+}
+
+// AddPasskey registers another passkey for the account, and notifies the account as the passkey can sign in without the password.
+func (a *authenticator) AddPasskey(ctx context.Context, username string, passkey *Passkey) error {
+	if err := a.addPasskey(ctx, username, passkey); err != nil {
+		return err
+	}
+	a.notify(ctx, username, fmt.Sprintf("A passkey named %q was added to your account. If this was not you, delete it and change your password.", passkey.Name))
+	return nil
+}
+
+func (a *authenticator) addPasskey(ctx context.Context, username string, passkey *Passkey) error {
+	if err := ValidatePasskeyName(passkey.Name); err != nil {
+		return err
+	}
+	id, err := a.accounts.CreatePasskey(ctx, username, passkey)
+	if err != nil {
+		return err
+	}
+	log.Println("Created Passkey", id, "for", username)
+	return nil
+}
+
+// NewPasskeyAccount creates an account that signs in with the passkey instead of a password.
+// The account is given a random password, which can later be replaced through account recovery.
+func (a *authenticator) NewPasskeyAccount(ctx context.Context, email, username string, passkey *Passkey) (*Account, error) {
+	// Check the passkey can be added before creating the account, so a rejected passkey does not leave an account that cannot be signed in to
+	if err := ValidatePasskeyName(passkey.Name); err != nil {
+		return nil, err
+	}
+	if _, _, err := a.accounts.SelectPasskey(ctx, passkey.ID); err == nil {
+		return nil, ErrPasskeyAlreadyRegistered
+	} else if !errors.Is(err, ErrPasskeyNotRecognized) {
+		return nil, err
+	}
+	password := make([]byte, 32)
+	if _, err := rand.Read(password); err != nil {
+		return nil, err
+	}
+	account, err := a.NewAccount(ctx, email, username, []byte(passkeyEncoding.EncodeToString(password)))
+	if err != nil {
+		return nil, err
+	}
+	if err := a.addPasskey(ctx, username, passkey); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// NewPasskeyRequestOptions returns the options for signing in with any passkey discoverable by the authenticator.
+func (a *authenticator) NewPasskeyRequestOptions(challenge string) (*PasskeyRequestOptions, error) {
+	rp := a.relyingParty
+	if rp == nil {
+		return nil, ErrRelyingPartyNotConfigured
+	}
+	return &PasskeyRequestOptions{
+		Challenge:        challenge,
+		RPID:             rp.ID,
+		Timeout:          PASSKEY_TIMEOUT.Milliseconds(),
+		AllowCredentials: []PasskeyDescriptor{},
+		UserVerification: "required",
+	}, nil
+}
+
+// AuthenticatePasskey verifies the assertion response to the challenge and returns the passkey's account.
+// Failures count towards the lockout policy.
+func (a *authenticator) AuthenticatePasskey(ctx context.Context, challenge string, response []byte) (*Account, error) {
+	rp := a.relyingParty
+	if rp == nil {
+		return nil, ErrRelyingPartyNotConfigured
+	}
+	now := time.Now()
+	if err := a.checkLockout(ctx, a.clientLockoutSubjects(ctx), now); err != nil {
+		return nil, err
+	}
+	credential, id, cd, err := parsePasskeyCredential(response)
+	if err != nil {
+		return nil, err
+	}
+	username, passkey, err := a.accounts.SelectPasskey(ctx, passkeyEncoding.EncodeToString(id))
+	if err != nil {
+		log.Println(err)
+		if ctx.Err() == nil {
+			a.recordFailure(ctx, a.clientLockoutSubjects(ctx), now)
+		}
+		return nil, ErrPasskeyNotRecognized
+	}
+	subjects := a.lockoutSubjects(ctx, username)
+	if err := a.checkLockout(ctx, subjects, now); err != nil {
+		return nil, err
+	}
+	signCount, err := a.verifyPasskeyAssertion(rp, username, passkey, credential, cd, challenge, now)
+	if err != nil {
+		a.recordFailure(ctx, subjects, now)
+		return nil, err
+	}
+	if (signCount != 0 || passkey.SignCount != 0) && signCount <= passkey.SignCount {
+		// A counter that does not increase suggests two authenticators hold the same private key
+		log.Println("Passkey", passkey.ID, "for", username, "sign count", signCount, "not greater than", passkey.SignCount)
+		a.recordFailure(ctx, subjects, now)
+		return nil, ErrPasskeyCloned
+	}
+	if _, err := a.accounts.UpdatePasskeySignCount(ctx, passkey.ID, signCount, now); err != nil {
+		return nil, err
+	}
+	if _, err := a.accounts.DeleteAuthenticationFailures(ctx, accountSubject(username)); err != nil {
+		log.Println(err)
+	}
+	return a.LookupAccount(ctx, username)
+}
+
+func (a *authenticator) verifyPasskeyAssertion(rp *RelyingParty, username string, passkey *Passkey, credential *PasskeyCredential, cd []byte, challenge string, now time.Time) (int64, error) {
+	if err := rp.checkClientData(cd, "webauthn.get", challenge, now); err != nil {
+		return 0, err
+	}
+	if credential.Response.UserHandle != "" {
+		handle, err := decodePasskeyBase64(credential.Response.UserHandle)
+		if err != nil || !bytes.Equal(handle, passkeyUserHandle(username)) {
+			return 0, fmt.Errorf("%w: user handle", ErrPasskeyInvalid)
+		}
+	}
+	data, err := decodePasskeyBase64(credential.Response.AuthenticatorData)
+	if err != nil {
+		return 0, fmt.Errorf("%w: authenticator data", ErrPasskeyInvalid)
+	}
+	ad, err := parseAuthenticatorData(data)
+	if err != nil {
+		return 0, err
+	}
+	if err := rp.checkAuthenticatorData(ad); err != nil {
+		return 0, err
+	}
+	signature, err := decodePasskeyBase64(credential.Response.Signature)
+	if err != nil {
+		return 0, fmt.Errorf("%w: signature", ErrPasskeyInvalid)
+	}
+	hash := sha256.Sum256(cd)
+	if err := verifyPasskeySignature(passkey.PublicKey, append(data, hash[:]...), signature); err != nil {
+		return 0, err
+	}
+	return int64(ad.SignCount), nil
+}
+
+func (a *authenticator) LookupPasskeys(ctx context.Context, username string) ([]*Passkey, error) {
+	return a.accounts.SelectPasskeys(ctx, username)
+}
+
+func (a *authenticator) DeletePasskey(ctx context.Context, username, id string) error {
+	if _, err := a.accounts.DeletePasskey(ctx, username, id); err != nil {
+		return err
+	}
+	log.Println("Deleted Passkey", id, "for", username)
+	a.notify(ctx, username, "A passkey was deleted from your account.")
+	return nil
+}